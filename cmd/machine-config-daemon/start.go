@@ -41,6 +41,7 @@ var (
 		kubeletHealthzEnabled      bool
 		kubeletHealthzEndpoint     string
 		promMetricsURL             string
+		dryRun                     bool
 	}
 )
 
@@ -55,6 +56,7 @@ func init() {
 	startCmd.PersistentFlags().BoolVar(&startOpts.kubeletHealthzEnabled, "kubelet-healthz-enabled", true, "kubelet healthz endpoint monitoring")
 	startCmd.PersistentFlags().StringVar(&startOpts.kubeletHealthzEndpoint, "kubelet-healthz-endpoint", "http://localhost:10248/healthz", "healthz endpoint to check health")
 	startCmd.PersistentFlags().StringVar(&startOpts.promMetricsURL, "metrics-url", "127.0.0.1:8797", "URL for prometheus metrics listener")
+	startCmd.PersistentFlags().BoolVar(&startOpts.dryRun, "dry-run", false, "Records the plan for each update in the MachineConfigNode status and an event instead of applying it")
 }
 
 //nolint:gocritic
@@ -189,6 +191,7 @@ func runStartCmd(_ *cobra.Command, _ []string) {
 		ctrlctx.ClientBuilder.OperatorClientOrDie(componentName),
 		startOpts.kubeletHealthzEnabled,
 		startOpts.kubeletHealthzEndpoint,
+		startOpts.dryRun,
 		ctrlctx.FeatureGateAccess,
 	)
 	if err != nil {
@@ -22,6 +22,7 @@ var (
 		serverBaseDir    string
 		serverKubeConfig string
 		certificates     []string
+		tokenDir         string
 	}
 )
 
@@ -30,6 +31,7 @@ func init() {
 	bootstrapCmd.PersistentFlags().StringVar(&bootstrapOpts.serverBaseDir, "server-basedir", "/etc/mcs/bootstrap", "base directory on the host, relative to which machine-configs and pools can be found.")
 	bootstrapCmd.PersistentFlags().StringVar(&bootstrapOpts.serverKubeConfig, "bootstrap-kubeconfig", "/etc/kubernetes/kubeconfig", "path to bootstrap kubeconfig served by the bootstrap server.")
 	bootstrapCmd.PersistentFlags().StringArrayVar(&bootstrapOpts.certificates, "bootstrap-certs", []string{}, "a certificate bundle formatted in a string array with the format key=value,key=value")
+	bootstrapCmd.PersistentFlags().StringVar(&bootstrapOpts.tokenDir, "bootstrap-token-dir", "", "directory of bootstrap token Secret manifests used to authenticate config requests")
 }
 
 func runBootstrapCmd(_ *cobra.Command, _ []string) {
@@ -59,11 +61,22 @@ func runBootstrapCmd(_ *cobra.Command, _ []string) {
 	klog.Infof("Launching bootstrap server with tls min version: %v & cipher suites %v", tlsminversion, tlsciphersuites)
 	tlsConfig := ctrlcommon.GetGoTLSConfig(tlsminversion, tlsciphersuites)
 
-	apiHandler := server.NewServerAPIHandler(bs)
-	secureServer := server.NewAPIServer(apiHandler, rootOpts.sport, false, rootOpts.cert, rootOpts.key, tlsConfig)
-	insecureServer := server.NewAPIServer(apiHandler, rootOpts.isport, true, "", "", tlsConfig)
+	auth := &server.AuthOptions{ClientCAFile: rootOpts.clientCA}
+	if bootstrapOpts.tokenDir != "" {
+		auth.TokenAuthenticator, err = server.NewFileTokenAuthenticator(bootstrapOpts.tokenDir)
+		if err != nil {
+			klog.Exitf("Machine Config Server exited with error: %v", err)
+		}
+	}
+
+	apiHandler := newAPIHandler(bs)
+	secureServer := server.NewAPIServer(apiHandler, rootOpts.sport, false, rootOpts.cert, rootOpts.key, tlsConfig, auth)
+	insecureServer := server.NewAPIServer(apiHandler, rootOpts.isport, true, "", "", tlsConfig, auth)
 
 	stopCh := make(chan struct{})
+	if rootOpts.metricsAddress != "" {
+		go ctrlcommon.StartMetricsListener(rootOpts.metricsAddress, stopCh, server.RegisterMCSMetrics)
+	}
 	go secureServer.Serve()
 	go insecureServer.Serve()
 	<-stopCh
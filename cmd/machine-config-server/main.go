@@ -26,8 +26,12 @@ var (
 		isport          int
 		cert            string
 		key             string
+		clientCA        string
 		tlsciphersuites []string
 		tlsminversion   string
+		rateLimit       float64
+		rateLimitBurst  int
+		metricsAddress  string
 	}
 )
 
@@ -36,12 +40,24 @@ func init() {
 	rootCmd.PersistentFlags().IntVar(&rootOpts.sport, "secure-port", server.SecurePort, "secure port to serve ignition configs")
 	rootCmd.PersistentFlags().StringVar(&rootOpts.cert, "cert", "/etc/ssl/mcs/tls.crt", "cert file for TLS")
 	rootCmd.PersistentFlags().StringVar(&rootOpts.key, "key", "/etc/ssl/mcs/tls.key", "key file for TLS")
+	rootCmd.PersistentFlags().StringVar(&rootOpts.clientCA, "client-ca", "", "CA bundle used to authenticate client certificates; enables authentication of config requests")
 	rootCmd.PersistentFlags().StringSliceVar(&rootOpts.tlsciphersuites, "tls-cipher-suites", nil, "ciphers suites for TLS")
 	rootCmd.PersistentFlags().StringVar(&rootOpts.tlsminversion, "tls-min-version", "VersionTLS12", "min version for TLS")
 	rootCmd.PersistentFlags().IntVar(&rootOpts.isport, "insecure-port", server.InsecurePort, "insecure port to serve ignition configs")
+	rootCmd.PersistentFlags().Float64Var(&rootOpts.rateLimit, "rate-limit", 0, "maximum sustained config requests per second from a single source address; 0 disables rate limiting")
+	rootCmd.PersistentFlags().IntVar(&rootOpts.rateLimitBurst, "rate-limit-burst", 10, "number of config requests a single source address may burst above --rate-limit")
+	rootCmd.PersistentFlags().StringVar(&rootOpts.metricsAddress, "metrics-listen-address", "", "address to serve Prometheus metrics on; metrics are not served if empty")
 	rootCmd.PersistentFlags().StringVar(&version.ReleaseVersion, "payload-version", version.ReleaseVersion, "Version of the openshift release")
 }
 
+// newAPIHandler returns the API handler for s, rate limited if requested.
+func newAPIHandler(s server.Server) *server.APIHandler {
+	if rootOpts.rateLimit > 0 {
+		return server.NewServerAPIHandlerWithRateLimit(s, rootOpts.rateLimit, rootOpts.rateLimitBurst)
+	}
+	return server.NewServerAPIHandler(s)
+}
+
 func main() {
 	code := cli.Run(rootCmd)
 	os.Exit(code)
@@ -20,8 +20,9 @@ var (
 	}
 
 	startOpts struct {
-		kubeconfig   string
-		apiserverURL string
+		kubeconfig         string
+		apiserverURL       string
+		bootstrapTokenAuth bool
 	}
 )
 
@@ -29,6 +30,7 @@ func init() {
 	rootCmd.AddCommand(startCmd)
 	startCmd.PersistentFlags().StringVar(&startOpts.kubeconfig, "kubeconfig", "", "Kubeconfig file to access a remote cluster (testing only)")
 	startCmd.PersistentFlags().StringVar(&startOpts.apiserverURL, "apiserver-url", "", "URL for apiserver; Used to generate kubeconfig")
+	startCmd.PersistentFlags().BoolVar(&startOpts.bootstrapTokenAuth, "bootstrap-token-auth", false, "authenticate config requests with bootstrap tokens from the kube-system namespace")
 
 }
 
@@ -51,11 +53,23 @@ func runStartCmd(_ *cobra.Command, _ []string) {
 	klog.Infof("Launching server with tls min version: %v & cipher suites %v", rootOpts.tlsminversion, rootOpts.tlsciphersuites)
 	tlsConfig := ctrlcommon.GetGoTLSConfig(rootOpts.tlsminversion, rootOpts.tlsciphersuites)
 
-	apiHandler := server.NewServerAPIHandler(cs)
-	secureServer := server.NewAPIServer(apiHandler, rootOpts.sport, false, rootOpts.cert, rootOpts.key, tlsConfig)
-	insecureServer := server.NewAPIServer(apiHandler, rootOpts.isport, true, "", "", tlsConfig)
-
 	stopCh := make(chan struct{})
+
+	auth := &server.AuthOptions{ClientCAFile: rootOpts.clientCA}
+	if startOpts.bootstrapTokenAuth {
+		auth.TokenAuthenticator, err = server.NewSecretTokenAuthenticator(startOpts.kubeconfig, stopCh)
+		if err != nil {
+			ctrlcommon.WriteTerminationError(err)
+		}
+	}
+
+	apiHandler := newAPIHandler(cs)
+	secureServer := server.NewAPIServer(apiHandler, rootOpts.sport, false, rootOpts.cert, rootOpts.key, tlsConfig, auth)
+	insecureServer := server.NewAPIServer(apiHandler, rootOpts.isport, true, "", "", tlsConfig, auth)
+
+	if rootOpts.metricsAddress != "" {
+		go ctrlcommon.StartMetricsListener(rootOpts.metricsAddress, stopCh, server.RegisterMCSMetrics)
+	}
 	go secureServer.Serve()
 	go insecureServer.Serve()
 	<-stopCh
@@ -61,7 +61,7 @@ func getBuildControllers(ctx context.Context, cb *clients.Builder) ([]*build.Con
 	podRequestExisted := 0
 	for _, mosc := range machineOSConfigs.Items {
 		if mosc.Spec.BuildInputs.ImageBuilder.ImageBuilderType == mcfgv1alpha1.MachineOSImageBuilderType("PodImageBuilder") && podRequestExisted == 0 {
-			controllersToStart = append(controllersToStart, build.NewWithCustomPodBuilder(cfg, buildClients))
+			controllersToStart = append(controllersToStart, build.New(cfg, buildClients))
 			podRequestExisted++
 		}
 	}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+)
+
+var (
+	diffCmd = &cobra.Command{
+		Use:   "diff --current <rendered-machineconfig> <machineconfig file or dir>...",
+		Short: "Show what a set of MachineConfigs would do to the nodes of a pool",
+		Long: `Merges the given MachineConfigs the same way the render controller does and compares
+the result against the pool's current rendered MachineConfig. The candidate MachineConfigs
+must be the complete set of MachineConfigs selected by the pool, including the ones generated
+by the MCO. A MachineConfig overrides the ones with the same name given before it, so the
+changed MachineConfigs can be given after a dump of the cluster's MachineConfigs. No cluster
+connection is needed; the node disruption policies and the ControllerConfig can optionally be
+read from files.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runDiffCmd,
+	}
+
+	diffOpts struct {
+		current              string
+		controllerConfig     string
+		machineConfiguration string
+		legacyActions        bool
+		output               string
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().StringVar(&diffOpts.current, "current", "", "File containing the pool's current rendered MachineConfig")
+	diffCmd.Flags().StringVar(&diffOpts.controllerConfig, "controller-config", "", "File containing the cluster's ControllerConfig. Defaults to one derived from the current rendered MachineConfig")
+	diffCmd.Flags().StringVar(&diffOpts.machineConfiguration, "machine-configuration", "", "File containing the cluster's MachineConfiguration, whose node disruption policies are merged with the defaults")
+	diffCmd.Flags().BoolVar(&diffOpts.legacyActions, "legacy-actions", false, "Calculate post config change actions as done when the NodeDisruptionPolicy feature is disabled")
+	diffCmd.Flags().StringVarP(&diffOpts.output, "output", "o", "text", "Output format, one of: text, json")
+	diffCmd.MarkFlagRequired("current")
+}
+
+func runDiffCmd(_ *cobra.Command, args []string) error {
+	if diffOpts.output != "text" && diffOpts.output != "json" {
+		return fmt.Errorf("unknown output format %q", diffOpts.output)
+	}
+
+	objs, err := readObjects(diffOpts.current)
+	if err != nil {
+		return err
+	}
+	currentConfigs := filterMachineConfigs(objs)
+	if len(currentConfigs) != 1 {
+		return fmt.Errorf("expected exactly one MachineConfig in %s, found %d", diffOpts.current, len(currentConfigs))
+	}
+	current := currentConfigs[0]
+
+	candidates := []*mcfgv1.MachineConfig{}
+	for _, path := range args {
+		objs, err := readObjects(path)
+		if err != nil {
+			return err
+		}
+		candidates = append(candidates, filterMachineConfigs(objs)...)
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("no MachineConfigs found in %v", args)
+	}
+
+	cconfig, err := getControllerConfig(current)
+	if err != nil {
+		return err
+	}
+
+	var clusterPolicies *opv1.NodeDisruptionPolicyClusterStatus
+	if !diffOpts.legacyActions {
+		clusterPolicies, err = getClusterPolicies()
+		if err != nil {
+			return err
+		}
+	}
+
+	candidate, err := mergeCandidates(current, candidates, cconfig)
+	if err != nil {
+		return err
+	}
+
+	plan, err := daemon.NewUpdatePlan(current, candidate, clusterPolicies)
+	if err != nil {
+		return err
+	}
+
+	if diffOpts.output == "json" {
+		return printPlanJSON(os.Stdout, plan)
+	}
+	printPlan(os.Stdout, plan)
+	return nil
+}
+
+// mergeCandidates validates and merges the candidate MachineConfigs into a
+// rendered MachineConfig, as the render controller would. A candidate
+// replaces the earlier candidates with the same name.
+func mergeCandidates(current *mcfgv1.MachineConfig, candidates []*mcfgv1.MachineConfig, cconfig *mcfgv1.ControllerConfig) (*mcfgv1.MachineConfig, error) {
+	configs := []*mcfgv1.MachineConfig{}
+	indexes := map[string]int{}
+	for _, config := range candidates {
+		if err := ctrlcommon.ValidateMachineConfig(config.Spec); err != nil {
+			return nil, fmt.Errorf("invalid MachineConfig %s: %w", config.Name, err)
+		}
+		if i, ok := indexes[config.Name]; ok {
+			klog.V(2).Infof("MachineConfig %s overrides an earlier one with the same name", config.Name)
+			configs[i] = config
+			continue
+		}
+		indexes[config.Name] = len(configs)
+		configs = append(configs, config)
+	}
+
+	merged, err := ctrlcommon.MergeMachineConfigs(configs, cconfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not merge MachineConfigs: %w", err)
+	}
+
+	pool := "candidate"
+	if controllerRef := metav1.GetControllerOf(current); controllerRef != nil {
+		pool = controllerRef.Name
+	}
+	merged.SetName(fmt.Sprintf("rendered-%s-candidate", pool))
+
+	return merged, nil
+}
+
+// getControllerConfig reads the ControllerConfig from the file given on the
+// command line. Without one, only the base OS images are needed to merge
+// MachineConfigs, and those are taken from the current rendered MachineConfig.
+func getControllerConfig(current *mcfgv1.MachineConfig) (*mcfgv1.ControllerConfig, error) {
+	if diffOpts.controllerConfig == "" {
+		return &mcfgv1.ControllerConfig{
+			Spec: mcfgv1.ControllerConfigSpec{
+				BaseOSContainerImage:           current.Spec.OSImageURL,
+				BaseOSExtensionsContainerImage: current.Spec.BaseOSExtensionsContainerImage,
+			},
+		}, nil
+	}
+
+	objs, err := readObjects(diffOpts.controllerConfig)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objs {
+		if cconfig, ok := obj.(*mcfgv1.ControllerConfig); ok {
+			return cconfig, nil
+		}
+	}
+	return nil, fmt.Errorf("no ControllerConfig found in %s", diffOpts.controllerConfig)
+}
+
+// getClusterPolicies returns the node disruption policies in effect, which
+// are the defaults merged with the user defined ones, if any were given.
+func getClusterPolicies() (*opv1.NodeDisruptionPolicyClusterStatus, error) {
+	userPolicies := opv1.NodeDisruptionPolicyConfig{}
+	builtinActions := false
+	if diffOpts.machineConfiguration != "" {
+		objs, err := readObjects(diffOpts.machineConfiguration)
+		if err != nil {
+			return nil, err
+		}
+		found := false
+		for _, obj := range objs {
+			if mcop, ok := obj.(*opv1.MachineConfiguration); ok {
+				userPolicies = mcop.Spec.NodeDisruptionPolicy
+				builtinActions = mcop.Annotations[daemonconsts.BuiltinNodeDisruptionActionsAnnotationKey] == "true"
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no MachineConfiguration found in %s", diffOpts.machineConfiguration)
+		}
+	}
+
+	clusterPolicies := apihelpers.MergeClusterPolicies(userPolicies, builtinActions)
+	return &clusterPolicies, nil
+}
+
+func filterMachineConfigs(objs []runtime.Object) []*mcfgv1.MachineConfig {
+	configs := []*mcfgv1.MachineConfig{}
+	for _, obj := range objs {
+		if config, ok := obj.(*mcfgv1.MachineConfig); ok {
+			configs = append(configs, config)
+		}
+	}
+	return configs
+}
+
+// readObjects decodes all of the objects in the given file, or in the YAML
+// and JSON files of the given directory. Multi-document files and Lists, such
+// as the output of "oc get -o yaml", are supported.
+func readObjects(path string) ([]runtime.Object, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		files = []string{}
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+	}
+
+	decoder := newDecoder()
+	objs := []runtime.Object{}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		fileObjs, err := decodeObjects(decoder, f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", file, err)
+		}
+		objs = append(objs, fileObjs...)
+	}
+	return objs, nil
+}
+
+// newDecoder returns a decoder for the MachineConfig and operator API objects
+// read by mcoctl.
+func newDecoder() runtime.Decoder {
+	scheme := runtime.NewScheme()
+	mcfgv1.Install(scheme)
+	opv1.Install(scheme)
+	return serializer.NewCodecFactory(scheme).UniversalDecoder(mcfgv1.GroupVersion, opv1.GroupVersion)
+}
+
+func decodeObjects(decoder runtime.Decoder, r io.Reader) ([]runtime.Object, error) {
+	d := yamlutil.NewYAMLOrJSONDecoder(r, 1024)
+	objs := []runtime.Object{}
+	for {
+		raw := runtime.RawExtension{}
+		if err := d.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return objs, nil
+			}
+			return nil, err
+		}
+		if len(raw.Raw) == 0 || string(raw.Raw) == "null" {
+			continue
+		}
+
+		decoded, err := decodeObject(decoder, raw.Raw)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, decoded...)
+	}
+}
+
+func decodeObject(decoder runtime.Decoder, raw []byte) ([]runtime.Object, error) {
+	typeMeta := metav1.TypeMeta{}
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(typeMeta.Kind, "List") {
+		list := struct {
+			Items []runtime.RawExtension `json:"items"`
+		}{}
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+		objs := []runtime.Object{}
+		for _, item := range list.Items {
+			decoded, err := decodeObject(decoder, item.Raw)
+			if err != nil {
+				return nil, err
+			}
+			objs = append(objs, decoded...)
+		}
+		return objs, nil
+	}
+
+	obj, err := runtime.Decode(decoder, raw)
+	if err != nil {
+		if !runtime.IsNotRegisteredError(err) {
+			return nil, err
+		}
+		// All of the kinds of the API versions read here are registered, so
+		// an unknown one is most likely a typo which would otherwise silently
+		// drop the object from the diff.
+		switch typeMeta.APIVersion {
+		case mcfgv1.GroupVersion.String(), opv1.GroupVersion.String():
+			return nil, fmt.Errorf("unknown kind %q in %s", typeMeta.Kind, typeMeta.APIVersion)
+		}
+		klog.V(4).Infof("skipping %s object: %v", typeMeta.Kind, err)
+		return nil, nil
+	}
+	return []runtime.Object{obj}, nil
+}
+
+func printPlanJSON(w io.Writer, plan *daemon.UpdatePlan) error {
+	out, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
+
+func printPlan(w io.Writer, plan *daemon.UpdatePlan) {
+	fmt.Fprintf(w, "Current config:   %s\n", plan.OldConfig)
+	fmt.Fprintf(w, "Candidate config: %s\n", plan.NewConfig)
+
+	if plan.OSImageURL != "" {
+		fmt.Fprintf(w, "\nOS image: %s\n", plan.OSImageURL)
+	}
+	if plan.KernelType != "" {
+		fmt.Fprintf(w, "\nKernel type: %s\n", plan.KernelType)
+	}
+	if plan.FIPS {
+		fmt.Fprintf(w, "\nFIPS: changed\n")
+	}
+	if plan.Passwd {
+		fmt.Fprintf(w, "\nUsers/SSH keys: changed\n")
+	}
+	printList(w, "Files", plan.Files)
+	printList(w, "Units", plan.Units)
+	printAddedRemoved(w, "Kernel arguments", plan.KernelArgumentsAdded, plan.KernelArgumentsRemoved)
+	printAddedRemoved(w, "Extensions", plan.ExtensionsAdded, plan.ExtensionsRemoved)
+
+	fmt.Fprintf(w, "\nActions:\n")
+	for _, action := range plan.Actions() {
+		fmt.Fprintf(w, "  %s\n", action)
+	}
+	fmt.Fprintf(w, "Drain required: %t\n", plan.Drain)
+}
+
+func printAddedRemoved(w io.Writer, title string, added, removed []string) {
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s:\n", title)
+	for _, item := range added {
+		fmt.Fprintf(w, "  + %s\n", item)
+	}
+	for _, item := range removed {
+		fmt.Fprintf(w, "  - %s\n", item)
+	}
+}
+
+func printList(w io.Writer, title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s:\n", title)
+	for _, item := range items {
+		fmt.Fprintf(w, "  %s\n", item)
+	}
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+const (
+	workerMachineConfigYAML = `apiVersion: machineconfiguration.openshift.io/v1
+kind: MachineConfig
+metadata:
+  name: 00-worker
+spec:
+  config:
+    ignition:
+      version: 3.4.0
+`
+
+	kargsMachineConfigYAML = `apiVersion: machineconfiguration.openshift.io/v1
+kind: MachineConfig
+metadata:
+  name: 99-worker-kargs
+spec:
+  kernelArguments:
+  - nosmt
+`
+
+	controllerConfigYAML = `apiVersion: machineconfiguration.openshift.io/v1
+kind: ControllerConfig
+metadata:
+  name: machine-config-controller
+spec:
+  baseOSContainerImage: quay.io/openshift/rhel-coreos@sha256:abc
+  baseOSExtensionsContainerImage: quay.io/openshift/rhel-coreos-extensions@sha256:def
+`
+
+	machineConfigurationYAML = `apiVersion: operator.openshift.io/v1
+kind: MachineConfiguration
+metadata:
+  name: cluster
+spec:
+  nodeDisruptionPolicy:
+    files:
+    - path: /etc/foo.conf
+      actions:
+      - type: Restart
+        restart:
+          serviceName: foo.service
+`
+
+	configMapYAML = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+data:
+  foo: bar
+`
+)
+
+// writeFile writes contents to a file named name in dir and returns its path.
+func writeFile(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+	return path
+}
+
+// objectNames returns the kind and name of each of objs.
+func objectNames(t *testing.T, objs []runtime.Object) []string {
+	t.Helper()
+	names := []string{}
+	for _, obj := range objs {
+		accessor, err := meta.Accessor(obj)
+		require.NoError(t, err)
+		names = append(names, reflect.TypeOf(obj).Elem().Name()+"/"+accessor.GetName())
+	}
+	return names
+}
+
+func TestDecodeObjects(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		input         string
+		expected      []string
+		expectedError string
+	}{
+		{
+			name:     "single document",
+			input:    workerMachineConfigYAML,
+			expected: []string{"MachineConfig/00-worker"},
+		},
+		{
+			name:     "multi-document YAML",
+			input:    "---\n" + workerMachineConfigYAML + "---\n" + controllerConfigYAML + "---\n" + machineConfigurationYAML + "---\n",
+			expected: []string{"MachineConfig/00-worker", "ControllerConfig/machine-config-controller", "MachineConfiguration/cluster"},
+		},
+		{
+			name: "v1 List",
+			input: `apiVersion: v1
+kind: List
+items:
+- apiVersion: machineconfiguration.openshift.io/v1
+  kind: MachineConfig
+  metadata:
+    name: 00-worker
+- apiVersion: machineconfiguration.openshift.io/v1
+  kind: MachineConfig
+  metadata:
+    name: 99-worker-kargs
+`,
+			expected: []string{"MachineConfig/00-worker", "MachineConfig/99-worker-kargs"},
+		},
+		{
+			name:     "typed List in JSON",
+			input:    `{"apiVersion": "machineconfiguration.openshift.io/v1", "kind": "MachineConfigList", "items": [{"apiVersion": "machineconfiguration.openshift.io/v1", "kind": "MachineConfig", "metadata": {"name": "00-worker"}}]}`,
+			expected: []string{"MachineConfig/00-worker"},
+		},
+		{
+			name:     "kinds of other API groups are skipped",
+			input:    configMapYAML + "---\n" + workerMachineConfigYAML,
+			expected: []string{"MachineConfig/00-worker"},
+		},
+		{
+			name:          "unknown MachineConfig kind",
+			input:         strings.Replace(workerMachineConfigYAML, "kind: MachineConfig", "kind: MachineConfg", 1),
+			expectedError: `unknown kind "MachineConfg" in machineconfiguration.openshift.io/v1`,
+		},
+		{
+			name:          "unknown operator kind",
+			input:         strings.Replace(machineConfigurationYAML, "kind: MachineConfiguration", "kind: MachineConfigurations", 1),
+			expectedError: `unknown kind "MachineConfigurations" in operator.openshift.io/v1`,
+		},
+		{
+			name:          "unknown kind in a List",
+			input:         `{"apiVersion": "v1", "kind": "List", "items": [{"apiVersion": "machineconfiguration.openshift.io/v1", "kind": "MachineConfg", "metadata": {"name": "00-worker"}}]}`,
+			expectedError: `unknown kind "MachineConfg"`,
+		},
+		{
+			name:          "missing kind",
+			input:         "apiVersion: machineconfiguration.openshift.io/v1\nmetadata:\n  name: 00-worker\n",
+			expectedError: "Object 'Kind' is missing",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			objs, err := decodeObjects(newDecoder(), strings.NewReader(test.input))
+			if test.expectedError != "" {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, objectNames(t, objs))
+		})
+	}
+}
+
+func TestReadObjects(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFile(t, dir, "00-worker.yaml", workerMachineConfigYAML)
+	writeFile(t, dir, "99-worker-kargs.yml", kargsMachineConfigYAML)
+	writeFile(t, dir, "controllerconfig.json", `{"apiVersion": "machineconfiguration.openshift.io/v1", "kind": "ControllerConfig", "metadata": {"name": "machine-config-controller"}}`)
+	writeFile(t, dir, "README.md", "not a manifest")
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "nested.yaml"), 0o755))
+	writeFile(t, filepath.Join(dir, "nested.yaml"), "00-master.yaml", workerMachineConfigYAML)
+
+	invalidDir := t.TempDir()
+	invalid := writeFile(t, invalidDir, "invalid.yaml", strings.Replace(kargsMachineConfigYAML, "kind: MachineConfig", "kind: MachineConfg", 1))
+
+	tests := []struct {
+		name          string
+		path          string
+		expected      []string
+		expectedError string
+	}{
+		{
+			name:     "file",
+			path:     filepath.Join(dir, "00-worker.yaml"),
+			expected: []string{"MachineConfig/00-worker"},
+		},
+		{
+			name:     "directory",
+			path:     dir,
+			expected: []string{"MachineConfig/00-worker", "MachineConfig/99-worker-kargs", "ControllerConfig/machine-config-controller"},
+		},
+		{
+			name:          "missing file",
+			path:          filepath.Join(dir, "missing.yaml"),
+			expectedError: "no such file or directory",
+		},
+		{
+			name:          "invalid file in directory",
+			path:          invalidDir,
+			expectedError: "error parsing " + invalid,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			objs, err := readObjects(test.path)
+			if test.expectedError != "" {
+				assert.ErrorContains(t, err, test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, objectNames(t, objs))
+		})
+	}
+}
+
+// Tests which set diffOpts do not run in parallel.
+
+func TestGetControllerConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	current := helpers.NewMachineConfig("rendered-worker-1", nil, "quay.io/openshift/rhel-coreos@sha256:current", nil)
+	current.Spec.BaseOSExtensionsContainerImage = "quay.io/openshift/rhel-coreos-extensions@sha256:current"
+
+	tests := []struct {
+		name                 string
+		controllerConfig     string
+		expectedOSImage      string
+		expectedExtensionsOS string
+		expectedError        string
+	}{
+		{
+			name:                 "derived from the current config",
+			expectedOSImage:      "quay.io/openshift/rhel-coreos@sha256:current",
+			expectedExtensionsOS: "quay.io/openshift/rhel-coreos-extensions@sha256:current",
+		},
+		{
+			name:                 "read from file",
+			controllerConfig:     writeFile(t, dir, "controllerconfig.yaml", workerMachineConfigYAML+"---\n"+controllerConfigYAML),
+			expectedOSImage:      "quay.io/openshift/rhel-coreos@sha256:abc",
+			expectedExtensionsOS: "quay.io/openshift/rhel-coreos-extensions@sha256:def",
+		},
+		{
+			name:             "file without a ControllerConfig",
+			controllerConfig: writeFile(t, dir, "00-worker.yaml", workerMachineConfigYAML),
+			expectedError:    "no ControllerConfig found in " + filepath.Join(dir, "00-worker.yaml"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diffOpts.controllerConfig = test.controllerConfig
+			t.Cleanup(func() { diffOpts.controllerConfig = "" })
+
+			cconfig, err := getControllerConfig(current)
+			if test.expectedError != "" {
+				assert.EqualError(t, err, test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedOSImage, cconfig.Spec.BaseOSContainerImage)
+			assert.Equal(t, test.expectedExtensionsOS, cconfig.Spec.BaseOSExtensionsContainerImage)
+		})
+	}
+}
+
+func TestGetClusterPolicies(t *testing.T) {
+	dir := t.TempDir()
+
+	builtinActions := strings.Replace(machineConfigurationYAML, "  name: cluster\n",
+		"  name: cluster\n  annotations:\n    "+daemonconsts.BuiltinNodeDisruptionActionsAnnotationKey+": \"true\"\n", 1)
+
+	tests := []struct {
+		name                 string
+		machineConfiguration string
+		expectedPaths        []string
+		unexpectedPaths      []string
+		expectedError        string
+	}{
+		{
+			name:            "defaults",
+			expectedPaths:   []string{daemonconsts.ContainerRegistryPolicyPath},
+			unexpectedPaths: []string{"/etc/foo.conf", daemonconsts.SysctlConfigDir},
+		},
+		{
+			name:                 "user defined policies",
+			machineConfiguration: writeFile(t, dir, "machineconfiguration.yaml", machineConfigurationYAML),
+			expectedPaths:        []string{daemonconsts.ContainerRegistryPolicyPath, "/etc/foo.conf"},
+			unexpectedPaths:      []string{daemonconsts.SysctlConfigDir},
+		},
+		{
+			name:                 "built-in actions",
+			machineConfiguration: writeFile(t, dir, "builtin.yaml", builtinActions),
+			expectedPaths:        []string{daemonconsts.ContainerRegistryPolicyPath, "/etc/foo.conf", daemonconsts.SysctlConfigDir},
+		},
+		{
+			name:                 "file without a MachineConfiguration",
+			machineConfiguration: writeFile(t, dir, "00-worker.yaml", workerMachineConfigYAML),
+			expectedError:        "no MachineConfiguration found in " + filepath.Join(dir, "00-worker.yaml"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diffOpts.machineConfiguration = test.machineConfiguration
+			t.Cleanup(func() { diffOpts.machineConfiguration = "" })
+
+			policies, err := getClusterPolicies()
+			if test.expectedError != "" {
+				assert.EqualError(t, err, test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			paths := []string{}
+			for _, file := range policies.Files {
+				paths = append(paths, file.Path)
+			}
+			assert.Subset(t, paths, test.expectedPaths)
+			for _, path := range test.unexpectedPaths {
+				assert.NotContains(t, paths, path)
+			}
+		})
+	}
+}
+
+func TestMergeCandidates(t *testing.T) {
+	t.Parallel()
+
+	cconfig := &mcfgv1.ControllerConfig{Spec: mcfgv1.ControllerConfigSpec{BaseOSContainerImage: "quay.io/openshift/rhel-coreos@sha256:abc"}}
+
+	current := helpers.NewMachineConfig("rendered-worker-1", nil, "", nil)
+	current.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(helpers.NewMachineConfigPool("worker", nil, nil, ""), mcfgv1.SchemeGroupVersion.WithKind("MachineConfigPool"))}
+
+	clusterFile := ctrlcommon.NewIgnFile("/etc/foo.conf", "cluster")
+	candidateFile := ctrlcommon.NewIgnFile("/etc/foo.conf", "candidate")
+	invalid := helpers.NewMachineConfig("99-invalid", nil, "", nil)
+	invalid.Spec.KernelType = "foo"
+
+	tests := []struct {
+		name             string
+		current          *mcfgv1.MachineConfig
+		candidates       []*mcfgv1.MachineConfig
+		expectedName     string
+		expectedFiles    []ign3types.File
+		expectedKargs    []string
+		expectedErrorMsg string
+	}{
+		{
+			name:    "candidates are merged",
+			current: current,
+			candidates: []*mcfgv1.MachineConfig{
+				helpers.NewMachineConfig("00-worker", nil, "", []ign3types.File{clusterFile}),
+				helpers.NewMachineConfigExtended("99-worker-kargs", nil, nil, nil, nil, nil, nil, false, []string{"nosmt"}, "", ""),
+			},
+			expectedName:  "rendered-worker-candidate",
+			expectedFiles: []ign3types.File{clusterFile},
+			expectedKargs: []string{"nosmt"},
+		},
+		{
+			name:    "candidate overrides a same-named cluster MachineConfig",
+			current: current,
+			candidates: []*mcfgv1.MachineConfig{
+				helpers.NewMachineConfig("00-worker", nil, "", []ign3types.File{clusterFile}),
+				helpers.NewMachineConfigExtended("99-worker-kargs", nil, nil, nil, nil, nil, nil, false, []string{"nosmt"}, "", ""),
+				helpers.NewMachineConfig("00-worker", nil, "", []ign3types.File{candidateFile}),
+			},
+			expectedName:  "rendered-worker-candidate",
+			expectedFiles: []ign3types.File{candidateFile},
+			expectedKargs: []string{"nosmt"},
+		},
+		{
+			name:         "current config without a pool",
+			current:      helpers.NewMachineConfig("rendered-worker-1", nil, "", nil),
+			candidates:   []*mcfgv1.MachineConfig{helpers.NewMachineConfig("00-worker", nil, "", nil)},
+			expectedName: "rendered-candidate-candidate",
+		},
+		{
+			name:             "invalid candidate",
+			current:          current,
+			candidates:       []*mcfgv1.MachineConfig{invalid},
+			expectedErrorMsg: "invalid MachineConfig 99-invalid",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			merged, err := mergeCandidates(test.current, test.candidates, cconfig)
+			if test.expectedErrorMsg != "" {
+				assert.ErrorContains(t, err, test.expectedErrorMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedName, merged.Name)
+			assert.Equal(t, cconfig.Spec.BaseOSContainerImage, merged.Spec.OSImageURL)
+			assert.ElementsMatch(t, test.expectedKargs, merged.Spec.KernelArguments)
+
+			ignConfig, err := ctrlcommon.ParseAndConvertConfig(merged.Spec.Config.Raw)
+			require.NoError(t, err)
+			assert.Equal(t, len(test.expectedFiles), len(ignConfig.Storage.Files))
+			for i, file := range test.expectedFiles {
+				assert.Equal(t, file.Path, ignConfig.Storage.Files[i].Path)
+				assert.Equal(t, file.Contents.Source, ignConfig.Storage.Files[i].Contents.Source)
+			}
+		})
+	}
+}
+
+func TestPredictedActions(t *testing.T) {
+	dir := t.TempDir()
+	diffOpts.controllerConfig = writeFile(t, dir, "controllerconfig.yaml", controllerConfigYAML)
+	t.Cleanup(func() { diffOpts.controllerConfig = "" })
+
+	clusterConfigs := []*mcfgv1.MachineConfig{
+		helpers.NewMachineConfig("00-worker", nil, "", []ign3types.File{ctrlcommon.NewIgnFile(daemonconsts.ContainerRegistryPolicyPath, "cluster")}),
+	}
+
+	// The current rendered config is rendered from the cluster's
+	// MachineConfigs and the fixture ControllerConfig.
+	cconfig, err := getControllerConfig(nil)
+	require.NoError(t, err)
+	current, err := mergeCandidates(&mcfgv1.MachineConfig{}, clusterConfigs, cconfig)
+	require.NoError(t, err)
+	current.Name = "rendered-worker-1"
+
+	tests := []struct {
+		name            string
+		candidates      []*mcfgv1.MachineConfig
+		legacyActions   bool
+		expectedActions []string
+		expectedDrain   bool
+	}{
+		{
+			name:            "no changes",
+			expectedActions: []string{string(opv1.NoneStatusAction)},
+		},
+		{
+			name: "files only",
+			candidates: []*mcfgv1.MachineConfig{
+				helpers.NewMachineConfig("00-worker", nil, "", []ign3types.File{ctrlcommon.NewIgnFile(daemonconsts.ContainerRegistryPolicyPath, "candidate")}),
+			},
+			expectedActions: []string{"Reload crio.service"},
+		},
+		{
+			name: "files only with legacy actions",
+			candidates: []*mcfgv1.MachineConfig{
+				helpers.NewMachineConfig("00-worker", nil, "", []ign3types.File{ctrlcommon.NewIgnFile(daemonconsts.ContainerRegistryPolicyPath, "candidate")}),
+			},
+			legacyActions:   true,
+			expectedActions: []string{"reload crio"},
+		},
+		{
+			name: "kernel arguments",
+			candidates: []*mcfgv1.MachineConfig{
+				helpers.NewMachineConfigExtended("99-worker-kargs", nil, nil, nil, nil, nil, nil, false, []string{"nosmt"}, "", ""),
+			},
+			expectedActions: []string{string(opv1.RebootStatusAction)},
+			expectedDrain:   true,
+		},
+		{
+			name: "files and kernel arguments",
+			candidates: []*mcfgv1.MachineConfig{
+				helpers.NewMachineConfig("00-worker", nil, "", []ign3types.File{ctrlcommon.NewIgnFile(daemonconsts.ContainerRegistryPolicyPath, "candidate")}),
+				helpers.NewMachineConfigExtended("99-worker-kargs", nil, nil, nil, nil, nil, nil, false, []string{"nosmt"}, "", ""),
+			},
+			expectedActions: []string{string(opv1.RebootStatusAction)},
+			expectedDrain:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cconfig, err := getControllerConfig(current)
+			require.NoError(t, err)
+
+			var clusterPolicies *opv1.NodeDisruptionPolicyClusterStatus
+			if !test.legacyActions {
+				clusterPolicies, err = getClusterPolicies()
+				require.NoError(t, err)
+			}
+
+			candidate, err := mergeCandidates(current, append(append([]*mcfgv1.MachineConfig{}, clusterConfigs...), test.candidates...), cconfig)
+			require.NoError(t, err)
+
+			plan, err := daemon.NewUpdatePlan(current, candidate, clusterPolicies)
+			require.NoError(t, err)
+			assert.Empty(t, plan.OSImageURL)
+			assert.Equal(t, test.expectedActions, plan.Actions())
+			assert.Equal(t, test.expectedDrain, plan.Drain)
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/spf13/cobra"
+	"k8s.io/component-base/cli"
+)
+
+const componentName = "mcoctl"
+
+var (
+	rootCmd = &cobra.Command{
+		Use:   componentName,
+		Short: "Offline tooling for Machine Config Operator resources",
+		Long:  "",
+	}
+)
+
+func init() {
+	rootCmd.PersistentFlags().AddGoFlagSet(flag.CommandLine)
+}
+
+func main() {
+	os.Exit(cli.Run(rootCmd))
+}
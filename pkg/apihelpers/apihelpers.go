@@ -116,6 +116,38 @@ var (
 			},
 		},
 	}
+
+	// These default policies apply changes to kernel and device configuration
+	// with the built-in actions of the MCD instead of rebooting. They are only
+	// merged if the cluster opts in with the
+	// constants.BuiltinNodeDisruptionActionsAnnotationKey annotation on the
+	// MachineConfiguration.
+	builtinActionClusterPolicies = []opv1.NodeDisruptionPolicyStatusFile{
+		{
+			Path: constants.SysctlConfigDir,
+			Actions: []opv1.NodeDisruptionPolicyStatusAction{
+				{
+					Type: opv1.SpecialStatusAction,
+				},
+			},
+		},
+		{
+			Path: constants.ModulesLoadConfigDir,
+			Actions: []opv1.NodeDisruptionPolicyStatusAction{
+				{
+					Type: opv1.SpecialStatusAction,
+				},
+			},
+		},
+		{
+			Path: constants.UdevRulesDir,
+			Actions: []opv1.NodeDisruptionPolicyStatusAction{
+				{
+					Type: opv1.SpecialStatusAction,
+				},
+			},
+		},
+	}
 )
 
 // NewMachineConfigPoolCondition creates a new MachineConfigPool condition.
@@ -325,19 +357,25 @@ func IsControllerConfigCompleted(ccName string, ccGetter func(string) (*mcfgv1.C
 }
 
 // Merges the cluster's default node disruption policies with the user defined policies, if any.
-func MergeClusterPolicies(userDefinedClusterPolicies opv1.NodeDisruptionPolicyConfig) opv1.NodeDisruptionPolicyClusterStatus {
+// If builtinActions is true, the default policies include those using the built-in actions of the MCD.
+func MergeClusterPolicies(userDefinedClusterPolicies opv1.NodeDisruptionPolicyConfig, builtinActions bool) opv1.NodeDisruptionPolicyClusterStatus {
 
 	mergedClusterPolicies := opv1.NodeDisruptionPolicyClusterStatus{}
 
+	defaultFiles := defaultClusterPolicies.Files
+	if builtinActions {
+		defaultFiles = append(append([]opv1.NodeDisruptionPolicyStatusFile{}, defaultFiles...), builtinActionClusterPolicies...)
+	}
+
 	// Add default file policies to the merged list.
-	mergedClusterPolicies.Files = append(mergedClusterPolicies.Files, defaultClusterPolicies.Files...)
+	mergedClusterPolicies.Files = append(mergedClusterPolicies.Files, defaultFiles...)
 
 	// Iterate through user file policies.
 	// If there is a conflict with default policy, replace that entry in the merged list with the user defined policy.
 	// If there was no conflict, add the user defined policy as a new entry to the merged list.
 	for _, userDefinedPolicyFile := range userDefinedClusterPolicies.Files {
 		override := false
-		for i, defaultPolicyFile := range defaultClusterPolicies.Files {
+		for i, defaultPolicyFile := range defaultFiles {
 			if defaultPolicyFile.Path == userDefinedPolicyFile.Path {
 				mergedClusterPolicies.Files[i] = convertSpecFileToStatusFile(userDefinedPolicyFile)
 				override = true
@@ -0,0 +1,75 @@
+package build
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	mcfgv1alpha1 "github.com/openshift/api/machineconfiguration/v1alpha1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/buildrequest"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// Keys of the digest ConfigMap holding the build cache statistics, written by
+// the build pod when the build was cached.
+const (
+	cacheHitsDigestKey      = "cache-hits"
+	cacheableStepsDigestKey = "cacheable-steps"
+)
+
+// Validates the build cache repository of a MachineOSConfig, if it has one.
+// The cache repository only holds cache layers, so it has to be a repository
+// of its own without a tag or digest.
+func validateBuildCacheRepository(mosc *mcfgv1alpha1.MachineOSConfig) error {
+	cacheRepo := buildrequest.GetBuildCacheRepository(mosc)
+	if cacheRepo == "" {
+		return nil
+	}
+
+	named, err := reference.ParseNormalizedNamed(cacheRepo)
+	if err != nil {
+		return fmt.Errorf("invalid build cache repository %q: %w", cacheRepo, err)
+	}
+
+	if !reference.IsNameOnly(named) {
+		return fmt.Errorf("invalid build cache repository %q: must not have a tag or digest", cacheRepo)
+	}
+
+	rendered, err := reference.ParseNormalizedNamed(mosc.Spec.BuildInputs.RenderedImagePushspec)
+	if err == nil && rendered.Name() == named.Name() {
+		return fmt.Errorf("invalid build cache repository %q: must not be the renderedImagePushspec repository", cacheRepo)
+	}
+
+	return nil
+}
+
+// Copies the build cache statistics from the digest ConfigMap onto the
+// MachineOSBuild, if the build was cached.
+func setBuildCacheStats(mosb *mcfgv1alpha1.MachineOSBuild, digestConfigMap *corev1.ConfigMap) {
+	stats := map[string]string{
+		cacheHitsDigestKey:      constants.BuildCacheHitsAnnotationKey,
+		cacheableStepsDigestKey: constants.BuildCacheableStepsAnnotationKey,
+	}
+
+	for digestKey, annoKey := range stats {
+		val, ok := digestConfigMap.Data[digestKey]
+		if !ok {
+			continue
+		}
+
+		val = strings.TrimSpace(val)
+		if _, err := strconv.Atoi(val); err != nil {
+			klog.Warningf("Ignoring invalid %s %q of build %s", digestKey, val, mosb.Name)
+			continue
+		}
+
+		if mosb.Annotations == nil {
+			mosb.Annotations = map[string]string{}
+		}
+
+		mosb.Annotations[annoKey] = val
+	}
+}
@@ -0,0 +1,69 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSetBuildCacheStats(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name                string
+		digestData          map[string]string
+		expectedAnnotations map[string]string
+	}{
+		{
+			name:       "Uncached build",
+			digestData: map[string]string{"digest": expectedImageSHA},
+		},
+		{
+			name: "Cached build",
+			digestData: map[string]string{
+				"digest":                expectedImageSHA,
+				cacheHitsDigestKey:      "3\n",
+				cacheableStepsDigestKey: "5\n",
+			},
+			expectedAnnotations: map[string]string{
+				constants.BuildCacheHitsAnnotationKey:      "3",
+				constants.BuildCacheableStepsAnnotationKey: "5",
+			},
+		},
+		{
+			name: "Invalid stats are ignored",
+			digestData: map[string]string{
+				"digest":                expectedImageSHA,
+				cacheHitsDigestKey:      "",
+				cacheableStepsDigestKey: "5",
+			},
+			expectedAnnotations: map[string]string{
+				constants.BuildCacheableStepsAnnotationKey: "5",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			pool := newMachineConfigPool("worker", "rendered-worker-1")
+			mosb := newMachineOSBuild(newMachineOSConfig(pool), pool)
+
+			setBuildCacheStats(mosb, &corev1.ConfigMap{Data: testCase.digestData})
+
+			for key, val := range testCase.expectedAnnotations {
+				assert.Equal(t, val, mosb.Annotations[key])
+			}
+
+			for _, key := range []string{constants.BuildCacheHitsAnnotationKey, constants.BuildCacheableStepsAnnotationKey} {
+				if _, ok := testCase.expectedAnnotations[key]; !ok {
+					assert.NotContains(t, mosb.Annotations, key)
+				}
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package build
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/containers/image/v5/docker/reference"
@@ -10,6 +11,7 @@ import (
 	mcfgv1alpha1 "github.com/openshift/api/machineconfiguration/v1alpha1"
 
 	mcfginformersv1alpha1 "github.com/openshift/client-go/machineconfiguration/informers/externalversions/machineconfiguration/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	aggerrors "k8s.io/apimachinery/pkg/util/errors"
@@ -40,6 +42,7 @@ import (
 	corelistersv1 "k8s.io/client-go/listers/core/v1"
 
 	coreinformers "k8s.io/client-go/informers"
+	batchinformersv1 "k8s.io/client-go/informers/batch/v1"
 	coreinformersv1 "k8s.io/client-go/informers/core/v1"
 
 	"github.com/openshift/machine-config-operator/pkg/apihelpers"
@@ -115,9 +118,12 @@ type Controller struct {
 
 	mosQueue workqueue.TypedRateLimitingInterface[string]
 
-	config           BuildControllerConfig
-	imageBuilder     ImageBuilder
-	imageBuilderType mcfgv1alpha1.MachineOSImageBuilderType
+	config BuildControllerConfig
+	// The ImageBuilder for each backend a MachineOSConfig may select.
+	imageBuilders map[ImageBuilderBackend]ImageBuilder
+
+	// Pushes the manifest lists of multi-architecture builds.
+	pushManifestList manifestListPusher
 }
 
 // Creates a BuildControllerConfig with sensible production defaults.
@@ -153,6 +159,7 @@ type informers struct {
 	mcpInformer             mcfginformersv1.MachineConfigPoolInformer
 	buildInformer           buildinformersv1.BuildInformer
 	podInformer             coreinformersv1.PodInformer
+	jobInformer             batchinformersv1.JobInformer
 	cmInformer              coreinformersv1.ConfigMapInformer
 	machineOSBuildInformer  mcfginformersv1alpha1.MachineOSBuildInformer
 	machineOSConfigInformer mcfginformersv1alpha1.MachineOSConfigInformer
@@ -173,6 +180,7 @@ func newInformers(bcc *Clients) *informers {
 	cmInformer := coreinformers.NewFilteredSharedInformerFactory(bcc.kubeclient, 0, ctrlcommon.MCONamespace, nil)
 	buildInformer := buildinformers.NewSharedInformerFactoryWithOptions(bcc.buildclient, 0, buildinformers.WithNamespace(ctrlcommon.MCONamespace))
 	podInformer := coreinformers.NewSharedInformerFactoryWithOptions(bcc.kubeclient, 0, coreinformers.WithNamespace(ctrlcommon.MCONamespace))
+	jobInformer := coreinformers.NewSharedInformerFactoryWithOptions(bcc.kubeclient, 0, coreinformers.WithNamespace(ctrlcommon.MCONamespace))
 	// this may not work, might need a new mcfg client and or a new informer pkg
 	machineOSBuildInformer := mcfginformers.NewSharedInformerFactory(bcc.mcfgclient, 0)
 	machineOSConfigInformer := mcfginformers.NewSharedInformerFactory(bcc.mcfgclient, 0)
@@ -183,6 +191,7 @@ func newInformers(bcc *Clients) *informers {
 		cmInformer:              cmInformer.Core().V1().ConfigMaps(),
 		buildInformer:           buildInformer.Build().V1().Builds(),
 		podInformer:             podInformer.Core().V1().Pods(),
+		jobInformer:             jobInformer.Batch().V1().Jobs(),
 		machineOSBuildInformer:  machineOSBuildInformer.Machineconfiguration().V1alpha1().MachineOSBuilds(),
 		machineOSConfigInformer: machineOSConfigInformer.Machineconfiguration().V1alpha1().MachineOSConfigs(),
 		toStart: []interface{ Start(<-chan struct{}) }{
@@ -191,6 +200,7 @@ func newInformers(bcc *Clients) *informers {
 			buildInformer,
 			cmInformer,
 			podInformer,
+			jobInformer,
 			machineOSBuildInformer,
 			machineOSConfigInformer,
 		},
@@ -213,7 +223,8 @@ func newBuildController(
 		mosQueue: workqueue.NewTypedRateLimitingQueueWithConfig(
 			workqueue.DefaultTypedControllerRateLimiter[string](),
 			workqueue.TypedRateLimitingQueueConfig[string]{Name: "machineosbuilder"}),
-		config: ctrlConfig,
+		config:           ctrlConfig,
+		pushManifestList: pushManifestList,
 	}
 
 	ctrl.syncHandler = ctrl.syncMachineOSBuilder
@@ -254,10 +265,45 @@ func NewWithCustomPodBuilder(
 	clients *Clients,
 ) *Controller {
 	ctrl := newBuildController(ctrlConfig, clients)
-	ctrl.imageBuilder = newPodBuildController(ctrlConfig, clients, ctrl.customBuildPodUpdater)
+	ctrl.imageBuilders = map[ImageBuilderBackend]ImageBuilder{
+		PodImageBuilderBackend: newPodBuildController(ctrlConfig, clients, ctrl.customBuildPodUpdater),
+	}
 	return ctrl
 }
 
+// Creates a Build Controller instance with an ImageBuilder for each of the
+// image builder backends.
+func New(
+	ctrlConfig BuildControllerConfig,
+	clients *Clients,
+) *Controller {
+	ctrl := newBuildController(ctrlConfig, clients)
+	ctrl.imageBuilders = map[ImageBuilderBackend]ImageBuilder{
+		PodImageBuilderBackend:     newPodBuildController(ctrlConfig, clients, ctrl.customBuildPodUpdater),
+		JobImageBuilderBackend:     newJobBuildController(ctrlConfig, clients, ctrl.customBuildJobUpdater),
+		WebhookImageBuilderBackend: newWebhookBuildController(ctrlConfig, clients, ctrl.updateBuildFromObjectState),
+	}
+	return ctrl
+}
+
+// Gets the ImageBuilder for the backend selected by the given MachineOSConfig.
+func (ctrl *Controller) imageBuilderForConfig(mosc *mcfgv1alpha1.MachineOSConfig) (ImageBuilder, error) {
+	backend, err := getImageBuilderBackend(mosc)
+	if err != nil {
+		return nil, err
+	}
+
+	builder, ok := ctrl.imageBuilders[backend]
+	if !ok {
+		return nil, &ErrInvalidImageBuilder{
+			Message:     fmt.Sprintf("image builder backend %s is not available", backend),
+			InvalidType: string(backend),
+		}
+	}
+
+	return builder, nil
+}
+
 // Run executes the render controller.
 // TODO: Make this use a context instead of a stop channel.
 func (ctrl *Controller) Run(parentCtx context.Context, workers int) {
@@ -276,7 +322,9 @@ func (ctrl *Controller) Run(parentCtx context.Context, workers int) {
 		return
 	}
 
-	go ctrl.imageBuilder.Run(ctx, workers)
+	for _, builder := range ctrl.imageBuilders {
+		go builder.Run(ctx, workers)
+	}
 
 	for i := 0; i < workers; i++ {
 		go wait.Until(ctrl.mosWorker, time.Second, ctx.Done())
@@ -324,23 +372,20 @@ func (ctrl *Controller) processNextMosWorkItem() bool {
 	return true
 }
 
+// The state of the object performing a build, as reported by an ImageBuilder.
+type buildObjectState string
+
+const (
+	buildObjectPending   buildObjectState = "Pending"
+	buildObjectRunning   buildObjectState = "Running"
+	buildObjectSucceeded buildObjectState = "Succeeded"
+	buildObjectFailed    buildObjectState = "Failed"
+)
+
 // Reconciles the MachineConfigPool state with the state of a custom pod object.
 func (ctrl *Controller) customBuildPodUpdater(pod *corev1.Pod) error {
-	pool, err := ctrl.mcfgclient.MachineconfigurationV1().MachineConfigPools().Get(context.TODO(), pod.Labels[constants.TargetMachineConfigPoolLabelKey], metav1.GetOptions{})
-	if err != nil {
-		return err
-	}
-
 	klog.V(4).Infof("Build pod (%s) is %s", pod.Name, pod.Status.Phase)
 
-	mosc, mosb, err := ctrl.getConfigAndBuildForPool(pool)
-	if err != nil {
-		return err
-	}
-	if mosc == nil || mosb == nil {
-		return fmt.Errorf("Missing MOSC/MOSB for pool %s", pool.Name)
-	}
-
 	// We cannot solely rely upon the pod phase to determine whether the build
 	// pod is in an error state. This is because it is possible for the build
 	// container to enter an error state while the wait-for-done container is
@@ -350,36 +395,111 @@ func (ctrl *Controller) customBuildPodUpdater(pod *corev1.Pod) error {
 	// Sometimes, the wait-for-done container might take a few tries to pull, so
 	// provided that the pod is still pending, we should ignore any image pull
 	// errors.
+	// The verification pod only runs once the image was built successfully, so
+	// the build is reconciled as succeeded, which reevaluates the verification.
+	if isBuildVerificationPod(pod) {
+		return ctrl.updateBuildFromObjectState(pod.Labels[constants.TargetMachineConfigPoolLabelKey], buildObjectSucceeded, toObjectRef(pod))
+	}
+
 	if isBuildPodError(pod) && pod.Status.Phase != corev1.PodPending {
-		if err := ctrl.markBuildFailed(mosc, mosb); err != nil {
-			return err
-		}
+		return ctrl.updateBuildFromObjectState(pod.Labels[constants.TargetMachineConfigPoolLabelKey], buildObjectFailed, toObjectRef(pod))
+	}
 
-		ctrl.enqueueMachineOSBuild(mosb)
+	var state buildObjectState
+	switch pod.Status.Phase {
+	case corev1.PodPending:
+		state = buildObjectPending
+	case corev1.PodRunning:
+		state = buildObjectRunning
+	case corev1.PodSucceeded:
+		state = buildObjectSucceeded
+	case corev1.PodFailed:
+		state = buildObjectFailed
+	default:
 		return nil
 	}
 
+	return ctrl.updateBuildFromObjectState(pod.Labels[constants.TargetMachineConfigPoolLabelKey], state, toObjectRef(pod))
+}
+
+// Reconciles the MachineConfigPool state with the state of a build Job.
+func (ctrl *Controller) customBuildJobUpdater(job *batchv1.Job) error {
+	state := getBuildJobState(job)
+
+	klog.V(4).Infof("Build job (%s) is %s", job.Name, state)
+
+	return ctrl.updateBuildFromObjectState(job.Labels[constants.TargetMachineConfigPoolLabelKey], state, toObjectRef(job))
+}
+
+// Reconciles the MachineOSBuild for the given pool with the state of the
+// object performing its build.
+func (ctrl *Controller) updateBuildFromObjectState(poolName string, state buildObjectState, objRef *corev1.ObjectReference) error {
+	pool, err := ctrl.mcfgclient.MachineconfigurationV1().MachineConfigPools().Get(context.TODO(), poolName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	mosc, mosb, err := ctrl.getConfigAndBuildForPool(pool)
+	if err != nil {
+		return err
+	}
+	if mosc == nil || mosb == nil {
+		return fmt.Errorf("Missing MOSC/MOSB for pool %s", pool.Name)
+	}
+
+	// A multi-architecture build has a build object for each architecture,
+	// which only report the state of their own architecture.
+	if buildrequest.IsMultiArchBuild(mosb) {
+		state, err = ctrl.getMultiArchBuildState(mosc, mosb, state)
+		if err != nil {
+			return err
+		}
+	}
+
 	mosbState := ctrlcommon.NewMachineOSBuildState(mosb)
-	switch pod.Status.Phase {
-	case corev1.PodPending:
+
+	// A successfully built image has to pass verification and signing before
+	// it may be rolled out.
+	var buildErr error
+	if state == buildObjectSucceeded && isBuildVerificationEnabled(mosc) && !mosbState.IsBuildSuccess() && !mosbState.IsBuildFailure() {
+		state, buildErr, err = ctrl.getBuildVerificationState(mosc, mosb)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch state {
+	case buildObjectPending:
 		if !mosbState.IsBuildPending() {
-			objRef := toObjectRef(pod)
 			err = ctrl.markBuildPendingWithObjectRef(mosc, mosb, *objRef)
 		}
-	case corev1.PodRunning:
+	case buildObjectRunning:
 		// If we're running, then there's nothing to do right now.
 		if !mosbState.IsBuilding() {
 			err = ctrl.markBuildInProgress(mosb)
 		}
-	case corev1.PodSucceeded:
+	case buildObjectSucceeded:
 		// If we've succeeded, we need to update the pool to indicate that.
 		if !mosbState.IsBuildSuccess() {
+			// The build objects are cleaned up once the build succeeded, so
+			// their logs are retained first.
+			mosb, _ = ctrl.retainBuildLogs(mosc, mosb)
 			err = ctrl.markBuildSucceeded(mosc, mosb)
 		}
-	case corev1.PodFailed:
+	case buildObjectFailed:
 		// If we've failed, we need to update the pool to indicate that.
 		if !mosbState.IsBuildFailure() {
-			err = ctrl.markBuildFailed(mosc, mosb)
+			var failedStep string
+			mosb, failedStep = ctrl.retainBuildLogs(mosc, mosb)
+			if buildErr == nil && failedStep != "" {
+				buildErr = fmt.Errorf("build %s", failedStep)
+			}
+
+			if buildErr != nil {
+				err = ctrl.markBuildFailedWithError(mosc, mosb, buildErr)
+			} else {
+				err = ctrl.markBuildFailed(mosc, mosb)
+			}
 		}
 	}
 
@@ -467,7 +587,11 @@ func (ctrl *Controller) syncMachineOSBuilder(key string) error {
 					if err != nil {
 						return err
 					}
-					doABuild, err := shouldWeDoABuild(ctrl.imageBuilder, machineOSConfig, machineOSBuild, machineOSBuild)
+					builder, err := ctrl.imageBuilderForConfig(machineOSConfig)
+					if err != nil {
+						return err
+					}
+					doABuild, err := shouldWeDoABuild(builder, machineOSConfig, machineOSBuild, machineOSBuild)
 					if err != nil {
 						return err
 					}
@@ -603,9 +727,19 @@ func (ctrl *Controller) markBuildInterrupted(mosc *mcfgv1alpha1.MachineOSConfig,
 
 // Marks a given MachineConfigPool as a failed build.
 func (ctrl *Controller) markBuildFailed(mosc *mcfgv1alpha1.MachineOSConfig, mosb *mcfgv1alpha1.MachineOSBuild) error {
-	klog.Errorf("Build %s failed for pool %s", mosb.Name, mosc.Spec.MachineConfigPool.Name)
+	return ctrl.markBuildFailedWithError(mosc, mosb, fmt.Errorf("BuildFailed"))
+}
+
+// Marks a given MachineConfigPool as a failed build, reporting the given error
+// in the failed condition of the MachineOSBuild.
+func (ctrl *Controller) markBuildFailedWithError(mosc *mcfgv1alpha1.MachineOSConfig, mosb *mcfgv1alpha1.MachineOSBuild, buildErr error) error {
+	klog.Errorf("Build %s failed for pool %s: %v", mosb.Name, mosc.Spec.MachineConfigPool.Name, buildErr)
 
 	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if mosb.Status.BuildEnd == nil {
+			now := metav1.Now()
+			mosb.Status.BuildEnd = &now
+		}
 
 		bs := ctrlcommon.NewMachineOSBuildState(mosb)
 		bs.SetBuildConditions([]metav1.Condition{
@@ -641,7 +775,7 @@ func (ctrl *Controller) markBuildFailed(mosc *mcfgv1alpha1.MachineOSConfig, mosb
 			},
 		})
 
-		return ctrl.syncFailingStatus(mosc, bs.Build, fmt.Errorf("BuildFailed"))
+		return ctrl.syncFailingStatus(mosc, bs.Build, buildErr)
 	})
 
 }
@@ -695,7 +829,12 @@ func (ctrl *Controller) markBuildInProgress(mosb *mcfgv1alpha1.MachineOSBuild) e
 func (ctrl *Controller) postBuildCleanup(mosc *mcfgv1alpha1.MachineOSConfig, mosb *mcfgv1alpha1.MachineOSBuild, ignoreMissing bool) error {
 	// Delete the actual build object itself.
 	deleteBuildObject := func() error {
-		err := ctrl.imageBuilder.DeleteBuildObject(mosb, mosc)
+		builder, err := ctrl.imageBuilderForConfig(mosc)
+		if err != nil {
+			return err
+		}
+
+		err = builder.DeleteBuildObject(mosb, mosc)
 
 		if err == nil {
 			klog.Infof("Deleted build object %s", buildrequest.GetBuildPodName(mosb))
@@ -732,6 +871,20 @@ func (ctrl *Controller) postBuildCleanup(mosc *mcfgv1alpha1.MachineOSConfig, mos
 		return err
 	}
 
+	// Delete the pod which verified and signed the built image.
+	deleteVerificationPod := func() error {
+		podName := buildrequest.GetVerificationPodName(mosb)
+
+		err := ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Delete(context.TODO(), podName, metav1.DeleteOptions{})
+
+		if err == nil {
+			klog.Infof("Deleted verification pod %s", podName)
+		}
+
+		// Most builds are never verified.
+		return ignoreIsNotFoundErr(err)
+	}
+
 	maybeIgnoreMissing := func(f func() error) func() error {
 		return func() error {
 			if ignoreMissing {
@@ -748,6 +901,7 @@ func (ctrl *Controller) postBuildCleanup(mosc *mcfgv1alpha1.MachineOSConfig, mos
 		maybeIgnoreMissing(deleteBuildObject),
 		maybeIgnoreMissing(deleteMCConfigMap),
 		maybeIgnoreMissing(deleteDockerfileConfigMap),
+		deleteVerificationPod,
 	)
 }
 
@@ -777,6 +931,11 @@ func (ctrl *Controller) markBuildSucceeded(mosc *mcfgv1alpha1.MachineOSConfig, m
 		// now, all we need is to make sure this is used all around. (node controller, getters, etc)
 		mosc.Status.CurrentImagePullspec = sha
 		mosb.Status.FinalImagePushspec = sha
+		if mosb.Status.BuildEnd == nil {
+			now := metav1.Now()
+			mosb.Status.BuildEnd = &now
+		}
+		setBuildCacheStats(mosb, digestConfigMap)
 		// Not sure if this is correct way to do this.
 		mosc.Status.ObservedGeneration += mosc.GetGeneration()
 
@@ -939,7 +1098,12 @@ func (ctrl *Controller) startBuildForMachineConfigPool(mosc *mcfgv1alpha1.Machin
 		return fmt.Errorf("could not start build for MachineConfigPool %s: %w", ourConfig.Spec.MachineConfigPool.Name, err)
 	}
 
-	objRef, err := ctrl.imageBuilder.StartBuild(ibr)
+	builder, err := ctrl.imageBuilderForConfig(ourConfig)
+	if err != nil {
+		return err
+	}
+
+	objRef, err := builder.StartBuild(ibr)
 	if err != nil {
 		return err
 	}
@@ -1006,9 +1170,12 @@ func (ctrl *Controller) deleteMachineOSConfig(cur interface{}) {
 	// first, we need to stop and delete any existing builds.
 	mosb, err := ctrl.machineOSBuildLister.Get(getMOSBName(mosc, mcp))
 	if err == nil {
-		if running, _ := ctrl.imageBuilder.IsBuildRunning(mosb, mosc); running {
+		builder, err := ctrl.imageBuilderForConfig(mosc)
+		if err != nil {
+			utilruntime.HandleError(err)
+		} else if running, _ := builder.IsBuildRunning(mosb, mosc); running {
 			// Stop and delete the build if it is running
-			ctrl.imageBuilder.DeleteBuildObject(mosb, mosc)
+			builder.DeleteBuildObject(mosb, mosc)
 			ctrl.markBuildInterrupted(mosc, mosb)
 		}
 		ctrl.mcfgclient.MachineconfigurationV1alpha1().MachineOSBuilds().Delete(context.TODO(), mosb.Name, metav1.DeleteOptions{})
@@ -1036,7 +1203,12 @@ func (ctrl *Controller) updateMachineOSBuild(old, cur interface{}) {
 		return
 	}
 
-	doABuild, err := shouldWeDoABuild(ctrl.imageBuilder, ourConfig, oldMOSB, curMOSB)
+	builder, err := ctrl.imageBuilderForConfig(ourConfig)
+	if err != nil {
+		return
+	}
+
+	doABuild, err := shouldWeDoABuild(builder, ourConfig, oldMOSB, curMOSB)
 	if err != nil {
 		return
 	}
@@ -1148,14 +1320,28 @@ func (ctrl *Controller) createBuildFromConfig(config *mcfgv1alpha1.MachineOSConf
 		return nil, nil, fmt.Errorf("could not get labels: %w", err)
 	}
 
+	// Build an image for each architecture of the nodes in the pool.
+	arches, err := getPoolArchitectures(ctrl.kubeclient, mcp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var mosbAnnotations map[string]string
+	if len(arches) > 0 {
+		mosbAnnotations = map[string]string{
+			constants.BuildArchitecturesAnnotationKey: strings.Join(arches, ","),
+		}
+	}
+
 	build := mcfgv1alpha1.MachineOSBuild{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "MachineOSBuild",
 			APIVersion: "machineconfiguration.openshift.io/v1alpha1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   getMOSBName(config, mcp),
-			Labels: mosbLabels,
+			Name:        getMOSBName(config, mcp),
+			Labels:      mosbLabels,
+			Annotations: mosbAnnotations,
 		},
 		Spec: mcfgv1alpha1.MachineOSBuildSpec{
 			RenderedImagePushspec: config.Spec.BuildInputs.RenderedImagePushspec,
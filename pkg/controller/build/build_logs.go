@@ -0,0 +1,223 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	mcfgv1alpha1 "github.com/openshift/api/machineconfiguration/v1alpha1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/buildrequest"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// Upper bound for the logs retained for a build. ConfigMaps are limited to
+	// 1 MiB, so this leaves room for the keys and the object metadata.
+	maxBuildLogsSize = 768 * 1024
+	// Number of log lines fetched for each container of a build.
+	maxBuildLogLines = 5000
+	// Number of log lines of the failed build step shown in the Failed
+	// condition of a MachineOSBuild.
+	failedStepLogLines = 10
+	// Upper bound for the log lines shown in the Failed condition.
+	maxFailedStepLogSize = 2048
+
+	truncatedLogsMarker = "[earlier output truncated]\n"
+)
+
+// Retains the container logs of the given build in the build logs ConfigMap,
+// so they survive the cleanup of the build objects. The ConfigMap is owned by
+// the MachineOSBuild and referenced from its annotations. Retaining the logs is
+// best effort and never fails the build. Returns the updated MachineOSBuild
+// and, if the build failed, a description of the failed step with its last
+// log lines.
+func (ctrl *Controller) retainBuildLogs(mosc *mcfgv1alpha1.MachineOSConfig, mosb *mcfgv1alpha1.MachineOSBuild) (*mcfgv1alpha1.MachineOSBuild, string) {
+	logs, failedStep, err := ctrl.collectBuildLogs(mosc, mosb)
+	if err != nil {
+		klog.Warningf("Could not collect logs of build %s: %v", mosb.Name, err)
+		return mosb, ""
+	}
+
+	if len(logs) == 0 {
+		return mosb, failedStep
+	}
+
+	updated, err := ctrl.saveBuildLogs(mosc, mosb, logs)
+	if err != nil {
+		klog.Warningf("Could not retain logs of build %s: %v", mosb.Name, err)
+		return mosb, failedStep
+	}
+
+	return updated, failedStep
+}
+
+// Fetches the logs of each container of the pods which performed the given
+// build, keyed by pod and container name. Each container gets an equal share
+// of the size limit and keeps the end of its logs.
+func (ctrl *Controller) collectBuildLogs(mosc *mcfgv1alpha1.MachineOSConfig, mosb *mcfgv1alpha1.MachineOSBuild) (map[string]string, string, error) {
+	selector := labels.SelectorFromSet(map[string]string{
+		constants.OnClusterLayeringLabelKey:       "",
+		constants.RenderedMachineConfigLabelKey:   mosb.Spec.DesiredConfig.Name,
+		constants.TargetMachineConfigPoolLabelKey: mosc.Spec.MachineConfigPool.Name,
+	})
+
+	pods, err := ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, "", err
+	}
+
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+
+	numContainers := 0
+	for _, pod := range pods.Items {
+		numContainers += len(pod.Spec.InitContainers) + len(pod.Spec.Containers)
+	}
+
+	if numContainers == 0 {
+		return nil, "", nil
+	}
+
+	logs := map[string]string{}
+	failedStep := ""
+
+	for _, pod := range pods.Items {
+		statuses := map[string]corev1.ContainerStatus{}
+		for _, status := range append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+			statuses[status.Name] = status
+		}
+
+		for _, container := range append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+			raw, err := ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+				Container: container.Name,
+				TailLines: ptr.To(int64(maxBuildLogLines)),
+			}).DoRaw(context.TODO())
+			if err != nil {
+				// Containers which never started have no logs.
+				klog.V(4).Infof("Could not get logs of container %s of build pod %s: %v", container.Name, pod.Name, err)
+				continue
+			}
+
+			containerLogs := string(raw)
+			logs[fmt.Sprintf("%s.%s.log", pod.Name, container.Name)] = tailBuildLogs(containerLogs, maxBuildLogsSize/numContainers)
+
+			terminated := statuses[container.Name].State.Terminated
+			if failedStep == "" && terminated != nil && terminated.ExitCode != 0 {
+				failedStep = fmt.Sprintf("step %s of pod %s exited with code %d:\n%s", container.Name, pod.Name, terminated.ExitCode, lastBuildLogLines(containerLogs))
+			}
+		}
+	}
+
+	return logs, failedStep, nil
+}
+
+// Writes the given logs into the build logs ConfigMap and references it from
+// the MachineOSBuild.
+func (ctrl *Controller) saveBuildLogs(mosc *mcfgv1alpha1.MachineOSConfig, mosb *mcfgv1alpha1.MachineOSBuild, logs map[string]string) (*mcfgv1alpha1.MachineOSBuild, error) {
+	cm := newBuildLogsConfigMap(mosc, mosb, logs)
+
+	_, err := ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Create(context.TODO(), cm, metav1.CreateOptions{})
+	if k8serrors.IsAlreadyExists(err) {
+		_, err = ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Update(context.TODO(), cm, metav1.UpdateOptions{})
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not write build logs ConfigMap %s: %w", cm.Name, err)
+	}
+
+	klog.Infof("Retained logs of build %s in ConfigMap %s", mosb.Name, cm.Name)
+
+	if mosb.Annotations[constants.BuildLogsConfigMapAnnotationKey] == cm.Name {
+		return mosb, nil
+	}
+
+	// Unlike the other MachineOSBuild updates of the build controller, this
+	// uses a merge patch. The logs are retained while the caller is about to
+	// update the status of the MachineOSBuild, so only the annotation is sent
+	// to avoid conflicting with, or overwriting, concurrent changes.
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				constants.BuildLogsConfigMapAnnotationKey: cm.Name,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := ctrl.mcfgclient.MachineconfigurationV1alpha1().MachineOSBuilds().Patch(context.TODO(), mosb.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not patch MachineOSBuild %q: %w", mosb.Name, err)
+	}
+
+	// Keep the status the caller is about to write.
+	updated.Status = mosb.Status
+	return updated, nil
+}
+
+// Constructs the ConfigMap retaining the logs of a build. It is owned by the
+// MachineOSBuild, so it is garbage-collected along with it.
+func newBuildLogsConfigMap(mosc *mcfgv1alpha1.MachineOSConfig, mosb *mcfgv1alpha1.MachineOSBuild, logs map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      buildrequest.GetBuildLogsConfigMapName(mosb),
+			Namespace: ctrlcommon.MCONamespace,
+			Labels: map[string]string{
+				constants.OnClusterLayeringLabelKey:       "",
+				constants.RenderedMachineConfigLabelKey:   mosb.Spec.DesiredConfig.Name,
+				constants.TargetMachineConfigPoolLabelKey: mosc.Spec.MachineConfigPool.Name,
+				constants.BuildLogsLabelKey:               "",
+			},
+			Annotations: map[string]string{
+				constants.MachineOSConfigNameAnnotationKey: mosc.Name,
+				constants.MachineOSBuildNameAnnotationKey:  mosb.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: mcfgv1alpha1.GroupVersion.String(),
+					Kind:       "MachineOSBuild",
+					Name:       mosb.Name,
+					UID:        mosb.UID,
+				},
+			},
+		},
+		Data: logs,
+	}
+}
+
+// Keeps the end of the given logs within the given size, cutting at a line
+// boundary.
+func tailBuildLogs(logs string, maxSize int) string {
+	if len(logs) <= maxSize {
+		return logs
+	}
+
+	logs = logs[len(logs)-maxSize+len(truncatedLogsMarker):]
+	if i := strings.IndexByte(logs, '\n'); i >= 0 {
+		logs = logs[i+1:]
+	}
+
+	return truncatedLogsMarker + logs
+}
+
+// Returns the last lines of the given logs.
+func lastBuildLogLines(logs string) string {
+	lines := strings.Split(strings.TrimRight(logs, "\n"), "\n")
+	if len(lines) > failedStepLogLines {
+		lines = lines[len(lines)-failedStepLogLines:]
+	}
+
+	return tailBuildLogs(strings.Join(lines, "\n"), maxFailedStepLogSize)
+}
@@ -0,0 +1,121 @@
+package build
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/openshift/machine-config-operator/pkg/controller/build/buildrequest"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTailBuildLogs(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "short\n", tailBuildLogs("short\n", 1024))
+
+	logs := strings.Repeat("0123456789\n", 100)
+	tailed := tailBuildLogs(logs, 100)
+	assert.LessOrEqual(t, len(tailed), 100)
+	assert.True(t, strings.HasPrefix(tailed, truncatedLogsMarker))
+	assert.True(t, strings.HasSuffix(tailed, "0123456789\n0123456789\n"))
+	assert.Equal(t, 0, len(strings.TrimPrefix(tailed, truncatedLogsMarker))%len("0123456789\n"))
+}
+
+func TestLastBuildLogLines(t *testing.T) {
+	t.Parallel()
+
+	lines := []string{}
+	for i := 0; i < 20; i++ {
+		lines = append(lines, strings.Repeat("x", i))
+	}
+
+	last := lastBuildLogLines(strings.Join(lines, "\n") + "\n")
+	assert.Equal(t, strings.Join(lines[10:], "\n"), last)
+}
+
+func TestRetainBuildLogs(t *testing.T) {
+	t.Parallel()
+
+	clients := getClientsForTest()
+	ctrl := newBuildController(BuildControllerConfig{}, clients)
+
+	pool := newMachineConfigPool("worker", "rendered-worker-1")
+	mosc := newMachineOSConfig(pool)
+	mosb := newMachineOSBuild(mosc, pool)
+
+	mosb, err := clients.mcfgclient.MachineconfigurationV1alpha1().MachineOSBuilds().Create(context.TODO(), mosb, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Without any build pods there is nothing to retain.
+	retained, failedStep := ctrl.retainBuildLogs(mosc, mosb)
+	assert.Equal(t, mosb, retained)
+	assert.Empty(t, failedStep)
+
+	// Changes made to the MachineOSBuild since it was read are kept.
+	apiMosb := mosb.DeepCopy()
+	apiMosb.Annotations = map[string]string{"other": "annotation"}
+	_, err = clients.mcfgclient.MachineconfigurationV1alpha1().MachineOSBuilds().Update(context.TODO(), apiMosb, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	_, err = clients.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Create(context.TODO(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      buildrequest.GetBuildPodName(mosb),
+			Namespace: ctrlcommon.MCONamespace,
+			Labels: map[string]string{
+				constants.EphemeralBuildObjectLabelKey:    "",
+				constants.OnClusterLayeringLabelKey:       "",
+				constants.RenderedMachineConfigLabelKey:   "rendered-worker-1",
+				constants.TargetMachineConfigPoolLabelKey: "worker",
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "image-build"},
+				{Name: "wait-for-done"},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodFailed,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name: "image-build",
+					State: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{ExitCode: 125},
+					},
+				},
+			},
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	retained, failedStep = ctrl.retainBuildLogs(mosc, mosb)
+	assert.Equal(t, "step image-build of pod build-rendered-worker-1 exited with code 125:\nfake logs", failedStep)
+	assert.Equal(t, buildrequest.GetBuildLogsConfigMapName(mosb), retained.Annotations[constants.BuildLogsConfigMapAnnotationKey])
+	assert.NotContains(t, mosb.Annotations, constants.BuildLogsConfigMapAnnotationKey)
+
+	cm, err := clients.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), buildrequest.GetBuildLogsConfigMapName(mosb), metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"build-rendered-worker-1.image-build.log":   "fake logs",
+		"build-rendered-worker-1.wait-for-done.log": "fake logs",
+	}, cm.Data)
+	assert.False(t, isEphemeralBuildObject(cm))
+	require.Len(t, cm.OwnerReferences, 1)
+	assert.Equal(t, "MachineOSBuild", cm.OwnerReferences[0].Kind)
+	assert.Equal(t, mosb.Name, cm.OwnerReferences[0].Name)
+
+	apiMosb, err = clients.mcfgclient.MachineconfigurationV1alpha1().MachineOSBuilds().Get(context.TODO(), mosb.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, cm.Name, apiMosb.Annotations[constants.BuildLogsConfigMapAnnotationKey])
+	assert.Equal(t, "annotation", apiMosb.Annotations["other"])
+
+	// Retaining the logs again updates the ConfigMap.
+	_, failedStep = ctrl.retainBuildLogs(mosc, retained)
+	assert.NotEmpty(t, failedStep)
+}
@@ -0,0 +1,330 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	mcfgv1alpha1 "github.com/openshift/api/machineconfiguration/v1alpha1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/buildrequest"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+)
+
+// Keys of the Secret holding the cosign key built images are signed with.
+const (
+	cosignKeySecretKey      = "cosign.key"
+	cosignPasswordSecretKey = "cosign.password"
+)
+
+// Names of the containers of the verification pod.
+const (
+	verifyContainerName = "verify"
+	signContainerName   = "sign"
+)
+
+// The service account the verification pod runs as. It has no role bindings,
+// since the verify command is user supplied.
+const verificationServiceAccountName = "machine-os-builder-verifier"
+
+// Holds the verification and signing settings of a MachineOSConfig.
+type buildVerificationOpts struct {
+	verifyCommand string
+	verifyImage   string
+	signingSecret string
+	signingImage  string
+}
+
+func getBuildVerificationOpts(mosc *mcfgv1alpha1.MachineOSConfig) buildVerificationOpts {
+	return buildVerificationOpts{
+		verifyCommand: mosc.Annotations[constants.BuildVerifyCommandAnnotationKey],
+		verifyImage:   mosc.Annotations[constants.BuildVerifyImageAnnotationKey],
+		signingSecret: mosc.Annotations[constants.BuildSigningSecretAnnotationKey],
+		signingImage:  mosc.Annotations[constants.BuildSigningImageAnnotationKey],
+	}
+}
+
+func (o buildVerificationOpts) shouldVerify() bool {
+	return o.verifyCommand != ""
+}
+
+func (o buildVerificationOpts) shouldSign() bool {
+	return o.signingSecret != ""
+}
+
+// Determines whether the images built for a MachineOSConfig have to be
+// verified or signed before they are rolled out.
+func isBuildVerificationEnabled(mosc *mcfgv1alpha1.MachineOSConfig) bool {
+	opts := getBuildVerificationOpts(mosc)
+	return opts.shouldVerify() || opts.shouldSign()
+}
+
+// Validates the verification and signing settings of a MachineOSConfig.
+func validateBuildVerification(secretGetter func(string) (*corev1.Secret, error), mosc *mcfgv1alpha1.MachineOSConfig) error {
+	opts := getBuildVerificationOpts(mosc)
+
+	if opts.verifyImage != "" {
+		if !opts.shouldVerify() {
+			return fmt.Errorf("verifier image %q given without a verify command", opts.verifyImage)
+		}
+
+		if _, err := reference.ParseNormalizedNamed(opts.verifyImage); err != nil {
+			return fmt.Errorf("invalid verifier image %q: %w", opts.verifyImage, err)
+		}
+	}
+
+	if opts.shouldSign() != (opts.signingImage != "") {
+		return fmt.Errorf("%s and %s must be given together", constants.BuildSigningSecretAnnotationKey, constants.BuildSigningImageAnnotationKey)
+	}
+
+	if !opts.shouldSign() {
+		return nil
+	}
+
+	if _, err := reference.ParseNormalizedNamed(opts.signingImage); err != nil {
+		return fmt.Errorf("invalid signing image %q: %w", opts.signingImage, err)
+	}
+
+	secret, err := secretGetter(opts.signingSecret)
+	if err != nil {
+		return fmt.Errorf("could not get signing secret %q: %w", opts.signingSecret, err)
+	}
+
+	if _, ok := secret.Data[cosignKeySecretKey]; !ok {
+		return fmt.Errorf("signing secret %q has no %q key", opts.signingSecret, cosignKeySecretKey)
+	}
+
+	return nil
+}
+
+// Reports the state of the verification of a successfully built image,
+// starting the verification if it has not been started yet. When the
+// verification failed, the returned build error holds the verifier output.
+func (ctrl *Controller) getBuildVerificationState(mosc *mcfgv1alpha1.MachineOSConfig, mosb *mcfgv1alpha1.MachineOSBuild) (buildObjectState, error, error) {
+	pod, err := ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Get(context.TODO(), buildrequest.GetVerificationPodName(mosb), metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return buildObjectRunning, nil, ctrl.startBuildVerification(mosc, mosb)
+	}
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		klog.Infof("Verification of build %s succeeded", mosb.Name)
+		return buildObjectSucceeded, nil, nil
+	case corev1.PodFailed:
+		return buildObjectFailed, getBuildVerificationError(pod), nil
+	default:
+		return buildObjectRunning, nil, nil
+	}
+}
+
+// Starts the pod verifying and signing the image built by the given build.
+func (ctrl *Controller) startBuildVerification(mosc *mcfgv1alpha1.MachineOSConfig, mosb *mcfgv1alpha1.MachineOSBuild) error {
+	digestConfigMap, err := ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), buildrequest.GetDigestConfigMapName(mosb), metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("could not get digest ConfigMap for build %s: %w", mosb.Name, err)
+	}
+
+	image, err := ParseImagePullspec(mosc.Status.CurrentImagePullspec, strings.TrimSpace(digestConfigMap.Data["digest"]))
+	if err != nil {
+		return fmt.Errorf("could not get image pullspec for build %s: %w", mosb.Name, err)
+	}
+
+	pod := newBuildVerificationPod(mosc, mosb, image)
+
+	_, err = ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Create(context.TODO(), pod, metav1.CreateOptions{})
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return fmt.Errorf("could not create verification pod for build %s: %w", mosb.Name, err)
+	}
+
+	klog.Infof("Verifying image %s built by %s", image, mosb.Name)
+	return nil
+}
+
+// Constructs the pod verifying and signing the given image. The image is
+// verified before it is signed, so when both are configured, the verifier runs
+// as an init container.
+func newBuildVerificationPod(mosc *mcfgv1alpha1.MachineOSConfig, mosb *mcfgv1alpha1.MachineOSBuild, image string) *corev1.Pod {
+	opts := getBuildVerificationOpts(mosc)
+
+	env := []corev1.EnvVar{
+		{
+			Name:  "IMAGE",
+			Value: image,
+		},
+	}
+
+	containers := []corev1.Container{}
+	volumes := []corev1.Volume{}
+
+	if opts.shouldVerify() {
+		verifyImage := opts.verifyImage
+		if verifyImage == "" {
+			verifyImage = image
+		}
+
+		containers = append(containers, corev1.Container{
+			Name:                     verifyContainerName,
+			Image:                    verifyImage,
+			Command:                  []string{"/bin/sh", "-c", opts.verifyCommand},
+			Env:                      env,
+			ImagePullPolicy:          corev1.PullAlways,
+			TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+		})
+	}
+
+	if opts.shouldSign() {
+		signEnv := append([]corev1.EnvVar{
+			{
+				Name:  "DOCKER_CONFIG",
+				Value: "/tmp/final-image-push-creds",
+			},
+			{
+				Name: "COSIGN_PASSWORD",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: opts.signingSecret},
+						Key:                  cosignPasswordSecretKey,
+						Optional:             ptr.To(true),
+					},
+				},
+			},
+		}, env...)
+
+		containers = append(containers, corev1.Container{
+			Name:    signContainerName,
+			Image:   opts.signingImage,
+			Command: []string{"cosign"},
+			// Signatures are only pushed next to the image in its registry, not
+			// uploaded to a public transparency log.
+			Args:                     []string{"sign", "--yes", "--tlog-upload=false", "--key", "/tmp/signing-key/" + cosignKeySecretKey, "$(IMAGE)"},
+			Env:                      signEnv,
+			ImagePullPolicy:          corev1.PullAlways,
+			TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      "signing-key",
+					MountPath: "/tmp/signing-key",
+					ReadOnly:  true,
+				},
+				{
+					Name:      "final-image-push-creds",
+					MountPath: "/tmp/final-image-push-creds",
+					ReadOnly:  true,
+				},
+			},
+		})
+
+		volumes = append(volumes, corev1.Volume{
+			Name: "signing-key",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: opts.signingSecret,
+					Items: []corev1.KeyToPath{
+						{
+							Key:  cosignKeySecretKey,
+							Path: cosignKeySecretKey,
+						},
+					},
+				},
+			},
+		}, corev1.Volume{
+			// Provides the credentials needed to push the signature next to the
+			// image.
+			Name: "final-image-push-creds",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: buildrequest.GetFinalPushSecretName(mosb),
+					Items: []corev1.KeyToPath{
+						{
+							Key:  corev1.DockerConfigJsonKey,
+							Path: "config.json",
+						},
+					},
+				},
+			},
+		})
+	}
+
+	// The verifier and signer do not talk to the API server, so they get no
+	// credentials for it.
+	podSpec := corev1.PodSpec{
+		RestartPolicy:                corev1.RestartPolicyNever,
+		ServiceAccountName:           verificationServiceAccountName,
+		AutomountServiceAccountToken: ptr.To(false),
+		Volumes:                      volumes,
+	}
+
+	if len(containers) > 1 {
+		podSpec.InitContainers = containers[:1]
+		podSpec.Containers = containers[1:]
+	} else {
+		podSpec.Containers = containers
+	}
+
+	if pullSecret := mosc.Spec.BuildOutputs.CurrentImagePullSecret.Name; pullSecret != "" {
+		podSpec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: pullSecret}}
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      buildrequest.GetVerificationPodName(mosb),
+			Namespace: ctrlcommon.MCONamespace,
+			Labels: map[string]string{
+				constants.EphemeralBuildObjectLabelKey:    "",
+				constants.OnClusterLayeringLabelKey:       "",
+				constants.RenderedMachineConfigLabelKey:   mosb.Spec.DesiredConfig.Name,
+				constants.TargetMachineConfigPoolLabelKey: mosc.Spec.MachineConfigPool.Name,
+				constants.BuildVerificationLabelKey:       "",
+			},
+			Annotations: map[string]string{
+				constants.MachineOSConfigNameAnnotationKey: mosc.Name,
+				constants.MachineOSBuildNameAnnotationKey:  mosb.Name,
+			},
+		},
+		Spec: podSpec,
+	}
+}
+
+// Determines whether the given pod verifies a built image.
+func isBuildVerificationPod(pod *corev1.Pod) bool {
+	_, ok := pod.Labels[constants.BuildVerificationLabelKey]
+	return ok
+}
+
+// Builds an error from the output of the failed container of a verification
+// pod.
+func getBuildVerificationError(pod *corev1.Pod) error {
+	statuses := append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+
+	for _, status := range statuses {
+		terminated := status.State.Terminated
+		if terminated == nil || terminated.ExitCode == 0 {
+			continue
+		}
+
+		stage := "verification"
+		if status.Name == signContainerName {
+			stage = "signing"
+		}
+
+		output := strings.TrimSpace(terminated.Message)
+		if output == "" {
+			return fmt.Errorf("image %s failed with exit code %d", stage, terminated.ExitCode)
+		}
+
+		return fmt.Errorf("image %s failed with exit code %d: %s", stage, terminated.ExitCode, output)
+	}
+
+	return fmt.Errorf("image verification failed: verification pod %s failed", pod.Name)
+}
@@ -0,0 +1,183 @@
+package build
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openshift/machine-config-operator/pkg/controller/build/buildrequest"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestNewBuildVerificationPod(t *testing.T) {
+	t.Parallel()
+
+	pool := newMachineConfigPool("worker", "rendered-worker-1")
+
+	t.Run("Verify only", func(t *testing.T) {
+		t.Parallel()
+
+		mosc := newMachineOSConfig(pool)
+		mosc.Annotations = map[string]string{
+			constants.BuildVerifyCommandAnnotationKey: "rpm -V kernel",
+		}
+		mosb := newMachineOSBuild(mosc, pool)
+
+		pod := newBuildVerificationPod(mosc, mosb, expectedImagePullspecWithSHA)
+		assert.Equal(t, buildrequest.GetVerificationPodName(mosb), pod.Name)
+		assert.True(t, hasAllRequiredOSBuildLabels(pod.Labels))
+		assert.True(t, isBuildVerificationPod(pod))
+		assert.Empty(t, pod.Spec.InitContainers)
+		assert.Equal(t, []corev1.LocalObjectReference{{Name: "current-image-pull-secret"}}, pod.Spec.ImagePullSecrets)
+		assert.Equal(t, verificationServiceAccountName, pod.Spec.ServiceAccountName)
+		assert.Equal(t, ptr.To(false), pod.Spec.AutomountServiceAccountToken)
+
+		require.Len(t, pod.Spec.Containers, 1)
+		verify := pod.Spec.Containers[0]
+		assert.Equal(t, expectedImagePullspecWithSHA, verify.Image)
+		assert.Equal(t, []string{"/bin/sh", "-c", "rpm -V kernel"}, verify.Command)
+		assert.Contains(t, verify.Env, corev1.EnvVar{Name: "IMAGE", Value: expectedImagePullspecWithSHA})
+	})
+
+	t.Run("Verify and sign", func(t *testing.T) {
+		t.Parallel()
+
+		mosc := newMachineOSConfig(pool)
+		mosc.Annotations = map[string]string{
+			constants.BuildVerifyCommandAnnotationKey: "bootc container lint",
+			constants.BuildVerifyImageAnnotationKey:   "registry.hostname.com/org/verifier:latest",
+			constants.BuildSigningSecretAnnotationKey: "signing-secret",
+			constants.BuildSigningImageAnnotationKey:  "registry.hostname.com/org/cosign:latest",
+		}
+		mosb := newMachineOSBuild(mosc, pool)
+
+		pod := newBuildVerificationPod(mosc, mosb, expectedImagePullspecWithSHA)
+
+		require.Len(t, pod.Spec.InitContainers, 1)
+		assert.Equal(t, verifyContainerName, pod.Spec.InitContainers[0].Name)
+		assert.Equal(t, "registry.hostname.com/org/verifier:latest", pod.Spec.InitContainers[0].Image)
+
+		require.Len(t, pod.Spec.Containers, 1)
+		sign := pod.Spec.Containers[0]
+		assert.Equal(t, signContainerName, sign.Name)
+		assert.Equal(t, "registry.hostname.com/org/cosign:latest", sign.Image)
+		assert.Contains(t, sign.Args, "$(IMAGE)")
+		assert.Contains(t, sign.Args, "--tlog-upload=false")
+
+		secretNames := []string{}
+		for _, volume := range pod.Spec.Volumes {
+			secretNames = append(secretNames, volume.Secret.SecretName)
+		}
+		assert.ElementsMatch(t, []string{"signing-secret", buildrequest.GetFinalPushSecretName(mosb)}, secretNames)
+	})
+}
+
+func TestGetBuildVerificationState(t *testing.T) {
+	t.Parallel()
+
+	clients := getClientsForTest()
+	ctrl := newBuildController(BuildControllerConfig{}, clients)
+
+	pool := newMachineConfigPool("worker", "rendered-worker-1")
+	mosc := newMachineOSConfig(pool)
+	mosc.Annotations = map[string]string{
+		constants.BuildVerifyCommandAnnotationKey: "rpm -V kernel",
+	}
+	mosc.Status.CurrentImagePullspec = expectedImagePullspecWithTag
+	mosb := newMachineOSBuild(mosc, pool)
+
+	// The verification cannot start without the digest of the built image.
+	_, _, err := ctrl.getBuildVerificationState(mosc, mosb)
+	assert.Error(t, err)
+
+	_, err = clients.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Create(context.TODO(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      buildrequest.GetDigestConfigMapName(mosb),
+			Namespace: ctrlcommon.MCONamespace,
+		},
+		Data: map[string]string{
+			"digest": expectedImageSHA,
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	state, buildErr, err := ctrl.getBuildVerificationState(mosc, mosb)
+	require.NoError(t, err)
+	assert.NoError(t, buildErr)
+	assert.Equal(t, buildObjectRunning, state)
+
+	pods := clients.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace)
+
+	pod, err := pods.Get(context.TODO(), buildrequest.GetVerificationPodName(mosb), metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, expectedImagePullspecWithSHA, pod.Spec.Containers[0].Image)
+
+	setPodStatus := func(status corev1.PodStatus) {
+		pod.Status = status
+		_, err := pods.UpdateStatus(context.TODO(), pod, metav1.UpdateOptions{})
+		require.NoError(t, err)
+	}
+
+	setPodStatus(corev1.PodStatus{Phase: corev1.PodRunning})
+	state, _, err = ctrl.getBuildVerificationState(mosc, mosb)
+	require.NoError(t, err)
+	assert.Equal(t, buildObjectRunning, state)
+
+	setPodStatus(corev1.PodStatus{
+		Phase: corev1.PodFailed,
+		ContainerStatuses: []corev1.ContainerStatus{
+			{
+				Name: verifyContainerName,
+				State: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{
+						ExitCode: 1,
+						Message:  "S.5....T.  c /etc/ssh/sshd_config\n",
+					},
+				},
+			},
+		},
+	})
+	state, buildErr, err = ctrl.getBuildVerificationState(mosc, mosb)
+	require.NoError(t, err)
+	assert.Equal(t, buildObjectFailed, state)
+	assert.EqualError(t, buildErr, "image verification failed with exit code 1: S.5....T.  c /etc/ssh/sshd_config")
+
+	setPodStatus(corev1.PodStatus{Phase: corev1.PodSucceeded})
+	state, buildErr, err = ctrl.getBuildVerificationState(mosc, mosb)
+	require.NoError(t, err)
+	assert.NoError(t, buildErr)
+	assert.Equal(t, buildObjectSucceeded, state)
+}
+
+func TestGetBuildVerificationError(t *testing.T) {
+	t.Parallel()
+
+	terminated := func(name string, exitCode int32, message string) corev1.ContainerStatus {
+		return corev1.ContainerStatus{
+			Name: name,
+			State: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{
+					ExitCode: exitCode,
+					Message:  message,
+				},
+			},
+		}
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "verify-rendered-worker-1"},
+		Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{terminated(verifyContainerName, 0, "")},
+			ContainerStatuses:     []corev1.ContainerStatus{terminated(signContainerName, 1, "")},
+		},
+	}
+	assert.EqualError(t, getBuildVerificationError(pod), "image signing failed with exit code 1")
+
+	pod.Status.ContainerStatuses = nil
+	assert.EqualError(t, getBuildVerificationError(pod), "image verification failed: verification pod verify-rendered-worker-1 failed")
+}
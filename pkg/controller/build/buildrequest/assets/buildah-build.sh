@@ -9,6 +9,11 @@ ETC_PKI_ENTITLEMENT_MOUNTPOINT="${ETC_PKI_ENTITLEMENT_MOUNTPOINT:-}"
 ETC_PKI_RPM_GPG_MOUNTPOINT="${ETC_PKI_RPM_GPG_MOUNTPOINT:-}"
 ETC_YUM_REPOS_D_MOUNTPOINT="${ETC_YUM_REPOS_D_MOUNTPOINT:-}"
 MAX_RETRIES="${MAX_RETRIES:-3}"
+CACHE_REPOSITORY="${CACHE_REPOSITORY:-}"
+
+# Holds the CA certificates of the cluster, which are needed to talk to the
+# internal image registry.
+CERT_DIR="/var/run/secrets/kubernetes.io/serviceaccount"
 
 # Retry a command up to a specific number of times until it exits successfully.
 # Adapted from https://gist.github.com/sj26/88e1c6584397bb7c13bd11108a579746
@@ -87,12 +92,42 @@ if [[ -n "$ETC_PKI_RPM_GPG_MOUNTPOINT" ]] && [[ -d "$ETC_PKI_RPM_GPG_MOUNTPOINT"
 	build_args+=("--volume=$configs:$ETC_PKI_RPM_GPG_MOUNTPOINT:$mount_opts")
 fi
 
+# If we have a build cache repository, reuse the layers of unchanged build
+# steps from it and store the layers of this build there. The repository may
+# be in the internal image registry, so use the same TLS settings as the push.
+if [[ -n "$CACHE_REPOSITORY" ]]; then
+	build_args+=(
+		--layers
+		--cache-from="$CACHE_REPOSITORY"
+		--cache-to="$CACHE_REPOSITORY"
+		--cert-dir "$CERT_DIR"
+	)
+fi
+
+build_log="$(mktemp)"
+
+# Run the build, keeping its output around so that we can count the cache
+# hits of the last attempt.
+function build {
+	buildah bud "${build_args[@]}" "$build_context" 2>&1 | tee "$build_log"
+	return "${PIPESTATUS[0]}"
+}
+
 # Build our image.
-retry buildah bud "${build_args[@]}" "$build_context"
+retry build
+
+# Record how many build steps were reused from the cache. FROM steps are never
+# cached so they are not counted. This has to be done before the push since the
+# wait-for-done container picks these files up as soon as the digestfile
+# appears.
+if [[ -n "$CACHE_REPOSITORY" ]]; then
+	grep -E 'STEP [0-9]+/[0-9]+: ' "$build_log" | grep -vcE 'STEP [0-9]+/[0-9]+: FROM ' > /tmp/done/cacheable-steps || true
+	grep -c -- '--> Using cache ' "$build_log" > /tmp/done/cache-hits || true
+fi
 
 # Push our built image.
 retry buildah push \
 	--storage-driver vfs \
 	--authfile="$FINAL_IMAGE_PUSH_CREDS" \
 	--digestfile="/tmp/done/digestfile" \
-	--cert-dir /var/run/secrets/kubernetes.io/serviceaccount "$TAG"
+	--cert-dir "$CERT_DIR" "$TAG"
@@ -17,11 +17,20 @@ done
 # Inject the contents of the digestfile into a ConfigMap.
 set -x
 
+configmap_args=(--from-file=digest=/tmp/done/digestfile)
+
+# Include the build cache statistics, if the build was cached.
+for statsfile in cache-hits cacheable-steps; do
+	if [ -f "/tmp/done/$statsfile" ]; then
+		configmap_args+=("--from-file=$statsfile=/tmp/done/$statsfile")
+	fi
+done
+
 # Create the digestfile ConfigMap
 oc create configmap \
 	"$DIGEST_CONFIGMAP_NAME" \
 	--namespace openshift-machine-config-operator \
-	--from-file=digest=/tmp/done/digestfile
+	"${configmap_args[@]}"
 
 # Label the digestfile ConfigMap
 # shellcheck disable=SC2086
@@ -76,7 +76,44 @@ func (br buildRequestImpl) Opts() BuildRequestOpts {
 
 // Creates the Build Pod object.
 func (br buildRequestImpl) BuildPod() *corev1.Pod {
-	return br.toBuildahPod()
+	return br.toBuildahPod(buildahPodTarget{
+		name:            br.getBuildName(),
+		digestConfigMap: br.getDigestConfigMapName(),
+		tag:             br.opts.MachineOSConfig.Status.CurrentImagePullspec,
+		labels:          br.getLabelsForObjectMeta(),
+		nodeArch:        br.getSingleBuildArchitecture(),
+	})
+}
+
+// Creates the Build Pod objects. A multi-architecture build has a build pod
+// for each of its architectures, which pushes its image to a tag suffixed with
+// the architecture. Otherwise, this is the Build Pod.
+func (br buildRequestImpl) BuildPods() ([]*corev1.Pod, error) {
+	if !IsMultiArchBuild(br.opts.MachineOSBuild) {
+		return []*corev1.Pod{br.BuildPod()}, nil
+	}
+
+	pods := []*corev1.Pod{}
+
+	for _, arch := range GetBuildArchitectures(br.opts.MachineOSBuild) {
+		tag, err := GetArchImagePullspec(br.opts.MachineOSConfig.Status.CurrentImagePullspec, arch)
+		if err != nil {
+			return nil, fmt.Errorf("could not get %s image pullspec: %w", arch, err)
+		}
+
+		podLabels := br.getLabelsForObjectMeta()
+		podLabels[constants.BuildArchitectureLabelKey] = arch
+
+		pods = append(pods, br.toBuildahPod(buildahPodTarget{
+			name:            GetArchBuildPodName(br.opts.MachineOSBuild, arch),
+			digestConfigMap: GetArchDigestConfigMapName(br.opts.MachineOSBuild, arch),
+			tag:             tag,
+			labels:          podLabels,
+			nodeArch:        arch,
+		}))
+	}
+
+	return pods, nil
 }
 
 // Takes the configured secrets and creates an ephemeral clone of them, canonicalizing them, if needed.
@@ -86,6 +123,14 @@ func (br buildRequestImpl) Secrets() ([]*corev1.Secret, error) {
 		return nil, fmt.Errorf("could not canonicalize secret %s: %w", br.opts.BaseImagePullSecret.Name, err)
 	}
 
+	// Buildah only takes a single authfile for the build, which needs to be
+	// able to push to and pull from the build cache repository, too.
+	if cacheRepo := GetBuildCacheRepository(br.opts.MachineOSConfig); cacheRepo != "" {
+		if err := addRepositoryAuth(baseImagePullSecret, br.opts.FinalImagePushSecret, cacheRepo); err != nil {
+			return nil, fmt.Errorf("could not add build cache repository credentials: %w", err)
+		}
+	}
+
 	finalImagePushSecret, err := br.canonicalizeSecret(br.getFinalPushSecretName(), br.opts.FinalImagePushSecret)
 	if err != nil {
 		return nil, fmt.Errorf("could not canonicalize secret %s: %w", br.opts.FinalImagePushSecret.Name, err)
@@ -257,7 +302,21 @@ func (br buildRequestImpl) getReleaseVersion() string {
 // context enabled to allow us to use UID 1000, which maps to the UID within
 // the official Buildah image.
 // nolint:dupl // I don't want to deduplicate this yet since there are still some unknowns.
-func (br buildRequestImpl) toBuildahPod() *corev1.Pod {
+// Holds what differs between the build pods of a multi-architecture build.
+type buildahPodTarget struct {
+	// The name of the build pod.
+	name string
+	// The name of the ConfigMap the image digest is written to.
+	digestConfigMap string
+	// The pullspec the image is pushed to.
+	tag string
+	// The labels of the build pod and the digest ConfigMap.
+	labels map[string]string
+	// The architecture of the nodes the build pod may run on, if any.
+	nodeArch string
+}
+
+func (br buildRequestImpl) toBuildahPod(target buildahPodTarget) *corev1.Pod {
 	env := []corev1.EnvVar{
 		// How many times the build / push steps should be retried. In the future,
 		// this should be wired up to the MachineOSConfig or other higher-level
@@ -272,14 +331,14 @@ func (br buildRequestImpl) toBuildahPod() *corev1.Pod {
 		},
 		{
 			Name:  "DIGEST_CONFIGMAP_NAME",
-			Value: br.getDigestConfigMapName(),
+			Value: target.digestConfigMap,
 		},
 		{
 			Name: "DIGEST_CONFIGMAP_LABELS",
 			// Gets the labels for all objects created by imageBuildRequest, converts
 			// them into a string representation, and replaces the separating commas
 			// with spaces.
-			Value: strings.ReplaceAll(labels.Set(br.getLabelsForObjectMeta()).String(), ",", " "),
+			Value: strings.ReplaceAll(labels.Set(target.labels).String(), ",", " "),
 		},
 		{
 			Name:  "HOME",
@@ -287,7 +346,7 @@ func (br buildRequestImpl) toBuildahPod() *corev1.Pod {
 		},
 		{
 			Name:  "TAG",
-			Value: br.opts.MachineOSConfig.Status.CurrentImagePullspec,
+			Value: target.tag,
 		},
 		{
 			Name:  "BASE_IMAGE_PULL_CREDS",
@@ -416,6 +475,15 @@ func (br buildRequestImpl) toBuildahPod() *corev1.Pod {
 		},
 	}
 
+	// If the MachineOSConfig has a build cache repository, have Buildah reuse
+	// and store the build layers there.
+	if cacheRepo := GetBuildCacheRepository(br.opts.MachineOSConfig); cacheRepo != "" {
+		env = append(env, corev1.EnvVar{
+			Name:  "CACHE_REPOSITORY",
+			Value: cacheRepo,
+		})
+	}
+
 	// If the etc-pki-entitlement secret is found, mount it into the build pod.
 	if br.opts.HasEtcPkiEntitlementKeys {
 		opts := optsForEtcPkiEntitlements()
@@ -440,6 +508,18 @@ func (br buildRequestImpl) toBuildahPod() *corev1.Pod {
 		volumes = append(volumes, opts.volumeForSecret())
 	}
 
+	// The image is built for the architecture of the node the build pod runs
+	// on, so pin it to the architecture of the nodes which will use it.
+	var nodeSelector map[string]string
+	if target.nodeArch != "" {
+		nodeSelector = map[string]string{
+			corev1.LabelArchStable: target.nodeArch,
+		}
+	}
+
+	objectMeta := br.getObjectMeta(target.name)
+	objectMeta.Labels = target.labels
+
 	// TODO: We need pull creds with permissions to pull the base image. By
 	// default, none of the MCO pull secrets can directly pull it. We can use the
 	// pull-secret creds from openshift-config to do that, though we'll need to
@@ -450,9 +530,10 @@ func (br buildRequestImpl) toBuildahPod() *corev1.Pod {
 			APIVersion: "v1",
 			Kind:       "Pod",
 		},
-		ObjectMeta: br.getObjectMeta(br.getBuildName()),
+		ObjectMeta: objectMeta,
 		Spec: corev1.PodSpec{
 			RestartPolicy: corev1.RestartPolicyNever,
+			NodeSelector:  nodeSelector,
 			Containers: []corev1.Container{
 				{
 					// This container performs the image build / push process.
@@ -552,6 +633,16 @@ func (br buildRequestImpl) getDigestConfigMapName() string {
 	return GetDigestConfigMapName(br.opts.MachineOSBuild)
 }
 
+// Gets the architecture of a MachineOSBuild which is built for exactly one
+// architecture.
+func (br buildRequestImpl) getSingleBuildArchitecture() string {
+	if arches := GetBuildArchitectures(br.opts.MachineOSBuild); len(arches) == 1 {
+		return arches[0]
+	}
+
+	return ""
+}
+
 func (br buildRequestImpl) getBasePullSecretName() string {
 	return GetBasePullSecretName(br.opts.MachineOSBuild)
 }
@@ -111,6 +111,16 @@ func TestImageBuildRequest(t *testing.T) {
 			},
 			unexpectedContainerfileContents: expectedContents(),
 		},
+		{
+			name: "Has build cache repository",
+			optsFunc: func() BuildRequestOpts {
+				opts := getBuildRequestOpts()
+				opts.MachineOSConfig.Annotations = map[string]string{
+					constants.BuildCacheRepositoryAnnotationKey: "registry.hostname.com/org/cache",
+				}
+				return opts
+			},
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -166,8 +176,14 @@ func TestImageBuildRequest(t *testing.T) {
 				assert.True(t, constants.IsObjectCreatedByBuildController(object))
 			}
 
-			for _, secret := range secrets {
-				assertSecretInCorrectFormat(t, secret)
+			cacheRepo := GetBuildCacheRepository(opts.MachineOSConfig)
+			if cacheRepo != "" {
+				assert.Contains(t, string(secrets[0].Data[corev1.DockerConfigJsonKey]), cacheRepo)
+				assertSecretInCorrectFormat(t, secrets[1])
+			} else {
+				for _, secret := range secrets {
+					assertSecretInCorrectFormat(t, secret)
+				}
 			}
 
 			assert.Equal(t, secrets[0].Name, "base-rendered-worker-1")
@@ -211,6 +227,17 @@ func assertBuildPodIsCorrect(t *testing.T, buildPod *corev1.Pod, opts BuildReque
 		etcPkiEntitlementKeysOpts.volumeMount(),
 	)
 
+	cacheRepoEnvVar := corev1.EnvVar{
+		Name:  "CACHE_REPOSITORY",
+		Value: GetBuildCacheRepository(opts.MachineOSConfig),
+	}
+
+	if cacheRepoEnvVar.Value != "" {
+		assert.Contains(t, buildPod.Spec.Containers[1].Env, cacheRepoEnvVar)
+	} else {
+		assert.NotContains(t, buildPod.Spec.Containers[1].Env, cacheRepoEnvVar)
+	}
+
 	assert.Equal(t, buildPod.Spec.Containers[0].Image, mcoImagePullspec)
 	expectedPullspecs := []string{
 		"base-os-image-from-osimageurlconfig",
@@ -327,3 +354,85 @@ RUN rpm-ostree install && \
 		},
 	}
 }
+
+// Tests that a build pod is created for each architecture of a
+// multi-architecture build and that single-architecture builds are pinned to
+// their architecture.
+func TestBuildPods(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		architectures string
+		expectedPods  map[string]string
+		expectedTags  map[string]string
+	}{
+		{
+			name:         "No architectures",
+			expectedPods: map[string]string{"build-rendered-worker-1": ""},
+			expectedTags: map[string]string{"build-rendered-worker-1": "registry.hostname.com/org/repo:rendered-worker-1"},
+		},
+		{
+			name:          "Single architecture",
+			architectures: "arm64",
+			expectedPods:  map[string]string{"build-rendered-worker-1": "arm64"},
+			expectedTags:  map[string]string{"build-rendered-worker-1": "registry.hostname.com/org/repo:rendered-worker-1"},
+		},
+		{
+			name:          "Multiple architectures",
+			architectures: "amd64,arm64",
+			expectedPods: map[string]string{
+				"build-rendered-worker-1-amd64": "amd64",
+				"build-rendered-worker-1-arm64": "arm64",
+			},
+			expectedTags: map[string]string{
+				"build-rendered-worker-1-amd64": "registry.hostname.com/org/repo:rendered-worker-1-amd64",
+				"build-rendered-worker-1-arm64": "registry.hostname.com/org/repo:rendered-worker-1-arm64",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := getBuildRequestOpts()
+			if testCase.architectures != "" {
+				opts.MachineOSBuild.Annotations = map[string]string{
+					constants.BuildArchitecturesAnnotationKey: testCase.architectures,
+				}
+			}
+
+			buildPods, err := newBuildRequest(opts).BuildPods()
+			assert.NoError(t, err)
+			assert.Len(t, buildPods, len(testCase.expectedPods))
+
+			for _, buildPod := range buildPods {
+				arch, ok := testCase.expectedPods[buildPod.Name]
+				assert.True(t, ok, buildPod.Name)
+
+				assert.True(t, constants.OSBuildSelector().Matches(labels.Set(buildPod.Labels)))
+
+				if arch == "" {
+					assert.Empty(t, buildPod.Spec.NodeSelector)
+				} else {
+					assert.Equal(t, map[string]string{corev1.LabelArchStable: arch}, buildPod.Spec.NodeSelector)
+				}
+
+				digestConfigMapName := "digest-rendered-worker-1"
+				if len(testCase.expectedPods) > 1 {
+					digestConfigMapName += "-" + arch
+					assert.Equal(t, arch, buildPod.Labels[constants.BuildArchitectureLabelKey])
+				} else {
+					assert.NotContains(t, buildPod.Labels, constants.BuildArchitectureLabelKey)
+				}
+
+				for _, container := range buildPod.Spec.Containers {
+					assert.Contains(t, container.Env, corev1.EnvVar{Name: "TAG", Value: testCase.expectedTags[buildPod.Name]})
+					assert.Contains(t, container.Env, corev1.EnvVar{Name: "DIGEST_CONFIGMAP_NAME", Value: digestConfigMapName})
+				}
+			}
+		})
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
+
+	"github.com/distribution/reference"
 
 	corev1 "k8s.io/api/core/v1"
 
@@ -36,6 +40,107 @@ func GetDigestConfigMapName(mosb *mcfgv1alpha1.MachineOSBuild) string {
 	return fmt.Sprintf("digest-%s", getFieldFromMachineOSBuild(mosb))
 }
 
+// Computes the build pod name for a single architecture of a
+// multi-architecture build.
+func GetArchBuildPodName(mosb *mcfgv1alpha1.MachineOSBuild, arch string) string {
+	return fmt.Sprintf("%s-%s", GetBuildPodName(mosb), arch)
+}
+
+// Computes the digest configmap name for a single architecture of a
+// multi-architecture build.
+func GetArchDigestConfigMapName(mosb *mcfgv1alpha1.MachineOSBuild, arch string) string {
+	return fmt.Sprintf("%s-%s", GetDigestConfigMapName(mosb), arch)
+}
+
+// Gets the names of all of the build pods of a MachineOSBuild. This is a single
+// build pod unless the MachineOSBuild is built for multiple architectures.
+func GetBuildPodNames(mosb *mcfgv1alpha1.MachineOSBuild) []string {
+	if !IsMultiArchBuild(mosb) {
+		return []string{GetBuildPodName(mosb)}
+	}
+
+	names := []string{}
+	for _, arch := range GetBuildArchitectures(mosb) {
+		names = append(names, GetArchBuildPodName(mosb, arch))
+	}
+
+	return names
+}
+
+// Gets the names of all of the digest ConfigMaps of a MachineOSBuild. A
+// multi-architecture build has one for each of its architectures besides the
+// one for its manifest list.
+func GetDigestConfigMapNames(mosb *mcfgv1alpha1.MachineOSBuild) []string {
+	names := []string{GetDigestConfigMapName(mosb)}
+	if !IsMultiArchBuild(mosb) {
+		return names
+	}
+
+	for _, arch := range GetBuildArchitectures(mosb) {
+		names = append(names, GetArchDigestConfigMapName(mosb, arch))
+	}
+
+	return names
+}
+
+// Gets the architectures a MachineOSBuild is built for. This is empty for
+// MachineOSBuilds which do not target a specific architecture.
+func GetBuildArchitectures(mosb *mcfgv1alpha1.MachineOSBuild) []string {
+	arches := []string{}
+
+	for _, arch := range strings.Split(mosb.Annotations[constants.BuildArchitecturesAnnotationKey], ",") {
+		if arch = strings.TrimSpace(arch); arch != "" {
+			arches = append(arches, arch)
+		}
+	}
+
+	return arches
+}
+
+// Determines whether a MachineOSBuild is built for more than one architecture,
+// in which case its image is a manifest list.
+func IsMultiArchBuild(mosb *mcfgv1alpha1.MachineOSBuild) bool {
+	return len(GetBuildArchitectures(mosb)) > 1
+}
+
+// Computes the pullspec the image for a single architecture of a
+// multi-architecture build is pushed to by appending the architecture to the
+// tag of the final image pullspec.
+func GetArchImagePullspec(pullspec, arch string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(pullspec)
+	if err != nil {
+		return "", err
+	}
+
+	tag := "latest"
+	if tagged, ok := named.(reference.Tagged); ok {
+		tag = tagged.Tag()
+	}
+
+	archTagged, err := reference.WithTag(reference.TrimNamed(named), fmt.Sprintf("%s-%s", tag, arch))
+	if err != nil {
+		return "", err
+	}
+
+	return archTagged.String(), nil
+}
+
+// Computes the name of the ConfigMap tracking a build handed to an external
+// build system by the webhook image builder backend.
+func GetWebhookBuildConfigMapName(mosb *mcfgv1alpha1.MachineOSBuild) string {
+	return fmt.Sprintf("webhook-build-%s", getFieldFromMachineOSBuild(mosb))
+}
+
+// Computes the name of the ConfigMap retaining the logs of the build.
+func GetBuildLogsConfigMapName(mosb *mcfgv1alpha1.MachineOSBuild) string {
+	return fmt.Sprintf("build-logs-%s", getFieldFromMachineOSBuild(mosb))
+}
+
+// Computes the name of the pod verifying and signing the built image.
+func GetVerificationPodName(mosb *mcfgv1alpha1.MachineOSBuild) string {
+	return fmt.Sprintf("verify-%s", getFieldFromMachineOSBuild(mosb))
+}
+
 // Computes the base image pull secret name.
 func GetBasePullSecretName(mosb *mcfgv1alpha1.MachineOSBuild) string {
 	return fmt.Sprintf("base-%s", getFieldFromMachineOSBuild(mosb))
@@ -56,6 +161,80 @@ func ValidatePullSecret(secret *corev1.Secret) error {
 	return nil
 }
 
+// Gets the image repository a MachineOSConfig uses as a build layer cache, if any.
+func GetBuildCacheRepository(mosc *mcfgv1alpha1.MachineOSConfig) string {
+	return strings.TrimSpace(mosc.Annotations[constants.BuildCacheRepositoryAnnotationKey])
+}
+
+// Copies the credentials for the given image repository from the src pull
+// secret into the canonicalized dst pull secret, keyed by the repository so
+// that they do not replace the credentials dst already has for its registry.
+// Nothing is copied if src has no credentials for the repository.
+func addRepositoryAuth(dst, src *corev1.Secret, repo string) error {
+	named, err := reference.ParseNormalizedNamed(repo)
+	if err != nil {
+		return err
+	}
+
+	key, err := getPullSecretKey(src)
+	if err != nil {
+		return err
+	}
+
+	srcBytes, _, err := ctrlcommon.ConvertSecretToDockerconfigJSON(src.Data[key])
+	if err != nil {
+		return err
+	}
+
+	type dockerConfigJSON struct {
+		Auths map[string]json.RawMessage `json:"auths"`
+	}
+
+	srcConfig := dockerConfigJSON{}
+	if err := json.Unmarshal(srcBytes, &srcConfig); err != nil {
+		return err
+	}
+
+	dstConfig := dockerConfigJSON{}
+	if err := json.Unmarshal(dst.Data[corev1.DockerConfigJsonKey], &dstConfig); err != nil {
+		return err
+	}
+
+	// Look for the most specific credentials, from the repository itself up to
+	// its registry, the same way the containers auth file is looked up.
+	candidates := []string{}
+	for path := named.Name(); path != reference.Domain(named); path = path[:strings.LastIndex(path, "/")] {
+		candidates = append(candidates, path)
+	}
+	candidates = append(candidates, reference.Domain(named))
+	if reference.Domain(named) == "docker.io" {
+		candidates = append(candidates, "index.docker.io", "https://index.docker.io/v1/")
+	}
+
+	for _, candidate := range candidates {
+		auth, ok := srcConfig.Auths[candidate]
+		if !ok {
+			continue
+		}
+
+		if dstConfig.Auths == nil {
+			dstConfig.Auths = map[string]json.RawMessage{}
+		}
+
+		dstConfig.Auths[named.Name()] = auth
+
+		out, err := json.Marshal(dstConfig)
+		if err != nil {
+			return err
+		}
+
+		dst.Data[corev1.DockerConfigJsonKey] = out
+		return nil
+	}
+
+	return nil
+}
+
 // Gets the field from the MachineOSBuild that is used for naming the ephemeral
 // build objects. For now, we're using the DesiredConfig name from the
 // MachineConfig, but arguably, we should be using the name of the
@@ -142,3 +142,101 @@ func TestCanonicalizePullSecret(t *testing.T) {
 		})
 	}
 }
+
+// Tests that the credentials for a build cache repository are copied from the
+// push secret without replacing the ones the pull secret already has.
+func TestAddRepositoryAuth(t *testing.T) {
+	t.Parallel()
+
+	pullSecret := `{"auths":{"registry.hostname.com":{"auth":"cHVsbA=="}}}`
+
+	testCases := []struct {
+		name         string
+		pushSecret   string
+		repo         string
+		expectedJSON string
+	}{
+		{
+			name:         "registry credentials",
+			pushSecret:   `{"auths":{"registry.hostname.com":{"auth":"cHVzaA=="}}}`,
+			repo:         "registry.hostname.com/org/cache",
+			expectedJSON: `{"auths":{"registry.hostname.com":{"auth":"cHVsbA=="},"registry.hostname.com/org/cache":{"auth":"cHVzaA=="}}}`,
+		},
+		{
+			name:         "most specific credentials",
+			pushSecret:   `{"auths":{"registry.hostname.com":{"auth":"cHVzaA=="},"registry.hostname.com/org":{"auth":"b3Jn"}}}`,
+			repo:         "registry.hostname.com/org/cache",
+			expectedJSON: `{"auths":{"registry.hostname.com":{"auth":"cHVsbA=="},"registry.hostname.com/org/cache":{"auth":"b3Jn"}}}`,
+		},
+		{
+			name:         "legacy push secret",
+			pushSecret:   `{"quay.io":{"auth":"cXVheQ=="}}`,
+			repo:         "quay.io/org/cache",
+			expectedJSON: `{"auths":{"registry.hostname.com":{"auth":"cHVsbA=="},"quay.io/org/cache":{"auth":"cXVheQ=="}}}`,
+		},
+		{
+			name:         "Docker Hub credentials",
+			pushSecret:   `{"auths":{"https://index.docker.io/v1/":{"auth":"aHVi"}}}`,
+			repo:         "org/cache",
+			expectedJSON: `{"auths":{"registry.hostname.com":{"auth":"cHVsbA=="},"docker.io/org/cache":{"auth":"aHVi"}}}`,
+		},
+		{
+			name:         "no credentials",
+			pushSecret:   `{"auths":{"quay.io":{"auth":"cXVheQ=="}}}`,
+			repo:         "registry.hostname.com/org/cache",
+			expectedJSON: pullSecret,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			dst := &corev1.Secret{
+				Type: corev1.SecretTypeDockerConfigJson,
+				Data: map[string][]byte{
+					corev1.DockerConfigJsonKey: []byte(pullSecret),
+				},
+			}
+
+			src := &corev1.Secret{
+				Type: corev1.SecretTypeDockerConfigJson,
+				Data: map[string][]byte{
+					corev1.DockerConfigJsonKey: []byte(testCase.pushSecret),
+				},
+			}
+
+			assert.NoError(t, addRepositoryAuth(dst, src, testCase.repo))
+			assert.JSONEq(t, testCase.expectedJSON, string(dst.Data[corev1.DockerConfigJsonKey]))
+		})
+	}
+}
+
+func TestGetArchImagePullspec(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		pullspec string
+		expected string
+	}{
+		{
+			pullspec: "registry.hostname.com/org/repo:rendered-worker-1",
+			expected: "registry.hostname.com/org/repo:rendered-worker-1-arm64",
+		},
+		{
+			pullspec: "registry.hostname.com/org/repo",
+			expected: "registry.hostname.com/org/repo:latest-arm64",
+		},
+		{
+			pullspec: "quay.io/org/repo@sha256:628e4e8f0a78d91015c6cebeee95931ae2e8defe5dfb4ced4a82830e08937573",
+			expected: "quay.io/org/repo:latest-arm64",
+		},
+	}
+
+	for _, testCase := range testCases {
+		out, err := GetArchImagePullspec(testCase.pullspec, "arm64")
+		assert.NoError(t, err)
+		assert.Equal(t, testCase.expected, out)
+	}
+}
@@ -9,6 +9,7 @@ import (
 type BuildRequest interface {
 	Opts() BuildRequestOpts
 	BuildPod() *corev1.Pod
+	BuildPods() ([]*corev1.Pod, error)
 	Secrets() ([]*corev1.Secret, error)
 	ConfigMaps() ([]*corev1.ConfigMap, error)
 }
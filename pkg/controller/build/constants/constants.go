@@ -50,3 +50,84 @@ const (
 	EtcYumReposDAnnotationKey      = entitlementsAnnotationKeyBase + EtcYumReposDConfigMapName
 	EtcPkiRpmGpgAnnotationKey      = entitlementsAnnotationKeyBase + EtcPkiRpmGpgSecretName
 )
+
+// Annotations on a MachineOSConfig which select and configure the image
+// builder backend. The imageBuilderType field of the MachineOSConfig only
+// allows PodImageBuilder, so the backend performing its builds is selected
+// with an annotation instead.
+const (
+	ImageBuilderBackendAnnotationKey = "machineconfiguration.openshift.io/image-builder-backend"
+
+	// Job backend options
+	JobTTLSecondsAnnotationKey   = "machineconfiguration.openshift.io/image-builder-job-ttl-seconds"
+	JobBackoffLimitAnnotationKey = "machineconfiguration.openshift.io/image-builder-job-backoff-limit"
+	JobRetriesAnnotationKey      = "machineconfiguration.openshift.io/image-builder-job-retries"
+
+	// Webhook backend options
+	WebhookURLAnnotationKey    = "machineconfiguration.openshift.io/image-builder-webhook-url"
+	WebhookSecretAnnotationKey = "machineconfiguration.openshift.io/image-builder-webhook-secret"
+)
+
+// Label applied to the ConfigMaps which track the builds handed to an
+// external build system by the webhook image builder backend.
+const (
+	WebhookBuildLabelKey = "machineconfiguration.openshift.io/image-builder-webhook-build"
+)
+
+// Annotation on a MachineOSConfig naming the image repository its builds use as
+// a layer cache. Builds are not cached when it is unset.
+const (
+	BuildCacheRepositoryAnnotationKey = "machineconfiguration.openshift.io/build-cache-repository"
+)
+
+// Annotations added to a MachineOSBuild once its build succeeded.
+const (
+	// How many of the build steps were reused from the layer cache.
+	BuildCacheHitsAnnotationKey = "machineconfiguration.openshift.io/build-cache-hits"
+	// How many build steps could have been reused from the layer cache.
+	BuildCacheableStepsAnnotationKey = "machineconfiguration.openshift.io/build-cacheable-steps"
+)
+
+// Annotation on a MachineOSBuild listing the architectures of the nodes in its
+// MachineConfigPool, separated by commas. An image is built for each of them
+// and assembled into a manifest list when there is more than one.
+const (
+	BuildArchitecturesAnnotationKey = "machineconfiguration.openshift.io/build-architectures"
+)
+
+// Label applied to the build objects of a single architecture of a
+// multi-architecture build.
+const (
+	BuildArchitectureLabelKey = "machineconfiguration.openshift.io/build-architecture"
+)
+
+// Annotations on a MachineOSConfig which configure the verification and
+// signing of its built images. A built image is only rolled out once it passed
+// verification and was signed.
+const (
+	// Shell command which verifies the built image. It runs inside the built
+	// image unless a verifier image is given.
+	BuildVerifyCommandAnnotationKey = "machineconfiguration.openshift.io/build-verify-command"
+	// Image the verify command runs in instead of the built image.
+	BuildVerifyImageAnnotationKey = "machineconfiguration.openshift.io/build-verify-image"
+	// Name of the Secret holding the cosign key the built image is signed with.
+	BuildSigningSecretAnnotationKey = "machineconfiguration.openshift.io/build-signing-secret"
+	// Image providing the cosign binary.
+	BuildSigningImageAnnotationKey = "machineconfiguration.openshift.io/build-signing-image"
+)
+
+// Label applied to the pods verifying and signing a built image.
+const (
+	BuildVerificationLabelKey = "machineconfiguration.openshift.io/build-verification"
+)
+
+// Annotation on a MachineOSBuild naming the ConfigMap which retains the logs
+// of its build after the build objects were cleaned up.
+const (
+	BuildLogsConfigMapAnnotationKey = "machineconfiguration.openshift.io/build-logs-configmap"
+)
+
+// Label applied to the ConfigMaps retaining build logs.
+const (
+	BuildLogsLabelKey = "machineconfiguration.openshift.io/build-logs"
+)
@@ -40,6 +40,18 @@ func EphemeralBuildObjectSelector() labels.Selector {
 	})
 }
 
+// Returns a selector for the ConfigMaps tracking the builds handed to an
+// external build system by the webhook image builder backend.
+func WebhookBuildSelector() labels.Selector {
+	return labelsToSelector([]string{
+		EphemeralBuildObjectLabelKey,
+		OnClusterLayeringLabelKey,
+		RenderedMachineConfigLabelKey,
+		TargetMachineConfigPoolLabelKey,
+		WebhookBuildLabelKey,
+	})
+}
+
 func EphemeralBuildObjectSelectorForSpecificBuild(mosb *mcfgv1alpha1.MachineOSBuild, mosc *mcfgv1alpha1.MachineOSConfig) (labels.Selector, error) {
 	selector := labelsToSelector([]string{
 		EphemeralBuildObjectLabelKey,
@@ -154,6 +154,18 @@ func validateMachineOSConfig(mcpGetter func(string) (*mcfgv1.MachineConfigPool,
 		return fmt.Errorf("could not validate renderdImagePushspec %s for MachineOSConfig %s: %w", mosc.Spec.BuildInputs.RenderedImagePushspec, mosc.Name, err)
 	}
 
+	if err := validateBuildCacheRepository(mosc); err != nil {
+		return fmt.Errorf("could not validate build cache repository for MachineOSConfig %s: %w", mosc.Name, err)
+	}
+
+	if err := validateImageBuilderBackend(secretGetter, mosc); err != nil {
+		return fmt.Errorf("could not validate image builder backend for MachineOSConfig %s: %w", mosc.Name, err)
+	}
+
+	if err := validateBuildVerification(secretGetter, mosc); err != nil {
+		return fmt.Errorf("could not validate build verification for MachineOSConfig %s: %w", mosc.Name, err)
+	}
+
 	return nil
 }
 
@@ -6,6 +6,7 @@ import (
 
 	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
 	mcfgv1alpha1 "github.com/openshift/api/machineconfiguration/v1alpha1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -82,6 +83,152 @@ func TestValidateOnClusterBuildConfig(t *testing.T) {
 			},
 			errExpected: true,
 		},
+		{
+			name: "build cache repository",
+			mosc: func() *mcfgv1alpha1.MachineOSConfig {
+				mosc := newMosc()
+				mosc.Annotations = map[string]string{
+					constants.BuildCacheRepositoryAnnotationKey: "registry.hostname.com/org/cache",
+				}
+				return mosc
+			},
+		},
+		{
+			name: "tagged build cache repository",
+			mosc: func() *mcfgv1alpha1.MachineOSConfig {
+				mosc := newMosc()
+				mosc.Annotations = map[string]string{
+					constants.BuildCacheRepositoryAnnotationKey: "registry.hostname.com/org/cache:latest",
+				}
+				return mosc
+			},
+			errExpected: true,
+		},
+		{
+			name: "build cache repository is the rendered image repository",
+			mosc: func() *mcfgv1alpha1.MachineOSConfig {
+				mosc := newMosc()
+				mosc.Annotations = map[string]string{
+					constants.BuildCacheRepositoryAnnotationKey: "registry.hostname.com/org/repo",
+				}
+				return mosc
+			},
+			errExpected: true,
+		},
+		{
+			name: "job image builder backend",
+			mosc: func() *mcfgv1alpha1.MachineOSConfig {
+				mosc := newMosc()
+				mosc.Annotations = map[string]string{
+					constants.ImageBuilderBackendAnnotationKey: string(JobImageBuilderBackend),
+					constants.JobBackoffLimitAnnotationKey:     "0",
+				}
+				return mosc
+			},
+		},
+		{
+			name: "unknown image builder backend",
+			mosc: func() *mcfgv1alpha1.MachineOSConfig {
+				mosc := newMosc()
+				mosc.Annotations = map[string]string{
+					constants.ImageBuilderBackendAnnotationKey: "Kaniko",
+				}
+				return mosc
+			},
+			errExpected: true,
+		},
+		{
+			name: "invalid job retries",
+			mosc: func() *mcfgv1alpha1.MachineOSConfig {
+				mosc := newMosc()
+				mosc.Annotations = map[string]string{
+					constants.ImageBuilderBackendAnnotationKey: string(JobImageBuilderBackend),
+					constants.JobRetriesAnnotationKey:          "-1",
+				}
+				return mosc
+			},
+			errExpected: true,
+		},
+		{
+			name: "webhook image builder backend",
+			mosc: func() *mcfgv1alpha1.MachineOSConfig {
+				mosc := newMosc()
+				mosc.Annotations = map[string]string{
+					constants.ImageBuilderBackendAnnotationKey: string(WebhookImageBuilderBackend),
+					constants.WebhookURLAnnotationKey:          "https://builds.example.com/api/builds",
+				}
+				return mosc
+			},
+		},
+		{
+			name: "webhook URL without TLS",
+			mosc: func() *mcfgv1alpha1.MachineOSConfig {
+				mosc := newMosc()
+				mosc.Annotations = map[string]string{
+					constants.ImageBuilderBackendAnnotationKey: string(WebhookImageBuilderBackend),
+					constants.WebhookURLAnnotationKey:          "http://builds.example.com/api/builds",
+				}
+				return mosc
+			},
+			errExpected: true,
+		},
+		{
+			name: "missing webhook secret",
+			mosc: func() *mcfgv1alpha1.MachineOSConfig {
+				mosc := newMosc()
+				mosc.Annotations = map[string]string{
+					constants.ImageBuilderBackendAnnotationKey: string(WebhookImageBuilderBackend),
+					constants.WebhookURLAnnotationKey:          "https://builds.example.com/api/builds",
+					constants.WebhookSecretAnnotationKey:       "webhook-secret",
+				}
+				return mosc
+			},
+			errExpected: true,
+		},
+		{
+			name: "build verification",
+			mosc: func() *mcfgv1alpha1.MachineOSConfig {
+				mosc := newMosc()
+				mosc.Annotations = map[string]string{
+					constants.BuildVerifyCommandAnnotationKey: "rpm -V kernel",
+				}
+				return mosc
+			},
+		},
+		{
+			name: "verifier image without verify command",
+			mosc: func() *mcfgv1alpha1.MachineOSConfig {
+				mosc := newMosc()
+				mosc.Annotations = map[string]string{
+					constants.BuildVerifyImageAnnotationKey: "registry.hostname.com/org/verifier:latest",
+				}
+				return mosc
+			},
+			errExpected: true,
+		},
+		{
+			name: "signing secret without signing image",
+			mosc: func() *mcfgv1alpha1.MachineOSConfig {
+				mosc := newMosc()
+				mosc.Annotations = map[string]string{
+					constants.BuildSigningSecretAnnotationKey: "signing-secret",
+				}
+				return mosc
+			},
+			errExpected: true,
+		},
+		{
+			name: "missing signing secret",
+			mosc: func() *mcfgv1alpha1.MachineOSConfig {
+				mosc := newMosc()
+				mosc.Annotations = map[string]string{
+					constants.BuildSigningSecretAnnotationKey: "signing-secret",
+					constants.BuildSigningImageAnnotationKey:  "registry.hostname.com/org/cosign:latest",
+				}
+				return mosc
+			},
+			errExpected: true,
+		},
 	}
 
 	for _, testCase := range testCases {
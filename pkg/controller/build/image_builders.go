@@ -0,0 +1,173 @@
+package build
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	mcfgv1alpha1 "github.com/openshift/api/machineconfiguration/v1alpha1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ImageBuilderBackend is the backend which performs the builds of a
+// MachineOSConfig, as selected by its image builder backend annotation.
+type ImageBuilderBackend string
+
+const (
+	// Runs the build in a standalone pod. This is the default.
+	PodImageBuilderBackend ImageBuilderBackend = "Pod"
+	// Runs the build pod from a Kubernetes Job, which retries failed pods.
+	JobImageBuilderBackend ImageBuilderBackend = "Job"
+	// Hands the build to an external build system over HTTPS.
+	WebhookImageBuilderBackend ImageBuilderBackend = "Webhook"
+)
+
+// Defaults for the Job image builder backend options.
+const (
+	defaultJobTTLSeconds   int32 = 24 * 60 * 60
+	defaultJobBackoffLimit int32 = 3
+	defaultJobRetries      int32 = 3
+)
+
+// Keys of the secret referenced by the webhook backend secret annotation.
+const (
+	// Bearer token sent to the external build system.
+	webhookTokenSecretKey = "token"
+	// PEM-encoded CA bundle used to verify the external build system.
+	webhookCASecretKey = "ca.crt"
+)
+
+// Gets the image builder backend selected by a MachineOSConfig.
+func getImageBuilderBackend(mosc *mcfgv1alpha1.MachineOSConfig) (ImageBuilderBackend, error) {
+	backend := ImageBuilderBackend(mosc.Annotations[constants.ImageBuilderBackendAnnotationKey])
+	switch backend {
+	case "":
+		return PodImageBuilderBackend, nil
+	case PodImageBuilderBackend, JobImageBuilderBackend, WebhookImageBuilderBackend:
+		return backend, nil
+	}
+
+	return "", &ErrInvalidImageBuilder{
+		Message:     fmt.Sprintf("unknown image builder backend %q, valid backends are %s, %s and %s", backend, PodImageBuilderBackend, JobImageBuilderBackend, WebhookImageBuilderBackend),
+		InvalidType: string(backend),
+	}
+}
+
+// Options of the Job image builder backend.
+type jobBuilderOpts struct {
+	// How long a finished build Job is kept around.
+	ttlSeconds int32
+	// How many times a failed build pod is replaced.
+	backoffLimit int32
+	// How many times the build and push steps are retried within a build pod.
+	retries int32
+}
+
+// Gets the Job image builder backend options of a MachineOSConfig.
+func getJobBuilderOpts(mosc *mcfgv1alpha1.MachineOSConfig) (jobBuilderOpts, error) {
+	opts := jobBuilderOpts{
+		ttlSeconds:   defaultJobTTLSeconds,
+		backoffLimit: defaultJobBackoffLimit,
+		retries:      defaultJobRetries,
+	}
+
+	fields := map[string]*int32{
+		constants.JobTTLSecondsAnnotationKey:   &opts.ttlSeconds,
+		constants.JobBackoffLimitAnnotationKey: &opts.backoffLimit,
+		constants.JobRetriesAnnotationKey:      &opts.retries,
+	}
+
+	for key, field := range fields {
+		val, ok := mosc.Annotations[key]
+		if !ok {
+			continue
+		}
+
+		parsed, err := strconv.ParseInt(val, 10, 32)
+		if err != nil || parsed < 0 {
+			return opts, fmt.Errorf("invalid value %q for annotation %s: must be a non-negative integer", val, key)
+		}
+
+		*field = int32(parsed)
+	}
+
+	// The build script needs at least one attempt.
+	if opts.retries == 0 {
+		return opts, fmt.Errorf("invalid value for annotation %s: must be at least 1", constants.JobRetriesAnnotationKey)
+	}
+
+	return opts, nil
+}
+
+// Options of the webhook image builder backend.
+type webhookBuilderOpts struct {
+	// The endpoint of the external build system.
+	url string
+	// The name of the secret holding the token and CA bundle, if any.
+	secretName string
+}
+
+// Gets the webhook image builder backend options of a MachineOSConfig.
+func getWebhookBuilderOpts(mosc *mcfgv1alpha1.MachineOSConfig) (webhookBuilderOpts, error) {
+	opts := webhookBuilderOpts{
+		url:        mosc.Annotations[constants.WebhookURLAnnotationKey],
+		secretName: mosc.Annotations[constants.WebhookSecretAnnotationKey],
+	}
+
+	if opts.url == "" {
+		return opts, fmt.Errorf("annotation %s is required for the %s image builder backend", constants.WebhookURLAnnotationKey, WebhookImageBuilderBackend)
+	}
+
+	parsed, err := url.Parse(opts.url)
+	if err != nil {
+		return opts, fmt.Errorf("invalid webhook URL %q: %w", opts.url, err)
+	}
+
+	// The build context contains the rendered MachineConfig, so it must not be
+	// sent in the clear.
+	if parsed.Scheme != "https" || parsed.Host == "" {
+		return opts, fmt.Errorf("invalid webhook URL %q: must be an https:// URL", opts.url)
+	}
+
+	return opts, nil
+}
+
+// Validates the image builder backend selected by a MachineOSConfig and its options.
+func validateImageBuilderBackend(secretGetter func(string) (*corev1.Secret, error), mosc *mcfgv1alpha1.MachineOSConfig) error {
+	backend, err := getImageBuilderBackend(mosc)
+	if err != nil {
+		return err
+	}
+
+	switch backend {
+	case JobImageBuilderBackend:
+		_, err := getJobBuilderOpts(mosc)
+		return err
+	case WebhookImageBuilderBackend:
+		opts, err := getWebhookBuilderOpts(mosc)
+		if err != nil {
+			return err
+		}
+
+		if opts.secretName == "" {
+			return nil
+		}
+
+		secret, err := secretGetter(opts.secretName)
+		if err != nil && k8serrors.IsNotFound(err) {
+			return fmt.Errorf("webhook secret %s from %s is not found. Did you use the right secret name?", opts.secretName, mosc.Name)
+		}
+
+		if err != nil {
+			return fmt.Errorf("could not get webhook secret %s for MachineOSConfig %s: %w", opts.secretName, mosc.Name, err)
+		}
+
+		if len(secret.Data[webhookTokenSecretKey]) == 0 && len(secret.Data[webhookCASecretKey]) == 0 {
+			return fmt.Errorf("webhook secret %s has neither a %q nor a %q key", opts.secretName, webhookTokenSecretKey, webhookCASecretKey)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,410 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	mcfgv1alpha1 "github.com/openshift/api/machineconfiguration/v1alpha1"
+	"github.com/openshift/client-go/machineconfiguration/clientset/versioned/scheme"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/buildrequest"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	aggerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreclientsetv1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	batchlistersv1 "k8s.io/client-go/listers/batch/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// JobBuildController runs the build pod from a Kubernetes Job. Unlike a
+// standalone build pod, the Job replaces build pods which fail or are evicted
+// up to its backoff limit.
+type JobBuildController struct {
+	*Clients
+	*informers
+
+	eventRecorder record.EventRecorder
+
+	// The function to call whenever we've encountered a build Job. This
+	// function is responsible for examining the build Job to determine what
+	// state its in and map that state to the appropriate MachineOSBuild object.
+	jobHandler func(*batchv1.Job) error
+
+	syncHandler func(job string) error
+	enqueueJob  func(*batchv1.Job)
+
+	jobLister batchlistersv1.JobLister
+
+	jobListerSynced cache.InformerSynced
+
+	queue workqueue.TypedRateLimitingInterface[string]
+
+	config BuildControllerConfig
+}
+
+var _ ImageBuilder = (*JobBuildController)(nil)
+
+// Returns a new Job build controller.
+func newJobBuildController(
+	ctrlConfig BuildControllerConfig,
+	clients *Clients,
+	jobHandler func(*batchv1.Job) error,
+) *JobBuildController {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(klog.Infof)
+	eventBroadcaster.StartRecordingToSink(&coreclientsetv1.EventSinkImpl{Interface: clients.kubeclient.CoreV1().Events("")})
+
+	ctrl := &JobBuildController{
+		Clients:       clients,
+		informers:     newInformers(clients),
+		eventRecorder: eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "machineosbuilder-jobbuildcontroller"}),
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "machineosbuilder-jobbuildcontroller"}),
+		config:     ctrlConfig,
+		jobHandler: jobHandler,
+	}
+
+	ctrl.jobInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ctrl.addJob,
+		UpdateFunc: ctrl.updateJob,
+		DeleteFunc: ctrl.deleteJob,
+	})
+
+	ctrl.jobLister = ctrl.jobInformer.Lister()
+
+	ctrl.jobListerSynced = ctrl.jobInformer.Informer().HasSynced
+
+	ctrl.syncHandler = ctrl.syncJob
+	ctrl.enqueueJob = ctrl.enqueueDefault
+
+	return ctrl
+}
+
+// enqueueDefault enqueues a Job after the configured update delay.
+func (ctrl *JobBuildController) enqueueDefault(job *batchv1.Job) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(job)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("Couldn't get key for object %#v: %v", job, err))
+		return
+	}
+
+	ctrl.queue.AddAfter(key, ctrl.config.UpdateDelay)
+}
+
+// Syncs Jobs.
+func (ctrl *JobBuildController) syncJob(key string) error { //nolint:dupl // This does have commonality with the PodBuildController.
+	start := time.Now()
+	defer func() {
+		klog.Infof("Finished syncing job %s: %s", key, time.Since(start))
+	}()
+	klog.Infof("Started syncing job %s", key)
+
+	_, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	job, err := ctrl.jobLister.Jobs(ctrlcommon.MCONamespace).Get(name)
+	if k8serrors.IsNotFound(err) {
+		klog.V(2).Infof("Job %v has been deleted", key)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	job, err = ctrl.kubeclient.BatchV1().Jobs(ctrlcommon.MCONamespace).Get(context.TODO(), job.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if !hasAllRequiredOSBuildLabels(job.Labels) {
+		klog.Infof("Ignoring non-build job %s", job.Name)
+		return nil
+	}
+
+	if err := ctrl.jobHandler(job); err != nil {
+		return fmt.Errorf("unable to update with build job status: %w", err)
+	}
+
+	klog.Infof("Updated MachineOSBuild with build job status. Build job %s is %s", job.Name, getBuildJobState(job))
+
+	return nil
+}
+
+// Starts the Job Build Controller.
+func (ctrl *JobBuildController) Run(ctx context.Context, workers int) {
+	defer utilruntime.HandleCrash()
+	defer ctrl.queue.ShutDown()
+
+	ctrl.informers.start(ctx)
+
+	if !cache.WaitForCacheSync(ctx.Done(), ctrl.jobListerSynced) {
+		return
+	}
+
+	klog.Info("Starting MachineOSBuilder-JobBuildController")
+	defer klog.Info("Shutting down MachineOSBuilder-JobBuildController")
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(ctrl.worker, time.Second, ctx.Done())
+	}
+
+	<-ctx.Done()
+}
+
+// Deletes the underlying build Job along with its pods.
+func (ctrl *JobBuildController) DeleteBuildObject(mosb *mcfgv1alpha1.MachineOSBuild, _ *mcfgv1alpha1.MachineOSConfig) error {
+	propagationPolicy := metav1.DeletePropagationBackground
+
+	funcs := []func() error{}
+
+	for _, name := range buildrequest.GetBuildPodNames(mosb) {
+		name := name
+		funcs = append(funcs, func() error {
+			return ignoreIsNotFoundErr(ctrl.kubeclient.BatchV1().Jobs(ctrlcommon.MCONamespace).Delete(context.TODO(), name, metav1.DeleteOptions{PropagationPolicy: &propagationPolicy}))
+		})
+	}
+
+	for _, name := range buildrequest.GetDigestConfigMapNames(mosb) {
+		name := name
+		funcs = append(funcs, func() error {
+			return ignoreIsNotFoundErr(ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Delete(context.TODO(), name, metav1.DeleteOptions{}))
+		})
+	}
+
+	return aggerrors.AggregateGoroutines(funcs...)
+}
+
+// Determines if a build is currently running by looking for a corresponding Job.
+func (ctrl *JobBuildController) IsBuildRunning(mosb *mcfgv1alpha1.MachineOSBuild, _ *mcfgv1alpha1.MachineOSConfig) (bool, error) {
+	for _, name := range buildrequest.GetBuildPodNames(mosb) {
+		_, err := ctrl.kubeclient.BatchV1().Jobs(ctrlcommon.MCONamespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err == nil {
+			return true, nil
+		}
+
+		if !k8serrors.IsNotFound(err) {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// Starts a new build Job, assuming one is not found first. In that case, it
+// returns an object reference to the preexisting build Job. Multi-architecture
+// builds start a build Job for each architecture and return an object
+// reference to the first one.
+func (ctrl *JobBuildController) StartBuild(ibr buildrequest.BuildRequest) (*corev1.ObjectReference, error) {
+	ibrOpts := ibr.Opts()
+
+	targetMC := ibrOpts.MachineOSBuild.Spec.DesiredConfig.Name
+
+	if !strings.HasPrefix(targetMC, "rendered-") {
+		return nil, fmt.Errorf("%s is not a rendered MachineConfig", targetMC)
+	}
+
+	jobOpts, err := getJobBuilderOpts(ibrOpts.MachineOSConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	buildPods, err := ibr.BuildPods()
+	if err != nil {
+		return nil, err
+	}
+
+	var objRef *corev1.ObjectReference
+
+	for _, buildPod := range buildPods {
+		jobRef, err := ctrl.startBuildJob(ibrOpts, newBuildJob(buildPod, jobOpts))
+		if err != nil {
+			return nil, err
+		}
+
+		if objRef == nil {
+			objRef = jobRef
+		}
+	}
+
+	return objRef, nil
+}
+
+// Starts a single build Job, assuming one is not found first.
+func (ctrl *JobBuildController) startBuildJob(ibrOpts buildrequest.BuildRequestOpts, buildJob *batchv1.Job) (*corev1.ObjectReference, error) {
+	job, err := ctrl.kubeclient.BatchV1().Jobs(ctrlcommon.MCONamespace).Get(context.TODO(), buildJob.Name, metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	if job != nil && err == nil && hasAllRequiredOSBuildLabels(job.Labels) {
+		klog.Infof("Found preexisting build job (%s) for pool %s", job.Name, ibrOpts.MachineOSConfig.Spec.MachineConfigPool.Name)
+		return toObjectRef(job), nil
+	}
+
+	klog.Infof("Starting build job %s for pool %s", buildJob.Name, ibrOpts.MachineOSConfig.Spec.MachineConfigPool.Name)
+
+	job, err = ctrl.kubeclient.BatchV1().Jobs(ctrlcommon.MCONamespace).Create(context.TODO(), buildJob, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not create build job: %w", err)
+	}
+
+	klog.Infof("Build started for pool %s in %s!", ibrOpts.MachineOSConfig.Spec.MachineConfigPool.Name, job.Name)
+
+	return toObjectRef(job), nil
+}
+
+// Fires whenever a new Job is created.
+func (ctrl *JobBuildController) addJob(obj interface{}) {
+	job := obj.(*batchv1.Job).DeepCopy()
+	if hasAllRequiredOSBuildLabels(job.Labels) {
+		klog.V(4).Infof("Adding build job %s", job.Name)
+		ctrl.enqueueJob(job)
+	}
+}
+
+// Fires whenever a Job is updated.
+func (ctrl *JobBuildController) updateJob(_, curObj interface{}) {
+	curJob := curObj.(*batchv1.Job).DeepCopy()
+	if hasAllRequiredOSBuildLabels(curJob.Labels) {
+		klog.V(4).Infof("Updating build job %s", curJob.Name)
+		ctrl.enqueueJob(curJob)
+	}
+}
+
+// Fires whenever a Job is deleted.
+func (ctrl *JobBuildController) deleteJob(obj interface{}) {
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		return
+	}
+	job = job.DeepCopy()
+	klog.V(4).Infof("Deleting Job %s. Is build job? %v", job.Name, hasAllRequiredOSBuildLabels(job.Labels))
+	ctrl.enqueueJob(job)
+}
+
+func (ctrl *JobBuildController) handleErr(err error, key string) {
+	if err == nil {
+		ctrl.queue.Forget(key)
+		return
+	}
+
+	if ctrl.queue.NumRequeues(key) < ctrl.config.MaxRetries {
+		klog.V(2).Infof("Error syncing job %v: %v", key, err)
+		ctrl.queue.AddRateLimited(key)
+		return
+	}
+
+	utilruntime.HandleError(err)
+	klog.V(2).Infof("Dropping job %q out of the queue: %v", key, err)
+	ctrl.queue.Forget(key)
+	ctrl.queue.AddAfter(key, 1*time.Minute)
+}
+
+// worker runs a worker thread that just dequeues items, processes them, and marks them done.
+// It enforces that the syncHandler is never invoked concurrently with the same key.
+func (ctrl *JobBuildController) worker() {
+	for ctrl.processNextWorkItem() {
+	}
+}
+
+func (ctrl *JobBuildController) processNextWorkItem() bool {
+	key, quit := ctrl.queue.Get()
+	if quit {
+		return false
+	}
+	defer ctrl.queue.Done(key)
+
+	err := ctrl.syncHandler(key)
+	ctrl.handleErr(err, key)
+
+	return true
+}
+
+// Wraps the build pod in a Job with the given options. The image-build
+// container becomes an init container so that a failed build fails the pod,
+// which the Job then replaces, instead of leaving the wait-for-done container
+// waiting forever.
+func newBuildJob(pod *corev1.Pod, opts jobBuilderOpts) *batchv1.Job {
+	podSpec := *pod.Spec.DeepCopy()
+	podSpec.InitContainers = nil
+	podSpec.Containers = nil
+
+	retries := strconv.Itoa(int(opts.retries))
+
+	for _, container := range pod.Spec.Containers {
+		container := *container.DeepCopy()
+		for i := range container.Env {
+			if container.Env[i].Name == "MAX_RETRIES" {
+				container.Env[i].Value = retries
+			}
+		}
+
+		if container.Name == "image-build" {
+			podSpec.InitContainers = append(podSpec.InitContainers, container)
+		} else {
+			podSpec.Containers = append(podSpec.Containers, container)
+		}
+	}
+
+	// Jobs only allow the Never and OnFailure restart policies.
+	podSpec.RestartPolicy = corev1.RestartPolicyNever
+
+	backoffLimit := opts.backoffLimit
+	ttlSeconds := opts.ttlSeconds
+
+	return &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+		},
+		ObjectMeta: *pod.ObjectMeta.DeepCopy(),
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			TTLSecondsAfterFinished: &ttlSeconds,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      pod.Labels,
+					Annotations: pod.Annotations,
+				},
+				Spec: podSpec,
+			},
+		},
+	}
+}
+
+// Maps the status of a build Job onto the state of its build.
+func getBuildJobState(job *batchv1.Job) buildObjectState {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return buildObjectSucceeded
+		case batchv1.JobFailed:
+			return buildObjectFailed
+		}
+	}
+
+	// The build runs in an init container, so the build pod does not become
+	// ready until the build is done. Any active pod is considered to be building.
+	if job.Status.Active > 0 {
+		return buildObjectRunning
+	}
+
+	return buildObjectPending
+}
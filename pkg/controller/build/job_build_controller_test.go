@@ -0,0 +1,103 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewBuildJob(t *testing.T) {
+	t.Parallel()
+
+	env := []corev1.EnvVar{{Name: "MAX_RETRIES", Value: "3"}, {Name: "HOME", Value: "/home/build"}}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "build-rendered-worker-1",
+			Labels: map[string]string{"label": ""},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{
+				{Name: "image-build", Env: env},
+				{Name: "wait-for-done", Env: env},
+			},
+			ServiceAccountName: "machine-os-builder",
+		},
+	}
+
+	job := newBuildJob(pod, jobBuilderOpts{ttlSeconds: 60, backoffLimit: 2, retries: 5})
+
+	assert.Equal(t, pod.Name, job.Name)
+	assert.Equal(t, pod.Labels, job.Labels)
+	assert.Equal(t, pod.Labels, job.Spec.Template.Labels)
+	assert.Equal(t, int32(60), *job.Spec.TTLSecondsAfterFinished)
+	assert.Equal(t, int32(2), *job.Spec.BackoffLimit)
+	assert.Equal(t, "machine-os-builder", job.Spec.Template.Spec.ServiceAccountName)
+
+	// The build has to finish before the digest is collected.
+	assert.Len(t, job.Spec.Template.Spec.InitContainers, 1)
+	assert.Equal(t, "image-build", job.Spec.Template.Spec.InitContainers[0].Name)
+	assert.Len(t, job.Spec.Template.Spec.Containers, 1)
+	assert.Equal(t, "wait-for-done", job.Spec.Template.Spec.Containers[0].Name)
+
+	for _, container := range append(job.Spec.Template.Spec.InitContainers, job.Spec.Template.Spec.Containers...) {
+		assert.Contains(t, container.Env, corev1.EnvVar{Name: "MAX_RETRIES", Value: "5"})
+	}
+
+	// The build pod is left untouched.
+	assert.Equal(t, "3", pod.Spec.Containers[0].Env[0].Value)
+	assert.Len(t, pod.Spec.Containers, 2)
+}
+
+func TestGetBuildJobState(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		status   batchv1.JobStatus
+		expected buildObjectState
+	}{
+		{
+			name:     "No pods yet",
+			expected: buildObjectPending,
+		},
+		{
+			name:     "Active pod",
+			status:   batchv1.JobStatus{Active: 1, Failed: 1},
+			expected: buildObjectRunning,
+		},
+		{
+			name: "Complete",
+			status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{
+					{Type: batchv1.JobFailed, Status: corev1.ConditionFalse},
+					{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+				},
+			},
+			expected: buildObjectSucceeded,
+		},
+		{
+			name: "Backoff limit exceeded",
+			status: batchv1.JobStatus{
+				Failed: 3,
+				Conditions: []batchv1.JobCondition{
+					{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded"},
+				},
+			},
+			expected: buildObjectFailed,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, testCase.expected, getBuildJobState(&batchv1.Job{Status: testCase.status}))
+		})
+	}
+}
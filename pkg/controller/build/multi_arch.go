@@ -0,0 +1,373 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	mcfgv1alpha1 "github.com/openshift/api/machineconfiguration/v1alpha1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/buildrequest"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// Pushes a manifest list referencing the given image for each architecture to
+// the given pullspec and returns the digest of the manifest list.
+type manifestListPusher func(ctx context.Context, pushSecret *corev1.Secret, pullspec string, archImages map[string]string) (digest.Digest, error)
+
+// A platform-specific image referenced by a manifest list.
+type manifestListEntry struct {
+	arch     string
+	mimeType string
+	size     int64
+	digest   digest.Digest
+	pullspec string
+}
+
+// Gets the architectures of the nodes in a MachineConfigPool, in sorted order.
+func getPoolArchitectures(kubeclient clientset.Interface, pool *mcfgv1.MachineConfigPool) ([]string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(pool.Spec.NodeSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node selector for MachineConfigPool %s: %w", pool.Name, err)
+	}
+
+	nodes, err := kubeclient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("could not list nodes for MachineConfigPool %s: %w", pool.Name, err)
+	}
+
+	arches := sets.New[string]()
+	for _, node := range nodes.Items {
+		if arch := node.Labels[corev1.LabelArchStable]; arch != "" {
+			arches.Insert(arch)
+		}
+	}
+
+	return sets.List(arches), nil
+}
+
+// Maps the state of one of the build objects of a multi-architecture build to
+// the state of the whole build. The build is running as long as any of its
+// architectures is still building. It only succeeds once the images of all of
+// its architectures were assembled into a manifest list, and it fails as soon
+// as any of its architectures failed.
+func (ctrl *Controller) getMultiArchBuildState(mosc *mcfgv1alpha1.MachineOSConfig, mosb *mcfgv1alpha1.MachineOSBuild, state buildObjectState) (buildObjectState, error) {
+	mosbState := ctrlcommon.NewMachineOSBuildState(mosb)
+
+	if mosbState.IsBuildFailure() {
+		return buildObjectFailed, nil
+	}
+
+	switch state {
+	case buildObjectPending:
+		if mosbState.IsBuilding() {
+			return buildObjectRunning, nil
+		}
+	case buildObjectSucceeded:
+		if mosbState.IsBuildSuccess() {
+			return state, nil
+		}
+
+		assembled, err := ctrl.assembleMultiArchImage(mosc, mosb)
+		if err != nil {
+			return "", err
+		}
+
+		if !assembled {
+			return buildObjectRunning, nil
+		}
+	}
+
+	return state, nil
+}
+
+// Assembles the images of a multi-architecture build into a manifest list once
+// all of them were built, and writes the digest of the manifest list into the
+// digest ConfigMap of the build. Returns false while any of the images is still
+// being built.
+func (ctrl *Controller) assembleMultiArchImage(mosc *mcfgv1alpha1.MachineOSConfig, mosb *mcfgv1alpha1.MachineOSBuild) (bool, error) {
+	_, err := ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), buildrequest.GetDigestConfigMapName(mosb), metav1.GetOptions{})
+	if err == nil {
+		return true, nil
+	}
+
+	if !k8serrors.IsNotFound(err) {
+		return false, err
+	}
+
+	archImages := map[string]string{}
+	archDigestConfigMaps := []*corev1.ConfigMap{}
+
+	for _, arch := range buildrequest.GetBuildArchitectures(mosb) {
+		archDigestConfigMap, err := ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), buildrequest.GetArchDigestConfigMapName(mosb, arch), metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			klog.V(4).Infof("Build %s is still building the %s image", mosb.Name, arch)
+			return false, nil
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		archTag, err := buildrequest.GetArchImagePullspec(mosc.Status.CurrentImagePullspec, arch)
+		if err != nil {
+			return false, err
+		}
+
+		archImages[arch], err = ParseImagePullspec(archTag, strings.TrimSpace(archDigestConfigMap.Data["digest"]))
+		if err != nil {
+			return false, fmt.Errorf("could not get %s image pullspec for build %s: %w", arch, mosb.Name, err)
+		}
+
+		archDigestConfigMaps = append(archDigestConfigMaps, archDigestConfigMap)
+	}
+
+	pushSecret, err := ctrl.kubeclient.CoreV1().Secrets(ctrlcommon.MCONamespace).Get(context.TODO(), mosc.Spec.BuildInputs.RenderedImagePushSecret.Name, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("could not get renderedImagePushSecret for build %s: %w", mosb.Name, err)
+	}
+
+	listDigest, err := ctrl.pushManifestList(context.TODO(), pushSecret, mosc.Status.CurrentImagePullspec, archImages)
+	if err != nil {
+		return false, fmt.Errorf("could not push manifest list for build %s: %w", mosb.Name, err)
+	}
+
+	klog.Infof("Pushed manifest list %s for build %s", listDigest, mosb.Name)
+
+	digestConfigMap := newMultiArchDigestConfigMap(mosb, listDigest, archDigestConfigMaps)
+	_, err = ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Create(context.TODO(), digestConfigMap, metav1.CreateOptions{})
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return false, fmt.Errorf("could not create digest ConfigMap for build %s: %w", mosb.Name, err)
+	}
+
+	return true, nil
+}
+
+// Creates the digest ConfigMap of a multi-architecture build from the digest
+// ConfigMaps of each of its architectures. The build cache statistics of the
+// architectures are added up.
+func newMultiArchDigestConfigMap(mosb *mcfgv1alpha1.MachineOSBuild, listDigest digest.Digest, archDigestConfigMaps []*corev1.ConfigMap) *corev1.ConfigMap {
+	cmLabels := map[string]string{}
+	stats := map[string]int{}
+
+	for _, archDigestConfigMap := range archDigestConfigMaps {
+		for key, val := range archDigestConfigMap.Labels {
+			if key != constants.BuildArchitectureLabelKey {
+				cmLabels[key] = val
+			}
+		}
+
+		for _, key := range []string{cacheHitsDigestKey, cacheableStepsDigestKey} {
+			if val, err := strconv.Atoi(strings.TrimSpace(archDigestConfigMap.Data[key])); err == nil {
+				stats[key] += val
+			}
+		}
+	}
+
+	data := map[string]string{
+		"digest": listDigest.String(),
+	}
+
+	for key, val := range stats {
+		data[key] = strconv.Itoa(val)
+	}
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      buildrequest.GetDigestConfigMapName(mosb),
+			Namespace: ctrlcommon.MCONamespace,
+			Labels:    cmLabels,
+		},
+		Data: data,
+	}
+}
+
+// Pushes a manifest list referencing the given image for each architecture
+// using the credentials from the given push secret.
+func pushManifestList(ctx context.Context, pushSecret *corev1.Secret, pullspec string, archImages map[string]string) (digest.Digest, error) {
+	authfile, err := writeAuthfile(pushSecret)
+	if err != nil {
+		return "", err
+	}
+
+	defer os.Remove(authfile)
+
+	sys := &types.SystemContext{AuthFilePath: authfile}
+
+	entries := []manifestListEntry{}
+
+	for arch, archImage := range archImages {
+		entry, err := getManifestListEntry(ctx, sys, arch, archImage)
+		if err != nil {
+			return "", err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	list, err := newManifestList(entries)
+	if err != nil {
+		return "", err
+	}
+
+	ref, err := docker.ParseReference("//" + pullspec)
+	if err != nil {
+		return "", err
+	}
+
+	dest, err := ref.NewImageDestination(ctx, sys)
+	if err != nil {
+		return "", err
+	}
+
+	defer dest.Close()
+
+	if err := dest.PutManifest(ctx, list, nil); err != nil {
+		return "", fmt.Errorf("could not push manifest list to %s: %w", pullspec, err)
+	}
+
+	if err := dest.Commit(ctx, nil); err != nil {
+		return "", err
+	}
+
+	return manifest.Digest(list)
+}
+
+// Writes the credentials from a pull secret into a temporary auth file. The
+// caller must remove the returned file.
+func writeAuthfile(secret *corev1.Secret) (string, error) {
+	secretBytes, ok := secret.Data[corev1.DockerConfigJsonKey]
+	if !ok {
+		secretBytes, ok = secret.Data[corev1.DockerConfigKey]
+	}
+
+	if !ok {
+		return "", fmt.Errorf("secret %s has no pull secret", secret.Name)
+	}
+
+	authBytes, _, err := ctrlcommon.ConvertSecretToDockerconfigJSON(secretBytes)
+	if err != nil {
+		return "", fmt.Errorf("could not parse secret %s: %w", secret.Name, err)
+	}
+
+	authfile, err := os.CreateTemp("", "auth-*.json")
+	if err != nil {
+		return "", err
+	}
+
+	defer authfile.Close()
+
+	if _, err := authfile.Write(authBytes); err != nil {
+		os.Remove(authfile.Name())
+		return "", err
+	}
+
+	return authfile.Name(), nil
+}
+
+// Fetches the manifest of a digested image pullspec to reference it from a
+// manifest list.
+func getManifestListEntry(ctx context.Context, sys *types.SystemContext, arch, pullspec string) (manifestListEntry, error) {
+	ref, err := docker.ParseReference("//" + pullspec)
+	if err != nil {
+		return manifestListEntry{}, err
+	}
+
+	src, err := ref.NewImageSource(ctx, sys)
+	if err != nil {
+		return manifestListEntry{}, fmt.Errorf("could not get image %s: %w", pullspec, err)
+	}
+
+	defer src.Close()
+
+	rawManifest, mimeType, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return manifestListEntry{}, fmt.Errorf("could not get manifest of image %s: %w", pullspec, err)
+	}
+
+	manifestDigest, err := manifest.Digest(rawManifest)
+	if err != nil {
+		return manifestListEntry{}, err
+	}
+
+	return manifestListEntry{
+		arch:     arch,
+		mimeType: mimeType,
+		size:     int64(len(rawManifest)),
+		digest:   manifestDigest,
+		pullspec: pullspec,
+	}, nil
+}
+
+// Creates a manifest list from its entries, sorted by architecture. Buildah
+// pushes either Docker or OCI manifests, so a Docker manifest list is created
+// for Docker manifests and an OCI image index otherwise.
+func newManifestList(entries []manifestListEntry) ([]byte, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no images for manifest list")
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].arch < entries[j].arch
+	})
+
+	allDocker := true
+	for _, entry := range entries {
+		if manifest.MIMETypeIsMultiImage(entry.mimeType) {
+			return nil, fmt.Errorf("image %s for %s is a manifest list", entry.pullspec, entry.arch)
+		}
+
+		if entry.mimeType != manifest.DockerV2Schema2MediaType {
+			allDocker = false
+		}
+	}
+
+	if allDocker {
+		components := []manifest.Schema2ManifestDescriptor{}
+		for _, entry := range entries {
+			components = append(components, manifest.Schema2ManifestDescriptor{
+				Schema2Descriptor: manifest.Schema2Descriptor{
+					MediaType: entry.mimeType,
+					Size:      entry.size,
+					Digest:    entry.digest,
+				},
+				Platform: manifest.Schema2PlatformSpec{
+					Architecture: entry.arch,
+					OS:           "linux",
+				},
+			})
+		}
+
+		return manifest.Schema2ListFromComponents(components).Serialize()
+	}
+
+	components := []imgspecv1.Descriptor{}
+	for _, entry := range entries {
+		components = append(components, imgspecv1.Descriptor{
+			MediaType: entry.mimeType,
+			Size:      entry.size,
+			Digest:    entry.digest,
+			Platform: &imgspecv1.Platform{
+				Architecture: entry.arch,
+				OS:           "linux",
+			},
+		})
+	}
+
+	return manifest.OCI1IndexFromComponents(components, nil).Serialize()
+}
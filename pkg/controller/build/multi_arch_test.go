@@ -0,0 +1,204 @@
+package build
+
+import (
+	"context"
+	"testing"
+
+	"github.com/containers/image/v5/manifest"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/buildrequest"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakecorev1client "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetPoolArchitectures(t *testing.T) {
+	t.Parallel()
+
+	newNode := func(name, role, arch string) *corev1.Node {
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					"node-role.kubernetes.io/" + role: "",
+				},
+			},
+		}
+
+		if arch != "" {
+			node.Labels[corev1.LabelArchStable] = arch
+		}
+
+		return node
+	}
+
+	kubeclient := fakecorev1client.NewSimpleClientset(
+		newNode("worker-0", "worker", "arm64"),
+		newNode("worker-1", "worker", "amd64"),
+		newNode("worker-2", "worker", "arm64"),
+		newNode("worker-3", "worker", ""),
+		newNode("master-0", "master", "s390x"),
+	)
+
+	arches, err := getPoolArchitectures(kubeclient, newMachineConfigPool("worker"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"amd64", "arm64"}, arches)
+
+	arches, err = getPoolArchitectures(kubeclient, newMachineConfigPool("infra"))
+	require.NoError(t, err)
+	assert.Empty(t, arches)
+}
+
+func TestNewManifestList(t *testing.T) {
+	t.Parallel()
+
+	newEntry := func(arch, mimeType string) manifestListEntry {
+		return manifestListEntry{
+			arch:     arch,
+			mimeType: mimeType,
+			size:     1234,
+			digest:   digest.FromString(arch),
+			pullspec: "registry.hostname.com/org/repo@" + digest.FromString(arch).String(),
+		}
+	}
+
+	t.Run("Docker manifests", func(t *testing.T) {
+		t.Parallel()
+
+		list, err := newManifestList([]manifestListEntry{
+			newEntry("arm64", manifest.DockerV2Schema2MediaType),
+			newEntry("amd64", manifest.DockerV2Schema2MediaType),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, manifest.DockerV2ListMediaType, manifest.GuessMIMEType(list))
+
+		schema2List, err := manifest.Schema2ListFromManifest(list)
+		require.NoError(t, err)
+		require.Len(t, schema2List.Manifests, 2)
+		assert.Equal(t, "amd64", schema2List.Manifests[0].Platform.Architecture)
+		assert.Equal(t, "linux", schema2List.Manifests[0].Platform.OS)
+		assert.Equal(t, digest.FromString("amd64"), schema2List.Manifests[0].Digest)
+		assert.Equal(t, "arm64", schema2List.Manifests[1].Platform.Architecture)
+	})
+
+	t.Run("OCI manifests", func(t *testing.T) {
+		t.Parallel()
+
+		list, err := newManifestList([]manifestListEntry{
+			newEntry("amd64", manifest.DockerV2Schema2MediaType),
+			newEntry("arm64", imgspecv1.MediaTypeImageManifest),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, imgspecv1.MediaTypeImageIndex, manifest.GuessMIMEType(list))
+
+		index, err := manifest.OCI1IndexFromManifest(list)
+		require.NoError(t, err)
+		require.Len(t, index.Manifests, 2)
+		assert.Equal(t, "amd64", index.Manifests[0].Platform.Architecture)
+		assert.Equal(t, imgspecv1.MediaTypeImageManifest, index.Manifests[1].MediaType)
+	})
+
+	t.Run("Nested manifest list", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newManifestList([]manifestListEntry{
+			newEntry("amd64", manifest.DockerV2ListMediaType),
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestAssembleMultiArchImage(t *testing.T) {
+	t.Parallel()
+
+	clients := getClientsForTest()
+	ctrl := newBuildController(BuildControllerConfig{}, clients)
+
+	listDigest := digest.FromString("manifest-list")
+
+	type pushedList struct {
+		pullspec   string
+		archImages map[string]string
+	}
+
+	pushed := []pushedList{}
+	ctrl.pushManifestList = func(_ context.Context, pushSecret *corev1.Secret, pullspec string, archImages map[string]string) (digest.Digest, error) {
+		assert.Equal(t, "final-image-push-secret", pushSecret.Name)
+		pushed = append(pushed, pushedList{pullspec: pullspec, archImages: archImages})
+		return listDigest, nil
+	}
+
+	pool := newMachineConfigPool("worker", "rendered-worker-1")
+	mosc := newMachineOSConfig(pool)
+	mosc.Status.CurrentImagePullspec = "registry.hostname.com/org/repo:rendered-worker-1"
+	mosb := newMachineOSBuild(mosc, pool)
+	mosb.Annotations = map[string]string{
+		constants.BuildArchitecturesAnnotationKey: "amd64,arm64",
+	}
+
+	createArchDigestConfigMap := func(arch, imageDigest string) {
+		_, err := clients.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Create(context.TODO(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      buildrequest.GetArchDigestConfigMapName(mosb, arch),
+				Namespace: ctrlcommon.MCONamespace,
+				Labels: map[string]string{
+					constants.OnClusterLayeringLabelKey: "",
+					constants.BuildArchitectureLabelKey: arch,
+				},
+			},
+			Data: map[string]string{
+				"digest":                imageDigest,
+				cacheHitsDigestKey:      "2\n",
+				cacheableStepsDigestKey: "3\n",
+			},
+		}, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	// The build keeps running until the images of all architectures were built.
+	state, err := ctrl.getMultiArchBuildState(mosc, mosb, buildObjectSucceeded)
+	require.NoError(t, err)
+	assert.Equal(t, buildObjectRunning, state)
+
+	amd64Digest := digest.FromString("amd64").String()
+	createArchDigestConfigMap("amd64", amd64Digest)
+
+	state, err = ctrl.getMultiArchBuildState(mosc, mosb, buildObjectSucceeded)
+	require.NoError(t, err)
+	assert.Equal(t, buildObjectRunning, state)
+	assert.Empty(t, pushed)
+
+	arm64Digest := digest.FromString("arm64").String()
+	createArchDigestConfigMap("arm64", arm64Digest)
+
+	state, err = ctrl.getMultiArchBuildState(mosc, mosb, buildObjectSucceeded)
+	require.NoError(t, err)
+	assert.Equal(t, buildObjectSucceeded, state)
+
+	require.Len(t, pushed, 1)
+	assert.Equal(t, mosc.Status.CurrentImagePullspec, pushed[0].pullspec)
+	assert.Equal(t, map[string]string{
+		"amd64": "registry.hostname.com/org/repo@" + amd64Digest,
+		"arm64": "registry.hostname.com/org/repo@" + arm64Digest,
+	}, pushed[0].archImages)
+
+	digestConfigMap, err := clients.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), buildrequest.GetDigestConfigMapName(mosb), metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"digest":                listDigest.String(),
+		cacheHitsDigestKey:      "4",
+		cacheableStepsDigestKey: "6",
+	}, digestConfigMap.Data)
+	assert.Equal(t, map[string]string{constants.OnClusterLayeringLabelKey: ""}, digestConfigMap.Labels)
+
+	// The manifest list is only pushed once.
+	state, err = ctrl.getMultiArchBuildState(mosc, mosb, buildObjectSucceeded)
+	require.NoError(t, err)
+	assert.Equal(t, buildObjectSucceeded, state)
+	assert.Len(t, pushed, 1)
+}
@@ -159,6 +159,13 @@ func (ctrl *PodBuildController) syncPod(key string) error { //nolint:dupl // Thi
 		return nil
 	}
 
+	// Build pods created by a build Job carry the same labels. Those are
+	// reconciled through their Job instead.
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		klog.V(4).Infof("Ignoring build pod %s controlled by %s %s", pod.Name, owner.Kind, owner.Name)
+		return nil
+	}
+
 	if err := ctrl.podHandler(pod); err != nil {
 		return fmt.Errorf("unable to update with build pod status: %w", err)
 	}
@@ -195,40 +202,79 @@ func (ctrl *PodBuildController) DeleteBuildObject(mosb *mcfgv1alpha1.MachineOSBu
 	// This is because when a pool is opted out of layering *after* a successful
 	// build, no pod nor ConfigMap will remain. So we want to be able to
 	// idempotently call this function in that case.
-	return aggerrors.AggregateGoroutines(
-		func() error {
-			return ignoreIsNotFoundErr(ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Delete(context.TODO(), buildrequest.GetBuildPodName(mosb), metav1.DeleteOptions{}))
-		},
-		func() error {
-			return ignoreIsNotFoundErr(ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Delete(context.TODO(), buildrequest.GetDigestConfigMapName(mosb), metav1.DeleteOptions{}))
-		},
-	)
+	funcs := []func() error{}
+
+	for _, name := range buildrequest.GetBuildPodNames(mosb) {
+		name := name
+		funcs = append(funcs, func() error {
+			return ignoreIsNotFoundErr(ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Delete(context.TODO(), name, metav1.DeleteOptions{}))
+		})
+	}
+
+	for _, name := range buildrequest.GetDigestConfigMapNames(mosb) {
+		name := name
+		funcs = append(funcs, func() error {
+			return ignoreIsNotFoundErr(ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Delete(context.TODO(), name, metav1.DeleteOptions{}))
+		})
+	}
+
+	return aggerrors.AggregateGoroutines(funcs...)
 }
 
 // Determines if a build is currently running by looking for a corresponding pod.
 func (ctrl *PodBuildController) IsBuildRunning(mosb *mcfgv1alpha1.MachineOSBuild, _ *mcfgv1alpha1.MachineOSConfig) (bool, error) {
 	// First check if we have a build in progress for this MachineConfigPool and rendered config.
-	_, err := ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Get(context.TODO(), buildrequest.GetBuildPodName(mosb), metav1.GetOptions{})
-	if err != nil && !k8serrors.IsNotFound(err) {
-		return false, err
+	for _, name := range buildrequest.GetBuildPodNames(mosb) {
+		_, err := ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err == nil {
+			return true, nil
+		}
+
+		if !k8serrors.IsNotFound(err) {
+			return false, err
+		}
 	}
 
-	return err == nil, nil
+	return false, nil
 }
 
-// Starts a new build pod, assuming one is not found first. In that case, it returns an object reference to the preexisting build pod.
+// Starts a new build pod, assuming one is not found first. In that case, it
+// returns an object reference to the preexisting build pod. Multi-architecture
+// builds start a build pod for each architecture and return an object
+// reference to the first one.
 func (ctrl *PodBuildController) StartBuild(ibr buildrequest.BuildRequest) (*corev1.ObjectReference, error) {
 	ibrOpts := ibr.Opts()
 
 	targetMC := ibrOpts.MachineOSBuild.Spec.DesiredConfig.Name
 
-	buildPod := ibr.BuildPod()
-
 	// TODO: Find a constant for this:
 	if !strings.HasPrefix(targetMC, "rendered-") {
 		return nil, fmt.Errorf("%s is not a rendered MachineConfig", targetMC)
 	}
 
+	buildPods, err := ibr.BuildPods()
+	if err != nil {
+		return nil, err
+	}
+
+	var objRef *corev1.ObjectReference
+
+	for _, buildPod := range buildPods {
+		podRef, err := ctrl.startBuildPod(ibrOpts, buildPod)
+		if err != nil {
+			return nil, err
+		}
+
+		if objRef == nil {
+			objRef = podRef
+		}
+	}
+
+	return objRef, nil
+}
+
+// Starts a single build pod, assuming one is not found first.
+func (ctrl *PodBuildController) startBuildPod(ibrOpts buildrequest.BuildRequestOpts, buildPod *corev1.Pod) (*corev1.ObjectReference, error) {
 	// First check if we have a build in progress for this MachineConfigPool and rendered config.
 	pod, err := ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Get(context.TODO(), buildPod.Name, metav1.GetOptions{})
 	if err != nil && !k8serrors.IsNotFound(err) {
@@ -0,0 +1,508 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/docker/reference"
+	mcfgv1alpha1 "github.com/openshift/api/machineconfiguration/v1alpha1"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/buildrequest"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	aggerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corelistersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+var (
+	// How often the external build system is polled for the state of the
+	// builds handed to it.
+	webhookPollInterval = 15 * time.Second
+
+	// How long a single request to the external build system may take.
+	webhookRequestTimeout = 30 * time.Second
+)
+
+// Keys of the ConfigMap tracking a build handed to an external build system.
+const (
+	// The ID the external build system assigned to the build.
+	webhookBuildIDKey = "id"
+	// The URL of the external build system.
+	webhookBuildURLKey = "url"
+	// The name of the secret used to talk to the external build system.
+	webhookBuildSecretKey = "secret"
+	// The name of the ConfigMap the digest of the built image is written to.
+	webhookBuildDigestConfigMapKey = "digestConfigMap"
+	// The last state reported by the external build system.
+	webhookBuildStateKey = "state"
+	// The message which came along with the last state, if any.
+	webhookBuildMessageKey = "message"
+)
+
+// The request sent to the external build system to start a build.
+type webhookBuildRequest struct {
+	// The name of the build object, which is unique per rendered MachineConfig.
+	Name            string `json:"name"`
+	MachineOSConfig string `json:"machineOSConfig"`
+	MachineOSBuild  string `json:"machineOSBuild"`
+	Pool            string `json:"pool"`
+	// The name of the rendered MachineConfig to build.
+	RenderedMachineConfig string `json:"renderedMachineConfig"`
+	// The rendered Containerfile.
+	Containerfile string `json:"containerfile"`
+	// The files of the build context, keyed by their path relative to it.
+	BuildContext map[string]string `json:"buildContext"`
+	// Where the built image is expected to be pushed.
+	Pushspec string `json:"pushspec"`
+	// The image repository to use as a layer cache, if any.
+	CacheRepository string `json:"cacheRepository,omitempty"`
+	// The architectures to build the image for. When there is more than one,
+	// the pushed image has to be a manifest list.
+	Architectures []string `json:"architectures,omitempty"`
+}
+
+// The response of the external build system to a build request.
+type webhookBuildResponse struct {
+	ID string `json:"id"`
+}
+
+// The state of a build as reported by the external build system.
+type webhookBuildStatus struct {
+	// One of Pending, Running, Succeeded or Failed.
+	State string `json:"state"`
+	// The digested pullspec of the built image, once the build succeeded.
+	Pullspec string `json:"pullspec,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// WebhookBuildController hands builds to an external build system over HTTPS
+// and polls it until the build has a digested pullspec. The state of each build
+// is tracked in a ConfigMap so that it survives restarts of the
+// machine-os-builder.
+//
+// The pull and push secrets of the MachineOSConfig are not sent to the
+// external build system; it must have its own credentials for the base image
+// and the rendered image repository.
+type WebhookBuildController struct {
+	*Clients
+	*informers
+
+	// The function to call whenever the state of a build changes.
+	stateHandler func(string, buildObjectState, *corev1.ObjectReference) error
+
+	cmLister       corelistersv1.ConfigMapLister
+	cmListerSynced cache.InformerSynced
+}
+
+var _ ImageBuilder = (*WebhookBuildController)(nil)
+
+// Returns a new webhook build controller.
+func newWebhookBuildController(
+	_ BuildControllerConfig,
+	clients *Clients,
+	stateHandler func(string, buildObjectState, *corev1.ObjectReference) error,
+) *WebhookBuildController {
+	ctrl := &WebhookBuildController{
+		Clients:      clients,
+		informers:    newInformers(clients),
+		stateHandler: stateHandler,
+	}
+
+	ctrl.cmLister = ctrl.cmInformer.Lister()
+	ctrl.cmListerSynced = ctrl.cmInformer.Informer().HasSynced
+
+	return ctrl
+}
+
+// Starts the Webhook Build Controller. Since the external build system cannot
+// be watched, the builds handed to it are polled instead.
+func (ctrl *WebhookBuildController) Run(ctx context.Context, _ int) {
+	defer utilruntime.HandleCrash()
+
+	ctrl.informers.start(ctx)
+
+	if !cache.WaitForCacheSync(ctx.Done(), ctrl.cmListerSynced) {
+		return
+	}
+
+	klog.Info("Starting MachineOSBuilder-WebhookBuildController")
+	defer klog.Info("Shutting down MachineOSBuilder-WebhookBuildController")
+
+	wait.Until(ctrl.pollBuilds, webhookPollInterval, ctx.Done())
+}
+
+// Hands a build to the external build system, assuming it was not handed to it
+// already. In that case, it returns an object reference to the preexisting
+// tracking ConfigMap.
+func (ctrl *WebhookBuildController) StartBuild(ibr buildrequest.BuildRequest) (*corev1.ObjectReference, error) {
+	ibrOpts := ibr.Opts()
+	mosb := ibrOpts.MachineOSBuild
+	mosc := ibrOpts.MachineOSConfig
+
+	targetMC := mosb.Spec.DesiredConfig.Name
+	if !strings.HasPrefix(targetMC, "rendered-") {
+		return nil, fmt.Errorf("%s is not a rendered MachineConfig", targetMC)
+	}
+
+	cmName := buildrequest.GetWebhookBuildConfigMapName(mosb)
+	cm, err := ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), cmName, metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	if err == nil {
+		klog.Infof("Found preexisting webhook build %s (%s) for pool %s", cm.Data[webhookBuildIDKey], cm.Name, mosc.Spec.MachineConfigPool.Name)
+		return toObjectRef(cm), nil
+	}
+
+	opts, err := getWebhookBuilderOpts(mosc)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := newWebhookBuildRequest(ibr)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := webhookBuildResponse{}
+	if err := ctrl.doRequest(http.MethodPost, opts.url, opts.secretName, body, &resp); err != nil {
+		return nil, fmt.Errorf("could not start webhook build for pool %s: %w", mosc.Spec.MachineConfigPool.Name, err)
+	}
+
+	if resp.ID == "" {
+		return nil, fmt.Errorf("could not start webhook build for pool %s: %s returned no build ID", mosc.Spec.MachineConfigPool.Name, opts.url)
+	}
+
+	cm = &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cmName,
+			Namespace: ctrlcommon.MCONamespace,
+			Labels:    getWebhookBuildLabels(mosb, mosc),
+			Annotations: map[string]string{
+				constants.MachineOSConfigNameAnnotationKey: mosc.Name,
+				constants.MachineOSBuildNameAnnotationKey:  mosb.Name,
+			},
+		},
+		Data: map[string]string{
+			webhookBuildIDKey:              resp.ID,
+			webhookBuildURLKey:             opts.url,
+			webhookBuildSecretKey:          opts.secretName,
+			webhookBuildDigestConfigMapKey: buildrequest.GetDigestConfigMapName(mosb),
+			webhookBuildStateKey:           string(buildObjectPending),
+		},
+	}
+
+	cm, err = ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Create(context.TODO(), cm, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not create webhook build ConfigMap: %w", err)
+	}
+
+	klog.Infof("Build %s started by %s for pool %s", resp.ID, opts.url, mosc.Spec.MachineConfigPool.Name)
+
+	return toObjectRef(cm), nil
+}
+
+// Determines if a build is currently running by looking for a corresponding
+// tracking ConfigMap whose build has not finished yet.
+func (ctrl *WebhookBuildController) IsBuildRunning(mosb *mcfgv1alpha1.MachineOSBuild, _ *mcfgv1alpha1.MachineOSConfig) (bool, error) {
+	cm, err := ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), buildrequest.GetWebhookBuildConfigMapName(mosb), metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return !isWebhookBuildFinished(cm), nil
+}
+
+// Cancels the build on the external build system, if possible, and deletes
+// the tracking and digest ConfigMaps.
+func (ctrl *WebhookBuildController) DeleteBuildObject(mosb *mcfgv1alpha1.MachineOSBuild, _ *mcfgv1alpha1.MachineOSConfig) error {
+	cmName := buildrequest.GetWebhookBuildConfigMapName(mosb)
+
+	cm, err := ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), cmName, metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+
+	// The external build system may have already forgotten about the build, so
+	// a failure to cancel it does not prevent the cleanup.
+	if err == nil && !isWebhookBuildFinished(cm) {
+		if err := ctrl.doBuildRequest(http.MethodDelete, cm, nil); err != nil {
+			klog.Warningf("Could not cancel webhook build %s: %v", cm.Data[webhookBuildIDKey], err)
+		}
+	}
+
+	return aggerrors.AggregateGoroutines(
+		func() error {
+			return ignoreIsNotFoundErr(ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Delete(context.TODO(), cmName, metav1.DeleteOptions{}))
+		},
+		func() error {
+			return ignoreIsNotFoundErr(ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Delete(context.TODO(), buildrequest.GetDigestConfigMapName(mosb), metav1.DeleteOptions{}))
+		},
+	)
+}
+
+// Polls the external build system for each build which has not finished yet.
+func (ctrl *WebhookBuildController) pollBuilds() {
+	cms, err := ctrl.cmLister.ConfigMaps(ctrlcommon.MCONamespace).List(constants.WebhookBuildSelector())
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+
+	for _, cm := range cms {
+		if isWebhookBuildFinished(cm) {
+			continue
+		}
+
+		if err := ctrl.pollBuild(cm.DeepCopy()); err != nil {
+			utilruntime.HandleError(fmt.Errorf("could not poll webhook build %s (%s): %w", cm.Data[webhookBuildIDKey], cm.Name, err))
+		}
+	}
+}
+
+// Polls the external build system for the state of a single build and
+// reconciles the MachineOSBuild with it whenever it changes.
+func (ctrl *WebhookBuildController) pollBuild(cm *corev1.ConfigMap) error {
+	status := webhookBuildStatus{}
+	if err := ctrl.doBuildRequest(http.MethodGet, cm, &status); err != nil {
+		return err
+	}
+
+	state := buildObjectState(status.State)
+	switch state {
+	case buildObjectPending, buildObjectRunning, buildObjectFailed:
+	case buildObjectSucceeded:
+		if err := ctrl.createDigestConfigMap(cm, status.Pullspec); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown build state %q", status.State)
+	}
+
+	if cm.Data[webhookBuildStateKey] == string(state) && state != buildObjectSucceeded {
+		return nil
+	}
+
+	klog.Infof("Webhook build %s (%s) is %s", cm.Data[webhookBuildIDKey], cm.Name, state)
+
+	if err := ctrl.stateHandler(cm.Labels[constants.TargetMachineConfigPoolLabelKey], state, toObjectRef(cm)); err != nil {
+		return fmt.Errorf("unable to update with webhook build status: %w", err)
+	}
+
+	// On success, the post-build cleanup already deleted the ConfigMap.
+	cm.Data[webhookBuildStateKey] = string(state)
+	cm.Data[webhookBuildMessageKey] = status.Message
+	_, err := ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Update(context.TODO(), cm, metav1.UpdateOptions{})
+	return ignoreIsNotFoundErr(err)
+}
+
+// Creates the digest ConfigMap for a successful build, which is where the
+// build controller looks for the digest of the built image.
+func (ctrl *WebhookBuildController) createDigestConfigMap(cm *corev1.ConfigMap, pullspec string) error {
+	if err := validateImageHasDigestedPullspec(pullspec); err != nil {
+		return fmt.Errorf("invalid pullspec for successful build: %w", err)
+	}
+
+	named, err := reference.ParseNamed(pullspec)
+	if err != nil {
+		return err
+	}
+
+	digested, ok := named.(reference.Digested)
+	if !ok {
+		return fmt.Errorf("expected a pullspec with a SHA256 digest, got %q", pullspec)
+	}
+
+	labels := map[string]string{}
+	for key, val := range cm.Labels {
+		if key != constants.WebhookBuildLabelKey {
+			labels[key] = val
+		}
+	}
+
+	digestCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        cm.Data[webhookBuildDigestConfigMapKey],
+			Namespace:   ctrlcommon.MCONamespace,
+			Labels:      labels,
+			Annotations: cm.Annotations,
+		},
+		Data: map[string]string{
+			"digest": digested.Digest().String(),
+		},
+	}
+
+	_, err = ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Create(context.TODO(), digestCM, metav1.CreateOptions{})
+	if k8serrors.IsAlreadyExists(err) {
+		return nil
+	}
+
+	return err
+}
+
+// Sends a request for the build tracked by the given ConfigMap to the external
+// build system.
+func (ctrl *WebhookBuildController) doBuildRequest(method string, cm *corev1.ConfigMap, out interface{}) error {
+	buildURL, err := url.JoinPath(cm.Data[webhookBuildURLKey], cm.Data[webhookBuildIDKey])
+	if err != nil {
+		return err
+	}
+
+	return ctrl.doRequest(method, buildURL, cm.Data[webhookBuildSecretKey], nil, out)
+}
+
+// Sends a request to the external build system, authenticating with the
+// bearer token and trusting the CA bundle from the given secret, if any. When
+// out is not nil, the JSON response is decoded into it.
+func (ctrl *WebhookBuildController) doRequest(method, reqURL, secretName string, body []byte, out interface{}) error {
+	client := &http.Client{Timeout: webhookRequestTimeout}
+	token := ""
+
+	if secretName != "" {
+		secret, err := ctrl.kubeclient.CoreV1().Secrets(ctrlcommon.MCONamespace).Get(context.TODO(), secretName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("could not get webhook secret %s: %w", secretName, err)
+		}
+
+		token = strings.TrimSpace(string(secret.Data[webhookTokenSecretKey]))
+
+		if ca := secret.Data[webhookCASecretKey]; len(ca) != 0 {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(ca) {
+				return fmt.Errorf("could not parse %s from webhook secret %s", webhookCASecretKey, secretName)
+			}
+
+			client.Transport = &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+			}
+		}
+	}
+
+	req, err := http.NewRequest(method, reqURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s returned %s: %s", method, reqURL, resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("could not decode response of %s %s: %w", method, reqURL, err)
+	}
+
+	return nil
+}
+
+// Creates the request handing a build to the external build system from the
+// rendered Containerfile and MachineConfig.
+func newWebhookBuildRequest(ibr buildrequest.BuildRequest) (*webhookBuildRequest, error) {
+	ibrOpts := ibr.Opts()
+	mosb := ibrOpts.MachineOSBuild
+	mosc := ibrOpts.MachineOSConfig
+
+	cms, err := ibr.ConfigMaps()
+	if err != nil {
+		return nil, fmt.Errorf("could not render build context: %w", err)
+	}
+
+	req := &webhookBuildRequest{
+		Name:                  buildrequest.GetBuildPodName(mosb),
+		MachineOSConfig:       mosc.Name,
+		MachineOSBuild:        mosb.Name,
+		Pool:                  mosc.Spec.MachineConfigPool.Name,
+		RenderedMachineConfig: mosb.Spec.DesiredConfig.Name,
+		BuildContext:          map[string]string{},
+		Pushspec:              mosc.Status.CurrentImagePullspec,
+		CacheRepository:       buildrequest.GetBuildCacheRepository(mosc),
+		Architectures:         buildrequest.GetBuildArchitectures(mosb),
+	}
+
+	for _, cm := range cms {
+		if containerfile, ok := cm.Data["Containerfile"]; ok {
+			req.Containerfile = containerfile
+			continue
+		}
+
+		// The Containerfile copies the MachineConfig from the machineconfig
+		// directory of the build context.
+		for name, content := range cm.Data {
+			req.BuildContext[path.Join("machineconfig", name)] = content
+		}
+	}
+
+	if req.Containerfile == "" {
+		return nil, fmt.Errorf("no Containerfile rendered for %s", mosb.Name)
+	}
+
+	return req, nil
+}
+
+// Gets the labels of the ConfigMap tracking a webhook build.
+func getWebhookBuildLabels(mosb *mcfgv1alpha1.MachineOSBuild, mosc *mcfgv1alpha1.MachineOSConfig) map[string]string {
+	return map[string]string{
+		constants.EphemeralBuildObjectLabelKey:    "",
+		constants.OnClusterLayeringLabelKey:       "",
+		constants.RenderedMachineConfigLabelKey:   mosb.Spec.DesiredConfig.Name,
+		constants.TargetMachineConfigPoolLabelKey: mosc.Spec.MachineConfigPool.Name,
+		constants.WebhookBuildLabelKey:            "",
+	}
+}
+
+// Determines if the external build system reported a build to be finished.
+func isWebhookBuildFinished(cm *corev1.ConfigMap) bool {
+	state := buildObjectState(cm.Data[webhookBuildStateKey])
+	return state == buildObjectSucceeded || state == buildObjectFailed
+}
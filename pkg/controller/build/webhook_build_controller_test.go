@@ -0,0 +1,212 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/openshift/machine-config-operator/pkg/controller/build/buildrequest"
+	"github.com/openshift/machine-config-operator/pkg/controller/build/constants"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// A BuildRequest which only renders the build context.
+type fakeBuildRequest struct {
+	opts buildrequest.BuildRequestOpts
+}
+
+var _ buildrequest.BuildRequest = (*fakeBuildRequest)(nil)
+
+func (f *fakeBuildRequest) Opts() buildrequest.BuildRequestOpts { return f.opts }
+
+func (f *fakeBuildRequest) BuildPod() *corev1.Pod { return nil }
+
+func (f *fakeBuildRequest) BuildPods() ([]*corev1.Pod, error) { return nil, nil }
+
+func (f *fakeBuildRequest) Secrets() ([]*corev1.Secret, error) { return nil, nil }
+
+func (f *fakeBuildRequest) ConfigMaps() ([]*corev1.ConfigMap, error) {
+	return []*corev1.ConfigMap{
+		{Data: map[string]string{"Containerfile": "FROM base"}},
+		{Data: map[string]string{"machineconfig.json.gz": "H4sI"}},
+	}, nil
+}
+
+// An external build system which reports the given state for each build.
+type fakeWebhookBuildServer struct {
+	mu       sync.Mutex
+	requests []webhookBuildRequest
+	status   webhookBuildStatus
+	canceled []string
+}
+
+func (f *fakeWebhookBuildServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer s3kr1t" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/builds":
+		req := webhookBuildRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.requests = append(f.requests, req)
+		json.NewEncoder(w).Encode(webhookBuildResponse{ID: "build-1"})
+	case r.Method == http.MethodGet && r.URL.Path == "/builds/build-1":
+		json.NewEncoder(w).Encode(f.status)
+	case r.Method == http.MethodDelete && r.URL.Path == "/builds/build-1":
+		f.canceled = append(f.canceled, "build-1")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeWebhookBuildServer) setStatus(status webhookBuildStatus) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status = status
+}
+
+func TestWebhookBuildController(t *testing.T) {
+	t.Parallel()
+
+	buildServer := &fakeWebhookBuildServer{}
+	server := httptest.NewTLSServer(buildServer)
+	t.Cleanup(server.Close)
+
+	clients := getClientsForTest()
+	_, err := clients.kubeclient.CoreV1().Secrets(ctrlcommon.MCONamespace).Create(context.TODO(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "webhook-secret",
+			Namespace: ctrlcommon.MCONamespace,
+		},
+		Data: map[string][]byte{
+			webhookTokenSecretKey: []byte("s3kr1t\n"),
+			webhookCASecretKey:    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}),
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	type stateChange struct {
+		pool  string
+		state buildObjectState
+	}
+
+	stateChanges := []stateChange{}
+	ctrl := newWebhookBuildController(BuildControllerConfig{}, clients, func(pool string, state buildObjectState, _ *corev1.ObjectReference) error {
+		stateChanges = append(stateChanges, stateChange{pool: pool, state: state})
+		return nil
+	})
+
+	pool := newMachineConfigPool("worker", "rendered-worker-1")
+	mosc := newMachineOSConfig(pool)
+	mosc.Annotations = map[string]string{
+		constants.ImageBuilderBackendAnnotationKey: string(WebhookImageBuilderBackend),
+		constants.WebhookURLAnnotationKey:          server.URL + "/builds",
+		constants.WebhookSecretAnnotationKey:       "webhook-secret",
+	}
+	mosc.Status.CurrentImagePullspec = expectedImagePullspecWithTag
+	mosb := newMachineOSBuild(mosc, pool)
+
+	ibr := &fakeBuildRequest{opts: buildrequest.BuildRequestOpts{MachineOSConfig: mosc, MachineOSBuild: mosb}}
+
+	_, err = ctrl.StartBuild(ibr)
+	require.NoError(t, err)
+
+	// Starting the build again reuses the build handed to the external build system.
+	_, err = ctrl.StartBuild(ibr)
+	require.NoError(t, err)
+
+	require.Len(t, buildServer.requests, 1)
+	assert.Equal(t, "FROM base", buildServer.requests[0].Containerfile)
+	assert.Equal(t, map[string]string{"machineconfig/machineconfig.json.gz": "H4sI"}, buildServer.requests[0].BuildContext)
+	assert.Equal(t, expectedImagePullspecWithTag, buildServer.requests[0].Pushspec)
+	assert.Equal(t, "worker", buildServer.requests[0].Pool)
+
+	running, err := ctrl.IsBuildRunning(mosb, mosc)
+	require.NoError(t, err)
+	assert.True(t, running)
+
+	getTrackingConfigMap := func() *corev1.ConfigMap {
+		cm, err := clients.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), buildrequest.GetWebhookBuildConfigMapName(mosb), metav1.GetOptions{})
+		require.NoError(t, err)
+		return cm
+	}
+
+	// Polling the same state again does not report a state change.
+	buildServer.setStatus(webhookBuildStatus{State: "Running"})
+	require.NoError(t, ctrl.pollBuild(getTrackingConfigMap()))
+	require.NoError(t, ctrl.pollBuild(getTrackingConfigMap()))
+	assert.Equal(t, []stateChange{{pool: "worker", state: buildObjectRunning}}, stateChanges)
+
+	// A successful build needs a digested pullspec.
+	buildServer.setStatus(webhookBuildStatus{State: "Succeeded", Pullspec: expectedImagePullspecWithTag})
+	assert.Error(t, ctrl.pollBuild(getTrackingConfigMap()))
+
+	buildServer.setStatus(webhookBuildStatus{State: "Succeeded", Pullspec: expectedImagePullspecWithSHA})
+	require.NoError(t, ctrl.pollBuild(getTrackingConfigMap()))
+	assert.Equal(t, buildObjectSucceeded, stateChanges[len(stateChanges)-1].state)
+
+	digestCM, err := clients.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), buildrequest.GetDigestConfigMapName(mosb), metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, expectedImageSHA, digestCM.Data["digest"])
+
+	running, err = ctrl.IsBuildRunning(mosb, mosc)
+	require.NoError(t, err)
+	assert.False(t, running)
+
+	// A finished build is not canceled.
+	require.NoError(t, ctrl.DeleteBuildObject(mosb, mosc))
+	assert.Empty(t, buildServer.canceled)
+
+	for _, name := range []string{buildrequest.GetWebhookBuildConfigMapName(mosb), buildrequest.GetDigestConfigMapName(mosb)} {
+		_, err := clients.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), name, metav1.GetOptions{})
+		assert.True(t, k8serrors.IsNotFound(err), name)
+	}
+
+	// An unfinished build is canceled.
+	_, err = ctrl.StartBuild(ibr)
+	require.NoError(t, err)
+	require.NoError(t, ctrl.DeleteBuildObject(mosb, mosc))
+	assert.Equal(t, []string{"build-1"}, buildServer.canceled)
+}
+
+func TestWebhookBuildControllerRejectsUntrustedServer(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(&fakeWebhookBuildServer{})
+	t.Cleanup(server.Close)
+
+	clients := getClientsForTest()
+	ctrl := newWebhookBuildController(BuildControllerConfig{}, clients, nil)
+
+	pool := newMachineConfigPool("worker", "rendered-worker-1")
+	mosc := newMachineOSConfig(pool)
+	mosc.Annotations = map[string]string{
+		constants.ImageBuilderBackendAnnotationKey: string(WebhookImageBuilderBackend),
+		constants.WebhookURLAnnotationKey:          server.URL + "/builds",
+	}
+
+	_, err := ctrl.StartBuild(&fakeBuildRequest{opts: buildrequest.BuildRequestOpts{MachineOSConfig: mosc, MachineOSBuild: newMachineOSBuild(mosc, pool)}})
+	assert.ErrorContains(t, err, "certificate")
+
+	// Nothing is tracked for a build which was never started.
+	cms, err := clients.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).List(context.TODO(), metav1.ListOptions{LabelSelector: constants.WebhookBuildSelector().String()})
+	require.NoError(t, err)
+	assert.Empty(t, cms.Items)
+}
@@ -85,6 +85,78 @@ const (
 
 	ServiceCARotateAnnotation = "machineconfiguration.openshift.io/service-ca-rotate"
 
+	// RenderedConfigRetentionCountAnnotationKey is set on a MachineConfigPool to enable garbage collection of its rendered
+	// MachineConfigs. Its value is the number of unused rendered MachineConfigs to keep around, newest first.
+	RenderedConfigRetentionCountAnnotationKey = "machineconfiguration.openshift.io/rendered-config-retention-count"
+
+	// RenderedConfigRetentionAgeAnnotationKey is set on a MachineConfigPool to enable garbage collection of its rendered
+	// MachineConfigs. Its value is a duration (e.g. "720h"); unused rendered MachineConfigs younger than this are kept.
+	RenderedConfigRetentionAgeAnnotationKey = "machineconfiguration.openshift.io/rendered-config-retention-age"
+
+	// RenderedConfigHistoryAnnotationKey is set by the node controller on a MachineConfigPool to a JSON list of the
+	// rendered MachineConfigs the pool successfully completed an update to, and their sources, newest first.
+	RenderedConfigHistoryAnnotationKey = "machineconfiguration.openshift.io/rendered-config-history"
+
+	// RenderedConfigHistoryLimitAnnotationKey is set on a MachineConfigPool to the number of successfully completed
+	// rendered MachineConfigs recorded in its RenderedConfigHistoryAnnotationKey annotation. Defaults to
+	// DefaultRenderedConfigHistoryLimit.
+	RenderedConfigHistoryLimitAnnotationKey = "machineconfiguration.openshift.io/rendered-config-history-limit"
+
+	// RollbackToAnnotationKey is set on a MachineConfigPool to the name of a rendered MachineConfig from its history to
+	// roll the pool back to. New rendered MachineConfigs are not generated for the pool until it is removed.
+	RollbackToAnnotationKey = "machineconfiguration.openshift.io/rollback-to"
+
+	// NodeOverlayLabelKey is set on a MachineConfig to the name of the node it is an overlay for. The
+	// machine-config-server merges node overlays into the config it serves to that node when it boots.
+	NodeOverlayLabelKey = "machineconfiguration.openshift.io/node-overlay"
+
+	// UpdateStrategyAnnotationKey is set on a MachineConfigPool to select the order in which its nodes are updated.
+	// Its value is one of the UpdateStrategy* values below; if unset, nodes are updated in zone order.
+	UpdateStrategyAnnotationKey = "machineconfiguration.openshift.io/update-strategy"
+
+	// UpdateStrategyPriorityLabelAnnotationKey is the key of the node label whose integer value orders the
+	// update of a pool using the LabelPriority strategy; lower values are updated first.
+	UpdateStrategyPriorityLabelAnnotationKey = "machineconfiguration.openshift.io/update-strategy-priority-label"
+
+	// UpdateStrategyCanarySelectorAnnotationKey is the label selector for the canary nodes of a pool using the
+	// Canary strategy.
+	UpdateStrategyCanarySelectorAnnotationKey = "machineconfiguration.openshift.io/update-strategy-canary-selector"
+
+	// UpdateStrategyCanarySoakAnnotationKey is a duration (e.g. "30m") for which the canary nodes of a pool using
+	// the Canary strategy must stay Ready after updating before the rest of the pool is updated.
+	UpdateStrategyCanarySoakAnnotationKey = "machineconfiguration.openshift.io/update-strategy-canary-soak"
+
+	// UpdateStrategyZone updates the nodes of one topology zone at a time.
+	UpdateStrategyZone = "Zone"
+	// UpdateStrategyLabelPriority updates nodes in the order given by a node label.
+	UpdateStrategyLabelPriority = "LabelPriority"
+	// UpdateStrategyLeastLoaded updates the nodes running the fewest non-DaemonSet pods first.
+	UpdateStrategyLeastLoaded = "LeastLoaded"
+	// UpdateStrategyCanary updates a set of canary nodes, then waits for them to soak before updating the rest.
+	UpdateStrategyCanary = "Canary"
+
+	// FailureBudgetAnnotationKey is set on a MachineConfigPool to the number of its nodes which may fail to update to
+	// a new rendered MachineConfig before the rollout of that MachineConfig is paused.
+	FailureBudgetAnnotationKey = "machineconfiguration.openshift.io/failure-budget"
+
+	// FailureBudgetAcknowledgedAnnotationKey is set on a MachineConfigPool to the name of its target rendered
+	// MachineConfig to acknowledge the node failures during its rollout and resume it.
+	FailureBudgetAcknowledgedAnnotationKey = "machineconfiguration.openshift.io/failure-budget-acknowledged"
+
+	// FailureBudgetExceededAnnotationKey is set by the node controller on a MachineConfigPool to the name of the
+	// rendered MachineConfig whose rollout exceeded the failure budget of the pool. The rollout stays paused while it
+	// matches the pool's target rendered MachineConfig.
+	FailureBudgetExceededAnnotationKey = "machineconfiguration.openshift.io/failure-budget-exceeded"
+
+	// MaintenanceWindowAnnotationKey is set on a MachineConfigPool to the maintenance windows in which updates that
+	// drain or reboot its nodes may start. Windows are separated by ";", each being a cron schedule for the start of
+	// the window followed by its duration, e.g. "0 1 * * * 4h".
+	MaintenanceWindowAnnotationKey = "machineconfiguration.openshift.io/maintenance-window"
+
+	// MaintenanceWindowTimeZoneAnnotationKey is the IANA time zone (e.g. "Europe/Berlin") in which the maintenance
+	// windows of a MachineConfigPool are evaluated. Defaults to UTC.
+	MaintenanceWindowTimeZoneAnnotationKey = "machineconfiguration.openshift.io/maintenance-window-timezone"
+
 	ServiceCARotateTrue  = "true"
 	ServiceCARotateFalse = "false"
 )
@@ -95,4 +167,6 @@ const (
 	MachineConfigOperatorImagesConfigMapName string = "machine-config-operator-images"
 	// The name of the machine-config-osimageurl ConfigMap.
 	MachineConfigOSImageURLConfigMapName string = "machine-config-osimageurl"
+	// The name of the ConfigMap listing the node identities the machine-config-server serves node overlays to.
+	MachineConfigServerNodeAllowlistConfigMapName string = "machine-config-server-node-allowlist"
 )
@@ -0,0 +1,95 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"k8s.io/klog/v2"
+)
+
+// ExtensionsCatalogueFile is the path, relative to the root of the extensions
+// container, of the metadata listing the extensions it provides.
+const ExtensionsCatalogueFile = "usr/share/rpm-ostree/extensions/catalogue.json"
+
+// ExtensionsCatalogue maps the name of each extension available in an
+// extensions container to the packages required to enable it on a host.
+type ExtensionsCatalogue map[string][]string
+
+// DefaultExtensionsCatalogue returns the RHCOS extensions known to this
+// version of the MCO. It is used for extensions containers which predate the
+// catalogue metadata.
+func DefaultExtensionsCatalogue() ExtensionsCatalogue {
+	return ExtensionsCatalogue{
+		"wasm":                 {"crun-wasm"},
+		"ipsec":                {"NetworkManager-libreswan", "libreswan"},
+		"usbguard":             {"usbguard"},
+		"kerberos":             {"krb5-workstation", "libkadm5"},
+		"kernel-devel":         {"kernel-devel", "kernel-headers"},
+		"sandboxed-containers": {"kata-containers"},
+		"sysstat":              {"sysstat"},
+	}
+}
+
+// ParseExtensionsCatalogue parses the catalogue metadata of an extensions
+// container, a JSON object mapping each extension to its list of packages.
+func ParseExtensionsCatalogue(data []byte) (ExtensionsCatalogue, error) {
+	catalogue := ExtensionsCatalogue{}
+	if err := json.Unmarshal(data, &catalogue); err != nil {
+		return nil, fmt.Errorf("could not parse extensions catalogue: %w", err)
+	}
+
+	for ext, pkgs := range catalogue {
+		if ext == "" {
+			return nil, fmt.Errorf("extensions catalogue contains an extension without a name")
+		}
+		if len(pkgs) == 0 {
+			return nil, fmt.Errorf("extension %q in the extensions catalogue has no packages", ext)
+		}
+	}
+
+	return catalogue, nil
+}
+
+// ReadExtensionsCatalogue reads the catalogue from an extensions container
+// extracted to dir. The default catalogue is returned if the container does
+// not ship one.
+func ReadExtensionsCatalogue(dir string) (ExtensionsCatalogue, error) {
+	path := filepath.Join(dir, ExtensionsCatalogueFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		klog.Infof("Extensions container has no catalogue at %s, using the default extensions", path)
+		return DefaultExtensionsCatalogue(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read extensions catalogue: %w", err)
+	}
+
+	return ParseExtensionsCatalogue(data)
+}
+
+// Validate returns an error listing the extensions which are not in the catalogue.
+func (c ExtensionsCatalogue) Validate(exts []string) error {
+	invalidExts := []string{}
+	for _, ext := range exts {
+		if _, ok := c[ext]; !ok {
+			invalidExts = append(invalidExts, ext)
+		}
+	}
+	if len(invalidExts) != 0 {
+		return fmt.Errorf("invalid extensions found: %v", invalidExts)
+	}
+	return nil
+}
+
+// Names returns the sorted names of the extensions in the catalogue.
+func (c ExtensionsCatalogue) Names() []string {
+	names := make([]string, 0, len(c))
+	for ext := range c {
+		names = append(names, ext)
+	}
+	sort.Strings(names)
+	return names
+}
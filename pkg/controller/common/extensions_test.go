@@ -0,0 +1,44 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExtensionsCatalogue(t *testing.T) {
+	catalogue, err := ParseExtensionsCatalogue([]byte(`{"usbguard": ["usbguard"], "ipsec": ["NetworkManager-libreswan", "libreswan"]}`))
+	require.NoError(t, err)
+	assert.Equal(t, ExtensionsCatalogue{"usbguard": {"usbguard"}, "ipsec": {"NetworkManager-libreswan", "libreswan"}}, catalogue)
+	assert.Equal(t, []string{"ipsec", "usbguard"}, catalogue.Names())
+
+	assert.NoError(t, catalogue.Validate([]string{"ipsec"}))
+	assert.EqualError(t, catalogue.Validate([]string{"ipsec", "kerberos", "wasm"}), "invalid extensions found: [kerberos wasm]")
+
+	_, err = ParseExtensionsCatalogue([]byte(`{"usbguard": []}`))
+	assert.Error(t, err)
+	_, err = ParseExtensionsCatalogue([]byte(`{"": ["usbguard"]}`))
+	assert.Error(t, err)
+	_, err = ParseExtensionsCatalogue([]byte(`["usbguard"]`))
+	assert.Error(t, err)
+}
+
+func TestReadExtensionsCatalogue(t *testing.T) {
+	dir := t.TempDir()
+
+	// Extensions containers without a catalogue get the default one
+	catalogue, err := ReadExtensionsCatalogue(dir)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultExtensionsCatalogue(), catalogue)
+
+	path := filepath.Join(dir, ExtensionsCatalogueFile)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(`{"nvidia-drivers": ["nvidia-driver", "nvidia-driver-cuda"]}`), 0o644))
+
+	catalogue, err = ReadExtensionsCatalogue(dir)
+	require.NoError(t, err)
+	assert.Equal(t, ExtensionsCatalogue{"nvidia-drivers": {"nvidia-driver", "nvidia-driver-cuda"}}, catalogue)
+}
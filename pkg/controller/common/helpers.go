@@ -22,6 +22,7 @@ import (
 
 	"github.com/clarketm/json"
 	fcctbase "github.com/coreos/fcct/base/v0_1"
+	"github.com/coreos/go-semver/semver"
 	"github.com/coreos/ign-converter/translate/v23tov30"
 	"github.com/coreos/ign-converter/translate/v32tov22"
 	"github.com/coreos/ign-converter/translate/v32tov31"
@@ -44,6 +45,8 @@ import (
 	ign3_4 "github.com/coreos/ignition/v2/config/v3_4"
 	translate3 "github.com/coreos/ignition/v2/config/v3_4/translate"
 	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	translate3_5 "github.com/coreos/ignition/v2/config/v3_5_experimental/translate"
+	ign3_5types "github.com/coreos/ignition/v2/config/v3_5_experimental/types"
 	validate3 "github.com/coreos/ignition/v2/config/validate"
 	"github.com/ghodss/yaml"
 	"github.com/vincent-petithory/dataurl"
@@ -265,159 +268,133 @@ func WriteTerminationError(err error) {
 	klog.Fatal(msg)
 }
 
-// ConvertRawExtIgnitionToV3 ensures that the Ignition config in
-// the RawExtension is spec v3.2, or translates to it.
-func ConvertRawExtIgnitionToV3_4(inRawExtIgn *runtime.RawExtension) (runtime.RawExtension, error) {
+// ign3_5Version is Ignition spec v3.5. The vendored Ignition release ships it
+// as v3_5_experimental, whose types are a subset of the stable v3.5 types, so a
+// config translated from v3.4 is a valid v3.5.0 config.
+var ign3_5Version = semver.Version{Major: 3, Minor: 5}
+
+// ignitionSpecConversion is a rung of the Ignition conversion ladder: it
+// converts a config of spec version from to spec version version.
+type ignitionSpecConversion struct {
+	version semver.Version
+	from    semver.Version
+	convert func(interface{}) (interface{}, error)
+}
 
-	// Parse the raw extension to the MCO's current internal ignition version
-	ignCfgV3, err := IgnParseWrapper(inRawExtIgn.Raw)
-	if err != nil {
-		return runtime.RawExtension{}, err
-	}
+// ignitionSpecConversions is the Ignition conversion ladder. Configs are kept
+// at the internal spec version ign3types.MaxVersion and converted along
+// these rungs to whatever version a consumer asks for. Supporting a new spec
+// version only requires a rung from an already supported version.
+var ignitionSpecConversions = []ignitionSpecConversion{
+	{
+		version: ign3_5Version,
+		from:    ign3types.MaxVersion,
+		convert: func(cfg interface{}) (interface{}, error) { return convertIgnition34to35(cfg.(ign3types.Config)) },
+	},
+	{
+		version: ign3_3types.MaxVersion,
+		from:    ign3types.MaxVersion,
+		convert: func(cfg interface{}) (interface{}, error) { return convertIgnition34to33(cfg.(ign3types.Config)) },
+	},
+	{
+		version: ign3_2types.MaxVersion,
+		from:    ign3_3types.MaxVersion,
+		convert: func(cfg interface{}) (interface{}, error) { return convertIgnition33to32(cfg.(ign3_3types.Config)) },
+	},
+	{
+		version: ign3_1types.MaxVersion,
+		from:    ign3_2types.MaxVersion,
+		convert: func(cfg interface{}) (interface{}, error) { return convertIgnition32to31(cfg.(ign3_2types.Config)) },
+	},
+	{
+		version: ign2types.MaxVersion,
+		from:    ign3_2types.MaxVersion,
+		convert: func(cfg interface{}) (interface{}, error) { return convertIgnition32to22(cfg.(ign3_2types.Config)) },
+	},
+}
 
-	// TODO(jkyros): we used to only re-marshal this if it was the wrong version, now we're
-	// re-marshaling every time
-	outIgnV3, err := json.Marshal(ignCfgV3)
-	if err != nil {
-		return runtime.RawExtension{}, fmt.Errorf("failed to marshal converted config: %w", err)
+// SupportedIgnitionSpecVersions returns the Ignition spec versions configs
+// can be converted to, newest first.
+func SupportedIgnitionSpecVersions() []semver.Version {
+	versions := []semver.Version{ign3types.MaxVersion}
+	for _, conversion := range ignitionSpecConversions {
+		versions = append(versions, conversion.version)
 	}
+	sort.Slice(versions, func(i, j int) bool { return versions[j].LessThan(versions[i]) })
+	return versions
+}
 
-	outRawExt := runtime.RawExtension{}
-	outRawExt.Raw = outIgnV3
-
-	return outRawExt, nil
+// getIgnitionConversionChain returns the rungs of the conversion ladder leading
+// from the internal spec version to version, in the order they are applied.
+func getIgnitionConversionChain(version semver.Version) ([]ignitionSpecConversion, error) {
+	chain := []ignitionSpecConversion{}
+	for !version.Equal(ign3types.MaxVersion) {
+		found := false
+		for _, conversion := range ignitionSpecConversions {
+			if conversion.version.Equal(version) {
+				chain = append([]ignitionSpecConversion{conversion}, chain...)
+				version = conversion.from
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unsupported Ignition spec version %s", version)
+		}
+	}
+	return chain, nil
 }
 
-// ConvertRawExtIgnitionToV3_3 ensures that the Ignition config in
-// the RawExtension is spec v3.3, or translates to it.
-func ConvertRawExtIgnitionToV3_3(inRawExtIgn *runtime.RawExtension) (runtime.RawExtension, error) {
-	rawExt, err := ConvertRawExtIgnitionToV3_4(inRawExtIgn)
+// ConvertRawExtIgnitionToVersion ensures that the Ignition config in the
+// RawExtension is of the given spec version, or translates to it.
+func ConvertRawExtIgnitionToVersion(inRawExtIgn *runtime.RawExtension, version semver.Version) (runtime.RawExtension, error) {
+	// Parse the raw extension to the MCO's current internal ignition version
+	ignCfg, err := ParseAndConvertConfig(inRawExtIgn.Raw)
 	if err != nil {
 		return runtime.RawExtension{}, err
 	}
 
-	ignCfgV3, rptV3, errV3 := ign3.Parse(rawExt.Raw)
-	if errV3 != nil || rptV3.IsFatal() {
-		return runtime.RawExtension{}, fmt.Errorf("parsing Ignition config failed with error: %w\nReport: %v", errV3, rptV3)
-	}
-
-	// TODO(jkyros): someday we should write a recursive chain-downconverter, but until then,
-	// we're going to do it the hard way
-	ignCfgV33, err := convertIgnition34to33(ignCfgV3)
+	converted, err := convertIgnition(ignCfg, version)
 	if err != nil {
 		return runtime.RawExtension{}, err
 	}
 
-	outIgnV33, err := json.Marshal(ignCfgV33)
+	outIgn, err := json.Marshal(converted)
 	if err != nil {
 		return runtime.RawExtension{}, fmt.Errorf("failed to marshal converted config: %w", err)
 	}
 
-	outRawExt := runtime.RawExtension{}
-	outRawExt.Raw = outIgnV33
+	return runtime.RawExtension{Raw: outIgn}, nil
+}
 
-	return outRawExt, nil
+// ConvertRawExtIgnitionToV3_4 ensures that the Ignition config in
+// the RawExtension is spec v3.4, or translates to it.
+func ConvertRawExtIgnitionToV3_4(inRawExtIgn *runtime.RawExtension) (runtime.RawExtension, error) {
+	return ConvertRawExtIgnitionToVersion(inRawExtIgn, ign3types.MaxVersion)
 }
 
 // ConvertRawExtIgnitionToV3_3 ensures that the Ignition config in
 // the RawExtension is spec v3.3, or translates to it.
-func ConvertRawExtIgnitionToV3_2(inRawExtIgn *runtime.RawExtension) (runtime.RawExtension, error) {
-	rawExt, err := ConvertRawExtIgnitionToV3_4(inRawExtIgn)
-	if err != nil {
-		return runtime.RawExtension{}, err
-	}
-
-	ignCfgV3, rptV3, errV3 := ign3.Parse(rawExt.Raw)
-	if errV3 != nil || rptV3.IsFatal() {
-		return runtime.RawExtension{}, fmt.Errorf("parsing Ignition config failed with error: %w\nReport: %v", errV3, rptV3)
-	}
-
-	// TODO(jkyros): someday we should write a recursive chain-downconverter, but until then,
-	// we're going to do it the hard way
-	ignCfgV33, err := convertIgnition34to33(ignCfgV3)
-	if err != nil {
-		return runtime.RawExtension{}, err
-	}
-
-	ignCfgV32, err := convertIgnition33to32(ignCfgV33)
-	if err != nil {
-		return runtime.RawExtension{}, err
-	}
-
-	outIgnV32, err := json.Marshal(ignCfgV32)
-	if err != nil {
-		return runtime.RawExtension{}, fmt.Errorf("failed to marshal converted config: %w", err)
-	}
-
-	outRawExt := runtime.RawExtension{}
-	outRawExt.Raw = outIgnV32
+func ConvertRawExtIgnitionToV3_3(inRawExtIgn *runtime.RawExtension) (runtime.RawExtension, error) {
+	return ConvertRawExtIgnitionToVersion(inRawExtIgn, ign3_3types.MaxVersion)
+}
 
-	return outRawExt, nil
+// ConvertRawExtIgnitionToV3_2 ensures that the Ignition config in
+// the RawExtension is spec v3.2, or translates to it.
+func ConvertRawExtIgnitionToV3_2(inRawExtIgn *runtime.RawExtension) (runtime.RawExtension, error) {
+	return ConvertRawExtIgnitionToVersion(inRawExtIgn, ign3_2types.MaxVersion)
 }
 
 // ConvertRawExtIgnitionToV3_1 ensures that the Ignition config in
 // the RawExtension is spec v3.1, or translates to it.
 func ConvertRawExtIgnitionToV3_1(inRawExtIgn *runtime.RawExtension) (runtime.RawExtension, error) {
-	rawExt, err := ConvertRawExtIgnitionToV3_4(inRawExtIgn)
-	if err != nil {
-		return runtime.RawExtension{}, err
-	}
-
-	ignCfgV3, rptV3, errV3 := ign3.Parse(rawExt.Raw)
-	if errV3 != nil || rptV3.IsFatal() {
-		return runtime.RawExtension{}, fmt.Errorf("parsing Ignition config failed with error: %w\nReport: %v", errV3, rptV3)
-	}
-
-	// TODO(jkyros): someday we should write a recursive chain-downconverter, but until then,
-	// we're going to do it the hard way
-	ignCfgV33, err := convertIgnition34to33(ignCfgV3)
-	if err != nil {
-		return runtime.RawExtension{}, err
-	}
-
-	ignCfgV32, err := convertIgnition33to32(ignCfgV33)
-	if err != nil {
-		return runtime.RawExtension{}, err
-	}
-
-	ignCfgV31, err := convertIgnition32to31(ignCfgV32)
-	if err != nil {
-		return runtime.RawExtension{}, err
-	}
-
-	outIgnV31, err := json.Marshal(ignCfgV31)
-	if err != nil {
-		return runtime.RawExtension{}, fmt.Errorf("failed to marshal converted config: %w", err)
-	}
-
-	outRawExt := runtime.RawExtension{}
-	outRawExt.Raw = outIgnV31
-
-	return outRawExt, nil
+	return ConvertRawExtIgnitionToVersion(inRawExtIgn, ign3_1types.MaxVersion)
 }
 
-// ConvertRawExtIgnitionToV2 ensures that the Ignition config in
+// ConvertRawExtIgnitionToV2_2 ensures that the Ignition config in
 // the RawExtension is spec v2.2, or translates to it.
 func ConvertRawExtIgnitionToV2_2(inRawExtIgn *runtime.RawExtension) (runtime.RawExtension, error) {
-	ignCfg, rpt, err := ign3.Parse(inRawExtIgn.Raw)
-	if err != nil || rpt.IsFatal() {
-		return runtime.RawExtension{}, fmt.Errorf("parsing Ignition config spec v3.2 failed with error: %w\nReport: %v", err, rpt)
-	}
-
-	converted2, err := convertIgnition34to22(ignCfg)
-	if err != nil {
-		return runtime.RawExtension{}, fmt.Errorf("failed to convert config from spec v3.2 to v2.2: %w", err)
-	}
-
-	outIgnV2, err := json.Marshal(converted2)
-	if err != nil {
-		return runtime.RawExtension{}, fmt.Errorf("failed to marshal converted config: %w", err)
-	}
-
-	outRawExt := runtime.RawExtension{}
-	outRawExt.Raw = outIgnV2
-
-	return outRawExt, nil
+	return ConvertRawExtIgnitionToVersion(inRawExtIgn, ign2types.MaxVersion)
 }
 
 // convertIgnition2to3 takes an ignition spec v2.2 config and returns a v3.2 config
@@ -440,29 +417,57 @@ func convertIgnition22to34(ign2config ign2types.Config) (ign3types.Config, error
 	return converted3, nil
 }
 
-// convertIgnition3to2 takes an ignition spec v3.2 config and returns a v2.2 config
+// convertIgnition34to22 takes an ignition spec v3.4 config and returns a v2.2 config
 func convertIgnition34to22(ign3config ign3types.Config) (ign2types.Config, error) {
-
-	// TODO(jkyros): that recursive down-converter is looking like a better idea all the time
-	converted33, err := convertIgnition34to33(ign3config)
+	converted, err := convertIgnition(ign3config, ign2types.MaxVersion)
 	if err != nil {
 		return ign2types.Config{}, fmt.Errorf("unable to convert Ignition spec v3 config to v2: %w", err)
 	}
+	return converted.(ign2types.Config), nil
+}
 
-	converted32, err := convertIgnition33to32(converted33)
+// convertIgnition takes an ignition config of the internal spec version and
+// converts it down the conversion ladder to the given version.
+func convertIgnition(ign3config ign3types.Config, version semver.Version) (interface{}, error) {
+	chain, err := getIgnitionConversionChain(version)
 	if err != nil {
-		return ign2types.Config{}, fmt.Errorf("unable to convert Ignition spec v3 config to v2: %w", err)
+		return nil, err
 	}
 
-	converted2, err := v32tov22.Translate(converted32)
+	var converted interface{} = ign3config
+	for _, conversion := range chain {
+		converted, err = conversion.convert(converted)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert config from spec v%s to v%s: %w", conversion.from, conversion.version, err)
+		}
+	}
+	return converted, nil
+}
+
+// convertIgnition32to22 takes an ignition spec v3.2 config and returns a v2.2 config
+func convertIgnition32to22(ign3config ign3_2types.Config) (ign2types.Config, error) {
+	converted2, err := v32tov22.Translate(ign3config)
 	if err != nil {
-		return ign2types.Config{}, fmt.Errorf("unable to convert Ignition spec v3 config to v2: %w", err)
+		return ign2types.Config{}, fmt.Errorf("unable to convert Ignition spec v3_2 config to v2_2: %w", err)
 	}
-	klog.V(4).Infof("Successfully translated Ignition spec v3 config to Ignition spec v2 config: %v", converted2)
+	klog.V(4).Infof("Successfully translated Ignition spec v3_2 config to Ignition spec v2_2 config: %v", converted2)
 
 	return converted2, nil
 }
 
+// convertIgnition34to35 takes an ignition spec v3.4 config and returns a v3.5 config
+func convertIgnition34to35(ign3config ign3types.Config) (ign3_5types.Config, error) {
+	converted35 := translate3_5.Translate(ign3config)
+	// The experimental types only validate with their own version string.
+	if rpt := validate3.ValidateWithContext(converted35, nil); rpt.IsFatal() {
+		return ign3_5types.Config{}, fmt.Errorf("unable to convert Ignition spec v3_4 config to v3_5: %v", rpt)
+	}
+	converted35.Ignition.Version = ign3_5Version.String()
+	klog.V(4).Infof("Successfully translated Ignition spec v3_4 config to Ignition spec v3_5 config: %v", converted35)
+
+	return converted35, nil
+}
+
 // convertIgnition34to33 takes an ignition spec v3.4config and returns a v3.3 config
 func convertIgnition34to33(ign3config ign3types.Config) (ign3_3types.Config, error) {
 	converted33, err := v34tov33.Translate(ign3config)
@@ -975,20 +980,13 @@ func dedupePasswdUserSSHKeys(passwdUser ign2types.PasswdUser) ign2types.PasswdUs
 	return passwdUser
 }
 
-// CalculateConfigFileDiffs compares the files present in two ignition configurations and returns the list of files
-// that are different between them
-//
-//nolint:dupl
+// CalculateConfigFileDiffs compares the files, directories and links present in two ignition configurations and
+// returns the list of paths that are different between them
 func CalculateConfigFileDiffs(oldIgnConfig, newIgnConfig *ign3types.Config) []string {
-	// Go through the files and see what is new or different
-	oldFileSet := make(map[string]ign3types.File)
-	for _, f := range oldIgnConfig.Storage.Files {
-		oldFileSet[f.Path] = f
-	}
-	newFileSet := make(map[string]ign3types.File)
-	for _, f := range newIgnConfig.Storage.Files {
-		newFileSet[f.Path] = f
-	}
+	// Go through the files, directories and links and see what is new or different.
+	// Ignition validation makes sure a path belongs to only one of them.
+	oldFileSet := storageNodeSet(oldIgnConfig)
+	newFileSet := storageNodeSet(newIgnConfig)
 	diffFileSet := []string{}
 
 	// First check if any files were removed
@@ -1011,6 +1009,22 @@ func CalculateConfigFileDiffs(oldIgnConfig, newIgnConfig *ign3types.Config) []st
 	return diffFileSet
 }
 
+// storageNodeSet maps the paths of the files, directories and links of an
+// ignition configuration to their definition.
+func storageNodeSet(ignConfig *ign3types.Config) map[string]interface{} {
+	nodeSet := make(map[string]interface{})
+	for _, f := range ignConfig.Storage.Files {
+		nodeSet[f.Path] = f
+	}
+	for _, d := range ignConfig.Storage.Directories {
+		nodeSet[d.Path] = d
+	}
+	for _, l := range ignConfig.Storage.Links {
+		nodeSet[l.Path] = l
+	}
+	return nodeSet
+}
+
 // CalculateConfigUnitDiffs compares the units present in two ignition configurations and returns the list of units
 // that are different between them
 //
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/clarketm/json"
+	"github.com/coreos/go-semver/semver"
 	ign2types "github.com/coreos/ignition/config/v2_2/types"
 	ign3 "github.com/coreos/ignition/v2/config/v3_4"
 	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
@@ -163,6 +164,49 @@ func TestConvertIgnition3to2(t *testing.T) {
 	require.Nil(t, isValid2)
 }
 
+func TestConvertRawExtIgnitionToVersion(t *testing.T) {
+	testIgn3Config := ign3types.Config{}
+	tempUser := ign3types.PasswdUser{Name: "core", SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"5678", "abc"}}
+	testIgn3Config.Passwd.Users = []ign3types.PasswdUser{tempUser}
+	testIgn3Config.Ignition.Version = "3.4.0"
+	rawIgn := &runtime.RawExtension{Raw: helpers.MarshalOrDie(testIgn3Config)}
+
+	versions := SupportedIgnitionSpecVersions()
+	assert.Equal(t, []semver.Version{
+		*semver.New("3.5.0"),
+		*semver.New("3.4.0"),
+		*semver.New("3.3.0"),
+		*semver.New("3.2.0"),
+		*semver.New("3.1.0"),
+		*semver.New("2.2.0"),
+	}, versions)
+
+	for _, version := range versions {
+		version := version
+		t.Run(version.String(), func(t *testing.T) {
+			converted, err := ConvertRawExtIgnitionToVersion(rawIgn, version)
+			require.NoError(t, err)
+
+			out := struct {
+				Ignition struct {
+					Version string `json:"version"`
+				} `json:"ignition"`
+			}{}
+			require.NoError(t, json.Unmarshal(converted.Raw, &out))
+			assert.Equal(t, version.String(), out.Ignition.Version)
+			assert.Contains(t, string(converted.Raw), "5678")
+		})
+	}
+
+	// Configs are converted from any parseable spec version.
+	converted, err := ConvertRawExtIgnitionToVersion(&runtime.RawExtension{Raw: []byte(`{"ignition":{"version":"2.2.0"}}`)}, *semver.New("3.1.0"))
+	require.NoError(t, err)
+	assert.Contains(t, string(converted.Raw), `"version":"3.1.0"`)
+
+	_, err = ConvertRawExtIgnitionToVersion(rawIgn, *semver.New("3.0.0"))
+	assert.Error(t, err)
+}
+
 func TestParseAndConvert(t *testing.T) {
 	// Make a new Ign3.2 config
 	testIgn3Config := ign3types.Config{}
@@ -753,6 +797,18 @@ func TestCalculateConfigFileDiffs(t *testing.T) {
 	if !reflect.DeepEqual(unchangedDiffFileset, []string{}) {
 		t.Errorf("File changes detected where there should have been none: %s", unchangedDiffFileset)
 	}
+
+	// Directories and links are compared the same way
+	testIgn3ConfigOld.Storage.Directories = []ign3types.Directory{{Node: ign3types.Node{Path: "/etc/foo"}}}
+	testIgn3ConfigNew.Storage.Files = testIgn3ConfigOld.Storage.Files
+	testIgn3ConfigNew.Storage.Directories = []ign3types.Directory{
+		{Node: ign3types.Node{Path: "/etc/foo"}, DirectoryEmbedded1: ign3types.DirectoryEmbedded1{Mode: helpers.IntToPtr(0o700)}},
+	}
+	testIgn3ConfigNew.Storage.Links = []ign3types.Link{
+		{Node: ign3types.Node{Path: "/etc/bar"}, LinkEmbedded1: ign3types.LinkEmbedded1{Target: helpers.StrToPtr("/etc/foo")}},
+	}
+	assert.ElementsMatch(t, []string{"/etc/foo", "/etc/bar"}, CalculateConfigFileDiffs(&testIgn3ConfigOld, &testIgn3ConfigNew))
+	assert.ElementsMatch(t, []string{"/etc/foo", "/etc/bar"}, CalculateConfigFileDiffs(&testIgn3ConfigNew, &testIgn3ConfigOld))
 }
 
 func TestParseAndConvertGzippedConfig(t *testing.T) {
@@ -44,6 +44,12 @@ var (
 			Name: "mcc_sub_controller_state",
 			Help: "state of sub-controllers in the MCC",
 		}, []string{"subcontroller", "state", "object"})
+	// MCCRenderedConfigsGarbageCollected counts the rendered MachineConfigs deleted by the render controller
+	MCCRenderedConfigsGarbageCollected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcc_rendered_configs_garbage_collected_total",
+			Help: "total number of rendered machineconfigs garbage collected",
+		}, []string{"pool"})
 )
 
 func RegisterMCCMetrics() error {
@@ -52,6 +58,7 @@ func RegisterMCCMetrics() error {
 		MCCDrainErr,
 		MCCPoolAlert,
 		MCCSubControllerState,
+		MCCRenderedConfigsGarbageCollected,
 	})
 
 	if err != nil {
@@ -64,6 +71,7 @@ func RegisterMCCMetrics() error {
 	MCCDrainErr.WithLabelValues("initialize").Set(0)
 	MCCPoolAlert.WithLabelValues("initialize").Set(0)
 	MCCSubControllerState.WithLabelValues("initialize", "initialize", "initialize").Set(0)
+	MCCRenderedConfigsGarbageCollected.WithLabelValues("initialize").Add(0)
 
 	return nil
 }
@@ -2,7 +2,11 @@ package common
 
 import (
 	"fmt"
+	"path"
 	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 
 	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
 	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
@@ -17,8 +21,8 @@ import (
 // rationale.
 //
 // We can only update machine configs that have changes to the files,
-// directories, links, and systemd units sections of the included ignition
-// config currently.
+// directories, links, systemd units, passwd and kernelArguments sections of
+// the included ignition config currently.
 func IsRenderedConfigReconcilable(oldConfig, newConfig *mcfgv1.MachineConfig) error {
 	return IsComponentConfigsReconcilable(oldConfig, []*mcfgv1.MachineConfig{newConfig})
 }
@@ -69,26 +73,20 @@ func isReconcilable(oldIgn ign3types.Config, oldConfig, newConfig *mcfgv1.Machin
 func isConfigReconcilable(oldIgn, newIgn ign3types.Config, oldConfig, newConfig *mcfgv1.MachineConfig) error {
 	// Passwd section
 
-	// we don't currently configure Groups in place. we don't configure Users except
-	// for setting/updating SSHAuthorizedKeys for the only allowed user "core".
-	// otherwise we can't fix it if something changed here.
+	// for the user "core" we only configure SSHAuthorizedKeys and the password
+	// hash in place. other users and groups can be managed as long as they are
+	// not system accounts. otherwise we can't fix it if something changed here.
 	if !reflect.DeepEqual(oldIgn.Passwd, newIgn.Passwd) {
 		if err := validatePasswdChanges(oldIgn, newIgn); err != nil {
 			return fmt.Errorf("invalid passwd change(s): %w", err)
 		}
 	}
 
-	// Kernel args
-
-	// ignition now supports kernel args, but the MCO doesn't implement them yet
-	if !reflect.DeepEqual(oldIgn.KernelArguments, newIgn.KernelArguments) {
-		return fmt.Errorf("ignition kargs section contains changes")
-	}
-
 	// Storage section
 
-	// we can only reconcile files right now. make sure the sections we can't
-	// fix aren't changed.
+	// we can only reconcile files, directories and links right now (validation
+	// already rejects paths claimed by more than one of them). make sure the
+	// sections we can't fix aren't changed.
 	if !reflect.DeepEqual(oldIgn.Storage.Disks, newIgn.Storage.Disks) {
 		return fmt.Errorf("ignition disks section contains changes")
 	}
@@ -98,17 +96,6 @@ func isConfigReconcilable(oldIgn, newIgn ign3types.Config, oldConfig, newConfig
 	if !reflect.DeepEqual(oldIgn.Storage.Raid, newIgn.Storage.Raid) {
 		return fmt.Errorf("ignition raid section contains changes")
 	}
-	if !reflect.DeepEqual(oldIgn.Storage.Directories, newIgn.Storage.Directories) {
-		return fmt.Errorf("ignition directories section contains changes")
-	}
-	if !reflect.DeepEqual(oldIgn.Storage.Links, newIgn.Storage.Links) {
-		// This means links have been added, as opposed as being removed as it happened with
-		// https://bugzilla.redhat.com/show_bug.cgi?id=1677198. This doesn't really change behavior
-		// since we still don't support links but we allow old MC to remove links when upgrading.
-		if len(newIgn.Storage.Links) != 0 {
-			return fmt.Errorf("ignition links section contains changes")
-		}
-	}
 
 	// Special case files append: if the new config wants us to append, then we
 	// have to force a reprovision since it's not idempotent
@@ -136,8 +123,8 @@ func isConfigReconcilable(oldIgn, newIgn ign3types.Config, oldConfig, newConfig
 // verifyUserFields returns nil for the user Name = "core" if 1 or more SSHKeys exist for
 // this user or if a password exists for this user and if all other fields in User are empty.
 // Otherwise, an error will be returned and the proposed config will not be reconcilable.
-// At this time we do not support non-"core" users or any changes to the "core" user
-// outside of SSHAuthorizedKeys and passwordHash.
+// At this time we do not support any changes to the "core" user outside of
+// SSHAuthorizedKeys and passwordHash.
 func verifyUserFields(pwdUser ign3types.PasswdUser) error {
 	emptyUser := ign3types.PasswdUser{}
 	tempUser := pwdUser
@@ -155,25 +142,163 @@ func verifyUserFields(pwdUser ign3types.PasswdUser) error {
 	return nil
 }
 
+// accountNameRegexp matches the user and group names the MCD is willing to
+// create, which is the portable subset of what shadow-utils accepts.
+var accountNameRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+
+// verifyAccountName checks that name can be used for a user or group managed
+// on running nodes.
+func verifyAccountName(name string) error {
+	if !accountNameRegexp.MatchString(name) {
+		return fmt.Errorf("%q is not a valid user or group name", name)
+	}
+	if name == "root" || name == constants.CoreUserName || name == constants.CoreGroupName {
+		return fmt.Errorf("%q is a system account", name)
+	}
+	return nil
+}
+
+// verifyAccountID checks that id, if set, is not the id of a system account.
+func verifyAccountID(id *int) error {
+	if id != nil && (*id < constants.ManagedAccountMinID || *id > constants.ManagedAccountMaxID) {
+		return fmt.Errorf("id %d is reserved for system accounts, it must be between %d and %d", *id, constants.ManagedAccountMinID, constants.ManagedAccountMaxID)
+	}
+	return nil
+}
+
+// privilegedGroups are groups granting administrative access to a node, which
+// users managed on running nodes may not join.
+var privilegedGroups = []string{"root", "wheel", "sudo", "adm", "admin", "disk", "kmem", "shadow", "systemd-journal", "docker"}
+
+// homeDirParents are the directories the home directories of users managed on
+// running nodes must be in.
+var homeDirParents = []string{"/home/", "/var/home/"}
+
+// verifyUserGroup checks that a user managed on running nodes may join group,
+// given by name or gid.
+func verifyUserGroup(group string) error {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return verifyAccountID(&gid)
+	}
+	if InSlice(group, privilegedGroups) {
+		return fmt.Errorf("%q is a privileged group", group)
+	}
+	return nil
+}
+
+// verifyHomeDir checks that dir, if set, is a directory in /home or /var/home.
+func verifyHomeDir(dir *string) error {
+	if dir == nil {
+		return nil
+	}
+	clean := path.Clean(*dir)
+	for _, parent := range homeDirParents {
+		if strings.HasPrefix(clean, parent) {
+			return nil
+		}
+	}
+	return fmt.Errorf("home directory %q is not in %s", *dir, strings.Join(homeDirParents, " or "))
+}
+
+// verifyNonCoreUserFields returns nil if a user other than "core" only sets the
+// fields the MCD can manage on a running node: its uid, primary and
+// supplementary groups, shell, home directory, GECOS, password hash and SSH keys.
+// Users may not join privileged groups, and their home directory must be in
+// /home or /var/home.
+func verifyNonCoreUserFields(pwdUser ign3types.PasswdUser) error {
+	if err := verifyAccountName(pwdUser.Name); err != nil {
+		return fmt.Errorf("ignition passwd user section contains unsupported changes: %w", err)
+	}
+	if err := verifyAccountID(pwdUser.UID); err != nil {
+		return fmt.Errorf("ignition passwd user %q contains unsupported changes: %w", pwdUser.Name, err)
+	}
+	if pwdUser.PrimaryGroup != nil {
+		if err := verifyUserGroup(*pwdUser.PrimaryGroup); err != nil {
+			return fmt.Errorf("ignition passwd user %q contains unsupported changes: %w", pwdUser.Name, err)
+		}
+	}
+	for _, group := range pwdUser.Groups {
+		if err := verifyUserGroup(string(group)); err != nil {
+			return fmt.Errorf("ignition passwd user %q contains unsupported changes: %w", pwdUser.Name, err)
+		}
+	}
+	if err := verifyHomeDir(pwdUser.HomeDir); err != nil {
+		return fmt.Errorf("ignition passwd user %q contains unsupported changes: %w", pwdUser.Name, err)
+	}
+	if pwdUser.System != nil && *pwdUser.System {
+		return fmt.Errorf("ignition passwd user %q contains unsupported changes: system users are not reconcilable", pwdUser.Name)
+	}
+	if pwdUser.ShouldExist != nil && !*pwdUser.ShouldExist {
+		return fmt.Errorf("ignition passwd user %q contains unsupported changes: remove the user from the config instead of setting shouldExist to false", pwdUser.Name)
+	}
+	if pwdUser.NoCreateHome != nil || pwdUser.NoUserGroup != nil || pwdUser.NoLogInit != nil {
+		return fmt.Errorf("ignition passwd user %q contains unsupported changes: noCreateHome, noUserGroup and noLogInit are not reconcilable", pwdUser.Name)
+	}
+	return nil
+}
+
+// verifyGroupFields returns nil if a group can be managed on a running node.
+func verifyGroupFields(pwdGroup ign3types.PasswdGroup) error {
+	if err := verifyAccountName(pwdGroup.Name); err != nil {
+		return fmt.Errorf("ignition passwd group section contains unsupported changes: %w", err)
+	}
+	if err := verifyAccountID(pwdGroup.Gid); err != nil {
+		return fmt.Errorf("ignition passwd group %q contains unsupported changes: %w", pwdGroup.Name, err)
+	}
+	if pwdGroup.System != nil && *pwdGroup.System {
+		return fmt.Errorf("ignition passwd group %q contains unsupported changes: system groups are not reconcilable", pwdGroup.Name)
+	}
+	if pwdGroup.ShouldExist != nil && !*pwdGroup.ShouldExist {
+		return fmt.Errorf("ignition passwd group %q contains unsupported changes: remove the group from the config instead of setting shouldExist to false", pwdGroup.Name)
+	}
+	return nil
+}
+
 // Validates that changes to the Passwd section of the Ignition config are
 // reconcilable.
 func validatePasswdChanges(oldIgn, newIgn ign3types.Config) error {
 	if !reflect.DeepEqual(oldIgn.Passwd.Groups, newIgn.Passwd.Groups) {
-		return fmt.Errorf("ignition Passwd Groups section contains changes")
+		// groups other than core can be added, changed and removed as long as
+		// they are not system groups
+		oldGroups := map[string]ign3types.PasswdGroup{}
+		for _, group := range oldIgn.Passwd.Groups {
+			oldGroups[group.Name] = group
+		}
+		for _, group := range newIgn.Passwd.Groups {
+			if oldGroup, ok := oldGroups[group.Name]; ok && reflect.DeepEqual(oldGroup, group) {
+				continue
+			}
+			if err := verifyGroupFields(group); err != nil {
+				return err
+			}
+		}
 	}
 
 	if !reflect.DeepEqual(oldIgn.Passwd.Users, newIgn.Passwd.Users) {
 		// there is an update to Users, we must verify that it is ONLY making an acceptable
-		// change to the SSHAuthorizedKeys for the user "core"
-		for _, user := range newIgn.Passwd.Users {
-			if user.Name != constants.CoreUserName {
-				return fmt.Errorf("ignition passwd user section contains unsupported changes: non-core user")
+		// change to the SSHAuthorizedKeys for the user "core", or to users other than core
+		// that are not system users
+		oldUsers := map[string]ign3types.PasswdUser{}
+		for _, user := range oldIgn.Passwd.Users {
+			oldUsers[user.Name] = user
+		}
+		var coreUser *ign3types.PasswdUser
+		for i, user := range newIgn.Passwd.Users {
+			if user.Name == constants.CoreUserName {
+				coreUser = &newIgn.Passwd.Users[i]
+				continue
+			}
+			if oldUser, ok := oldUsers[user.Name]; ok && reflect.DeepEqual(oldUser, user) {
+				continue
+			}
+			if err := verifyNonCoreUserFields(user); err != nil {
+				return err
 			}
 		}
 		// We don't want to panic if the "new" users is empty, and it's still reconcilable because the absence of a user here does not mean "remove the user from the system"
-		if len(newIgn.Passwd.Users) != 0 {
-			klog.Infof("user data to be verified before ssh update: %v", newIgn.Passwd.Users[len(newIgn.Passwd.Users)-1])
-			if err := verifyUserFields(newIgn.Passwd.Users[len(newIgn.Passwd.Users)-1]); err != nil {
+		if coreUser != nil {
+			klog.Infof("user data to be verified before ssh update: %v", *coreUser)
+			if err := verifyUserFields(*coreUser); err != nil {
 				return err
 			}
 		}
@@ -83,7 +83,29 @@ func TestIsRenderedConfigReconcilable(t *testing.T) {
 	isReconcilable = IsRenderedConfigReconcilable(oldConfig, newConfig)
 	checkReconcilableResults(t, "Raid", isReconcilable)
 
-	// Verify Passwd Groups changes unsupported
+	// Verify Directories changes are supported
+	newIgnCfg.Storage.Directories = []ign3types.Directory{
+		{
+			Node:               ign3types.Node{Path: "/etc/foo"},
+			DirectoryEmbedded1: ign3types.DirectoryEmbedded1{Mode: helpers.IntToPtr(0o700)},
+		},
+	}
+	newConfig = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+	isReconcilable = IsRenderedConfigReconcilable(oldConfig, newConfig)
+	checkReconcilableResults(t, "Directories", isReconcilable)
+
+	// Verify Links changes are supported
+	newIgnCfg.Storage.Links = []ign3types.Link{
+		{
+			Node:          ign3types.Node{Path: "/etc/foo/bar"},
+			LinkEmbedded1: ign3types.LinkEmbedded1{Target: helpers.StrToPtr("/etc/bar")},
+		},
+	}
+	newConfig = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+	isReconcilable = IsRenderedConfigReconcilable(oldConfig, newConfig)
+	checkReconcilableResults(t, "Links", isReconcilable)
+
+	// Verify Passwd Groups changes are supported for non-system groups
 	oldIgnCfg = NewIgnConfig()
 	oldConfig = helpers.CreateMachineConfigFromIgnition(oldIgnCfg)
 	newIgnCfg = NewIgnConfig()
@@ -93,13 +115,28 @@ func TestIsRenderedConfigReconcilable(t *testing.T) {
 	checkReconcilableResults(t, "PasswdGroups", isReconcilable)
 
 	tempGroup := ign3types.PasswdGroup{}
-	tempGroup.Name = "testGroup"
+	tempGroup.Name = "auditors"
+	tempGroup.Gid = helpers.IntToPtr(5000)
 	newIgnCfg.Passwd.Groups = []ign3types.PasswdGroup{tempGroup}
 	newConfig = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
 	isReconcilable = IsRenderedConfigReconcilable(oldConfig, newConfig)
-	checkIrreconcilableResults(t, "PasswdGroups", isReconcilable)
+	checkReconcilableResults(t, "PasswdGroups", isReconcilable)
+
+	// System groups and invalid group names are still unsupported
+	for _, group := range []ign3types.PasswdGroup{
+		{Name: "testGroup"},
+		{Name: "wheel", Gid: helpers.IntToPtr(10)},
+		{Name: "auditors", System: helpers.BoolToPtr(true)},
+		{Name: "root"},
+		{Name: "core"},
+	} {
+		newIgnCfg.Passwd.Groups = []ign3types.PasswdGroup{group}
+		newConfig = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+		isReconcilable = IsRenderedConfigReconcilable(oldConfig, newConfig)
+		checkIrreconcilableResults(t, "PasswdGroups", isReconcilable)
+	}
 
-	// Verify Ignition kernelArguments changes unsupported
+	// Verify Ignition kernelArguments changes are supported
 	oldIgnCfg = NewIgnConfig()
 	oldConfig = helpers.CreateMachineConfigFromIgnition(oldIgnCfg)
 	newIgnCfg = NewIgnConfig()
@@ -109,7 +146,7 @@ func TestIsRenderedConfigReconcilable(t *testing.T) {
 	newConfig = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
 
 	isReconcilable = IsRenderedConfigReconcilable(oldConfig, newConfig)
-	checkIrreconcilableResults(t, "KernelArguments", isReconcilable)
+	checkReconcilableResults(t, "KernelArguments", isReconcilable)
 
 	// Verify Tang changes are supported (even though we don't do anything with them yet)
 	oldIgnCfg = NewIgnConfig()
@@ -166,7 +203,7 @@ func TestReconcilableSSH(t *testing.T) {
 	errMsg := IsRenderedConfigReconcilable(oldMcfg, newMcfg)
 	checkReconcilableResults(t, "SSH", errMsg)
 
-	// 	Check that updating User with User that is not core is not supported
+	// 	Check that updating User with an invalid user name is not supported
 	tempUser2 := ign3types.PasswdUser{Name: "core", SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"1234"}}
 	oldIgnCfg.Passwd.Users = append(oldIgnCfg.Passwd.Users, tempUser2)
 	oldMcfg = helpers.CreateMachineConfigFromIgnition(oldIgnCfg)
@@ -183,7 +220,7 @@ func TestReconcilableSSH(t *testing.T) {
 	errMsg = IsRenderedConfigReconcilable(oldMcfg, newMcfg)
 	checkIrreconcilableResults(t, "SSH", errMsg)
 
-	// check that we cannot add a user or have len(Passwd.Users)> 1
+	// check that we cannot add a user with an invalid user name
 	tempUser5 := ign3types.PasswdUser{Name: "some user", SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"5678"}}
 	newIgnCfg.Passwd.Users = append(newIgnCfg.Passwd.Users, tempUser5)
 	newMcfg = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
@@ -205,6 +242,67 @@ func TestReconcilableSSH(t *testing.T) {
 	checkReconcilableResults(t, "SSH", errMsg)
 }
 
+func TestReconcilableNonCoreUsers(t *testing.T) {
+	oldIgnCfg := NewIgnConfig()
+	oldIgnCfg.Passwd.Users = []ign3types.PasswdUser{{Name: "core", SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"1234"}}}
+	oldMcfg := helpers.CreateMachineConfigFromIgnition(oldIgnCfg)
+
+	breakGlass := ign3types.PasswdUser{
+		Name:              "breakglass",
+		UID:               helpers.IntToPtr(5000),
+		Shell:             helpers.StrToPtr("/bin/bash"),
+		Groups:            []ign3types.Group{"auditors", "5000"},
+		HomeDir:           helpers.StrToPtr("/var/home/breakglass"),
+		SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"5678"},
+		PasswordHash:      helpers.StrToPtr("hash"),
+	}
+
+	newIgnCfg := NewIgnConfig()
+	newIgnCfg.Passwd.Users = append([]ign3types.PasswdUser{}, oldIgnCfg.Passwd.Users...)
+	newIgnCfg.Passwd.Users = append(newIgnCfg.Passwd.Users, breakGlass)
+	newMcfg := helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+	checkReconcilableResults(t, "NonCoreUsers", IsRenderedConfigReconcilable(oldMcfg, newMcfg))
+
+	// Removing the user again is supported as well
+	checkReconcilableResults(t, "NonCoreUsers", IsRenderedConfigReconcilable(newMcfg, oldMcfg))
+
+	testCases := []struct {
+		name   string
+		modify func(*ign3types.PasswdUser)
+	}{
+		{name: "system uid", modify: func(u *ign3types.PasswdUser) { u.UID = helpers.IntToPtr(0) }},
+		{name: "nobody uid", modify: func(u *ign3types.PasswdUser) { u.UID = helpers.IntToPtr(65534) }},
+		{name: "system user", modify: func(u *ign3types.PasswdUser) { u.System = helpers.BoolToPtr(true) }},
+		{name: "root", modify: func(u *ign3types.PasswdUser) { u.Name = "root" }},
+		{name: "shouldExist", modify: func(u *ign3types.PasswdUser) { u.ShouldExist = helpers.BoolToPtr(false) }},
+		{name: "noCreateHome", modify: func(u *ign3types.PasswdUser) { u.NoCreateHome = helpers.BoolToPtr(true) }},
+		{name: "wheel group", modify: func(u *ign3types.PasswdUser) { u.Groups = []ign3types.Group{"auditors", "wheel"} }},
+		{name: "sudo group", modify: func(u *ign3types.PasswdUser) { u.Groups = []ign3types.Group{"sudo"} }},
+		{name: "system gid group", modify: func(u *ign3types.PasswdUser) { u.Groups = []ign3types.Group{"10"} }},
+		{name: "root primary group", modify: func(u *ign3types.PasswdUser) { u.PrimaryGroup = helpers.StrToPtr("root") }},
+		{name: "root primary gid", modify: func(u *ign3types.PasswdUser) { u.PrimaryGroup = helpers.StrToPtr("0") }},
+		{name: "home in /root", modify: func(u *ign3types.PasswdUser) { u.HomeDir = helpers.StrToPtr("/root") }},
+		{name: "home is /home", modify: func(u *ign3types.PasswdUser) { u.HomeDir = helpers.StrToPtr("/home") }},
+		{name: "home escaping /home", modify: func(u *ign3types.PasswdUser) { u.HomeDir = helpers.StrToPtr("/home/../etc/ssh") }},
+		{name: "relative home", modify: func(u *ign3types.PasswdUser) { u.HomeDir = helpers.StrToPtr("home/breakglass") }},
+	}
+	for _, testCase := range testCases {
+		user := breakGlass
+		testCase.modify(&user)
+		newIgnCfg.Passwd.Users = []ign3types.PasswdUser{oldIgnCfg.Passwd.Users[0], user}
+		newMcfg = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+		checkIrreconcilableResults(t, testCase.name, IsRenderedConfigReconcilable(oldMcfg, newMcfg))
+	}
+
+	// Users already in the old config are not checked again
+	oldIgnCfg.Passwd.Users = append(oldIgnCfg.Passwd.Users, ign3types.PasswdUser{Name: "legacy", System: helpers.BoolToPtr(true)})
+	oldMcfg = helpers.CreateMachineConfigFromIgnition(oldIgnCfg)
+	newIgnCfg.Passwd.Users = append([]ign3types.PasswdUser{}, oldIgnCfg.Passwd.Users...)
+	newIgnCfg.Passwd.Users = append(newIgnCfg.Passwd.Users, breakGlass)
+	newMcfg = helpers.CreateMachineConfigFromIgnition(newIgnCfg)
+	checkReconcilableResults(t, "NonCoreUsers", IsRenderedConfigReconcilable(oldMcfg, newMcfg))
+}
+
 // checkReconcilableResults is a shortcut for verifying results that should be reconcilable
 func checkReconcilableResults(t *testing.T, key string, reconcilableError error) {
 	if reconcilableError != nil {
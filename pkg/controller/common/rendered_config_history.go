@@ -0,0 +1,98 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// MachineConfigPoolRenderedConfigHistory is the pool condition which
+	// reports the rendered MachineConfig the pool most recently completed an
+	// update to. The history itself is kept in the
+	// RenderedConfigHistoryAnnotationKey annotation of the pool.
+	MachineConfigPoolRenderedConfigHistory mcfgv1.MachineConfigPoolConditionType = "RenderedConfigHistory"
+
+	// MachineConfigPoolRollingBack is the pool condition which reports
+	// whether the pool has been rolled back to a rendered MachineConfig from
+	// its history.
+	MachineConfigPoolRollingBack mcfgv1.MachineConfigPoolConditionType = "RollingBack"
+
+	// DefaultRenderedConfigHistoryLimit is the number of rendered
+	// MachineConfigs recorded in the history of a pool without a
+	// RenderedConfigHistoryLimitAnnotationKey annotation.
+	DefaultRenderedConfigHistoryLimit = 5
+)
+
+// RenderedConfigHistoryEntry is a rendered MachineConfig a pool has
+// successfully completed an update to.
+type RenderedConfigHistoryEntry struct {
+	Name string `json:"name"`
+	// Source lists the MachineConfigs the rendered MachineConfig was generated from.
+	Source []string `json:"source,omitempty"`
+}
+
+// GetRenderedConfigHistory returns the rendered MachineConfigs the pool has
+// successfully completed an update to, newest first.
+func GetRenderedConfigHistory(pool *mcfgv1.MachineConfigPool) ([]RenderedConfigHistoryEntry, error) {
+	val := pool.Annotations[RenderedConfigHistoryAnnotationKey]
+	if val == "" {
+		return nil, nil
+	}
+	history := []RenderedConfigHistoryEntry{}
+	if err := json.Unmarshal([]byte(val), &history); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %w", RenderedConfigHistoryAnnotationKey, err)
+	}
+	return history, nil
+}
+
+// GetRenderedConfigHistoryLimit returns the number of rendered MachineConfigs
+// recorded in the history of the pool.
+func GetRenderedConfigHistoryLimit(pool *mcfgv1.MachineConfigPool) (int, error) {
+	val, ok := pool.Annotations[RenderedConfigHistoryLimitAnnotationKey]
+	if !ok {
+		return DefaultRenderedConfigHistoryLimit, nil
+	}
+	limit, err := strconv.Atoi(val)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("invalid %s annotation %q: must be a positive integer", RenderedConfigHistoryLimitAnnotationKey, val)
+	}
+	return limit, nil
+}
+
+// AddToRenderedConfigHistory records config as the newest rendered
+// MachineConfig in the history annotation of the pool, keeping at most limit
+// entries. A history which cannot be parsed is started over.
+func AddToRenderedConfigHistory(pool *mcfgv1.MachineConfigPool, config mcfgv1.MachineConfigPoolStatusConfiguration, limit int) {
+	history, err := GetRenderedConfigHistory(pool)
+	if err != nil {
+		klog.Warningf("Pool %s: %v, starting a new rendered config history", pool.Name, err)
+	}
+
+	newest := RenderedConfigHistoryEntry{Name: config.Name}
+	for _, source := range config.Source {
+		newest.Source = append(newest.Source, source.Name)
+	}
+	updated := []RenderedConfigHistoryEntry{newest}
+	for _, entry := range history {
+		if entry.Name != newest.Name {
+			updated = append(updated, entry)
+		}
+	}
+	if len(updated) > limit {
+		updated = updated[:limit]
+	}
+
+	out, err := json.Marshal(updated)
+	if err != nil {
+		klog.Errorf("Pool %s: could not encode rendered config history: %v", pool.Name, err)
+		return
+	}
+	if pool.Annotations == nil {
+		pool.Annotations = map[string]string{}
+	}
+	pool.Annotations[RenderedConfigHistoryAnnotationKey] = string(out)
+}
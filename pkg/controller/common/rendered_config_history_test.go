@@ -0,0 +1,74 @@
+package common
+
+import (
+	"testing"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func renderedConfig(name string, sources ...string) mcfgv1.MachineConfigPoolStatusConfiguration {
+	config := mcfgv1.MachineConfigPoolStatusConfiguration{ObjectReference: corev1.ObjectReference{Name: name}}
+	for _, source := range sources {
+		config.Source = append(config.Source, corev1.ObjectReference{Kind: "MachineConfig", Name: source})
+	}
+	return config
+}
+
+func getRenderedConfigHistoryNames(t *testing.T, pool *mcfgv1.MachineConfigPool) []string {
+	history, err := GetRenderedConfigHistory(pool)
+	require.NoError(t, err)
+	names := []string{}
+	for _, entry := range history {
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
+func TestRenderedConfigHistory(t *testing.T) {
+	pool := &mcfgv1.MachineConfigPool{}
+	history, err := GetRenderedConfigHistory(pool)
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+
+	AddToRenderedConfigHistory(pool, renderedConfig("rendered-1", "00-worker"), 3)
+	AddToRenderedConfigHistory(pool, renderedConfig("rendered-2", "00-worker", "99-kargs"), 3)
+	AddToRenderedConfigHistory(pool, renderedConfig("rendered-2", "00-worker", "99-kargs"), 3)
+	assert.Equal(t, []string{"rendered-2", "rendered-1"}, getRenderedConfigHistoryNames(t, pool))
+	assert.JSONEq(t, `[{"name":"rendered-2","source":["00-worker","99-kargs"]},{"name":"rendered-1","source":["00-worker"]}]`,
+		pool.Annotations[RenderedConfigHistoryAnnotationKey])
+
+	// A config which is rolled back to moves to the front.
+	AddToRenderedConfigHistory(pool, renderedConfig("rendered-1", "00-worker"), 3)
+	assert.Equal(t, []string{"rendered-1", "rendered-2"}, getRenderedConfigHistoryNames(t, pool))
+
+	AddToRenderedConfigHistory(pool, renderedConfig("rendered-3"), 3)
+	AddToRenderedConfigHistory(pool, renderedConfig("rendered-4"), 3)
+	assert.Equal(t, []string{"rendered-4", "rendered-3", "rendered-1"}, getRenderedConfigHistoryNames(t, pool))
+
+	// A history which cannot be parsed is reported and started over.
+	pool.Annotations[RenderedConfigHistoryAnnotationKey] = "rendered-1, rendered-2"
+	_, err = GetRenderedConfigHistory(pool)
+	assert.Error(t, err)
+	AddToRenderedConfigHistory(pool, renderedConfig("rendered-6"), 3)
+	assert.Equal(t, []string{"rendered-6"}, getRenderedConfigHistoryNames(t, pool))
+}
+
+func TestGetRenderedConfigHistoryLimit(t *testing.T) {
+	pool := &mcfgv1.MachineConfigPool{}
+	limit, err := GetRenderedConfigHistoryLimit(pool)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultRenderedConfigHistoryLimit, limit)
+
+	pool.ObjectMeta = metav1.ObjectMeta{Annotations: map[string]string{RenderedConfigHistoryLimitAnnotationKey: "10"}}
+	limit, err = GetRenderedConfigHistoryLimit(pool)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, limit)
+
+	pool.Annotations[RenderedConfigHistoryLimitAnnotationKey] = "0"
+	_, err = GetRenderedConfigHistoryLimit(pool)
+	assert.Error(t, err)
+}
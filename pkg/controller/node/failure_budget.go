@@ -0,0 +1,123 @@
+package node
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+)
+
+// machineConfigPoolFailureBudgetExceeded is the pool condition which reports
+// whether the rollout of the pool's target config is paused because too many
+// of its nodes failed to update.
+const machineConfigPoolFailureBudgetExceeded mcfgv1.MachineConfigPoolConditionType = "FailureBudgetExceeded"
+
+const (
+	failureBudgetReasonInvalid      = "InvalidFailureBudget"
+	failureBudgetReasonWithinBudget = "WithinBudget"
+	failureBudgetReasonExceeded     = "FailureBudgetExceeded"
+	failureBudgetReasonAcknowledged = "Acknowledged"
+)
+
+// isFailureBudgetExceeded returns true if the rollout of the pool's target
+// config has been paused by the pool's failure budget.
+func isFailureBudgetExceeded(pool *mcfgv1.MachineConfigPool) bool {
+	return apihelpers.IsMachineConfigPoolConditionTrue(pool.Status.Conditions, machineConfigPoolFailureBudgetExceeded)
+}
+
+// getNodesFailingConfig returns the nodes which are degraded or unreconcilable
+// while updating to the pool's target config.
+func getNodesFailingConfig(pool *mcfgv1.MachineConfigPool, nodes []*corev1.Node) []*corev1.Node {
+	var failing []*corev1.Node
+	for _, node := range getDegradedMachines(nodes) {
+		if node.Annotations[daemonconsts.DesiredMachineConfigAnnotationKey] == pool.Spec.Configuration.Name {
+			failing = append(failing, node)
+		}
+	}
+	return failing
+}
+
+// syncFailureBudget checks the pool's failure budget against the nodes which
+// failed to update to its target config, and updates the FailureBudgetExceeded
+// condition of the pool. Once the budget is exceeded, the rollout stays paused
+// until an admin acknowledges the failures for the target config, even if the
+// nodes recover. The config the budget was exceeded for is kept in the
+// FailureBudgetExceededAnnotationKey annotation of the pool. It returns true if
+// no new nodes should be updated.
+func (ctrl *Controller) syncFailureBudget(pool *mcfgv1.MachineConfigPool, nodes []*corev1.Node) (bool, error) {
+	val, ok := pool.Annotations[ctrlcommon.FailureBudgetAnnotationKey]
+	if !ok {
+		if apihelpers.GetMachineConfigPoolCondition(pool.Status, machineConfigPoolFailureBudgetExceeded) != nil {
+			apihelpers.RemoveMachineConfigPoolCondition(&pool.Status, machineConfigPoolFailureBudgetExceeded)
+		}
+		return false, ctrl.setFailureBudgetExceededTarget(pool, "")
+	}
+
+	budget, err := strconv.Atoi(val)
+	if err != nil || budget < 1 {
+		// Rolling out without the budget the admin asked for could take down
+		// the whole pool, so don't update it at all until this is fixed.
+		msg := fmt.Sprintf("Not updating nodes: invalid %s annotation %q, must be a positive integer", ctrlcommon.FailureBudgetAnnotationKey, val)
+		ctrl.eventRecorder.Eventf(pool, corev1.EventTypeWarning, failureBudgetReasonInvalid, msg)
+		setFailureBudgetCondition(pool, corev1.ConditionTrue, failureBudgetReasonInvalid, msg)
+		return true, nil
+	}
+
+	target := pool.Spec.Configuration.Name
+	if pool.Annotations[ctrlcommon.FailureBudgetAcknowledgedAnnotationKey] == target {
+		if isFailureBudgetExceeded(pool) {
+			ctrl.eventRecorder.Eventf(pool, corev1.EventTypeNormal, "FailureBudgetAcknowledged", "Node failures during rollout of %s acknowledged, resuming rollout", target)
+		}
+		setFailureBudgetCondition(pool, corev1.ConditionFalse, failureBudgetReasonAcknowledged, fmt.Sprintf("Node failures during rollout of %s acknowledged", target))
+		return false, ctrl.setFailureBudgetExceededTarget(pool, "")
+	}
+
+	// The latch only holds for the config the budget was exceeded for, a new
+	// target config starts with a fresh budget.
+	exceeded := pool.Annotations[ctrlcommon.FailureBudgetExceededAnnotationKey]
+	if exceeded != "" && exceeded == target {
+		return true, nil
+	}
+	if exceeded != "" {
+		ctrl.logPool(pool, "Target config changed from %s to %s, resetting failure budget", exceeded, target)
+		if err := ctrl.setFailureBudgetExceededTarget(pool, ""); err != nil {
+			return false, err
+		}
+	}
+
+	failing := getNodesFailingConfig(pool, nodes)
+	if len(failing) < budget {
+		setFailureBudgetCondition(pool, corev1.ConditionFalse, failureBudgetReasonWithinBudget, fmt.Sprintf("%d of %d allowed node failures during rollout of %s", len(failing), budget, target))
+		return false, nil
+	}
+
+	names := []string{}
+	for _, node := range failing {
+		names = append(names, node.Name)
+	}
+	msg := fmt.Sprintf("%d nodes failed to update to %s, exceeding the failure budget of %d: %s. Set the %s annotation to %s to resume the rollout",
+		len(failing), target, budget, strings.Join(names, ", "), ctrlcommon.FailureBudgetAcknowledgedAnnotationKey, target)
+	if err := ctrl.setFailureBudgetExceededTarget(pool, target); err != nil {
+		return false, err
+	}
+	ctrl.eventRecorder.Eventf(pool, corev1.EventTypeWarning, failureBudgetReasonExceeded, msg)
+	ctrl.logPool(pool, "Pausing rollout: %s", msg)
+	setFailureBudgetCondition(pool, corev1.ConditionTrue, failureBudgetReasonExceeded, msg)
+	return true, nil
+}
+
+// setFailureBudgetExceededTarget records the config whose rollout exceeded
+// the failure budget of the pool. An empty target clears it.
+func (ctrl *Controller) setFailureBudgetExceededTarget(pool *mcfgv1.MachineConfigPool, target string) error {
+	return ctrl.syncPoolAnnotations(pool, map[string]string{ctrlcommon.FailureBudgetExceededAnnotationKey: target})
+}
+
+func setFailureBudgetCondition(pool *mcfgv1.MachineConfigPool, status corev1.ConditionStatus, reason, message string) {
+	apihelpers.SetMachineConfigPoolCondition(&pool.Status, *apihelpers.NewMachineConfigPoolCondition(machineConfigPoolFailureBudgetExceeded, status, reason, message))
+}
@@ -0,0 +1,184 @@
+package node
+
+import (
+	"context"
+	"testing"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	fakemcfgclientset "github.com/openshift/client-go/machineconfiguration/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestSyncFailureBudget(t *testing.T) {
+	t.Parallel()
+
+	degraded := func(name string) *corev1.Node {
+		return helpers.NewNodeBuilder(name).WithConfigs(machineConfigV0, machineConfigV1).WithMCDState(daemonconsts.MachineConfigDaemonStateDegraded).WithNodeReady().Node()
+	}
+	updated := func(name string) *corev1.Node {
+		return helpers.NewNodeBuilder(name).WithEqualConfigs(machineConfigV1).WithNodeReady().Node()
+	}
+	// A node which failed to update to an older config doesn't count against
+	// the budget of the new one.
+	staleDegraded := helpers.NewNodeBuilder("node-stale").WithConfigs(machineConfigV0, "v-old").WithMCDState(daemonconsts.MachineConfigDaemonStateDegraded).WithNodeReady().Node()
+
+	tests := []struct {
+		name           string
+		annotations    map[string]string
+		nodes          []*corev1.Node
+		expectedPaused bool
+		expectedReason string
+	}{
+		{
+			name:  "no budget",
+			nodes: []*corev1.Node{degraded("node-0"), degraded("node-1")},
+		},
+		{
+			name:           "within budget",
+			annotations:    map[string]string{ctrlcommon.FailureBudgetAnnotationKey: "2"},
+			nodes:          []*corev1.Node{degraded("node-0"), updated("node-1"), staleDegraded},
+			expectedReason: failureBudgetReasonWithinBudget,
+		},
+		{
+			name:           "budget exceeded",
+			annotations:    map[string]string{ctrlcommon.FailureBudgetAnnotationKey: "2"},
+			nodes:          []*corev1.Node{degraded("node-0"), degraded("node-1"), updated("node-2")},
+			expectedPaused: true,
+			expectedReason: failureBudgetReasonExceeded,
+		},
+		{
+			name: "budget exceeded and acknowledged",
+			annotations: map[string]string{
+				ctrlcommon.FailureBudgetAnnotationKey:             "1",
+				ctrlcommon.FailureBudgetAcknowledgedAnnotationKey: machineConfigV1,
+			},
+			nodes:          []*corev1.Node{degraded("node-0"), degraded("node-1")},
+			expectedReason: failureBudgetReasonAcknowledged,
+		},
+		{
+			name: "acknowledged for a previous config",
+			annotations: map[string]string{
+				ctrlcommon.FailureBudgetAnnotationKey:             "1",
+				ctrlcommon.FailureBudgetAcknowledgedAnnotationKey: machineConfigV0,
+			},
+			nodes:          []*corev1.Node{degraded("node-0")},
+			expectedPaused: true,
+			expectedReason: failureBudgetReasonExceeded,
+		},
+		{
+			name:           "invalid budget",
+			annotations:    map[string]string{ctrlcommon.FailureBudgetAnnotationKey: "0"},
+			nodes:          []*corev1.Node{updated("node-0")},
+			expectedPaused: true,
+			expectedReason: failureBudgetReasonInvalid,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			pool := helpers.NewMachineConfigPoolBuilder("worker").WithMachineConfig(machineConfigV1).MachineConfigPool()
+			pool.Annotations = test.annotations
+			ctrl := newFailureBudgetController(pool)
+
+			assert.Equal(t, test.expectedPaused, syncFailureBudget(t, ctrl, pool, test.nodes))
+			assert.Equal(t, test.expectedPaused, isFailureBudgetExceeded(pool))
+
+			cond := apihelpers.GetMachineConfigPoolCondition(pool.Status, machineConfigPoolFailureBudgetExceeded)
+			if test.expectedReason == "" {
+				assert.Nil(t, cond)
+				return
+			}
+			assert.NotNil(t, cond)
+			assert.Equal(t, test.expectedReason, cond.Reason)
+		})
+	}
+}
+
+func newFailureBudgetController(pool *mcfgv1.MachineConfigPool) *Controller {
+	return &Controller{
+		client:        fakemcfgclientset.NewSimpleClientset(pool),
+		eventRecorder: record.NewFakeRecorder(10),
+	}
+}
+
+func syncFailureBudget(t *testing.T, ctrl *Controller, pool *mcfgv1.MachineConfigPool, nodes []*corev1.Node) bool {
+	t.Helper()
+	paused, err := ctrl.syncFailureBudget(pool, nodes)
+	require.NoError(t, err)
+	return paused
+}
+
+// assertFailureBudgetExceededFor checks the config the failure budget of the
+// pool was exceeded for, both on pool and on the pool stored by ctrl.
+func assertFailureBudgetExceededFor(t *testing.T, ctrl *Controller, pool *mcfgv1.MachineConfigPool, target string) {
+	t.Helper()
+	stored, err := ctrl.client.MachineconfigurationV1().MachineConfigPools().Get(context.TODO(), pool.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	for _, p := range []*mcfgv1.MachineConfigPool{pool, stored} {
+		val, ok := p.Annotations[ctrlcommon.FailureBudgetExceededAnnotationKey]
+		assert.Equal(t, target, val)
+		assert.Equal(t, target != "", ok)
+	}
+}
+
+func TestSyncFailureBudgetLatches(t *testing.T) {
+	t.Parallel()
+
+	pool := helpers.NewMachineConfigPoolBuilder("worker").WithMachineConfig(machineConfigV1).MachineConfigPool()
+	pool.Annotations = map[string]string{ctrlcommon.FailureBudgetAnnotationKey: "1"}
+	ctrl := newFailureBudgetController(pool)
+
+	degraded := helpers.NewNodeBuilder("node-0").WithConfigs(machineConfigV0, machineConfigV1).WithMCDState(daemonconsts.MachineConfigDaemonStateDegraded).WithNodeReady().Node()
+	assert.True(t, syncFailureBudget(t, ctrl, pool, []*corev1.Node{degraded}))
+	assertFailureBudgetExceededFor(t, ctrl, pool, machineConfigV1)
+
+	// The rollout stays paused after the node recovers.
+	recovered := helpers.NewNodeBuilder("node-0").WithEqualConfigs(machineConfigV1).WithNodeReady().Node()
+	assert.True(t, syncFailureBudget(t, ctrl, pool, []*corev1.Node{recovered}))
+
+	// The latch does not depend on the wording of the condition.
+	setFailureBudgetCondition(pool, corev1.ConditionTrue, failureBudgetReasonExceeded, "Rollout paused")
+	assert.True(t, syncFailureBudget(t, ctrl, pool, []*corev1.Node{recovered}))
+	assertFailureBudgetExceededFor(t, ctrl, pool, machineConfigV1)
+
+	// Until the failures are acknowledged.
+	pool.Annotations[ctrlcommon.FailureBudgetAcknowledgedAnnotationKey] = machineConfigV1
+	assert.False(t, syncFailureBudget(t, ctrl, pool, []*corev1.Node{recovered}))
+	assert.False(t, isFailureBudgetExceeded(pool))
+	assertFailureBudgetExceededFor(t, ctrl, pool, "")
+}
+
+func TestSyncFailureBudgetResetsForNewTarget(t *testing.T) {
+	t.Parallel()
+
+	pool := helpers.NewMachineConfigPoolBuilder("worker").WithMachineConfig(machineConfigV0).MachineConfigPool()
+	pool.Annotations = map[string]string{ctrlcommon.FailureBudgetAnnotationKey: "1"}
+	ctrl := newFailureBudgetController(pool)
+
+	degraded := helpers.NewNodeBuilder("node-0").WithConfigs("v-old", machineConfigV0).WithMCDState(daemonconsts.MachineConfigDaemonStateDegraded).WithNodeReady().Node()
+	assert.True(t, syncFailureBudget(t, ctrl, pool, []*corev1.Node{degraded}))
+	assertFailureBudgetExceededFor(t, ctrl, pool, machineConfigV0)
+
+	// A new target config gets a fresh budget.
+	pool.Spec.Configuration.Name = machineConfigV1
+	updating := helpers.NewNodeBuilder("node-0").WithConfigs(machineConfigV0, machineConfigV1).WithNodeReady().Node()
+	assert.False(t, syncFailureBudget(t, ctrl, pool, []*corev1.Node{updating}))
+	assert.False(t, isFailureBudgetExceeded(pool))
+	assertFailureBudgetExceededFor(t, ctrl, pool, "")
+
+	cond := apihelpers.GetMachineConfigPoolCondition(pool.Status, machineConfigPoolFailureBudgetExceeded)
+	assert.NotNil(t, cond)
+	assert.Equal(t, failureBudgetReasonWithinBudget, cond.Reason)
+}
@@ -0,0 +1,236 @@
+package node
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	features "github.com/openshift/api/features"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/robfig/cron"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/daemon/disruption"
+)
+
+// machineConfigPoolMaintenanceWindow is the pool condition which reports
+// whether the pool is inside one of its maintenance windows, and if not, when
+// the next one starts and how many nodes are waiting for it.
+const machineConfigPoolMaintenanceWindow mcfgv1.MachineConfigPoolConditionType = "MaintenanceWindow"
+
+const (
+	maintenanceWindowReasonInside  = "InsideMaintenanceWindow"
+	maintenanceWindowReasonOutside = "OutsideMaintenanceWindow"
+	maintenanceWindowReasonInvalid = "InvalidMaintenanceWindow"
+)
+
+// maintenanceWindow is a recurring period of time starting on a cron schedule.
+type maintenanceWindow struct {
+	schedule cron.Schedule
+	duration time.Duration
+}
+
+// maintenanceWindows are the maintenance windows of a pool, evaluated in the
+// pool's time zone.
+type maintenanceWindows struct {
+	windows  []maintenanceWindow
+	location *time.Location
+}
+
+// getMaintenanceWindows parses the maintenance windows of the pool. It returns
+// nil if the pool has none, in which case updates may start at any time.
+func getMaintenanceWindows(pool *mcfgv1.MachineConfigPool) (*maintenanceWindows, error) {
+	val, ok := pool.Annotations[ctrlcommon.MaintenanceWindowAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	mw := &maintenanceWindows{location: time.UTC}
+	if tz, ok := pool.Annotations[ctrlcommon.MaintenanceWindowTimeZoneAnnotationKey]; ok {
+		location, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation %q: %w", ctrlcommon.MaintenanceWindowTimeZoneAnnotationKey, tz, err)
+		}
+		mw.location = location
+	}
+
+	for _, spec := range strings.Split(val, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		// The duration is the last field, the rest is the cron schedule.
+		idx := strings.LastIndex(spec, " ")
+		if idx == -1 {
+			return nil, fmt.Errorf("invalid maintenance window %q: must be a cron schedule followed by a duration", spec)
+		}
+		schedule, err := cron.ParseStandard(strings.TrimSpace(spec[:idx]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid maintenance window %q: %w", spec, err)
+		}
+		duration, err := time.ParseDuration(spec[idx+1:])
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid maintenance window %q: duration must be positive", spec)
+		}
+		mw.windows = append(mw.windows, maintenanceWindow{schedule: schedule, duration: duration})
+	}
+	if len(mw.windows) == 0 {
+		return nil, fmt.Errorf("invalid %s annotation %q: no maintenance windows", ctrlcommon.MaintenanceWindowAnnotationKey, val)
+	}
+	return mw, nil
+}
+
+// isOpen returns true if now is inside any of the maintenance windows.
+func (mw *maintenanceWindows) isOpen(now time.Time) bool {
+	now = now.In(mw.location)
+	for _, w := range mw.windows {
+		// The window is open if it last started less than its duration ago.
+		if !w.schedule.Next(now.Add(-w.duration)).After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextOpen returns when the next maintenance window after now starts.
+func (mw *maintenanceWindows) nextOpen(now time.Time) time.Time {
+	now = now.In(mw.location)
+	var next time.Time
+	for _, w := range mw.windows {
+		start := w.schedule.Next(now)
+		if !start.IsZero() && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+	return next
+}
+
+// applyMaintenanceWindows returns the candidates which may start updating now.
+// Outside of the pool's maintenance windows, these are only the candidates
+// whose update does not drain or reboot them. The MaintenanceWindow condition
+// of the pool is updated with the number of candidates left waiting for the
+// next window, and the pool is requeued for when it starts.
+func (ctrl *Controller) applyMaintenanceWindows(pool *mcfgv1.MachineConfigPool, candidates []*corev1.Node, layered bool, now time.Time) []*corev1.Node {
+	if _, ok := pool.Annotations[ctrlcommon.MaintenanceWindowAnnotationKey]; !ok {
+		apihelpers.RemoveMachineConfigPoolCondition(&pool.Status, machineConfigPoolMaintenanceWindow)
+		return candidates
+	}
+
+	mw, err := getMaintenanceWindows(pool)
+	if err != nil {
+		// Without knowing when the windows are, only non-disruptive updates
+		// are safe to start. The condition already reports a known error, so
+		// the event is only emitted when the error changes.
+		msg := fmt.Sprintf("Only starting updates which do not drain or reboot nodes: %v", err)
+		cond := apihelpers.GetMachineConfigPoolCondition(pool.Status, machineConfigPoolMaintenanceWindow)
+		if cond == nil || cond.Reason != maintenanceWindowReasonInvalid || cond.Message != msg {
+			ctrl.eventRecorder.Eventf(pool, corev1.EventTypeWarning, maintenanceWindowReasonInvalid, msg)
+		}
+		setMaintenanceWindowCondition(pool, corev1.ConditionFalse, maintenanceWindowReasonInvalid, msg)
+		allowed, _ := ctrl.filterDisruptiveCandidates(pool, candidates, layered)
+		return allowed
+	}
+
+	if mw.isOpen(now) {
+		setMaintenanceWindowCondition(pool, corev1.ConditionTrue, maintenanceWindowReasonInside, "Inside a maintenance window, updates which drain or reboot nodes may start")
+		return candidates
+	}
+
+	allowed, waiting := ctrl.filterDisruptiveCandidates(pool, candidates, layered)
+	next := mw.nextOpen(now)
+	if next.IsZero() {
+		setMaintenanceWindowCondition(pool, corev1.ConditionFalse, maintenanceWindowReasonOutside,
+			fmt.Sprintf("No upcoming maintenance window, %d nodes waiting", waiting))
+		return allowed
+	}
+	setMaintenanceWindowCondition(pool, corev1.ConditionFalse, maintenanceWindowReasonOutside,
+		fmt.Sprintf("Next maintenance window starts at %s, %d nodes waiting for it", next.Format(time.RFC3339), waiting))
+	if waiting > 0 {
+		ctrl.logPool(pool, "%d nodes waiting for the maintenance window starting at %s", waiting, next.Format(time.RFC3339))
+		ctrl.enqueueAfter(pool, next.Sub(now))
+	}
+	return allowed
+}
+
+// filterDisruptiveCandidates splits the candidates into those whose update to
+// the pool's target config does not drain or reboot them, which are returned,
+// and the number of those whose update does.
+func (ctrl *Controller) filterDisruptiveCandidates(pool *mcfgv1.MachineConfigPool, candidates []*corev1.Node, layered bool) ([]*corev1.Node, int) {
+	if len(candidates) == 0 {
+		return nil, 0
+	}
+	// A new OS image is always rebooted into.
+	if layered {
+		return nil, len(candidates)
+	}
+
+	clusterPolicies, err := ctrl.getNodeDisruptionPolicyClusterStatus()
+	if err != nil {
+		klog.Warningf("Pool %s: treating all updates as disruptive: %v", pool.Name, err)
+		return nil, len(candidates)
+	}
+
+	newConfig, err := ctrl.mcLister.Get(pool.Spec.Configuration.Name)
+	if err != nil {
+		klog.Warningf("Pool %s: treating all updates as disruptive: %v", pool.Name, err)
+		return nil, len(candidates)
+	}
+
+	allowed := []*corev1.Node{}
+	waiting := 0
+	for _, node := range candidates {
+		if ctrl.isDisruptiveUpdate(node, newConfig, clusterPolicies) {
+			waiting++
+			continue
+		}
+		allowed = append(allowed, node)
+	}
+	return allowed, waiting
+}
+
+// isDisruptiveUpdate returns true if updating the node from its current config
+// to newConfig drains or reboots it, or if that can't be determined.
+func (ctrl *Controller) isDisruptiveUpdate(node *corev1.Node, newConfig *mcfgv1.MachineConfig, clusterPolicies *opv1.NodeDisruptionPolicyClusterStatus) bool {
+	oldConfig, err := ctrl.mcLister.Get(node.Annotations[daemonconsts.CurrentMachineConfigAnnotationKey])
+	if err != nil {
+		klog.V(4).Infof("Treating update of node %s as disruptive: %v", node.Name, err)
+		return true
+	}
+	// The image registry drain override ConfigMap is only visible to the MCD,
+	// so registry changes which may skip the drain are treated as disruptive.
+	d, err := disruption.Calculate(oldConfig, newConfig, clusterPolicies, false)
+	if err != nil {
+		klog.V(4).Infof("Treating update of node %s as disruptive: %v", node.Name, err)
+		return true
+	}
+	return d.Drain || d.RequiresReboot()
+}
+
+// getNodeDisruptionPolicyClusterStatus returns the node disruption policies
+// the MCD applies, or nil if they are not in use.
+func (ctrl *Controller) getNodeDisruptionPolicyClusterStatus() (*opv1.NodeDisruptionPolicyClusterStatus, error) {
+	fg, err := ctrl.fgAcessor.CurrentFeatureGates()
+	if err != nil {
+		return nil, err
+	}
+	if !fg.Enabled(features.FeatureGateNodeDisruptionPolicy) {
+		return nil, nil
+	}
+	mcop, err := ctrl.mcopLister.Get(ctrlcommon.MCOOperatorKnobsObjectName)
+	if err != nil {
+		return nil, fmt.Errorf("could not get node disruption policies: %w", err)
+	}
+	if mcop.Generation != mcop.Status.ObservedGeneration {
+		return nil, fmt.Errorf("node disruption policy status is not up to date")
+	}
+	return &mcop.Status.NodeDisruptionPolicyStatus.ClusterPolicies, nil
+}
+
+func setMaintenanceWindowCondition(pool *mcfgv1.MachineConfigPool, status corev1.ConditionStatus, reason, message string) {
+	apihelpers.SetMachineConfigPoolCondition(&pool.Status, *apihelpers.NewMachineConfigPoolCondition(machineConfigPoolMaintenanceWindow, status, reason, message))
+}
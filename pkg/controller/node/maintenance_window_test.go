@@ -0,0 +1,243 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	configv1 "github.com/openshift/api/config/v1"
+	features "github.com/openshift/api/features"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	mcfglistersv1 "github.com/openshift/client-go/machineconfiguration/listers/machineconfiguration/v1"
+	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestGetMaintenanceWindows(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    int
+		expectErr   bool
+	}{
+		{
+			name: "no maintenance windows",
+		},
+		{
+			name:        "one window",
+			annotations: map[string]string{ctrlcommon.MaintenanceWindowAnnotationKey: "0 1 * * * 4h"},
+			expected:    1,
+		},
+		{
+			name: "several windows in a time zone",
+			annotations: map[string]string{
+				ctrlcommon.MaintenanceWindowAnnotationKey:         "0 1 * * 1-5 4h; @weekly 24h",
+				ctrlcommon.MaintenanceWindowTimeZoneAnnotationKey: "UTC",
+			},
+			expected: 2,
+		},
+		{
+			name:        "missing duration",
+			annotations: map[string]string{ctrlcommon.MaintenanceWindowAnnotationKey: "0 1 * * *"},
+			expectErr:   true,
+		},
+		{
+			name:        "invalid schedule",
+			annotations: map[string]string{ctrlcommon.MaintenanceWindowAnnotationKey: "0 25 * * * 4h"},
+			expectErr:   true,
+		},
+		{
+			name:        "no windows",
+			annotations: map[string]string{ctrlcommon.MaintenanceWindowAnnotationKey: ";"},
+			expectErr:   true,
+		},
+		{
+			name: "invalid time zone",
+			annotations: map[string]string{
+				ctrlcommon.MaintenanceWindowAnnotationKey:         "0 1 * * * 4h",
+				ctrlcommon.MaintenanceWindowTimeZoneAnnotationKey: "Nowhere/Special",
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			pool := helpers.NewMachineConfigPoolBuilder("worker").WithAnnotations(test.annotations).MachineConfigPool()
+			mw, err := getMaintenanceWindows(pool)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if test.expected == 0 {
+				assert.Nil(t, mw)
+				return
+			}
+			assert.Len(t, mw.windows, test.expected)
+		})
+	}
+}
+
+func TestMaintenanceWindowsOpen(t *testing.T) {
+	t.Parallel()
+
+	pool := helpers.NewMachineConfigPoolBuilder("worker").WithAnnotations(map[string]string{ctrlcommon.MaintenanceWindowAnnotationKey: "0 1 * * * 4h"}).MachineConfigPool()
+	mw, err := getMaintenanceWindows(pool)
+	require.NoError(t, err)
+	// 01:00 local time is 23:00 UTC the day before.
+	mw.location = time.FixedZone("UTC+2", 2*60*60)
+
+	day := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	assert.False(t, mw.isOpen(day.Add(22*time.Hour+59*time.Minute)))
+	assert.True(t, mw.isOpen(day.Add(23*time.Hour)))
+	assert.True(t, mw.isOpen(day.Add(26*time.Hour)))
+	assert.False(t, mw.isOpen(day.Add(27*time.Hour)))
+
+	assert.True(t, day.Add(23*time.Hour).Equal(mw.nextOpen(day.Add(12*time.Hour))))
+	assert.True(t, day.Add(47*time.Hour).Equal(mw.nextOpen(day.Add(27*time.Hour))))
+}
+
+func TestApplyMaintenanceWindows(t *testing.T) {
+	t.Parallel()
+
+	registries1 := ctrlcommon.NewIgnFile("/etc/containers/registries.conf", "unqualified-search-registries = ['registry.access.redhat.com', 'docker.io']")
+	registries2 := ctrlcommon.NewIgnFile("/etc/containers/registries.conf", "unqualified-search-registries = ['registry.access.redhat.com', 'docker.io', 'quay.io']")
+	randomFile := ctrlcommon.NewIgnFile("/etc/random-file", "hello")
+
+	mcIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, mc := range []*mcfgv1.MachineConfig{
+		helpers.NewMachineConfig("rendered-registries", nil, "", []ign3types.File{registries1}),
+		helpers.NewMachineConfig("rendered-random-file", nil, "", []ign3types.File{registries1, randomFile}),
+		helpers.NewMachineConfig("rendered-new", nil, "", []ign3types.File{registries2}),
+	} {
+		require.NoError(t, mcIndexer.Add(mc))
+	}
+
+	nodes := []*corev1.Node{
+		// Only reloads crio.
+		helpers.NewNodeBuilder("node-0").WithEqualConfigs("rendered-registries").WithNodeReady().Node(),
+		// Reboots to remove the file.
+		helpers.NewNodeBuilder("node-1").WithEqualConfigs("rendered-random-file").WithNodeReady().Node(),
+		// The current config is unknown.
+		helpers.NewNodeBuilder("node-2").WithEqualConfigs("rendered-gone").WithNodeReady().Node(),
+	}
+
+	day := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		annotations    map[string]string
+		now            time.Time
+		layered        bool
+		expected       []string
+		expectedReason string
+		expectedMsg    string
+	}{
+		{
+			name:     "no maintenance windows",
+			now:      day,
+			expected: []string{"node-0", "node-1", "node-2"},
+		},
+		{
+			name:           "inside window",
+			annotations:    map[string]string{ctrlcommon.MaintenanceWindowAnnotationKey: "0 1 * * * 4h"},
+			now:            day.Add(2 * time.Hour),
+			expected:       []string{"node-0", "node-1", "node-2"},
+			expectedReason: maintenanceWindowReasonInside,
+		},
+		{
+			name:           "outside window",
+			annotations:    map[string]string{ctrlcommon.MaintenanceWindowAnnotationKey: "0 1 * * * 4h"},
+			now:            day.Add(12 * time.Hour),
+			expected:       []string{"node-0"},
+			expectedReason: maintenanceWindowReasonOutside,
+			expectedMsg:    "Next maintenance window starts at 2024-06-11T01:00:00Z, 2 nodes waiting for it",
+		},
+		{
+			name:           "outside window with layered pool",
+			annotations:    map[string]string{ctrlcommon.MaintenanceWindowAnnotationKey: "0 1 * * * 4h"},
+			now:            day.Add(12 * time.Hour),
+			layered:        true,
+			expected:       []string{},
+			expectedReason: maintenanceWindowReasonOutside,
+			expectedMsg:    "Next maintenance window starts at 2024-06-11T01:00:00Z, 3 nodes waiting for it",
+		},
+		{
+			name:           "invalid window",
+			annotations:    map[string]string{ctrlcommon.MaintenanceWindowAnnotationKey: "sometime"},
+			now:            day,
+			expected:       []string{"node-0"},
+			expectedReason: maintenanceWindowReasonInvalid,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			pool := helpers.NewMachineConfigPoolBuilder("worker").WithMachineConfig("rendered-new").WithAnnotations(test.annotations).MachineConfigPool()
+			ctrl := &Controller{
+				eventRecorder: record.NewFakeRecorder(10),
+				mcLister:      mcfglistersv1.NewMachineConfigLister(mcIndexer),
+				fgAcessor:     featuregates.NewHardcodedFeatureGateAccess([]configv1.FeatureGateName{}, []configv1.FeatureGateName{features.FeatureGateNodeDisruptionPolicy}),
+				queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+					workqueue.DefaultTypedControllerRateLimiter[string](),
+					workqueue.TypedRateLimitingQueueConfig[string]{Name: "test"}),
+			}
+			defer ctrl.queue.ShutDown()
+
+			allowed := ctrl.applyMaintenanceWindows(pool, nodes, test.layered, test.now)
+			assert.ElementsMatch(t, test.expected, getNamesFromNodes(allowed))
+
+			cond := apihelpers.GetMachineConfigPoolCondition(pool.Status, machineConfigPoolMaintenanceWindow)
+			if test.expectedReason == "" {
+				assert.Nil(t, cond)
+				return
+			}
+			require.NotNil(t, cond)
+			assert.Equal(t, test.expectedReason, cond.Reason)
+			if test.expectedMsg != "" {
+				assert.Equal(t, test.expectedMsg, cond.Message)
+			}
+		})
+	}
+}
+
+func TestApplyInvalidMaintenanceWindows(t *testing.T) {
+	t.Parallel()
+
+	recorder := record.NewFakeRecorder(10)
+	ctrl := &Controller{eventRecorder: recorder}
+
+	// Without a maintenance window, nothing is reported.
+	pool := helpers.NewMachineConfigPoolBuilder("worker").WithMachineConfig("rendered-new").MachineConfigPool()
+	assert.Empty(t, ctrl.applyMaintenanceWindows(pool, nil, false, time.Now()))
+	assert.Nil(t, apihelpers.GetMachineConfigPoolCondition(pool.Status, machineConfigPoolMaintenanceWindow))
+	assert.Len(t, recorder.Events, 0)
+
+	// An invalid window is reported once, not on every sync.
+	pool.Annotations = map[string]string{ctrlcommon.MaintenanceWindowAnnotationKey: "sometime"}
+	ctrl.applyMaintenanceWindows(pool, nil, false, time.Now())
+	ctrl.applyMaintenanceWindows(pool, nil, false, time.Now())
+	assert.Len(t, recorder.Events, 1)
+
+	cond := apihelpers.GetMachineConfigPoolCondition(pool.Status, machineConfigPoolMaintenanceWindow)
+	require.NotNil(t, cond)
+	assert.Equal(t, maintenanceWindowReasonInvalid, cond.Reason)
+}
@@ -23,6 +23,8 @@ import (
 	"github.com/openshift/client-go/machineconfiguration/clientset/versioned/scheme"
 	mcfginformersv1 "github.com/openshift/client-go/machineconfiguration/informers/externalversions/machineconfiguration/v1"
 	mcfglistersv1alpha1 "github.com/openshift/client-go/machineconfiguration/listers/machineconfiguration/v1alpha1"
+	mcopinformersv1 "github.com/openshift/client-go/operator/informers/externalversions/operator/v1"
+	mcoplistersv1 "github.com/openshift/client-go/operator/listers/operator/v1"
 
 	mcfglistersv1 "github.com/openshift/client-go/machineconfiguration/listers/machineconfiguration/v1"
 	"github.com/openshift/library-go/pkg/operator/v1helpers"
@@ -104,6 +106,9 @@ type Controller struct {
 	schedulerList         cligolistersv1.SchedulerLister
 	schedulerListerSynced cache.InformerSynced
 
+	mcopLister       mcoplistersv1.MachineConfigurationLister
+	mcopListerSynced cache.InformerSynced
+
 	queue workqueue.TypedRateLimitingInterface[string]
 
 	fgAcessor featuregates.FeatureGateAccess
@@ -121,6 +126,7 @@ func New(
 	podInformer coreinformersv1.PodInformer,
 	moscInformer mcfginformersv1alpha1.MachineOSConfigInformer,
 	schedulerInformer cligoinformersv1.SchedulerInformer,
+	mcopInformer mcopinformersv1.MachineConfigurationInformer,
 	kubeClient clientset.Interface,
 	mcfgClient mcfgclientset.Interface,
 	fgAccessor featuregates.FeatureGateAccess,
@@ -133,6 +139,7 @@ func New(
 		nodeInformer,
 		podInformer,
 		schedulerInformer,
+		mcopInformer,
 		kubeClient,
 		mcfgClient,
 		defaultUpdateDelay,
@@ -148,6 +155,7 @@ func NewWithCustomUpdateDelay(
 	podInformer coreinformersv1.PodInformer,
 	moscInformer mcfginformersv1alpha1.MachineOSConfigInformer,
 	schedulerInformer cligoinformersv1.SchedulerInformer,
+	mcopInformer mcopinformersv1.MachineConfigurationInformer,
 	kubeClient clientset.Interface,
 	mcfgClient mcfgclientset.Interface,
 	updateDelay time.Duration,
@@ -161,6 +169,7 @@ func NewWithCustomUpdateDelay(
 		nodeInformer,
 		podInformer,
 		schedulerInformer,
+		mcopInformer,
 		kubeClient,
 		mcfgClient,
 		updateDelay,
@@ -177,6 +186,7 @@ func newController(
 	nodeInformer coreinformersv1.NodeInformer,
 	podInformer coreinformersv1.PodInformer,
 	schedulerInformer cligoinformersv1.SchedulerInformer,
+	mcopInformer mcopinformersv1.MachineConfigurationInformer,
 	kubeClient clientset.Interface,
 	mcfgClient mcfgclientset.Interface,
 	updateDelay time.Duration,
@@ -233,6 +243,9 @@ func newController(
 	ctrl.schedulerList = schedulerInformer.Lister()
 	ctrl.schedulerListerSynced = schedulerInformer.Informer().HasSynced
 
+	ctrl.mcopLister = mcopInformer.Lister()
+	ctrl.mcopListerSynced = mcopInformer.Informer().HasSynced
+
 	return ctrl
 }
 
@@ -241,7 +254,7 @@ func (ctrl *Controller) Run(workers int, stopCh <-chan struct{}) {
 	defer utilruntime.HandleCrash()
 	defer ctrl.queue.ShutDown()
 
-	if !cache.WaitForCacheSync(stopCh, ctrl.ccListerSynced, ctrl.mcListerSynced, ctrl.mcpListerSynced, ctrl.nodeListerSynced, ctrl.schedulerListerSynced) {
+	if !cache.WaitForCacheSync(stopCh, ctrl.ccListerSynced, ctrl.mcListerSynced, ctrl.mcpListerSynced, ctrl.nodeListerSynced, ctrl.schedulerListerSynced, ctrl.mcopListerSynced) {
 		return
 	}
 
@@ -1027,6 +1040,19 @@ func (ctrl *Controller) syncMachineConfigPool(key string) error {
 		return err
 	}
 
+	paused, err := ctrl.syncFailureBudget(pool, nodes)
+	if err != nil {
+		if syncErr := ctrl.syncStatusOnly(pool); syncErr != nil {
+			errs := kubeErrs.NewAggregate([]error{syncErr, err})
+			return fmt.Errorf("error checking failure budget for pool %q, sync error: %w", pool.Name, errs)
+		}
+		return err
+	}
+	if paused {
+		klog.Infof("Pool %s has exceeded its failure budget and will not update.", pool.Name)
+		return ctrl.syncStatusOnly(pool)
+	}
+
 	maxunavail, err := maxUnavailable(pool, nodes)
 	if err != nil {
 		if syncErr := ctrl.syncStatusOnly(pool); syncErr != nil {
@@ -1039,6 +1065,9 @@ func (ctrl *Controller) syncMachineConfigPool(key string) error {
 	if err := ctrl.setClusterConfigAnnotation(nodes); err != nil {
 		return fmt.Errorf("error setting clusterConfig Annotation for node in pool %q, error: %w", pool.Name, err)
 	}
+	if err := ctrl.setPoolAnnotationsOnNodes(pool, nodes); err != nil {
+		return fmt.Errorf("error setting pool annotations for node in pool %q, error: %w", pool.Name, err)
+	}
 	// Taint all the nodes in the node pool, irrespective of their upgrade status.
 	ctx := context.TODO()
 	for _, node := range nodes {
@@ -1065,6 +1094,7 @@ func (ctrl *Controller) syncMachineConfigPool(key string) error {
 		}
 	}
 	candidates, capacity := getAllCandidateMachines(layered, mosc, mosb, pool, nodes, maxunavail)
+	candidates, capacity = ctrl.applyUpdateStrategy(pool, nodes, candidates, capacity, layered)
 	if len(candidates) > 0 {
 		zones := make(map[string]bool)
 		for _, candidate := range candidates {
@@ -1074,7 +1104,7 @@ func (ctrl *Controller) syncMachineConfigPool(key string) error {
 			}
 		}
 		ctrl.logPool(pool, "%d candidate nodes in %d zones for update, capacity: %d", len(candidates), len(zones), capacity)
-		if err := ctrl.updateCandidateMachines(pool, candidates, capacity); err != nil {
+		if err := ctrl.updateCandidateMachines(pool, candidates, capacity, layered); err != nil {
 			if syncErr := ctrl.syncStatusOnly(pool); syncErr != nil {
 				errs := kubeErrs.NewAggregate([]error{syncErr, err})
 				return fmt.Errorf("error setting annotations for pool %q, sync error: %w", pool.Name, errs)
@@ -1082,6 +1112,9 @@ func (ctrl *Controller) syncMachineConfigPool(key string) error {
 			return err
 		}
 		ctrlcommon.UpdateStateMetric(ctrlcommon.MCCSubControllerState, "machine-config-controller-node", "Sync Machine Config Pool", pool.Name)
+	} else {
+		// Nobody is waiting for a maintenance window anymore.
+		ctrl.applyMaintenanceWindows(pool, nil, layered, time.Now())
 	}
 	return ctrl.syncStatusOnly(pool)
 }
@@ -1149,6 +1182,33 @@ func (ctrl *Controller) setClusterConfigAnnotation(nodes []*corev1.Node) error {
 	return nil
 }
 
+// setPoolAnnotationsOnNodes copies the pool's config drift policy and
+// exclusions and its live kernel arguments onto its nodes, where the MCD reads them.
+func (ctrl *Controller) setPoolAnnotationsOnNodes(pool *mcfgv1.MachineConfigPool, nodes []*corev1.Node) error {
+	for _, key := range []string{daemonconsts.ConfigDriftPolicyAnnotationKey, daemonconsts.ConfigDriftExclusionsAnnotationKey, daemonconsts.LiveKernelArgumentsAnnotationKey} {
+		value, hasValue := pool.Annotations[key]
+
+		for _, node := range nodes {
+			nodeValue, nodeHasValue := node.Annotations[key]
+			if nodeValue == value && nodeHasValue == hasValue {
+				continue
+			}
+			_, err := internal.UpdateNodeRetry(ctrl.kubeClient.CoreV1().Nodes(), ctrl.nodeLister, node.Name, func(node *corev1.Node) {
+				if hasValue {
+					node.Annotations[key] = value
+				} else {
+					delete(node.Annotations, key)
+				}
+			})
+			if err != nil {
+				return err
+			}
+			klog.Infof("Updated %s annotation of node %s from %q to %q", key, node.Name, nodeValue, value)
+		}
+	}
+	return nil
+}
+
 // updateCandidateNode needs to understand MOSB
 // specifically, the LayeredNodeState probably needs to understand mosb
 func (ctrl *Controller) updateCandidateNode(mosc *mcfgv1alpha1.MachineOSConfig, mosb *mcfgv1alpha1.MachineOSBuild, nodeName string, pool *mcfgv1.MachineConfigPool) error {
@@ -1304,7 +1364,11 @@ func (ctrl *Controller) filterControlPlaneCandidateNodes(pool *mcfgv1.MachineCon
 // SetDesiredStateFromPool in old mco explains how this works. Somehow you need to NOT FAIL if the mosb doesn't exist. So
 // we still need to base this whole things on pools but IsLayeredPool == does mosb exist
 // updateCandidateMachines sets the desiredConfig annotation the candidate machines
-func (ctrl *Controller) updateCandidateMachines(pool *mcfgv1.MachineConfigPool, candidates []*corev1.Node, capacity uint) error {
+func (ctrl *Controller) updateCandidateMachines(pool *mcfgv1.MachineConfigPool, candidates []*corev1.Node, capacity uint, layered bool) error {
+	candidates = ctrl.applyMaintenanceWindows(pool, candidates, layered, time.Now())
+	if len(candidates) == 0 {
+		return nil
+	}
 	if pool.Name == ctrlcommon.MachineConfigPoolMaster {
 		var err error
 		candidates, capacity, err = ctrl.filterControlPlaneCandidateNodes(pool, candidates, capacity)
@@ -1315,11 +1379,7 @@ func (ctrl *Controller) updateCandidateMachines(pool *mcfgv1.MachineConfigPool,
 		ctrl.logPool(pool, "filtered to %d candidate nodes for update, capacity: %d", len(candidates), capacity)
 	}
 	if capacity < uint(len(candidates)) {
-		// when list is longer than maxUnavailable, rollout nodes in zone order, zones without zone label
-		// are done last from oldest to youngest. this reduces likelihood of randomly picking nodes
-		// across multiple zones that run the same types of pods resulting in an outage in HA clusters
-		candidates = sortNodeList(candidates)
-
+		// the candidates have already been ordered by the pool's update strategy
 		candidates = candidates[:capacity]
 	}
 
@@ -34,6 +34,8 @@ import (
 	configv1informer "github.com/openshift/client-go/config/informers/externalversions"
 	"github.com/openshift/client-go/machineconfiguration/clientset/versioned/fake"
 	informers "github.com/openshift/client-go/machineconfiguration/informers/externalversions"
+	fakeoperatorclient "github.com/openshift/client-go/operator/clientset/versioned/fake"
+	operatorinformer "github.com/openshift/client-go/operator/informers/externalversions"
 	"github.com/openshift/machine-config-operator/pkg/constants"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
@@ -63,6 +65,7 @@ type fixture struct {
 	client          *fake.Clientset
 	kubeclient      *k8sfake.Clientset
 	schedulerClient *fakeconfigv1client.Clientset
+	operatorClient  *fakeoperatorclient.Clientset
 
 	ccLister   []*mcfgv1.ControllerConfig
 	mcpLister  []*mcfgv1.MachineConfigPool
@@ -100,8 +103,10 @@ func (f *fixture) newControllerWithStopChan(stopCh <-chan struct{}) *Controller
 	i := informers.NewSharedInformerFactory(f.client, noResyncPeriodFunc())
 	k8sI := kubeinformers.NewSharedInformerFactory(f.kubeclient, noResyncPeriodFunc())
 	ci := configv1informer.NewSharedInformerFactory(f.schedulerClient, noResyncPeriodFunc())
+	f.operatorClient = fakeoperatorclient.NewSimpleClientset()
+	oi := operatorinformer.NewSharedInformerFactory(f.operatorClient, noResyncPeriodFunc())
 	c := NewWithCustomUpdateDelay(i.Machineconfiguration().V1().ControllerConfigs(), i.Machineconfiguration().V1().MachineConfigs(), i.Machineconfiguration().V1().MachineConfigPools(), k8sI.Core().V1().Nodes(),
-		k8sI.Core().V1().Pods(), i.Machineconfiguration().V1alpha1().MachineOSConfigs(), ci.Config().V1().Schedulers(), f.kubeclient, f.client, time.Millisecond, f.fgAccess)
+		k8sI.Core().V1().Pods(), i.Machineconfiguration().V1alpha1().MachineOSConfigs(), ci.Config().V1().Schedulers(), oi.Operator().V1().MachineConfigurations(), f.kubeclient, f.client, time.Millisecond, f.fgAccess)
 
 	c.ccListerSynced = alwaysReady
 	c.mcpListerSynced = alwaysReady
@@ -268,6 +273,16 @@ func (f *fixture) expectUpdateMachineConfigPoolStatus(pool *mcfgv1.MachineConfig
 	f.actions = append(f.actions, core.NewRootUpdateSubresourceAction(schema.GroupVersionResource{Resource: "machineconfigpools"}, "status", pool))
 }
 
+func (f *fixture) expectPatchMachineConfigPool(pool *mcfgv1.MachineConfigPool, patch []byte) {
+	f.actions = append(f.actions, core.NewRootPatchAction(schema.GroupVersionResource{Resource: "machineconfigpools"}, pool.Name, types.MergePatchType, patch))
+}
+
+// setRenderedConfigHistory records that the pool completed an update to its
+// target config, so that syncing it does not add to its history.
+func setRenderedConfigHistory(pool *mcfgv1.MachineConfigPool) {
+	ctrlcommon.AddToRenderedConfigHistory(pool, pool.Spec.Configuration, ctrlcommon.DefaultRenderedConfigHistoryLimit)
+}
+
 func (f *fixture) expectGetNodeAction(node *corev1.Node) {
 	f.kubeactions = append(f.kubeactions, core.NewGetAction(schema.GroupVersionResource{Resource: "nodes"}, node.Namespace, node.Name))
 }
@@ -1138,6 +1153,7 @@ func TestShouldMakeProgress(t *testing.T) {
 
 			mcpWorker := test.workerPool
 			mcp := test.infraPool
+			setRenderedConfigHistory(mcp)
 
 			existingNodeBuilder := helpers.NewNodeBuilder("existingNodeAtDesiredConfig").WithEqualConfigs(machineConfigV1).WithLabels(map[string]string{"node-role/worker": "", "node-role/infra": ""})
 			lps := ctrlcommon.NewLayeredPoolState(mcp)
@@ -1328,6 +1344,8 @@ func TestShouldUpdateStatusOnlyUpdated(t *testing.T) {
 	expMcp := mcp.DeepCopy()
 	expMcp.Status = expStatus
 	f.expectUpdateMachineConfigPoolStatus(expMcp)
+	// The completed update is added to the rendered config history.
+	f.expectPatchMachineConfigPool(mcp, []byte(`{"metadata":{"annotations":{"machineconfiguration.openshift.io/rendered-config-history":"[{\"name\":\"`+machineConfigV1+`\"}]"}}}`))
 
 	f.run(getKey(mcp, t))
 }
@@ -1376,6 +1394,7 @@ func TestCertStatus(t *testing.T) {
 	mcp := helpers.NewMachineConfigPool("test-cluster-infra", nil, helpers.InfraSelector, "v1")
 	mcpWorker := helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v1")
 	mcp.Spec.MaxUnavailable = intStrPtr(intstr.FromInt(1))
+	setRenderedConfigHistory(mcp)
 	nodes := []*corev1.Node{
 		newNodeWithLabel("node-0", "v1", "v1", map[string]string{"node-role/worker": "", "node-role/infra": ""}),
 		newNodeWithLabel("node-1", "v1", "v1", map[string]string{"node-role/worker": "", "node-role/infra": ""}),
@@ -1409,6 +1428,7 @@ func TestShouldDoNothing(t *testing.T) {
 	mcp := helpers.NewMachineConfigPool("test-cluster-infra", nil, helpers.InfraSelector, machineConfigV1)
 	mcpWorker := helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, machineConfigV1)
 	mcp.Spec.MaxUnavailable = intStrPtr(intstr.FromInt(1))
+	setRenderedConfigHistory(mcp)
 	nodes := []*corev1.Node{
 		newNodeWithLabel("node-0", machineConfigV1, machineConfigV1, map[string]string{"node-role/worker": "", "node-role/infra": ""}),
 		newNodeWithLabel("node-1", machineConfigV1, machineConfigV1, map[string]string{"node-role/worker": "", "node-role/infra": ""}),
@@ -1499,6 +1519,7 @@ func TestControlPlaneTopology(t *testing.T) {
 	mcp := helpers.NewMachineConfigPool("test-cluster-infra", nil, helpers.InfraSelector, machineConfigV1)
 	mcpWorker := helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, machineConfigV1)
 	mcp.Spec.MaxUnavailable = intStrPtr(intstr.FromInt(1))
+	setRenderedConfigHistory(mcp)
 	annotations := map[string]string{daemonconsts.ClusterControlPlaneTopologyAnnotationKey: "SingleReplica"}
 
 	nodes := []*corev1.Node{
@@ -0,0 +1,84 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+// syncRenderedConfigHistory adds the config the pool completed an update to
+// to the rendered config history in the pool annotations, once status reports
+// all of its nodes as updated.
+func (ctrl *Controller) syncRenderedConfigHistory(pool *mcfgv1.MachineConfigPool, status mcfgv1.MachineConfigPoolStatus) error {
+	if pool.Spec.Configuration.Name == "" || !apihelpers.IsMachineConfigPoolConditionTrue(status.Conditions, mcfgv1.MachineConfigPoolUpdated) {
+		return nil
+	}
+
+	limit, err := ctrlcommon.GetRenderedConfigHistoryLimit(pool)
+	if err != nil {
+		klog.Warningf("Pool %s: %v, recording the last %d rendered configs", pool.Name, err, ctrlcommon.DefaultRenderedConfigHistoryLimit)
+		limit = ctrlcommon.DefaultRenderedConfigHistoryLimit
+	}
+
+	updated := pool.DeepCopy()
+	ctrlcommon.AddToRenderedConfigHistory(updated, pool.Spec.Configuration, limit)
+	return ctrl.syncPoolAnnotations(pool, map[string]string{
+		ctrlcommon.RenderedConfigHistoryAnnotationKey: updated.Annotations[ctrlcommon.RenderedConfigHistoryAnnotationKey],
+	})
+}
+
+// syncPoolAnnotations sets the given annotations on the pool, removing the
+// ones with an empty value, and patches the ones which changed. The API of the
+// pool cannot be extended, so the node controller keeps state which does not
+// fit into its conditions in annotations.
+func (ctrl *Controller) syncPoolAnnotations(pool *mcfgv1.MachineConfigPool, annotations map[string]string) error {
+	changed := map[string]interface{}{}
+	for key, val := range annotations {
+		if pool.Annotations[key] == val {
+			continue
+		}
+		if val == "" {
+			changed[key] = nil
+		} else {
+			changed[key] = val
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": changed,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	patched, err := ctrl.client.MachineconfigurationV1().MachineConfigPools().Patch(context.TODO(), pool.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("could not update annotations of MachineConfigPool %q: %w", pool.Name, err)
+	}
+	// Later status updates of pool must not conflict with the patch.
+	pool.ResourceVersion = patched.ResourceVersion
+
+	if pool.Annotations == nil {
+		pool.Annotations = map[string]string{}
+	}
+	for key, val := range annotations {
+		if val == "" {
+			delete(pool.Annotations, key)
+		} else {
+			pool.Annotations[key] = val
+		}
+	}
+	return nil
+}
@@ -58,8 +58,14 @@ func (ctrl *Controller) syncStatusOnly(pool *mcfgv1.MachineConfigPool) error {
 	mosc, mosb, _ := ctrl.GetConfigAndBuild(pool)
 
 	newStatus := ctrl.calculateStatus(fg, machineConfigStates, cc, pool, nodes, mosc, mosb)
-	if equality.Semantic.DeepEqual(pool.Status, newStatus) {
-		return nil
+	// Compare against the cached pool, since the caller may already have set
+	// conditions on pool which still need to be written.
+	oldStatus := pool.Status
+	if cachedPool, err := ctrl.mcpLister.Get(pool.Name); err == nil {
+		oldStatus = cachedPool.Status
+	}
+	if equality.Semantic.DeepEqual(oldStatus, newStatus) {
+		return ctrl.syncRenderedConfigHistory(pool, newStatus)
 	}
 
 	newPool := pool
@@ -68,6 +74,9 @@ func (ctrl *Controller) syncStatusOnly(pool *mcfgv1.MachineConfigPool) error {
 	if err != nil {
 		return fmt.Errorf("could not update MachineConfigPool %q: %w", newPool.Name, err)
 	}
+	if err := ctrl.syncRenderedConfigHistory(newPool, newStatus); err != nil {
+		return err
+	}
 
 	l, err := ctrl.IsLayeredPool(mosc, mosb)
 	if err != nil {
@@ -266,12 +275,20 @@ func (ctrl *Controller) calculateStatus(fg featuregates.FeatureGate, mcs []*mcfg
 			klog.Infof("Pool %s: %s", pool.Name, updatedMsg)
 			status.Configuration = pool.Spec.Configuration
 		}
+		if pool.Spec.Configuration.Name != "" {
+			shistory := apihelpers.NewMachineConfigPoolCondition(ctrlcommon.MachineConfigPoolRenderedConfigHistory, corev1.ConditionTrue, "Completed",
+				fmt.Sprintf("Completed update to %s; the rendered configs the pool can be rolled back to are listed in its %s annotation", pool.Spec.Configuration.Name, ctrlcommon.RenderedConfigHistoryAnnotationKey))
+			apihelpers.SetMachineConfigPoolCondition(&status, *shistory)
+		}
 	} else {
 		supdated := apihelpers.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolUpdated, corev1.ConditionFalse, "", "")
 		apihelpers.SetMachineConfigPoolCondition(&status, *supdated)
 		if pool.Spec.Paused {
 			supdating := apihelpers.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolUpdating, corev1.ConditionFalse, "", fmt.Sprintf("Pool is paused; will not update to %s", getPoolUpdateLine(pool, mosc, l)))
 			apihelpers.SetMachineConfigPoolCondition(&status, *supdating)
+		} else if isFailureBudgetExceeded(pool) {
+			supdating := apihelpers.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolUpdating, corev1.ConditionFalse, "", fmt.Sprintf("Pool has exceeded its failure budget; will not update to %s", getPoolUpdateLine(pool, mosc, l)))
+			apihelpers.SetMachineConfigPoolCondition(&status, *supdating)
 		} else {
 			supdating := apihelpers.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolUpdating, corev1.ConditionTrue, "", fmt.Sprintf("All nodes are updating to %s", getPoolUpdateLine(pool, mosc, l)))
 			apihelpers.SetMachineConfigPoolCondition(&status, *supdating)
@@ -12,6 +12,7 @@ import (
 	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
 	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
 	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
 	"github.com/openshift/machine-config-operator/test/helpers"
 	"github.com/stretchr/testify/assert"
@@ -935,6 +936,10 @@ func TestCalculateStatus(t *testing.T) {
 			if got, want := condupdating.Status, corev1.ConditionFalse; got != want {
 				t.Fatalf("mismatch condupdating.Status: got %s want: %s", got, want)
 			}
+
+			if !apihelpers.IsMachineConfigPoolConditionTrue(status.Conditions, ctrlcommon.MachineConfigPoolRenderedConfigHistory) {
+				t.Fatal("rendered config history condition not set")
+			}
 		},
 	}}
 	for idx, test := range tests {
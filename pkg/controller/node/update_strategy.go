@@ -0,0 +1,369 @@
+package node
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+// machineConfigPoolUpdateStrategy is the pool condition which reports the
+// update strategy in use and the progress of the rollout according to it.
+const machineConfigPoolUpdateStrategy mcfgv1.MachineConfigPoolConditionType = "UpdateStrategy"
+
+const (
+	updateStrategyReasonInvalid        = "InvalidUpdateStrategy"
+	updateStrategyReasonCanaryUpdating = "CanaryUpdating"
+	updateStrategyReasonCanaryNotReady = "CanaryNotReady"
+	updateStrategyReasonCanarySoaking  = "CanarySoaking"
+	updateStrategyReasonCanaryComplete = "CanaryComplete"
+)
+
+// updateStrategyResult is the outcome of a nodeUpdateStrategy.
+type updateStrategyResult struct {
+	// candidates are the nodes which may be updated now, in order of preference.
+	candidates []*corev1.Node
+	// reason and message are reported in the pool's UpdateStrategy condition.
+	reason  string
+	message string
+	// requeueAfter is set if the pool should be synced again after a delay,
+	// e.g. when waiting for canary nodes to soak.
+	requeueAfter time.Duration
+}
+
+// nodeUpdateStrategy decides which of a pool's candidate nodes are updated
+// next, and in which order. It is given all nodes in the pool so that it can
+// take the progress of the rollout into account.
+type nodeUpdateStrategy interface {
+	selectCandidates(pool *mcfgv1.MachineConfigPool, nodes, candidates []*corev1.Node, layered bool) updateStrategyResult
+}
+
+// getUpdateStrategy returns the update strategy configured for the pool.
+func (ctrl *Controller) getUpdateStrategy(pool *mcfgv1.MachineConfigPool) (nodeUpdateStrategy, error) {
+	strategy := pool.Annotations[ctrlcommon.UpdateStrategyAnnotationKey]
+	switch strategy {
+	case "":
+		return &defaultUpdateStrategy{}, nil
+	case ctrlcommon.UpdateStrategyZone:
+		return &zoneUpdateStrategy{}, nil
+	case ctrlcommon.UpdateStrategyLabelPriority:
+		label := pool.Annotations[ctrlcommon.UpdateStrategyPriorityLabelAnnotationKey]
+		if label == "" {
+			return nil, fmt.Errorf("%s strategy requires the %s annotation", strategy, ctrlcommon.UpdateStrategyPriorityLabelAnnotationKey)
+		}
+		return &labelPriorityUpdateStrategy{label: label}, nil
+	case ctrlcommon.UpdateStrategyLeastLoaded:
+		return &leastLoadedUpdateStrategy{podLister: ctrl.podLister}, nil
+	case ctrlcommon.UpdateStrategyCanary:
+		selector, err := labels.Parse(pool.Annotations[ctrlcommon.UpdateStrategyCanarySelectorAnnotationKey])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", ctrlcommon.UpdateStrategyCanarySelectorAnnotationKey, err)
+		}
+		if selector.Empty() {
+			return nil, fmt.Errorf("%s strategy requires the %s annotation", strategy, ctrlcommon.UpdateStrategyCanarySelectorAnnotationKey)
+		}
+		var soak time.Duration
+		if val, ok := pool.Annotations[ctrlcommon.UpdateStrategyCanarySoakAnnotationKey]; ok {
+			soak, err = time.ParseDuration(val)
+			if err != nil || soak < 0 {
+				return nil, fmt.Errorf("invalid %s annotation %q", ctrlcommon.UpdateStrategyCanarySoakAnnotationKey, val)
+			}
+		}
+		return &canaryUpdateStrategy{selector: selector, soak: soak, now: time.Now}, nil
+	default:
+		return nil, fmt.Errorf("unknown update strategy %q", strategy)
+	}
+}
+
+// applyUpdateStrategy filters and orders the candidate nodes of the pool
+// according to its update strategy and records the progress of the rollout in
+// the UpdateStrategy condition of the pool's status.
+func (ctrl *Controller) applyUpdateStrategy(pool *mcfgv1.MachineConfigPool, nodes, candidates []*corev1.Node, capacity uint, layered bool) ([]*corev1.Node, uint) {
+	strategy, err := ctrl.getUpdateStrategy(pool)
+	if err != nil {
+		// Updating the pool in an order the admin did not ask for could be
+		// disruptive, so don't update it at all until this is fixed. The
+		// condition already reports a known error, so the event is only
+		// emitted when the error changes.
+		cond := apihelpers.GetMachineConfigPoolCondition(pool.Status, machineConfigPoolUpdateStrategy)
+		if cond == nil || cond.Reason != updateStrategyReasonInvalid || cond.Message != err.Error() {
+			ctrl.eventRecorder.Eventf(pool, corev1.EventTypeWarning, updateStrategyReasonInvalid, "Not updating nodes: %v", err)
+		}
+		setUpdateStrategyCondition(pool, corev1.ConditionFalse, updateStrategyReasonInvalid, err.Error())
+		return nil, 0
+	}
+
+	result := strategy.selectCandidates(pool, nodes, candidates, layered)
+	if result.reason == "" {
+		if apihelpers.GetMachineConfigPoolCondition(pool.Status, machineConfigPoolUpdateStrategy) != nil {
+			apihelpers.RemoveMachineConfigPoolCondition(&pool.Status, machineConfigPoolUpdateStrategy)
+		}
+	} else {
+		setUpdateStrategyCondition(pool, corev1.ConditionTrue, result.reason, result.message)
+	}
+	if result.requeueAfter > 0 {
+		ctrl.enqueueAfter(pool, result.requeueAfter)
+	}
+	if len(result.candidates) == 0 {
+		return nil, 0
+	}
+	return result.candidates, capacity
+}
+
+// setUpdateStrategyCondition sets the UpdateStrategy condition of the pool.
+// Unlike other pool conditions, its transition time is reset whenever its
+// reason changes, so that it records when the current phase of the rollout
+// started.
+func setUpdateStrategyCondition(pool *mcfgv1.MachineConfigPool, status corev1.ConditionStatus, reason, message string) {
+	cond := apihelpers.GetMachineConfigPoolCondition(pool.Status, machineConfigPoolUpdateStrategy)
+	if cond != nil && cond.Reason != reason {
+		apihelpers.RemoveMachineConfigPoolCondition(&pool.Status, machineConfigPoolUpdateStrategy)
+	}
+	apihelpers.SetMachineConfigPoolCondition(&pool.Status, *apihelpers.NewMachineConfigPoolCondition(machineConfigPoolUpdateStrategy, status, reason, message))
+}
+
+// filterNodes returns the nodes for which keep returns true.
+func filterNodes(nodes []*corev1.Node, keep func(*corev1.Node) bool) []*corev1.Node {
+	var filtered []*corev1.Node
+	for _, node := range nodes {
+		if keep(node) {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
+
+// defaultUpdateStrategy updates nodes in zone order, without waiting for one
+// zone to complete before starting on the next.
+type defaultUpdateStrategy struct{}
+
+func (s *defaultUpdateStrategy) selectCandidates(_ *mcfgv1.MachineConfigPool, _, candidates []*corev1.Node, _ bool) updateStrategyResult {
+	// rollout nodes in zone order, zones without zone label are done last from oldest to youngest.
+	// this reduces likelihood of randomly picking nodes across multiple zones that run the same
+	// types of pods resulting in an outage in HA clusters
+	return updateStrategyResult{candidates: sortNodeList(candidates)}
+}
+
+// zoneUpdateStrategy updates the nodes of a single zone at a time. Nodes
+// without a zone label are updated last.
+type zoneUpdateStrategy struct{}
+
+func (s *zoneUpdateStrategy) selectCandidates(pool *mcfgv1.MachineConfigPool, nodes, candidates []*corev1.Node, layered bool) updateStrategyResult {
+	allZones := map[string]bool{}
+	pendingZones := map[string]bool{}
+	activeZones := map[string]bool{}
+	for _, node := range nodes {
+		zone := node.Labels[zoneLabel]
+		allZones[zone] = true
+		if isNodeDoneAt(node, pool, layered) {
+			continue
+		}
+		pendingZones[zone] = true
+		if ctrlcommon.NewLayeredNodeState(node).IsDesiredEqualToPool(pool, layered) {
+			activeZones[zone] = true
+		}
+	}
+
+	if len(pendingZones) == 0 {
+		return updateStrategyResult{reason: ctrlcommon.UpdateStrategyZone, message: fmt.Sprintf("All %d zones updated", len(allZones))}
+	}
+
+	// Finish any zone which is already being updated before moving on.
+	zone := firstZone(pendingZones)
+	if len(activeZones) > 0 {
+		zone = firstZone(activeZones)
+	}
+
+	zoneName := fmt.Sprintf("zone %s", zone)
+	if zone == "" {
+		zoneName = "nodes without a zone"
+	}
+	return updateStrategyResult{
+		candidates: sortNodeList(filterNodes(candidates, func(node *corev1.Node) bool { return node.Labels[zoneLabel] == zone })),
+		reason:     ctrlcommon.UpdateStrategyZone,
+		message:    fmt.Sprintf("Updating %s; %d of %d zones updated", zoneName, len(allZones)-len(pendingZones), len(allZones)),
+	}
+}
+
+// firstZone returns the first of the zones in update order. The empty zone,
+// i.e. nodes without a zone label, is last.
+func firstZone(zones map[string]bool) string {
+	names := []string{}
+	for zone := range zones {
+		if zone != "" {
+			names = append(names, zone)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return names[0]
+}
+
+// labelPriorityUpdateStrategy updates nodes in the order given by the integer
+// value of a node label, lowest first. Nodes with the same value are updated
+// together, and nodes without a valid value are updated last.
+type labelPriorityUpdateStrategy struct {
+	label string
+}
+
+func (s *labelPriorityUpdateStrategy) priority(node *corev1.Node) int {
+	val, ok := node.Labels[s.label]
+	if !ok {
+		return math.MaxInt
+	}
+	priority, err := strconv.Atoi(val)
+	if err != nil {
+		return math.MaxInt
+	}
+	return priority
+}
+
+func (s *labelPriorityUpdateStrategy) selectCandidates(pool *mcfgv1.MachineConfigPool, nodes, candidates []*corev1.Node, layered bool) updateStrategyResult {
+	current := math.MaxInt
+	pending := false
+	for _, node := range nodes {
+		if isNodeDoneAt(node, pool, layered) {
+			continue
+		}
+		pending = true
+		if priority := s.priority(node); priority < current {
+			current = priority
+		}
+	}
+
+	if !pending {
+		return updateStrategyResult{reason: ctrlcommon.UpdateStrategyLabelPriority, message: "All nodes updated"}
+	}
+
+	message := fmt.Sprintf("Updating nodes with %s=%d", s.label, current)
+	if current == math.MaxInt {
+		message = fmt.Sprintf("Updating nodes without a valid %s label", s.label)
+	}
+	return updateStrategyResult{
+		candidates: sortNodeList(filterNodes(candidates, func(node *corev1.Node) bool { return s.priority(node) == current })),
+		reason:     ctrlcommon.UpdateStrategyLabelPriority,
+		message:    message,
+	}
+}
+
+// leastLoadedUpdateStrategy updates the nodes running the fewest pods first,
+// not counting DaemonSet and static pods which are not drained.
+type leastLoadedUpdateStrategy struct {
+	podLister corelisterv1.PodLister
+}
+
+func (s *leastLoadedUpdateStrategy) selectCandidates(_ *mcfgv1.MachineConfigPool, _, candidates []*corev1.Node, _ bool) updateStrategyResult {
+	candidates = sortNodeList(candidates)
+
+	pods, err := s.podLister.List(labels.Everything())
+	if err != nil {
+		return updateStrategyResult{
+			candidates: candidates,
+			reason:     ctrlcommon.UpdateStrategyLeastLoaded,
+			message:    fmt.Sprintf("Could not list pods, updating nodes in zone order: %v", err),
+		}
+	}
+
+	load := map[string]int{}
+	for _, pod := range pods {
+		if pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if _, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+			continue
+		}
+		if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "DaemonSet" {
+			continue
+		}
+		load[pod.Spec.NodeName]++
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return load[candidates[i].Name] < load[candidates[j].Name]
+	})
+
+	return updateStrategyResult{
+		candidates: candidates,
+		reason:     ctrlcommon.UpdateStrategyLeastLoaded,
+		message:    "Updating least loaded nodes first",
+	}
+}
+
+// canaryUpdateStrategy updates the canary nodes selected by a label selector
+// first. Once they have all updated, they have to stay Ready for the soak
+// period before the rest of the pool is updated.
+type canaryUpdateStrategy struct {
+	selector labels.Selector
+	soak     time.Duration
+	now      func() time.Time
+}
+
+func (s *canaryUpdateStrategy) isCanary(node *corev1.Node) bool {
+	return s.selector.Matches(labels.Set(node.Labels))
+}
+
+func (s *canaryUpdateStrategy) selectCandidates(pool *mcfgv1.MachineConfigPool, nodes, candidates []*corev1.Node, layered bool) updateStrategyResult {
+	canaries := filterNodes(nodes, s.isCanary)
+	if len(canaries) == 0 {
+		return updateStrategyResult{
+			candidates: sortNodeList(candidates),
+			reason:     updateStrategyReasonCanaryComplete,
+			message:    fmt.Sprintf("No canary nodes match %s, updating all nodes", s.selector),
+		}
+	}
+
+	pending := filterNodes(canaries, func(node *corev1.Node) bool { return !isNodeDoneAt(node, pool, layered) })
+	if len(pending) > 0 {
+		return updateStrategyResult{
+			candidates: sortNodeList(filterNodes(candidates, s.isCanary)),
+			reason:     updateStrategyReasonCanaryUpdating,
+			message:    fmt.Sprintf("Updating canary nodes; %d of %d updated", len(canaries)-len(pending), len(canaries)),
+		}
+	}
+
+	for _, node := range canaries {
+		if !isNodeReady(node) {
+			// Restart the soak period once the canaries are Ready again.
+			return updateStrategyResult{
+				reason:  updateStrategyReasonCanaryNotReady,
+				message: fmt.Sprintf("Canary node %s is not Ready", node.Name),
+			}
+		}
+	}
+
+	rest := updateStrategyResult{
+		candidates: sortNodeList(filterNodes(candidates, func(node *corev1.Node) bool { return !s.isCanary(node) })),
+		reason:     updateStrategyReasonCanaryComplete,
+		message:    fmt.Sprintf("%d canary nodes updated and soaked for %s, updating remaining nodes", len(canaries), s.soak),
+	}
+
+	cond := apihelpers.GetMachineConfigPoolCondition(pool.Status, machineConfigPoolUpdateStrategy)
+	if cond != nil && cond.Reason == updateStrategyReasonCanaryComplete {
+		return rest
+	}
+
+	soakStart := s.now()
+	if cond != nil && cond.Reason == updateStrategyReasonCanarySoaking {
+		soakStart = cond.LastTransitionTime.Time
+	}
+	if remaining := soakStart.Add(s.soak).Sub(s.now()); remaining > 0 {
+		return updateStrategyResult{
+			reason:       updateStrategyReasonCanarySoaking,
+			message:      fmt.Sprintf("%d canary nodes updated, soaking for %s", len(canaries), s.soak),
+			requeueAfter: remaining,
+		}
+	}
+	return rest
+}
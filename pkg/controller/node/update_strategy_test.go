@@ -0,0 +1,342 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func newStrategyNode(name, currentConfig, desiredConfig string, nodeLabels map[string]string) *corev1.Node {
+	return helpers.NewNodeBuilder(name).WithConfigs(currentConfig, desiredConfig).WithLabels(nodeLabels).WithNodeReady().Node()
+}
+
+// strategyCandidates returns the nodes which are not yet targeting the pool's config.
+func strategyCandidates(pool *mcfgv1.MachineConfigPool, nodes []*corev1.Node) []*corev1.Node {
+	return filterNodes(nodes, func(node *corev1.Node) bool {
+		return !ctrlcommon.NewLayeredNodeState(node).IsDesiredEqualToPool(pool, false)
+	})
+}
+
+func TestGetUpdateStrategy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    nodeUpdateStrategy
+		expectErr   bool
+	}{
+		{
+			name:     "no strategy",
+			expected: &defaultUpdateStrategy{},
+		},
+		{
+			name:        "zone",
+			annotations: map[string]string{ctrlcommon.UpdateStrategyAnnotationKey: ctrlcommon.UpdateStrategyZone},
+			expected:    &zoneUpdateStrategy{},
+		},
+		{
+			name: "label priority",
+			annotations: map[string]string{
+				ctrlcommon.UpdateStrategyAnnotationKey:              ctrlcommon.UpdateStrategyLabelPriority,
+				ctrlcommon.UpdateStrategyPriorityLabelAnnotationKey: "example.com/priority",
+			},
+			expected: &labelPriorityUpdateStrategy{label: "example.com/priority"},
+		},
+		{
+			name:        "label priority without label",
+			annotations: map[string]string{ctrlcommon.UpdateStrategyAnnotationKey: ctrlcommon.UpdateStrategyLabelPriority},
+			expectErr:   true,
+		},
+		{
+			name:        "canary without selector",
+			annotations: map[string]string{ctrlcommon.UpdateStrategyAnnotationKey: ctrlcommon.UpdateStrategyCanary},
+			expectErr:   true,
+		},
+		{
+			name: "canary with invalid soak",
+			annotations: map[string]string{
+				ctrlcommon.UpdateStrategyAnnotationKey:               ctrlcommon.UpdateStrategyCanary,
+				ctrlcommon.UpdateStrategyCanarySelectorAnnotationKey: "canary",
+				ctrlcommon.UpdateStrategyCanarySoakAnnotationKey:     "-1h",
+			},
+			expectErr: true,
+		},
+		{
+			name:        "unknown strategy",
+			annotations: map[string]string{ctrlcommon.UpdateStrategyAnnotationKey: "Random"},
+			expectErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			pool := helpers.NewMachineConfigPoolBuilder("worker").WithMachineConfig(machineConfigV1).MachineConfigPool()
+			pool.Annotations = test.annotations
+
+			strategy, err := (&Controller{}).getUpdateStrategy(pool)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, strategy)
+		})
+	}
+
+	pool := helpers.NewMachineConfigPoolBuilder("worker").WithMachineConfig(machineConfigV1).MachineConfigPool()
+	pool.Annotations = map[string]string{
+		ctrlcommon.UpdateStrategyAnnotationKey:               ctrlcommon.UpdateStrategyCanary,
+		ctrlcommon.UpdateStrategyCanarySelectorAnnotationKey: "canary",
+		ctrlcommon.UpdateStrategyCanarySoakAnnotationKey:     "30m",
+	}
+	strategy, err := (&Controller{}).getUpdateStrategy(pool)
+	require.NoError(t, err)
+	canary, ok := strategy.(*canaryUpdateStrategy)
+	require.True(t, ok)
+	assert.Equal(t, 30*time.Minute, canary.soak)
+	assert.Equal(t, "canary", canary.selector.String())
+}
+
+func TestApplyInvalidUpdateStrategy(t *testing.T) {
+	t.Parallel()
+
+	recorder := record.NewFakeRecorder(10)
+	ctrl := &Controller{eventRecorder: recorder}
+
+	pool := helpers.NewMachineConfigPoolBuilder("worker").WithMachineConfig(machineConfigV1).MachineConfigPool()
+	pool.Annotations = map[string]string{ctrlcommon.UpdateStrategyAnnotationKey: "Random"}
+	nodes := []*corev1.Node{newStrategyNode("node-0", machineConfigV0, machineConfigV0, nil)}
+
+	// No node is updated and the event is only emitted once for the same
+	// invalid strategy.
+	for i := 0; i < 3; i++ {
+		candidates, capacity := ctrl.applyUpdateStrategy(pool, nodes, nodes, 1, false)
+		assert.Empty(t, candidates)
+		assert.Zero(t, capacity)
+	}
+	assert.Len(t, recorder.Events, 1)
+
+	cond := apihelpers.GetMachineConfigPoolCondition(pool.Status, machineConfigPoolUpdateStrategy)
+	require.NotNil(t, cond)
+	assert.Equal(t, updateStrategyReasonInvalid, cond.Reason)
+
+	// A different invalid value is reported again.
+	pool.Annotations[ctrlcommon.UpdateStrategyAnnotationKey] = "Shuffle"
+	ctrl.applyUpdateStrategy(pool, nodes, nodes, 1, false)
+	assert.Len(t, recorder.Events, 2)
+}
+
+func TestZoneUpdateStrategy(t *testing.T) {
+	t.Parallel()
+
+	pool := helpers.NewMachineConfigPoolBuilder("worker").WithMachineConfig(machineConfigV1).MachineConfigPool()
+	zoneA := map[string]string{zoneLabel: "a"}
+	zoneB := map[string]string{zoneLabel: "b"}
+
+	tests := []struct {
+		name            string
+		nodes           []*corev1.Node
+		expected        []string
+		expectedMessage string
+	}{
+		{
+			name: "first zone is updated first",
+			nodes: []*corev1.Node{
+				newStrategyNode("node-0", machineConfigV0, machineConfigV0, zoneB),
+				newStrategyNode("node-1", machineConfigV0, machineConfigV0, nil),
+				newStrategyNode("node-2", machineConfigV0, machineConfigV0, zoneA),
+				newStrategyNode("node-3", machineConfigV0, machineConfigV0, zoneA),
+			},
+			expected:        []string{"node-2", "node-3"},
+			expectedMessage: "Updating zone a; 0 of 3 zones updated",
+		},
+		{
+			name: "zone in progress is finished first",
+			nodes: []*corev1.Node{
+				newStrategyNode("node-0", machineConfigV0, machineConfigV1, zoneB),
+				newStrategyNode("node-1", machineConfigV0, machineConfigV0, zoneB),
+				newStrategyNode("node-2", machineConfigV0, machineConfigV0, zoneA),
+			},
+			expected:        []string{"node-1"},
+			expectedMessage: "Updating zone b; 0 of 2 zones updated",
+		},
+		{
+			name: "nodes without a zone are updated last",
+			nodes: []*corev1.Node{
+				newStrategyNode("node-0", machineConfigV1, machineConfigV1, zoneA),
+				newStrategyNode("node-1", machineConfigV0, machineConfigV0, nil),
+			},
+			expected:        []string{"node-1"},
+			expectedMessage: "Updating nodes without a zone; 1 of 2 zones updated",
+		},
+		{
+			name: "all zones updated",
+			nodes: []*corev1.Node{
+				newStrategyNode("node-0", machineConfigV1, machineConfigV1, zoneA),
+				newStrategyNode("node-1", machineConfigV1, machineConfigV1, zoneB),
+			},
+			expectedMessage: "All 2 zones updated",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := (&zoneUpdateStrategy{}).selectCandidates(pool, test.nodes, strategyCandidates(pool, test.nodes), false)
+			assert.Equal(t, test.expected, getNamesFromNodes(result.candidates))
+			assert.Equal(t, ctrlcommon.UpdateStrategyZone, result.reason)
+			assert.Equal(t, test.expectedMessage, result.message)
+		})
+	}
+}
+
+func TestLabelPriorityUpdateStrategy(t *testing.T) {
+	t.Parallel()
+
+	pool := helpers.NewMachineConfigPoolBuilder("worker").WithMachineConfig(machineConfigV1).MachineConfigPool()
+	strategy := &labelPriorityUpdateStrategy{label: "priority"}
+
+	nodes := []*corev1.Node{
+		newStrategyNode("node-0", machineConfigV0, machineConfigV0, map[string]string{"priority": "2"}),
+		newStrategyNode("node-1", machineConfigV0, machineConfigV0, map[string]string{"priority": "invalid"}),
+		newStrategyNode("node-2", machineConfigV0, machineConfigV0, map[string]string{"priority": "1"}),
+		newStrategyNode("node-3", machineConfigV0, machineConfigV0, map[string]string{"priority": "2"}),
+	}
+	result := strategy.selectCandidates(pool, nodes, strategyCandidates(pool, nodes), false)
+	assert.Equal(t, []string{"node-2"}, getNamesFromNodes(result.candidates))
+	assert.Equal(t, "Updating nodes with priority=1", result.message)
+
+	// node-2 is updating, so the next priority must wait for it.
+	nodes[2] = newStrategyNode("node-2", machineConfigV0, machineConfigV1, map[string]string{"priority": "1"})
+	result = strategy.selectCandidates(pool, nodes, strategyCandidates(pool, nodes), false)
+	assert.Empty(t, result.candidates)
+
+	nodes[2] = newStrategyNode("node-2", machineConfigV1, machineConfigV1, map[string]string{"priority": "1"})
+	result = strategy.selectCandidates(pool, nodes, strategyCandidates(pool, nodes), false)
+	assert.Equal(t, []string{"node-0", "node-3"}, getNamesFromNodes(result.candidates))
+
+	nodes[0] = newStrategyNode("node-0", machineConfigV1, machineConfigV1, map[string]string{"priority": "2"})
+	nodes[3] = newStrategyNode("node-3", machineConfigV1, machineConfigV1, map[string]string{"priority": "2"})
+	result = strategy.selectCandidates(pool, nodes, strategyCandidates(pool, nodes), false)
+	assert.Equal(t, []string{"node-1"}, getNamesFromNodes(result.candidates))
+	assert.Equal(t, "Updating nodes without a valid priority label", result.message)
+}
+
+func TestLeastLoadedUpdateStrategy(t *testing.T) {
+	t.Parallel()
+
+	pool := helpers.NewMachineConfigPoolBuilder("worker").WithMachineConfig(machineConfigV1).MachineConfigPool()
+
+	newPod := func(name, nodeName string, mutate func(*corev1.Pod)) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: nodeName},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		if mutate != nil {
+			mutate(pod)
+		}
+		return pod
+	}
+	isController := true
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, pod := range []*corev1.Pod{
+		newPod("pod-0", "node-0", nil),
+		newPod("pod-1", "node-0", nil),
+		newPod("pod-2", "node-1", nil),
+		// None of these count towards the load of node-2.
+		newPod("pod-3", "node-2", func(pod *corev1.Pod) {
+			pod.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "ds", Controller: &isController}}
+		}),
+		newPod("pod-4", "node-2", func(pod *corev1.Pod) {
+			pod.Annotations = map[string]string{corev1.MirrorPodAnnotationKey: "mirror"}
+		}),
+		newPod("pod-5", "node-2", func(pod *corev1.Pod) { pod.Status.Phase = corev1.PodSucceeded }),
+	} {
+		require.NoError(t, indexer.Add(pod))
+	}
+
+	nodes := []*corev1.Node{
+		newStrategyNode("node-0", machineConfigV0, machineConfigV0, nil),
+		newStrategyNode("node-1", machineConfigV0, machineConfigV0, nil),
+		newStrategyNode("node-2", machineConfigV0, machineConfigV0, nil),
+	}
+	strategy := &leastLoadedUpdateStrategy{podLister: corelisterv1.NewPodLister(indexer)}
+	result := strategy.selectCandidates(pool, nodes, strategyCandidates(pool, nodes), false)
+	assert.Equal(t, []string{"node-2", "node-1", "node-0"}, getNamesFromNodes(result.candidates))
+}
+
+func TestCanaryUpdateStrategy(t *testing.T) {
+	t.Parallel()
+
+	pool := helpers.NewMachineConfigPoolBuilder("worker").WithMachineConfig(machineConfigV1).MachineConfigPool()
+	canaryLabels := map[string]string{"canary": ""}
+	now := time.Now()
+	strategy := &canaryUpdateStrategy{
+		selector: labels.SelectorFromSet(labels.Set{"canary": ""}),
+		soak:     time.Hour,
+		now:      func() time.Time { return now },
+	}
+
+	// The canaries are updated first.
+	nodes := []*corev1.Node{
+		newStrategyNode("node-0", machineConfigV0, machineConfigV0, nil),
+		newStrategyNode("node-1", machineConfigV0, machineConfigV0, canaryLabels),
+		newStrategyNode("node-2", machineConfigV0, machineConfigV0, nil),
+	}
+	result := strategy.selectCandidates(pool, nodes, strategyCandidates(pool, nodes), false)
+	assert.Equal(t, []string{"node-1"}, getNamesFromNodes(result.candidates))
+	assert.Equal(t, updateStrategyReasonCanaryUpdating, result.reason)
+
+	// Once they are updated, they soak.
+	nodes[1] = newStrategyNode("node-1", machineConfigV1, machineConfigV1, canaryLabels)
+	result = strategy.selectCandidates(pool, nodes, strategyCandidates(pool, nodes), false)
+	assert.Empty(t, result.candidates)
+	assert.Equal(t, updateStrategyReasonCanarySoaking, result.reason)
+	assert.Equal(t, time.Hour, result.requeueAfter)
+
+	// The soak is measured from when it started.
+	setUpdateStrategyCondition(pool, corev1.ConditionTrue, result.reason, result.message)
+	setSoakStart := func(start time.Time) {
+		cond := apihelpers.GetMachineConfigPoolCondition(pool.Status, machineConfigPoolUpdateStrategy)
+		cond.LastTransitionTime = metav1.NewTime(start)
+		apihelpers.RemoveMachineConfigPoolCondition(&pool.Status, machineConfigPoolUpdateStrategy)
+		pool.Status.Conditions = append(pool.Status.Conditions, *cond)
+	}
+	setSoakStart(now.Add(-45 * time.Minute))
+	result = strategy.selectCandidates(pool, nodes, strategyCandidates(pool, nodes), false)
+	assert.Empty(t, result.candidates)
+	assert.Equal(t, 15*time.Minute, result.requeueAfter)
+
+	// A canary which is not Ready stops the soak.
+	notReady := helpers.NewNodeBuilder("node-1").WithEqualConfigs(machineConfigV1).WithLabels(canaryLabels).WithNodeNotReady().Node()
+	result = strategy.selectCandidates(pool, []*corev1.Node{nodes[0], notReady, nodes[2]}, strategyCandidates(pool, nodes), false)
+	assert.Empty(t, result.candidates)
+	assert.Equal(t, updateStrategyReasonCanaryNotReady, result.reason)
+
+	// After the soak, the rest of the pool is updated.
+	setSoakStart(now.Add(-2 * time.Hour))
+	result = strategy.selectCandidates(pool, nodes, strategyCandidates(pool, nodes), false)
+	assert.Equal(t, []string{"node-0", "node-2"}, getNamesFromNodes(result.candidates))
+	assert.Equal(t, updateStrategyReasonCanaryComplete, result.reason)
+}
@@ -0,0 +1,260 @@
+package render
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/image"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/pkg/compression"
+	"github.com/containers/image/v5/types"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// extensionsCatalogueFetchTimeout bounds the time spent reading the
+	// catalogue of an extensions container.
+	extensionsCatalogueFetchTimeout = 30 * time.Second
+	// maxExtensionsCatalogueFetchSize bounds the size of the layers downloaded
+	// while looking for the catalogue. A layer is only downloaded if it fits.
+	maxExtensionsCatalogueFetchSize = 128 * 1024 * 1024
+	// extensionsCatalogueRetryInterval is how long the built-in catalogue is
+	// used for an extensions container whose catalogue could not be fetched.
+	extensionsCatalogueRetryInterval = 10 * time.Minute
+)
+
+// cachedExtensionsCatalogue is the catalogue cached for an extensions container.
+type cachedExtensionsCatalogue struct {
+	catalogue ctrlcommon.ExtensionsCatalogue
+	// expires is when a catalogue which could not be fetched is tried again.
+	// It is zero for catalogues read from the image.
+	expires time.Time
+}
+
+// validateExtensions checks the extensions of a rendered MachineConfig against
+// the catalogue shipped in its extensions container, so that an invalid
+// extension fails the render instead of degrading nodes mid-update.
+// Extensions containers without a catalogue are not validated here; the
+// daemon keeps checking them against its default catalogue.
+func (ctrl *Controller) validateExtensions(mc *mcfgv1.MachineConfig, cc *mcfgv1.ControllerConfig) error {
+	if len(mc.Spec.Extensions) == 0 || mc.Spec.BaseOSExtensionsContainerImage == "" {
+		return nil
+	}
+
+	catalogue := ctrl.getExtensionsCatalogue(mc, cc)
+	if catalogue == nil {
+		return nil
+	}
+
+	if err := catalogue.Validate(mc.Spec.Extensions); err != nil {
+		return fmt.Errorf("%w, the extensions available in %s are %v", err, mc.Spec.BaseOSExtensionsContainerImage, catalogue.Names())
+	}
+	return nil
+}
+
+// getExtensionsCatalogue returns the catalogue of the extensions container of
+// a rendered MachineConfig, fetching it the first time the image is seen. A nil
+// catalogue means the image does not ship one. If the catalogue cannot be
+// fetched, e.g. because the registry is not reachable from the controller, the
+// built-in catalogue is used until extensionsCatalogueRetryInterval has passed.
+func (ctrl *Controller) getExtensionsCatalogue(mc *mcfgv1.MachineConfig, cc *mcfgv1.ControllerConfig) ctrlcommon.ExtensionsCatalogue {
+	img := mc.Spec.BaseOSExtensionsContainerImage
+
+	ctrl.extensionsCataloguesMux.Lock()
+	cached, ok := ctrl.extensionsCatalogues[img]
+	ctrl.extensionsCataloguesMux.Unlock()
+	if ok && (cached.expires.IsZero() || time.Now().Before(cached.expires)) {
+		return cached.catalogue
+	}
+
+	// The lock is not held while fetching, so that a slow registry does not
+	// block the other pools. Concurrent fetches of the same image are harmless.
+	catalogue, err := ctrl.fetchExtensionsCatalogueForConfig(mc, cc)
+	cached = cachedExtensionsCatalogue{catalogue: catalogue}
+	if err != nil {
+		klog.Warningf("Could not get extensions catalogue from %s, validating extensions against the built-in catalogue: %v", img, err)
+		cached = cachedExtensionsCatalogue{
+			catalogue: ctrlcommon.DefaultExtensionsCatalogue(),
+			expires:   time.Now().Add(extensionsCatalogueRetryInterval),
+		}
+	}
+
+	// The extensions container is referenced by digest in the release payload,
+	// so its catalogue does not change.
+	ctrl.extensionsCataloguesMux.Lock()
+	ctrl.extensionsCatalogues[img] = cached
+	ctrl.extensionsCataloguesMux.Unlock()
+
+	return cached.catalogue
+}
+
+// fetchExtensionsCatalogueForConfig fetches the catalogue of the extensions
+// container of a rendered MachineConfig with the cluster pull secret, through
+// the registry mirrors configured in the MachineConfig.
+func (ctrl *Controller) fetchExtensionsCatalogueForConfig(mc *mcfgv1.MachineConfig, cc *mcfgv1.ControllerConfig) (ctrlcommon.ExtensionsCatalogue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), extensionsCatalogueFetchTimeout)
+	defer cancel()
+
+	var pullSecret []byte
+	if cc.Spec.PullSecret != nil {
+		secret, err := ctrl.kubeClient.CoreV1().Secrets(cc.Spec.PullSecret.Namespace).Get(ctx, cc.Spec.PullSecret.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("could not get pull secret: %w", err)
+		}
+		pullSecret = secret.Data[corev1.DockerConfigJsonKey]
+	}
+
+	registriesConf, err := getRegistriesConf(mc)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctrl.fetchExtensionsCatalogue(ctx, mc.Spec.BaseOSExtensionsContainerImage, pullSecret, registriesConf)
+}
+
+// getRegistriesConf returns the registries.conf written by a rendered
+// MachineConfig, which holds the mirrors of the ImageDigestMirrorSets,
+// ImageTagMirrorSets and ImageContentSourcePolicies. It returns nil if the
+// MachineConfig does not write one.
+func getRegistriesConf(mc *mcfgv1.MachineConfig) ([]byte, error) {
+	ignConfig, err := ctrlcommon.ParseAndConvertConfig(mc.Spec.Config.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse MachineConfig %s: %w", mc.Name, err)
+	}
+
+	for _, f := range ignConfig.Storage.Files {
+		if f.Path != daemonconsts.ContainerRegistryConfPath {
+			continue
+		}
+		contents, err := ctrlcommon.DecodeIgnitionFileContents(f.Contents.Source, f.Contents.Compression)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode %s: %w", f.Path, err)
+		}
+		return contents, nil
+	}
+
+	return nil, nil
+}
+
+// fetchExtensionsCatalogue reads the catalogue from the layers of an
+// extensions container without pulling the whole image to disk. Only layers
+// fitting into maxExtensionsCatalogueFetchSize are downloaded. It returns nil
+// if the image does not ship a catalogue.
+func fetchExtensionsCatalogue(ctx context.Context, img string, pullSecret, registriesConf []byte) (ctrlcommon.ExtensionsCatalogue, error) {
+	sys := &types.SystemContext{}
+
+	if len(pullSecret) != 0 {
+		authfile, err := writeTempFile("extensions-authfile", pullSecret)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(authfile)
+		sys.AuthFilePath = authfile
+	}
+
+	if len(registriesConf) != 0 {
+		registriesConfFile, err := writeTempFile("extensions-registries.conf", registriesConf)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(registriesConfFile)
+		sys.SystemRegistriesConfPath = registriesConfFile
+	}
+
+	ref, err := docker.ParseReference("//" + img)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing image name %q: %w", img, err)
+	}
+	src, err := ref.NewImageSource(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	parsed, err := image.FromUnparsedImage(ctx, sys, image.UnparsedInstance(src, nil))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing manifest for image %q: %w", img, err)
+	}
+
+	// Look at the topmost layer first, since it has the latest version of the file.
+	budget := int64(maxExtensionsCatalogueFetchSize)
+	layers := parsed.LayerInfos()
+	for i := len(layers) - 1; i >= 0; i-- {
+		if layers[i].Size < 0 || layers[i].Size > budget {
+			return nil, fmt.Errorf("not downloading layer %s of image %q to look for the extensions catalogue, as it is larger than the remaining %d bytes", layers[i].Digest, img, budget)
+		}
+		budget -= layers[i].Size
+
+		data, found, err := readFileFromLayer(ctx, src, layers[i], ctrlcommon.ExtensionsCatalogueFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading layer %s of image %q: %w", layers[i].Digest, img, err)
+		}
+		if found {
+			return ctrlcommon.ParseExtensionsCatalogue(data)
+		}
+	}
+
+	klog.Infof("Extensions container %s has no catalogue, skipping extensions validation", img)
+	return nil, nil
+}
+
+// writeTempFile writes data to a new temporary file and returns its path.
+func writeTempFile(pattern string, data []byte) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// readFileFromLayer returns the contents of the file at path if it is in the layer.
+func readFileFromLayer(ctx context.Context, src types.ImageSource, layer types.BlobInfo, filePath string) ([]byte, bool, error) {
+	blob, _, err := src.GetBlob(ctx, layer, none.NoCache)
+	if err != nil {
+		return nil, false, err
+	}
+	defer blob.Close()
+
+	stream, _, err := compression.AutoDecompress(blob)
+	if err != nil {
+		return nil, false, err
+	}
+	defer stream.Close()
+
+	tr := tar.NewReader(stream)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if strings.TrimPrefix(path.Clean(hdr.Name), "/") != filePath || hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		return data, err == nil, err
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
+	"sync"
 	"time"
 
 	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
@@ -24,9 +26,12 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
 	clientset "k8s.io/client-go/kubernetes"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
@@ -56,6 +61,7 @@ var (
 // Controller defines the render controller.
 type Controller struct {
 	client        mcfgclientset.Interface
+	kubeClient    clientset.Interface
 	eventRecorder record.EventRecorder
 
 	syncHandler              func(mcp string) error
@@ -70,7 +76,16 @@ type Controller struct {
 	ccLister       mcfglistersv1.ControllerConfigLister
 	ccListerSynced cache.InformerSynced
 
+	nodeLister       corelisterv1.NodeLister
+	nodeListerSynced cache.InformerSynced
+
 	queue workqueue.TypedRateLimitingInterface[string]
+
+	// extensionsCatalogues caches the catalogue of each extensions container
+	// by image, see getExtensionsCatalogue
+	extensionsCatalogues     map[string]cachedExtensionsCatalogue
+	extensionsCataloguesMux  sync.Mutex
+	fetchExtensionsCatalogue func(ctx context.Context, image string, pullSecret, registriesConf []byte) (ctrlcommon.ExtensionsCatalogue, error)
 }
 
 // New returns a new render controller.
@@ -78,6 +93,7 @@ func New(
 	mcpInformer mcfginformersv1.MachineConfigPoolInformer,
 	mcInformer mcfginformersv1.MachineConfigInformer,
 	ccInformer mcfginformersv1.ControllerConfigInformer,
+	nodeInformer coreinformersv1.NodeInformer,
 	kubeClient clientset.Interface,
 	mcfgClient mcfgclientset.Interface,
 ) *Controller {
@@ -87,10 +103,13 @@ func New(
 
 	ctrl := &Controller{
 		client:        mcfgClient,
+		kubeClient:    kubeClient,
 		eventRecorder: ctrlcommon.NamespacedEventRecorder(eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "machineconfigcontroller-rendercontroller"})),
 		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
 			workqueue.DefaultTypedControllerRateLimiter[string](),
 			workqueue.TypedRateLimitingQueueConfig[string]{Name: "machineconfigcontroller-rendercontroller"}),
+		extensionsCatalogues:     map[string]cachedExtensionsCatalogue{},
+		fetchExtensionsCatalogue: fetchExtensionsCatalogue,
 	}
 
 	mcpInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -113,6 +132,8 @@ func New(
 	ctrl.mcListerSynced = mcInformer.Informer().HasSynced
 	ctrl.ccLister = ccInformer.Lister()
 	ctrl.ccListerSynced = ccInformer.Informer().HasSynced
+	ctrl.nodeLister = nodeInformer.Lister()
+	ctrl.nodeListerSynced = nodeInformer.Informer().HasSynced
 
 	return ctrl
 }
@@ -122,7 +143,7 @@ func (ctrl *Controller) Run(workers int, stopCh <-chan struct{}) {
 	defer utilruntime.HandleCrash()
 	defer ctrl.queue.ShutDown()
 
-	if !cache.WaitForCacheSync(stopCh, ctrl.mcpListerSynced, ctrl.mcListerSynced, ctrl.ccListerSynced) {
+	if !cache.WaitForCacheSync(stopCh, ctrl.mcpListerSynced, ctrl.mcListerSynced, ctrl.ccListerSynced, ctrl.nodeListerSynced) {
 		return
 	}
 
@@ -431,6 +452,20 @@ func (ctrl *Controller) syncMachineConfigPool(key string) error {
 		return err
 	}
 
+	// The retention policy is applied once the pool was updated, so it is
+	// validated before anything is written for the pool.
+	if _, err := getRenderedConfigRetentionPolicy(pool); err != nil {
+		return ctrl.syncFailingStatus(pool, err)
+	}
+
+	if _, ok := pool.Annotations[ctrlcommon.RollbackToAnnotationKey]; ok {
+		if err := ctrl.syncRollback(pool); err != nil {
+			klog.Errorf("Error rolling back pool %s: %v", pool.Name, err)
+			return ctrl.syncFailingStatus(pool, err)
+		}
+		return nil
+	}
+
 	mcs, err := ctrl.mcLister.List(selector)
 	if err != nil {
 		return err
@@ -449,11 +484,14 @@ func (ctrl *Controller) syncMachineConfigPool(key string) error {
 }
 
 func (ctrl *Controller) syncAvailableStatus(pool *mcfgv1.MachineConfigPool) error {
-	if apihelpers.IsMachineConfigPoolConditionFalse(pool.Status.Conditions, mcfgv1.MachineConfigPoolRenderDegraded) {
+	rollingBack := apihelpers.GetMachineConfigPoolCondition(pool.Status, ctrlcommon.MachineConfigPoolRollingBack) != nil
+	if apihelpers.IsMachineConfigPoolConditionFalse(pool.Status.Conditions, mcfgv1.MachineConfigPoolRenderDegraded) && !rollingBack {
 		return nil
 	}
 	sdegraded := apihelpers.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolRenderDegraded, corev1.ConditionFalse, "", "")
 	apihelpers.SetMachineConfigPoolCondition(&pool.Status, *sdegraded)
+	// The rollback annotation has been removed, so rendering has resumed.
+	apihelpers.RemoveMachineConfigPoolCondition(&pool.Status, ctrlcommon.MachineConfigPoolRollingBack)
 	if _, err := ctrl.client.MachineconfigurationV1().MachineConfigPools().UpdateStatus(context.TODO(), pool, metav1.UpdateOptions{}); err != nil {
 		return err
 	}
@@ -469,14 +507,186 @@ func (ctrl *Controller) syncFailingStatus(pool *mcfgv1.MachineConfigPool, err er
 	return err
 }
 
-// This function will eventually contain a sane garbage collection policy for rendered MachineConfigs;
-// see https://github.com/openshift/machine-config-operator/issues/301
-// It will probably involve making sure we're only GCing a config after all nodes don't have it
-// in either desired or current config.
-func (ctrl *Controller) garbageCollectRenderedConfigs(_ *mcfgv1.MachineConfigPool) error {
-	// Temporarily until https://github.com/openshift/machine-config-operator/pull/318
-	// which depends on the strategy for https://github.com/openshift/machine-config-operator/issues/301
-	return nil
+// syncRollback points the pool at the rendered MachineConfig named by its
+// rollback-to annotation instead of generating a new one. The node controller
+// then moves the nodes back to it like it would for any other update. Only
+// rendered MachineConfigs the pool has completed an update to can be rolled
+// back to.
+func (ctrl *Controller) syncRollback(pool *mcfgv1.MachineConfigPool) error {
+	target := pool.Annotations[ctrlcommon.RollbackToAnnotationKey]
+	history, err := ctrlcommon.GetRenderedConfigHistory(pool)
+	if err != nil {
+		return fmt.Errorf("cannot roll back to %s: %w", target, err)
+	}
+	var entry *ctrlcommon.RenderedConfigHistoryEntry
+	names := []string{}
+	for i := range history {
+		names = append(names, history[i].Name)
+		if history[i].Name == target {
+			entry = &history[i]
+		}
+	}
+	if entry == nil {
+		return fmt.Errorf("cannot roll back to %s: not in the rendered config history of the pool %v", target, names)
+	}
+	if _, err := ctrl.mcLister.Get(target); err != nil {
+		return fmt.Errorf("cannot roll back to %s: %w", target, err)
+	}
+
+	source := []corev1.ObjectReference{}
+	for _, name := range entry.Source {
+		source = append(source, corev1.ObjectReference{Kind: machineconfigKind.Kind, Name: name, APIVersion: machineconfigKind.GroupVersion().String()})
+	}
+	if pool.Spec.Configuration.Name != target || !reflect.DeepEqual(pool.Spec.Configuration.Source, source) {
+		previous := pool.Spec.Configuration.Name
+		newPool := pool.DeepCopy()
+		newPool.Spec.Configuration.Name = target
+		newPool.Spec.Configuration.Source = source
+		updated, err := ctrl.client.MachineconfigurationV1().MachineConfigPools().Update(context.TODO(), newPool, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		pool = updated
+		if previous != target {
+			klog.Infof("Pool %s: rolling back from %s to %s", pool.Name, previous, target)
+			ctrl.eventRecorder.Eventf(pool, corev1.EventTypeNormal, "RollbackStarted", "Rolling back from %s to %s", previous, target)
+		}
+	}
+
+	newStatus := pool.Status.DeepCopy()
+	srollback := apihelpers.NewMachineConfigPoolCondition(ctrlcommon.MachineConfigPoolRollingBack, corev1.ConditionTrue, "RollbackRequested",
+		fmt.Sprintf("Rolled back to %s; new rendered MachineConfigs will not be generated until the %s annotation is removed", target, ctrlcommon.RollbackToAnnotationKey))
+	apihelpers.SetMachineConfigPoolCondition(newStatus, *srollback)
+	sdegraded := apihelpers.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolRenderDegraded, corev1.ConditionFalse, "", "")
+	apihelpers.SetMachineConfigPoolCondition(newStatus, *sdegraded)
+	if reflect.DeepEqual(&pool.Status, newStatus) {
+		return nil
+	}
+	pool.Status = *newStatus
+	_, err = ctrl.client.MachineconfigurationV1().MachineConfigPools().UpdateStatus(context.TODO(), pool, metav1.UpdateOptions{})
+	return err
+}
+
+// renderedConfigRetentionPolicy describes which unused rendered MachineConfigs
+// of a pool are kept around. An unused rendered MachineConfig is only deleted
+// once it is both outside of the newest count configs and older than maxAge.
+type renderedConfigRetentionPolicy struct {
+	count  int
+	maxAge time.Duration
+}
+
+// getRenderedConfigRetentionPolicy reads the retention policy from the pool
+// annotations. It returns nil if the pool does not opt into garbage collection.
+func getRenderedConfigRetentionPolicy(pool *mcfgv1.MachineConfigPool) (*renderedConfigRetentionPolicy, error) {
+	countVal, hasCount := pool.Annotations[ctrlcommon.RenderedConfigRetentionCountAnnotationKey]
+	ageVal, hasAge := pool.Annotations[ctrlcommon.RenderedConfigRetentionAgeAnnotationKey]
+	if !hasCount && !hasAge {
+		return nil, nil
+	}
+
+	policy := &renderedConfigRetentionPolicy{}
+	if hasCount {
+		count, err := strconv.Atoi(countVal)
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("invalid %s annotation %q: must be a non-negative integer", ctrlcommon.RenderedConfigRetentionCountAnnotationKey, countVal)
+		}
+		policy.count = count
+	}
+	if hasAge {
+		age, err := time.ParseDuration(ageVal)
+		if err != nil || age < 0 {
+			return nil, fmt.Errorf("invalid %s annotation %q: must be a non-negative duration", ctrlcommon.RenderedConfigRetentionAgeAnnotationKey, ageVal)
+		}
+		policy.maxAge = age
+	}
+	return policy, nil
+}
+
+// getRenderedConfigsInUse returns the names of the rendered MachineConfigs
+// which must never be garbage collected: the pool's current and desired
+// configs and the configs in its history which it can be rolled back to,
+// plus any config a node is currently on or is moving to.
+func (ctrl *Controller) getRenderedConfigsInUse(pool *mcfgv1.MachineConfigPool) (sets.Set[string], error) {
+	inUse := sets.New[string](pool.Spec.Configuration.Name, pool.Status.Configuration.Name)
+	history, err := ctrlcommon.GetRenderedConfigHistory(pool)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range history {
+		inUse.Insert(entry.Name)
+	}
+
+	// Nodes are checked regardless of pool membership so that a node which
+	// just moved between pools does not lose the config it is still on.
+	nodes, err := ctrl.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		for _, key := range []string{daemonconsts.CurrentMachineConfigAnnotationKey, daemonconsts.DesiredMachineConfigAnnotationKey} {
+			if name, ok := node.Annotations[key]; ok && name != "" {
+				inUse.Insert(name)
+			}
+		}
+	}
+	return inUse, nil
+}
+
+// garbageCollectRenderedConfigs deletes the rendered MachineConfigs owned by
+// the pool that are no longer in use, according to the retention policy set on
+// the pool. Pools without a retention policy keep all of their rendered
+// configs. See https://github.com/openshift/machine-config-operator/issues/301
+func (ctrl *Controller) garbageCollectRenderedConfigs(pool *mcfgv1.MachineConfigPool) error {
+	policy, err := getRenderedConfigRetentionPolicy(pool)
+	if err != nil {
+		return err
+	}
+	if policy == nil {
+		return nil
+	}
+
+	inUse, err := ctrl.getRenderedConfigsInUse(pool)
+	if err != nil {
+		return fmt.Errorf("could not determine rendered MachineConfigs in use for pool %s: %w", pool.Name, err)
+	}
+
+	mcs, err := ctrl.mcLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	unused := []*mcfgv1.MachineConfig{}
+	for _, mc := range mcs {
+		controllerRef := metav1.GetControllerOf(mc)
+		if controllerRef == nil || controllerRef.Kind != controllerKind.Kind || controllerRef.UID != pool.UID {
+			continue
+		}
+		if mc.DeletionTimestamp != nil || inUse.Has(mc.Name) {
+			continue
+		}
+		unused = append(unused, mc)
+	}
+
+	// Newest first, so that the configs within the retention count are the most recent ones.
+	sort.SliceStable(unused, func(i, j int) bool {
+		return unused[j].CreationTimestamp.Before(&unused[i].CreationTimestamp)
+	})
+
+	var errs []error
+	for i, mc := range unused {
+		if i < policy.count || time.Since(mc.CreationTimestamp.Time) < policy.maxAge {
+			continue
+		}
+		if err := ctrl.client.MachineconfigurationV1().MachineConfigs().Delete(context.TODO(), mc.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("could not delete rendered MachineConfig %s: %w", mc.Name, err))
+			continue
+		}
+		klog.V(2).Infof("Pool %s: garbage collected rendered MachineConfig %s", pool.Name, mc.Name)
+		ctrl.eventRecorder.Eventf(pool, corev1.EventTypeNormal, "RenderedConfigGarbageCollected", "Deleted unused rendered MachineConfig %s", mc.Name)
+		ctrlcommon.MCCRenderedConfigsGarbageCollected.WithLabelValues(pool.Name).Inc()
+	}
+
+	return goerrs.Join(errs...)
 }
 
 func (ctrl *Controller) getRenderedMachineConfig(pool *mcfgv1.MachineConfigPool, configs []*mcfgv1.MachineConfig, cc *mcfgv1.ControllerConfig) (*mcfgv1.MachineConfig, error) {
@@ -521,6 +731,10 @@ func (ctrl *Controller) syncGeneratedMachineConfig(pool *mcfgv1.MachineConfigPoo
 		return fmt.Errorf("could not generate rendered MachineConfig: %w", err)
 	}
 
+	if err := ctrl.validateExtensions(generated, cc); err != nil {
+		return err
+	}
+
 	// Emit event and collect metric when OSImageURL was overridden.
 	if generated.Spec.OSImageURL != ctrlcommon.GetDefaultBaseImageContainer(&cc.Spec) {
 		ctrlcommon.OSImageURLOverride.WithLabelValues(pool.Name).Set(1)
@@ -554,8 +768,11 @@ func (ctrl *Controller) syncGeneratedMachineConfig(pool *mcfgv1.MachineConfigPoo
 		if err != nil {
 			return err
 		}
-		_, err = ctrl.client.MachineconfigurationV1().MachineConfigPools().Update(context.TODO(), newPool, metav1.UpdateOptions{})
-		return err
+		pool, err = ctrl.client.MachineconfigurationV1().MachineConfigPools().Update(context.TODO(), newPool, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		return ctrl.garbageCollectRenderedConfigs(pool)
 	}
 
 	newPool.Spec.Configuration.Name = generated.Name
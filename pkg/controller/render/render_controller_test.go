@@ -1,6 +1,7 @@
 package render
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"testing"
@@ -19,6 +20,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/diff"
 	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	kubeinformers "k8s.io/client-go/informers"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 	core "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
@@ -27,6 +29,7 @@ import (
 	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
 	"github.com/openshift/client-go/machineconfiguration/clientset/versioned/fake"
 	informers "github.com/openshift/client-go/machineconfiguration/informers/externalversions"
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
 	"github.com/openshift/machine-config-operator/pkg/version"
@@ -43,9 +46,10 @@ type fixture struct {
 
 	client *fake.Clientset
 
-	mcpLister []*mcfgv1.MachineConfigPool
-	mcLister  []*mcfgv1.MachineConfig
-	ccLister  []*mcfgv1.ControllerConfig
+	mcpLister  []*mcfgv1.MachineConfigPool
+	mcLister   []*mcfgv1.MachineConfig
+	ccLister   []*mcfgv1.ControllerConfig
+	nodeLister []*corev1.Node
 
 	actions []core.Action
 
@@ -62,20 +66,26 @@ func newFixture(t *testing.T) *fixture {
 func (f *fixture) newController() *Controller {
 	f.client = fake.NewSimpleClientset(f.objects...)
 
+	kubeclient := k8sfake.NewSimpleClientset()
+
 	i := informers.NewSharedInformerFactory(f.client, noResyncPeriodFunc())
+	k8sI := kubeinformers.NewSharedInformerFactory(kubeclient, noResyncPeriodFunc())
 
 	c := New(i.Machineconfiguration().V1().MachineConfigPools(), i.Machineconfiguration().V1().MachineConfigs(),
-		i.Machineconfiguration().V1().ControllerConfigs(), k8sfake.NewSimpleClientset(), f.client)
+		i.Machineconfiguration().V1().ControllerConfigs(), k8sI.Core().V1().Nodes(), kubeclient, f.client)
 
 	c.mcpListerSynced = alwaysReady
 	c.mcListerSynced = alwaysReady
 	c.ccListerSynced = alwaysReady
+	c.nodeListerSynced = alwaysReady
 	c.eventRecorder = ctrlcommon.NamespacedEventRecorder(&record.FakeRecorder{})
 
 	stopCh := make(chan struct{})
 	defer close(stopCh)
 	i.Start(stopCh)
 	i.WaitForCacheSync(stopCh)
+	k8sI.Start(stopCh)
+	k8sI.WaitForCacheSync(stopCh)
 
 	for _, c := range f.ccLister {
 		i.Machineconfiguration().V1().ControllerConfigs().Informer().GetIndexer().Add(c)
@@ -90,6 +100,9 @@ func (f *fixture) newController() *Controller {
 	for _, m := range f.ccLister {
 		i.Machineconfiguration().V1().ControllerConfigs().Informer().GetIndexer().Add(m)
 	}
+	for _, n := range f.nodeLister {
+		k8sI.Core().V1().Nodes().Informer().GetIndexer().Add(n)
+	}
 
 	return c
 }
@@ -571,3 +584,308 @@ func TestGenerateMachineConfigValidation(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, gmc)
 }
+
+func TestGarbageCollectRenderedConfigs(t *testing.T) {
+	newRenderedConfig := func(name string, pool *mcfgv1.MachineConfigPool, age time.Duration) *mcfgv1.MachineConfig {
+		mc := helpers.NewMachineConfig(name, nil, "", []ign3types.File{})
+		mc.CreationTimestamp = metav1.NewTime(time.Now().Add(-age))
+		mc.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(pool, controllerKind)})
+		return mc
+	}
+
+	newNode := func(name, current, desired string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Annotations: map[string]string{
+					daemonconsts.CurrentMachineConfigAnnotationKey: current,
+					daemonconsts.DesiredMachineConfigAnnotationKey: desired,
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    []string
+		errExpected bool
+	}{
+		{
+			name:     "No retention policy",
+			expected: []string{},
+		},
+		{
+			name: "Retention count only",
+			annotations: map[string]string{
+				ctrlcommon.RenderedConfigRetentionCountAnnotationKey: "1",
+			},
+			expected: []string{"rendered-old-2", "rendered-old-3"},
+		},
+		{
+			name: "Retention age only",
+			annotations: map[string]string{
+				ctrlcommon.RenderedConfigRetentionAgeAnnotationKey: "48h",
+			},
+			expected: []string{"rendered-old-3"},
+		},
+		{
+			name: "Retention count and age",
+			annotations: map[string]string{
+				ctrlcommon.RenderedConfigRetentionCountAnnotationKey: "0",
+				ctrlcommon.RenderedConfigRetentionAgeAnnotationKey:   "12h",
+			},
+			expected: []string{"rendered-old-1", "rendered-old-2", "rendered-old-3"},
+		},
+		{
+			name: "Invalid retention count",
+			annotations: map[string]string{
+				ctrlcommon.RenderedConfigRetentionCountAnnotationKey: "-1",
+			},
+			errExpected: true,
+		},
+		{
+			name: "Invalid retention age",
+			annotations: map[string]string{
+				ctrlcommon.RenderedConfigRetentionAgeAnnotationKey: "a week",
+			},
+			errExpected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newFixture(t)
+
+			mcp := helpers.NewMachineConfigPool("worker", helpers.WorkerSelector, nil, "rendered-current")
+			mcp.Spec.Configuration.Name = "rendered-desired"
+			mcp.Annotations = testCase.annotations
+			ctrlcommon.AddToRenderedConfigHistory(mcp, newPoolConfiguration("rendered-in-history"), ctrlcommon.DefaultRenderedConfigHistoryLimit)
+			otherPool := helpers.NewMachineConfigPool("infra", helpers.InfraSelector, nil, "rendered-infra-current")
+
+			mcs := []*mcfgv1.MachineConfig{
+				newRenderedConfig("rendered-desired", mcp, 0),
+				newRenderedConfig("rendered-current", mcp, 100*time.Hour),
+				newRenderedConfig("rendered-on-node", mcp, 200*time.Hour),
+				newRenderedConfig("rendered-desired-by-node", mcp, 200*time.Hour),
+				newRenderedConfig("rendered-in-history", mcp, 300*time.Hour),
+				newRenderedConfig("rendered-old-1", mcp, 24*time.Hour),
+				newRenderedConfig("rendered-old-2", mcp, 36*time.Hour),
+				newRenderedConfig("rendered-old-3", mcp, 72*time.Hour),
+				newRenderedConfig("rendered-infra-old", otherPool, 300*time.Hour),
+				helpers.NewMachineConfig("00-worker", map[string]string{"node-role/worker": ""}, "", []ign3types.File{}),
+			}
+
+			f.mcpLister = append(f.mcpLister, mcp, otherPool)
+			f.mcLister = append(f.mcLister, mcs...)
+			f.nodeLister = append(f.nodeLister,
+				newNode("node-0", "rendered-current", "rendered-current"),
+				newNode("node-1", "rendered-on-node", "rendered-desired-by-node"),
+			)
+
+			c := f.newController()
+			err := c.garbageCollectRenderedConfigs(mcp)
+			if testCase.errExpected {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			deleted := []string{}
+			for _, action := range filterInformerActions(f.client.Actions()) {
+				if deleteAction, ok := action.(core.DeleteAction); ok {
+					deleted = append(deleted, deleteAction.GetName())
+				}
+			}
+			assert.ElementsMatch(t, testCase.expected, deleted)
+		})
+	}
+}
+
+func TestInvalidRetentionPolicyDoesNotUpdatePool(t *testing.T) {
+	f := newFixture(t)
+	mcp := helpers.NewMachineConfigPool("test-cluster-master", helpers.MasterSelector, nil, "")
+	mcp.Annotations = map[string]string{
+		ctrlcommon.RenderedConfigRetentionCountAnnotationKey: "-1",
+	}
+	mcs := []*mcfgv1.MachineConfig{
+		helpers.NewMachineConfig("00-test-cluster-master", map[string]string{"node-role/master": ""}, "dummy://", []ign3types.File{}),
+	}
+
+	f.ccLister = append(f.ccLister, newControllerConfig(ctrlcommon.ControllerConfigName))
+	f.mcpLister = append(f.mcpLister, mcp)
+	f.objects = append(f.objects, mcp)
+	f.mcLister = append(f.mcLister, mcs...)
+
+	c := f.newController()
+	assert.Error(t, c.syncHandler(getKey(mcp, t)))
+
+	// Only the failing status is reported; no rendered config is generated and
+	// the pool is not retargeted.
+	actions := filterInformerActions(f.client.Actions())
+	require.Len(t, actions, 1)
+	assert.True(t, actions[0].Matches("update", "machineconfigpools"))
+	assert.Equal(t, "status", actions[0].GetSubresource())
+}
+
+// newPoolConfiguration returns the configuration of a pool targeting the
+// rendered MachineConfig name, generated from the MachineConfigs sources.
+func newPoolConfiguration(name string, sources ...string) mcfgv1.MachineConfigPoolStatusConfiguration {
+	config := mcfgv1.MachineConfigPoolStatusConfiguration{ObjectReference: corev1.ObjectReference{Name: name}}
+	for _, source := range sources {
+		config.Source = append(config.Source, corev1.ObjectReference{Kind: machineconfigKind.Kind, Name: source, APIVersion: machineconfigKind.GroupVersion().String()})
+	}
+	return config
+}
+
+func TestSyncRollback(t *testing.T) {
+	testCases := []struct {
+		name           string
+		target         string
+		expectedSource []corev1.ObjectReference
+		errExpected    bool
+	}{
+		{
+			name:           "Roll back to a config in the history",
+			target:         "rendered-good",
+			expectedSource: newPoolConfiguration("", "00-worker").Source,
+		},
+		{
+			name:           "Already rolled back",
+			target:         "rendered-bad",
+			expectedSource: newPoolConfiguration("", "00-worker", "99-bad").Source,
+		},
+		{
+			name:        "Config not in the history",
+			target:      "rendered-unknown",
+			errExpected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newFixture(t)
+
+			mcp := helpers.NewMachineConfigPool("worker", helpers.WorkerSelector, nil, "rendered-bad")
+			mcp.Annotations = map[string]string{ctrlcommon.RollbackToAnnotationKey: testCase.target}
+			mcp.Spec.Configuration = newPoolConfiguration("rendered-bad", "00-worker", "99-bad")
+			ctrlcommon.AddToRenderedConfigHistory(mcp, newPoolConfiguration("rendered-good", "00-worker"), ctrlcommon.DefaultRenderedConfigHistoryLimit)
+			ctrlcommon.AddToRenderedConfigHistory(mcp, mcp.Spec.Configuration, ctrlcommon.DefaultRenderedConfigHistoryLimit)
+			history := mcp.Annotations[ctrlcommon.RenderedConfigHistoryAnnotationKey]
+
+			f.mcpLister = append(f.mcpLister, mcp)
+			f.objects = append(f.objects, mcp)
+			f.mcLister = append(f.mcLister,
+				helpers.NewMachineConfig("rendered-good", nil, "", []ign3types.File{}),
+				helpers.NewMachineConfig("rendered-bad", nil, "", []ign3types.File{}),
+			)
+
+			c := f.newController()
+			err := c.syncRollback(mcp)
+			if testCase.errExpected {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			pool, err := f.client.MachineconfigurationV1().MachineConfigPools().Get(context.TODO(), mcp.Name, metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, testCase.target, pool.Spec.Configuration.Name)
+			assert.Equal(t, testCase.expectedSource, pool.Spec.Configuration.Source)
+			assert.True(t, apihelpers.IsMachineConfigPoolConditionTrue(pool.Status.Conditions, ctrlcommon.MachineConfigPoolRollingBack))
+			// Rolling back does not change the history.
+			assert.Equal(t, history, pool.Annotations[ctrlcommon.RenderedConfigHistoryAnnotationKey])
+
+			// Rendering resumes once the annotation is removed.
+			pool.Annotations = nil
+			require.NoError(t, c.syncAvailableStatus(pool))
+			pool, err = f.client.MachineconfigurationV1().MachineConfigPools().Get(context.TODO(), mcp.Name, metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Nil(t, apihelpers.GetMachineConfigPoolCondition(pool.Status, ctrlcommon.MachineConfigPoolRollingBack))
+		})
+	}
+}
+
+func TestValidateExtensions(t *testing.T) {
+	catalogue := ctrlcommon.ExtensionsCatalogue{"usbguard": {"usbguard"}, "sysstat": {"sysstat"}}
+	cc := &mcfgv1.ControllerConfig{}
+
+	testCases := []struct {
+		name        string
+		extensions  []string
+		catalogue   ctrlcommon.ExtensionsCatalogue
+		fetchErr    error
+		errExpected bool
+	}{
+		{
+			name:       "Extensions in the catalogue",
+			extensions: []string{"usbguard", "sysstat"},
+			catalogue:  catalogue,
+		},
+		{
+			name:        "Extension missing from the catalogue",
+			extensions:  []string{"usbguard", "kerberos"},
+			catalogue:   catalogue,
+			errExpected: true,
+		},
+		{
+			name:       "Extensions container without a catalogue",
+			extensions: []string{"kerberos"},
+		},
+		{
+			name:       "Catalogue which cannot be fetched falls back to the built-in catalogue",
+			extensions: []string{"usbguard", "kerberos"},
+			fetchErr:   fmt.Errorf("registry unreachable"),
+		},
+		{
+			name:        "Extension missing from the built-in catalogue",
+			extensions:  []string{"usbguard", "not-an-extension"},
+			fetchErr:    fmt.Errorf("registry unreachable"),
+			errExpected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			f := newFixture(t)
+			c := f.newController()
+
+			registriesConf := "[[registry]]\nlocation = \"registry.example.com\"\n"
+
+			fetches := 0
+			c.fetchExtensionsCatalogue = func(_ context.Context, image string, _, conf []byte) (ctrlcommon.ExtensionsCatalogue, error) {
+				fetches++
+				assert.Equal(t, "registry.example.com/extensions@sha256:abc", image)
+				// The mirrors configured for the pool are used for the fetch.
+				assert.Equal(t, registriesConf, string(conf))
+				return testCase.catalogue, testCase.fetchErr
+			}
+
+			mc := helpers.NewMachineConfig("rendered-worker", nil, "", []ign3types.File{
+				helpers.CreateEncodedIgn3File(daemonconsts.ContainerRegistryConfPath, registriesConf, 420),
+			})
+			mc.Spec.BaseOSExtensionsContainerImage = "registry.example.com/extensions@sha256:abc"
+			mc.Spec.Extensions = testCase.extensions
+
+			for i := 0; i < 2; i++ {
+				err := c.validateExtensions(mc, cc)
+				if testCase.errExpected {
+					assert.ErrorContains(t, err, "invalid extensions found: ["+testCase.extensions[1]+"]")
+				} else {
+					assert.NoError(t, err)
+				}
+			}
+			// The catalogue of an image is only fetched once.
+			assert.Equal(t, 1, fetches)
+
+			// Nothing is fetched for a config without extensions.
+			mc.Spec.Extensions = nil
+			mc.Spec.BaseOSExtensionsContainerImage = "registry.example.com/other-extensions@sha256:def"
+			assert.NoError(t, c.validateExtensions(mc, cc))
+			assert.Equal(t, 1, fetches)
+		})
+	}
+}
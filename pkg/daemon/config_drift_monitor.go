@@ -21,16 +21,51 @@ type configDriftErr struct {
 	error
 }
 
+func (e *configDriftErr) Unwrap() error { return e.error }
+
 // Error type for file config drifts
 type fileConfigDriftErr struct {
 	error
 }
 
+func (e *fileConfigDriftErr) Unwrap() error { return e.error }
+
 // Error type for systemd unit config drifts
 type unitConfigDriftErr struct {
 	error
 }
 
+func (e *unitConfigDriftErr) Unwrap() error { return e.error }
+
+// Error type for directory config drifts
+type directoryConfigDriftErr struct {
+	error
+}
+
+func (e *directoryConfigDriftErr) Unwrap() error { return e.error }
+
+// Error type for link config drifts
+type linkConfigDriftErr struct {
+	error
+}
+
+func (e *linkConfigDriftErr) Unwrap() error { return e.error }
+
+// Error type for file and directory ownership drifts. Wrapped by
+// fileConfigDriftErr and directoryConfigDriftErr.
+type ownershipConfigDriftErr struct {
+	error
+}
+
+func (e *ownershipConfigDriftErr) Unwrap() error { return e.error }
+
+// Error type for systemd unit enablement drifts. Wrapped by unitConfigDriftErr.
+type unitEnablementConfigDriftErr struct {
+	error
+}
+
+func (e *unitEnablementConfigDriftErr) Unwrap() error { return e.error }
+
 type ConfigDriftMonitor interface {
 	Start(ConfigDriftMonitorOpts) error
 	Done() <-chan struct{}
@@ -48,6 +83,9 @@ type ConfigDriftMonitorOpts struct {
 	SystemdPath string
 	// Channel to report unknown errors
 	ErrChan chan<- error
+	// Returns the paths which are not checked for config drift. Called on
+	// every event so that changes to the exclusions apply immediately.
+	Exclusions func() configDriftExclusions
 }
 
 // Holds the Config Drift Watcher and ensures we only have a single instance
@@ -281,7 +319,17 @@ func (c *configDriftWatcher) checkMachineConfigForEvent(event fsnotify.Event) er
 		return nil
 	}
 
-	if err := validateOnDiskState(c.MachineConfig, c.SystemdPath); err != nil {
+	var exclusions configDriftExclusions
+	if c.Exclusions != nil {
+		exclusions = c.Exclusions()
+	}
+
+	// Ignore events for files which are excluded from config drift checks.
+	if exclusions.excludes(event.Name) {
+		return nil
+	}
+
+	if err := validateOnDiskState(c.MachineConfig, c.SystemdPath, exclusions); err != nil {
 		return &configDriftErr{err}
 	}
 
@@ -318,6 +366,18 @@ func getFilePathsFromIgn3Config(ignConfig ign3types.Config, systemdPath string)
 		}
 	}
 
+	// Get all the directory and link paths from the ignition config
+	for _, dir := range ignConfig.Storage.Directories {
+		if _, err := os.Lstat(dir.Path); err == nil {
+			files.Insert(dir.Path)
+		}
+	}
+	for _, link := range ignConfig.Storage.Links {
+		if _, err := os.Lstat(link.Path); err == nil {
+			files.Insert(link.Path)
+		}
+	}
+
 	// Get all the file paths for systemd dropins from the ignition config
 	for _, unit := range ignConfig.Systemd.Units {
 		unitPath := getIgn3SystemdUnitPath(systemdPath, unit)
@@ -325,6 +385,16 @@ func getFilePathsFromIgn3Config(ignConfig ign3types.Config, systemdPath string)
 			files.Insert(unitPath)
 		}
 
+		// Watch the enablement symlinks of the unit, including missing ones
+		// whose .wants or .requires directory exists.
+		if unit.Enabled != nil {
+			for _, link := range getUnitEnablementLinks(unit, systemdPath) {
+				if _, err := os.Stat(filepath.Dir(link)); err == nil {
+					files.Insert(link)
+				}
+			}
+		}
+
 		for _, dropin := range unit.Dropins {
 			dropinPath := getIgn3SystemdDropinPath(systemdPath, unit, dropin)
 			if _, err := os.Stat(dropinPath); err == nil && !os.IsNotExist(err) {
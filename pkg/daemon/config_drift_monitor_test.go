@@ -99,6 +99,17 @@ func TestConfigDriftMonitor(t *testing.T) {
 			expectedErr: fileErr,
 			mutateFile:  chmodFile,
 		},
+		{
+			name:       "ign file content drift excluded",
+			exclusions: []string{"/etc/a-config-*"},
+			mutateFile: changeFileContent,
+		},
+		{
+			name:        "ign file content drift with unrelated exclusion",
+			expectedErr: fileErr,
+			exclusions:  []string{"/etc/a-compressed-*"},
+			mutateFile:  changeFileContent,
+		},
 		// Compressed Ignition File
 		// These target the file called /etc/a-compressed-file defined by the test
 		// fixture.
@@ -245,6 +256,8 @@ type configDriftMonitorTestCase struct {
 	mutateUnit func(string) error
 	// The mutation to apply to the systemd dropin file
 	mutateDropin func(string) error
+	// Config drift exclusions, relative to the tmpdir
+	exclusions []string
 	// Mutex to ensure that parallel tests do not stomp on one another
 	testMutex *sync.Mutex
 }
@@ -311,6 +324,13 @@ func (tc configDriftMonitorTestCase) run(t *testing.T) {
 			onDriftCalled = true
 			tc.onDriftFunc(t, err)
 		},
+		Exclusions: func() configDriftExclusions {
+			exclusions := configDriftExclusions{}
+			for _, pattern := range tc.exclusions {
+				exclusions = append(exclusions, filepath.Join(tc.tmpDir, pattern))
+			}
+			return exclusions
+		},
 	}
 
 	// Start the config drift monitor
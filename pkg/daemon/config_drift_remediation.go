@@ -0,0 +1,344 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	features "github.com/openshift/api/features"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/daemon/disruption"
+)
+
+const (
+	// configDriftRemediationWindow is the period over which repeated remediations of
+	// the same path are counted.
+	configDriftRemediationWindow = 10 * time.Minute
+	// configDriftRemediationLimit is the number of times a path may be remediated
+	// within configDriftRemediationWindow before the node is degraded instead.
+	configDriftRemediationLimit = 3
+	// configDriftRemediationInterval is the minimum time between two remediations.
+	configDriftRemediationInterval = 10 * time.Second
+)
+
+// configDriftRemediator remembers when paths were remediated, so that something
+// that keeps rewriting a file degrades the node instead of fighting the MCD forever.
+// It also holds the MachineConfigs whose drift is queued for remediation.
+type configDriftRemediator struct {
+	window time.Duration
+	limit  int
+
+	mu      sync.Mutex
+	history map[string][]time.Time
+	// pending holds the MachineConfigs queued for remediation, by name.
+	pending map[string]*mcfgv1.MachineConfig
+	// remediating is set while drifted files and units are rewritten, so that
+	// the file events caused by the remediation are not taken for new drift.
+	remediating bool
+}
+
+func newConfigDriftRemediator() *configDriftRemediator {
+	return &configDriftRemediator{
+		window:  configDriftRemediationWindow,
+		limit:   configDriftRemediationLimit,
+		history: map[string][]time.Time{},
+		pending: map[string]*mcfgv1.MachineConfig{},
+	}
+}
+
+// newConfigDriftQueue returns the queue of config drift remediations. Drift
+// reported while a remediation is queued is coalesced into it, and remediations
+// are spaced out so that a burst of file events cannot turn into a burst of
+// service restarts.
+func newConfigDriftQueue() workqueue.TypedRateLimitingInterface[string] {
+	return workqueue.NewTypedRateLimitingQueueWithConfig[string](
+		&workqueue.TypedBucketRateLimiter[string]{Limiter: rate.NewLimiter(rate.Every(configDriftRemediationInterval), 1)},
+		workqueue.TypedRateLimitingQueueConfig[string]{Name: "machineconfigdaemon-configdrift"})
+}
+
+// setPending remembers mc as queued for remediation and returns its queue key.
+func (r *configDriftRemediator) setPending(mc *mcfgv1.MachineConfig) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending[mc.Name] = mc
+	return mc.Name
+}
+
+// takePending returns the MachineConfig queued for remediation under key and
+// forgets it.
+func (r *configDriftRemediator) takePending(key string) (*mcfgv1.MachineConfig, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	mc, ok := r.pending[key]
+	delete(r.pending, key)
+	return mc, ok
+}
+
+func (r *configDriftRemediator) setRemediating(remediating bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remediating = remediating
+}
+
+// isRemediating reports whether drifted files and units are being rewritten.
+func (r *configDriftRemediator) isRemediating() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.remediating
+}
+
+// record notes a remediation of each of paths at now. It returns an error
+// without recording anything if any of them was already remediated too often
+// within the window.
+func (r *configDriftRemediator) record(paths []string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for path, times := range r.history {
+		recent := times[:0]
+		for _, t := range times {
+			if now.Sub(t) < r.window {
+				recent = append(recent, t)
+			}
+		}
+		if len(recent) == 0 {
+			delete(r.history, path)
+		} else {
+			r.history[path] = recent
+		}
+	}
+
+	for _, path := range paths {
+		if len(r.history[path]) >= r.limit {
+			return fmt.Errorf("%s drifted %d times within %s", path, len(r.history[path]), r.window)
+		}
+	}
+
+	for _, path := range paths {
+		r.history[path] = append(r.history[path], now)
+	}
+
+	return nil
+}
+
+// getConfigDriftPolicy returns the config drift policy the node controller set on the node.
+func (dn *Daemon) getConfigDriftPolicy() string {
+	if dn.node != nil && dn.node.Annotations[constants.ConfigDriftPolicyAnnotationKey] == constants.ConfigDriftPolicyRemediate {
+		return constants.ConfigDriftPolicyRemediate
+	}
+	return constants.ConfigDriftPolicyDegrade
+}
+
+// remediateConfigDrift rewrites the files and units of mc that drifted on disk and
+// runs the service actions needed to pick them up. Drift that could only be applied
+// with a drain or a reboot is not remediated.
+func (dn *Daemon) remediateConfigDrift(mc *mcfgv1.MachineConfig) error {
+	ignConfig, err := ctrlcommon.ParseAndConvertConfig(mc.Spec.Config.Raw)
+	if err != nil {
+		return fmt.Errorf("could not parse MachineConfig %s: %w", mc.Name, err)
+	}
+
+	exclusions := dn.getConfigDriftExclusions()
+	driftedFiles, driftedUnits := getDriftedFilesAndUnits(ignConfig, pathSystemd, exclusions)
+	if len(driftedFiles) == 0 && len(driftedUnits) == 0 {
+		return fmt.Errorf("could not find the drifted files or units of MachineConfig %s", mc.Name)
+	}
+
+	if err := dn.configDriftRemediator.record(append(append([]string{}, driftedFiles...), driftedUnits...), time.Now()); err != nil {
+		return fmt.Errorf("not remediating config drift: %w", err)
+	}
+
+	runActions, err := dn.getConfigDriftRemediationActions(driftedFiles, driftedUnits)
+	if err != nil {
+		return err
+	}
+
+	files := []ign3types.File{}
+	for _, f := range ignConfig.Storage.Files {
+		if ctrlcommon.InSlice(f.Path, driftedFiles) {
+			files = append(files, f)
+		}
+	}
+	units := []ign3types.Unit{}
+	for _, u := range ignConfig.Systemd.Units {
+		if ctrlcommon.InSlice(u.Name, driftedUnits) {
+			units = append(units, u)
+		}
+	}
+
+	logSystem("Remediating config drift of files %v and units %v from MachineConfig %s", driftedFiles, driftedUnits, mc.Name)
+
+	dn.configDriftRemediator.setRemediating(true)
+	defer dn.configDriftRemediator.setRemediating(false)
+
+	if err := dn.writeFiles(files, true); err != nil {
+		return fmt.Errorf("could not rewrite drifted files: %w", err)
+	}
+	if err := dn.writeUnits(units); err != nil {
+		return fmt.Errorf("could not rewrite drifted units: %w", err)
+	}
+	if err := runActions(); err != nil {
+		return err
+	}
+
+	if err := validateOnDiskState(mc, pathSystemd, exclusions); err != nil {
+		return fmt.Errorf("on-disk state still differs from MachineConfig %s after remediation: %w", mc.Name, err)
+	}
+
+	if dn.nodeWriter != nil {
+		dn.nodeWriter.Eventf(corev1.EventTypeNormal, "ConfigDriftRemediated",
+			"Config drift of files %v and units %v was remediated from MachineConfig %s", driftedFiles, driftedUnits, mc.Name)
+	}
+	logSystem("Config drift remediated")
+	return nil
+}
+
+func (dn *Daemon) configDriftWorker() {
+	for dn.processNextConfigDriftWorkItem() {
+	}
+}
+
+func (dn *Daemon) processNextConfigDriftWorkItem() bool {
+	key, quit := dn.configDriftQueue.Get()
+	if quit {
+		return false
+	}
+	defer dn.configDriftQueue.Done(key)
+
+	dn.syncConfigDriftRemediation(key)
+
+	return true
+}
+
+// syncConfigDriftRemediation remediates the drift of the MachineConfig queued
+// under key. The node is degraded if the drift cannot be remediated.
+func (dn *Daemon) syncConfigDriftRemediation(key string) {
+	mc, ok := dn.configDriftRemediator.takePending(key)
+	if !ok {
+		return
+	}
+
+	// The drift may have been undone while the remediation was queued.
+	driftErr := validateOnDiskState(mc, pathSystemd, dn.getConfigDriftExclusions())
+	if driftErr == nil {
+		klog.Infof("Config drift of MachineConfig %s was undone before it was remediated", mc.Name)
+		mcdConfigDrift.Set(0)
+		dn.recordConfigDrift(nil)
+		return
+	}
+
+	remediationErr := dn.remediateConfigDrift(mc)
+	if remediationErr == nil {
+		mcdConfigDriftRemediations.WithLabelValues("success").Inc()
+		mcdConfigDrift.Set(0)
+		dn.recordConfigDrift(nil)
+		return
+	}
+
+	mcdConfigDriftRemediations.WithLabelValues("failure").Inc()
+	dn.nodeWriter.Eventf(corev1.EventTypeWarning, "ConfigDriftRemediationFailed", remediationErr.Error())
+	klog.Errorf("Could not remediate config drift: %v", remediationErr)
+
+	if err := dn.updateErrorState(fmt.Errorf("%w; remediation failed: %v", driftErr, remediationErr)); err != nil {
+		klog.Errorf("Could not update annotation: %v", err)
+	}
+}
+
+// getConfigDriftRemediationActions works out what has to be done for rewritten
+// files and units to take effect, the same way an update would, and returns a
+// function doing it. It returns an error if that requires a drain or a reboot.
+func (dn *Daemon) getConfigDriftRemediationActions(driftedFiles, driftedUnits []string) (func() error, error) {
+	var fg featuregates.FeatureGate
+	if dn.featureGatesAccessor != nil {
+		var err error
+		fg, err = dn.featureGatesAccessor.CurrentFeatureGates()
+		if err != nil {
+			return nil, fmt.Errorf("could not get feature gates: %w", err)
+		}
+	}
+
+	if fg != nil && fg.Enabled(features.FeatureGateNodeDisruptionPolicy) {
+		clusterPolicies, err := dn.getNodeDisruptionPolicyClusterStatus()
+		if err != nil {
+			return nil, err
+		}
+		actions := disruption.CalculatePostConfigChangeNodeDisruptionActionFromMCDiffs(false, driftedFiles, driftedUnits, *clusterPolicies)
+		for _, action := range actions {
+			if action.Type == opv1.RebootStatusAction || action.Type == opv1.DrainStatusAction {
+				return nil, fmt.Errorf("not remediating config drift: applying it requires a %s", action.Type)
+			}
+		}
+		return func() error { return runConfigDriftNodeDisruptionActions(actions, driftedFiles) }, nil
+	}
+
+	// Without node disruption policies any unit change requires a reboot.
+	if len(driftedUnits) > 0 {
+		return nil, fmt.Errorf("not remediating config drift: applying changes to units %v requires a reboot", driftedUnits)
+	}
+	actions := disruption.CalculatePostConfigChangeActionFromMCDiffs(driftedFiles)
+	if ctrlcommon.InSlice(disruption.PostConfigChangeActionReboot, actions) {
+		return nil, fmt.Errorf("not remediating config drift: applying changes to files %v requires a reboot", driftedFiles)
+	}
+	return func() error { return runConfigDriftActions(actions) }, nil
+}
+
+// runConfigDriftNodeDisruptionActions runs the service actions of a node disruption policy.
+func runConfigDriftNodeDisruptionActions(actions []opv1.NodeDisruptionPolicyStatusAction, driftedFiles []string) error {
+	for _, action := range actions {
+		var err error
+		switch action.Type {
+		case opv1.RestartStatusAction:
+			err = restartService(string(action.Restart.ServiceName))
+		case opv1.ReloadStatusAction:
+			err = reloadService(string(action.Reload.ServiceName))
+		case opv1.SpecialStatusAction:
+			err = reloadService(constants.CRIOServiceName)
+		case opv1.DaemonReloadStatusAction:
+			err = reloadDaemon()
+		case disruption.ApplySysctlStatusAction, disruption.LoadModulesStatusAction, disruption.UdevReloadStatusAction:
+			err = builtinNodeDisruptionActions[action.Type](driftedFiles)
+		}
+		if err != nil {
+			return fmt.Errorf("could not apply %s action: %w", action.Type, err)
+		}
+	}
+	return nil
+}
+
+// runConfigDriftActions runs the service actions of the legacy post config change actions.
+func runConfigDriftActions(actions []string) error {
+	if ctrlcommon.InSlice(disruption.PostConfigChangeActionReloadCrio, actions) {
+		if err := reloadService(constants.CRIOServiceName); err != nil {
+			return fmt.Errorf("could not reload %s: %w", constants.CRIOServiceName, err)
+		}
+	}
+
+	if ctrlcommon.InSlice(disruption.PostConfigChangeActionRestartCrio, actions) {
+		cmd := exec.Command(constants.UpdateCATrustCommand)
+		var stderr bytes.Buffer
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("error running %s: %s: %w", constants.UpdateCATrustCommand, stderr.String(), err)
+		}
+		if err := restartService(constants.CRIOServiceName); err != nil {
+			return fmt.Errorf("could not restart %s: %w", constants.CRIOServiceName, err)
+		}
+	}
+
+	klog.V(4).Infof("Ran config drift remediation actions %v", actions)
+	return nil
+}
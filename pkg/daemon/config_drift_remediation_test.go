@@ -0,0 +1,138 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestConfigDriftRemediator(t *testing.T) {
+	now := time.Now()
+	r := newConfigDriftRemediator()
+
+	for i := 0; i < configDriftRemediationLimit; i++ {
+		require.NoError(t, r.record([]string{"/etc/a"}, now.Add(time.Duration(i)*time.Second)))
+	}
+
+	// Once the limit is reached, the remediation is refused and nothing is
+	// recorded for the other paths either.
+	assert.Error(t, r.record([]string{"/etc/b", "/etc/a"}, now.Add(time.Minute)))
+	assert.Empty(t, r.history["/etc/b"])
+
+	// Other paths are counted independently.
+	assert.NoError(t, r.record([]string{"/etc/b"}, now.Add(time.Minute)))
+
+	// Remediations older than the window are forgotten.
+	later := now.Add(configDriftRemediationWindow + 30*time.Second)
+	assert.NoError(t, r.record([]string{"/etc/a"}, later))
+	assert.Len(t, r.history["/etc/a"], 1)
+	assert.Len(t, r.history["/etc/b"], 1)
+}
+
+func TestConfigDriftQueue(t *testing.T) {
+	tmpDir := t.TempDir()
+	file := setDefaultUIDandGID(helpers.CreateEncodedIgn3File(filepath.Join(tmpDir, "file"), "contents", int(defaultFilePermissions)))
+	require.NoError(t, os.WriteFile(file.Path, []byte("other contents"), defaultFilePermissions))
+	mc := helpers.NewMachineConfig("rendered-worker-1", nil, "", []ign3types.File{file})
+
+	dn := &Daemon{
+		node: &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{constants.ConfigDriftPolicyAnnotationKey: constants.ConfigDriftPolicyRemediate},
+		}},
+		configDriftRemediator: newConfigDriftRemediator(),
+		configDriftQueue:      newConfigDriftQueue(),
+	}
+	defer dn.configDriftQueue.ShutDown()
+
+	// Drift caused by the remediation's own writes is ignored.
+	dn.configDriftRemediator.setRemediating(true)
+	dn.onConfigDrift(mc, &configDriftErr{assert.AnError})
+	assert.Equal(t, 0, dn.configDriftQueue.Len())
+	assert.Empty(t, dn.configDriftRemediator.pending)
+	dn.configDriftRemediator.setRemediating(false)
+
+	// Repeated drift of the same MachineConfig is coalesced into a single
+	// remediation.
+	key := dn.configDriftRemediator.setPending(mc)
+	dn.configDriftQueue.Add(key)
+	dn.configDriftQueue.Add(dn.configDriftRemediator.setPending(mc))
+	assert.Equal(t, 1, dn.configDriftQueue.Len())
+
+	// Drift which was undone while the remediation was queued needs no
+	// remediation.
+	require.NoError(t, os.WriteFile(file.Path, []byte("contents"), defaultFilePermissions))
+	require.True(t, dn.processNextConfigDriftWorkItem())
+	assert.Equal(t, 0, dn.configDriftQueue.Len())
+	assert.Empty(t, dn.configDriftRemediator.pending)
+	assert.Empty(t, dn.configDriftRemediator.history)
+}
+
+func TestGetDriftedFilesAndUnits(t *testing.T) {
+	tmpDir := t.TempDir()
+	systemdPath := filepath.Join(tmpDir, "systemd")
+
+	intact := setDefaultUIDandGID(helpers.CreateEncodedIgn3File(filepath.Join(tmpDir, "intact"), "contents", int(defaultFilePermissions)))
+	drifted := setDefaultUIDandGID(helpers.CreateEncodedIgn3File(filepath.Join(tmpDir, "drifted"), "contents", int(defaultFilePermissions)))
+	missing := setDefaultUIDandGID(helpers.CreateEncodedIgn3File(filepath.Join(tmpDir, "missing"), "contents", int(defaultFilePermissions)))
+
+	intactUnit := ign3types.Unit{Name: "intact.service", Contents: helpers.StrToPtr("[Unit]")}
+	driftedUnit := ign3types.Unit{Name: "drifted.service", Contents: helpers.StrToPtr("[Unit]")}
+
+	writeFile := func(path, contents string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), defaultFilePermissions))
+		require.NoError(t, os.Chmod(path, defaultFilePermissions))
+	}
+
+	writeFile(intact.Path, "contents")
+	writeFile(drifted.Path, "other contents")
+	writeFile(getIgn3SystemdUnitPath(systemdPath, intactUnit), "[Unit]")
+	writeFile(getIgn3SystemdUnitPath(systemdPath, driftedUnit), "[Unit]\nDescription=drifted")
+
+	ignConfig := ign3types.Config{
+		Storage: ign3types.Storage{Files: []ign3types.File{intact, drifted, missing}},
+		Systemd: ign3types.Systemd{Units: []ign3types.Unit{intactUnit, driftedUnit}},
+	}
+
+	files, units := getDriftedFilesAndUnits(ignConfig, systemdPath, nil)
+	assert.Equal(t, []string{drifted.Path, missing.Path}, files)
+	assert.Equal(t, []string{driftedUnit.Name}, units)
+}
+
+func TestGetConfigDriftPolicy(t *testing.T) {
+	testCases := []struct {
+		annotations map[string]string
+		expected    string
+	}{
+		{
+			expected: constants.ConfigDriftPolicyDegrade,
+		},
+		{
+			annotations: map[string]string{constants.ConfigDriftPolicyAnnotationKey: constants.ConfigDriftPolicyRemediate},
+			expected:    constants.ConfigDriftPolicyRemediate,
+		},
+		{
+			annotations: map[string]string{constants.ConfigDriftPolicyAnnotationKey: constants.ConfigDriftPolicyDegrade},
+			expected:    constants.ConfigDriftPolicyDegrade,
+		},
+		{
+			annotations: map[string]string{constants.ConfigDriftPolicyAnnotationKey: "Ignore"},
+			expected:    constants.ConfigDriftPolicyDegrade,
+		},
+	}
+
+	for _, testCase := range testCases {
+		dn := &Daemon{node: &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: testCase.annotations}}}
+		assert.Equal(t, testCase.expected, dn.getConfigDriftPolicy())
+	}
+}
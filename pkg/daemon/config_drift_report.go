@@ -0,0 +1,386 @@
+package daemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	ign2types "github.com/coreos/ignition/config/v2_2/types"
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/upgrademonitor"
+)
+
+const (
+	// maxConfigDriftReportEntries bounds the number of drifted paths listed in
+	// the report stored in the ConfigDrift condition of the MachineConfigNode.
+	maxConfigDriftReportEntries = 25
+	// maxConfigDriftMessageSize bounds the size of the report stored in the
+	// condition message, well below the 32768 characters the API allows.
+	maxConfigDriftMessageSize = 16 * 1024
+)
+
+// configDriftExclusions holds glob patterns, as understood by filepath.Match,
+// of the paths which are not checked for config drift.
+type configDriftExclusions []string
+
+// parseConfigDriftExclusions parses a comma separated list of glob patterns.
+// Malformed patterns are left out of the returned exclusions and reported in
+// the returned error.
+func parseConfigDriftExclusions(patterns string) (configDriftExclusions, error) {
+	exclusions := configDriftExclusions{}
+	errs := []error{}
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("malformed config drift exclusion %q: %w", pattern, err))
+			continue
+		}
+		exclusions = append(exclusions, pattern)
+	}
+	return exclusions, errors.Join(errs...)
+}
+
+// excludes reports whether path matches any of the exclusions.
+func (e configDriftExclusions) excludes(path string) bool {
+	for _, pattern := range e {
+		if matched, _ := filepath.Match(pattern, path); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// filterIgn3Config returns a copy of ignConfig without the files, directories,
+// links, units and dropins whose paths are excluded.
+func (e configDriftExclusions) filterIgn3Config(ignConfig ign3types.Config, systemdPath string) ign3types.Config {
+	if len(e) == 0 {
+		return ignConfig
+	}
+
+	files := []ign3types.File{}
+	for _, f := range ignConfig.Storage.Files {
+		if e.excludes(f.Path) {
+			klog.V(4).Infof("Skipping excluded file %s during config drift checks", f.Path)
+			continue
+		}
+		files = append(files, f)
+	}
+	ignConfig.Storage.Files = files
+
+	dirs := []ign3types.Directory{}
+	for _, d := range ignConfig.Storage.Directories {
+		if !e.excludes(d.Path) {
+			dirs = append(dirs, d)
+		}
+	}
+	ignConfig.Storage.Directories = dirs
+
+	links := []ign3types.Link{}
+	for _, l := range ignConfig.Storage.Links {
+		if !e.excludes(l.Path) {
+			links = append(links, l)
+		}
+	}
+	ignConfig.Storage.Links = links
+
+	units := []ign3types.Unit{}
+	for _, u := range ignConfig.Systemd.Units {
+		dropins := []ign3types.Dropin{}
+		for _, d := range u.Dropins {
+			if !e.excludes(getIgn3SystemdDropinPath(systemdPath, u, d)) {
+				dropins = append(dropins, d)
+			}
+		}
+		u.Dropins = dropins
+		if e.excludes(getIgn3SystemdUnitPath(systemdPath, u)) {
+			klog.V(4).Infof("Skipping excluded unit %s during config drift checks", u.Name)
+			u.Contents = nil
+		}
+		units = append(units, u)
+	}
+	ignConfig.Systemd.Units = units
+
+	return ignConfig
+}
+
+// filterIgn2Config returns a copy of ignConfig without the files, units and
+// dropins whose paths are excluded.
+func (e configDriftExclusions) filterIgn2Config(ignConfig ign2types.Config, systemdPath string) ign2types.Config {
+	if len(e) == 0 {
+		return ignConfig
+	}
+
+	files := []ign2types.File{}
+	for _, f := range ignConfig.Storage.Files {
+		if !e.excludes(f.Path) {
+			files = append(files, f)
+		}
+	}
+	ignConfig.Storage.Files = files
+
+	units := []ign2types.Unit{}
+	for _, u := range ignConfig.Systemd.Units {
+		dropins := []ign2types.SystemdDropin{}
+		for _, d := range u.Dropins {
+			if !e.excludes(getIgn2SystemdDropinPath(systemdPath, u, d)) {
+				dropins = append(dropins, d)
+			}
+		}
+		u.Dropins = dropins
+		if e.excludes(getIgn2SystemdUnitPath(systemdPath, u)) {
+			u.Contents = ""
+		}
+		units = append(units, u)
+	}
+	ignConfig.Systemd.Units = units
+
+	return ignConfig
+}
+
+// getConfigDriftExclusions returns the config drift exclusions the node controller set on the node.
+// Malformed exclusions are ignored and reported in an event on the node.
+func (dn *Daemon) getConfigDriftExclusions() configDriftExclusions {
+	if dn.node == nil {
+		return nil
+	}
+
+	annotation := dn.node.Annotations[constants.ConfigDriftExclusionsAnnotationKey]
+	exclusions, err := parseConfigDriftExclusions(annotation)
+	if err != nil {
+		dn.reportInvalidConfigDriftExclusions(annotation, err)
+	}
+	return exclusions
+}
+
+// reportInvalidConfigDriftExclusions logs err and emits it in an event on the
+// node, once for each value of the exclusions annotation, as the exclusions
+// are read on every file event.
+func (dn *Daemon) reportInvalidConfigDriftExclusions(annotation string, err error) {
+	dn.configDriftExclusionsMu.Lock()
+	defer dn.configDriftExclusionsMu.Unlock()
+
+	if dn.invalidConfigDriftExclusions == annotation {
+		return
+	}
+	dn.invalidConfigDriftExclusions = annotation
+
+	klog.Warningf("Ignoring invalid config drift exclusions: %v", err)
+	if dn.nodeWriter != nil {
+		dn.nodeWriter.Eventf(corev1.EventTypeWarning, "InvalidConfigDriftExclusions",
+			"Ignoring invalid patterns in %s: %v", constants.ConfigDriftExclusionsAnnotationKey, err)
+	}
+}
+
+// configDriftReport describes how the on-disk state differs from a MachineConfig.
+type configDriftReport struct {
+	MachineConfig string                   `json:"machineConfig"`
+	DetectedTime  metav1.Time              `json:"detectedTime"`
+	Entries       []configDriftReportEntry `json:"entries"`
+	// Truncated is the number of drifted paths left out of Entries.
+	Truncated int `json:"truncated,omitempty"`
+}
+
+// configDriftReportEntry describes a single drifted file, unit or dropin.
+type configDriftReportEntry struct {
+	Path           string `json:"path"`
+	Unit           string `json:"unit,omitempty"`
+	ExpectedSHA256 string `json:"expectedSHA256,omitempty"`
+	ActualSHA256   string `json:"actualSHA256,omitempty"`
+	ExpectedMode   string `json:"expectedMode,omitempty"`
+	ActualMode     string `json:"actualMode,omitempty"`
+	ExpectedOwner  string `json:"expectedOwner,omitempty"`
+	ActualOwner    string `json:"actualOwner,omitempty"`
+	Error          string `json:"error"`
+}
+
+// newConfigDriftReport checks every file, directory, link, unit and dropin of
+// mc that is not excluded and reports the ones which have drifted.
+func newConfigDriftReport(mc *mcfgv1.MachineConfig, systemdPath string, exclusions configDriftExclusions, now time.Time) (*configDriftReport, error) {
+	ignConfig, err := ctrlcommon.ParseAndConvertConfig(mc.Spec.Config.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse MachineConfig %s: %w", mc.Name, err)
+	}
+	ignConfig = exclusions.filterIgn3Config(ignConfig, getSystemdPath(systemdPath))
+
+	report := &configDriftReport{
+		MachineConfig: mc.Name,
+		DetectedTime:  metav1.NewTime(now),
+		Entries:       []configDriftReportEntry{},
+	}
+	add := func(entry configDriftReportEntry) {
+		if len(report.Entries) >= maxConfigDriftReportEntries {
+			report.Truncated++
+			return
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+
+	for _, f := range ignConfig.Storage.Files {
+		err := checkV3Files([]ign3types.File{f})
+		if err == nil {
+			continue
+		}
+		mode := defaultFilePermissions
+		if f.Mode != nil {
+			mode = os.FileMode(*f.Mode)
+		}
+		entry := newConfigDriftReportEntry(f.Path, nil, mode, err)
+		if contents, err := ctrlcommon.DecodeIgnitionFileContents(f.Contents.Source, f.Contents.Compression); err == nil {
+			entry.ExpectedSHA256 = sha256Hex(contents)
+		}
+		if uid, gid, err := getFileOwnership(f); err == nil {
+			entry.ExpectedOwner = fmt.Sprintf("%d:%d", uid, gid)
+		}
+		add(entry)
+	}
+
+	for _, d := range ignConfig.Storage.Directories {
+		err := checkV3Directories([]ign3types.Directory{d})
+		if err == nil {
+			continue
+		}
+		mode := os.FileMode(0o755)
+		if d.Mode != nil {
+			mode = os.FileMode(*d.Mode)
+		}
+		entry := newConfigDriftReportEntry(d.Path, nil, mode, err)
+		if uid, gid, err := getNodeOwnership(d.Node); err == nil {
+			entry.ExpectedOwner = fmt.Sprintf("%d:%d", uid, gid)
+		}
+		add(entry)
+	}
+
+	for _, l := range ignConfig.Storage.Links {
+		if err := checkV3Links([]ign3types.Link{l}); err != nil {
+			add(configDriftReportEntry{Path: l.Path, Error: err.Error()})
+		}
+	}
+
+	for _, u := range ignConfig.Systemd.Units {
+		for _, d := range u.Dropins {
+			if err := checkV3Dropin(systemdPath, u, d); err != nil {
+				entry := newConfigDriftReportEntry(getIgn3SystemdDropinPath(systemdPath, u, d), []byte(stringOrEmpty(d.Contents)), defaultFilePermissions, err)
+				entry.Unit = u.Name
+				add(entry)
+			}
+		}
+
+		unit := u
+		unit.Dropins = nil
+		if err := checkV3Unit(unit, systemdPath); err != nil {
+			var expected []byte
+			if unit.Mask == nil || !*unit.Mask {
+				expected = []byte(stringOrEmpty(unit.Contents))
+			}
+			entry := newConfigDriftReportEntry(getIgn3SystemdUnitPath(systemdPath, unit), expected, defaultFilePermissions, err)
+			entry.Unit = unit.Name
+			add(entry)
+		}
+	}
+
+	return report, nil
+}
+
+// newConfigDriftReportEntry describes the drift of path, comparing it against
+// the expected contents and mode where given.
+func newConfigDriftReportEntry(path string, expected []byte, mode os.FileMode, err error) configDriftReportEntry {
+	entry := configDriftReportEntry{
+		Path:         path,
+		ExpectedMode: fmt.Sprintf("%#o", mode.Perm()),
+		Error:        err.Error(),
+	}
+	if expected != nil {
+		entry.ExpectedSHA256 = sha256Hex(expected)
+	}
+
+	fi, statErr := os.Lstat(path)
+	if statErr != nil {
+		return entry
+	}
+	entry.ActualMode = fmt.Sprintf("%#o", fi.Mode().Perm())
+	if fi.Mode()&os.ModeType != 0 {
+		entry.ActualMode = fi.Mode().String()
+	}
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
+		entry.ActualOwner = fmt.Sprintf("%d:%d", stat.Uid, stat.Gid)
+	}
+	if fi.Mode().IsRegular() {
+		if contents, err := os.ReadFile(path); err == nil {
+			entry.ActualSHA256 = sha256Hex(contents)
+		}
+	}
+	return entry
+}
+
+// String returns the report in the JSON form stored in the ConfigDrift
+// condition of the MachineConfigNode. Entries are left out, and counted as
+// truncated, until the report fits into maxConfigDriftMessageSize.
+func (r *configDriftReport) String() string {
+	bounded := *r
+	for {
+		out, err := json.Marshal(bounded)
+		if err != nil {
+			return fmt.Sprintf("could not encode config drift report: %v", err)
+		}
+		if len(out) <= maxConfigDriftMessageSize || len(bounded.Entries) == 0 {
+			return string(out)
+		}
+		bounded.Entries = bounded.Entries[:len(bounded.Entries)-1]
+		bounded.Truncated++
+	}
+}
+
+// recordConfigDrift sets the ConfigDrift condition on the node's
+// MachineConfigNode, with the report as its message. A nil report clears it.
+func (dn *Daemon) recordConfigDrift(report *configDriftReport) {
+	status := metav1.ConditionFalse
+	reason := "NoConfigDrift"
+	message := "The on-disk state matches the current MachineConfig"
+	if report != nil {
+		status = metav1.ConditionTrue
+		reason = "ConfigDriftDetected"
+		message = report.String()
+	}
+
+	err := upgrademonitor.GenerateAndApplyMachineConfigNodes(
+		&upgrademonitor.Condition{State: upgrademonitor.ConfigDrift, Reason: reason, Message: message},
+		nil,
+		status,
+		metav1.ConditionFalse,
+		dn.node,
+		dn.mcfgClient,
+		dn.featureGatesAccessor,
+	)
+	if err != nil {
+		klog.Errorf("Error making MCN for Config Drift: %v", err)
+	}
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,236 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	apicfgv1 "github.com/openshift/api/config/v1"
+	features "github.com/openshift/api/features"
+	"github.com/openshift/client-go/machineconfiguration/clientset/versioned/fake"
+	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/upgrademonitor"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+// fakeNodeWriter records the annotations and events written for a node.
+type fakeNodeWriter struct {
+	NodeWriter
+	annotations map[string]string
+	events      []string
+}
+
+func (f *fakeNodeWriter) SetAnnotations(annos map[string]string) (*corev1.Node, error) {
+	for k, v := range annos {
+		f.annotations[k] = v
+	}
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: f.annotations}}, nil
+}
+
+func (f *fakeNodeWriter) Eventf(_, reason, _ string, _ ...interface{}) {
+	f.events = append(f.events, reason)
+}
+
+func TestParseConfigDriftExclusions(t *testing.T) {
+	exclusions, err := parseConfigDriftExclusions(" /etc/agent/*.conf, ,/etc/[bad,/etc/motd")
+	assert.Equal(t, configDriftExclusions{"/etc/agent/*.conf", "/etc/motd"}, exclusions)
+	assert.ErrorContains(t, err, `malformed config drift exclusion "/etc/[bad"`)
+
+	assert.True(t, exclusions.excludes("/etc/agent/a.conf"))
+	assert.True(t, exclusions.excludes("/etc/motd"))
+	assert.False(t, exclusions.excludes("/etc/agent/sub/a.conf"))
+	assert.False(t, exclusions.excludes("/etc/hosts"))
+
+	exclusions, err = parseConfigDriftExclusions("")
+	assert.NoError(t, err)
+	assert.Empty(t, exclusions)
+}
+
+func TestGetConfigDriftExclusionsReportsMalformedPatterns(t *testing.T) {
+	nw := &fakeNodeWriter{annotations: map[string]string{}}
+	dn := &Daemon{
+		node: &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{constants.ConfigDriftExclusionsAnnotationKey: "/etc/[bad,/etc/motd"},
+		}},
+		nodeWriter: nw,
+	}
+
+	assert.Equal(t, configDriftExclusions{"/etc/motd"}, dn.getConfigDriftExclusions())
+	assert.Equal(t, configDriftExclusions{"/etc/motd"}, dn.getConfigDriftExclusions())
+	assert.Equal(t, []string{"InvalidConfigDriftExclusions"}, nw.events)
+
+	// Changed exclusions are reported again.
+	dn.node.Annotations[constants.ConfigDriftExclusionsAnnotationKey] = "/etc/[other"
+	assert.Empty(t, dn.getConfigDriftExclusions())
+	assert.Len(t, nw.events, 2)
+
+	dn.node.Annotations[constants.ConfigDriftExclusionsAnnotationKey] = "/etc/motd"
+	assert.Equal(t, configDriftExclusions{"/etc/motd"}, dn.getConfigDriftExclusions())
+	assert.Len(t, nw.events, 2)
+}
+
+func TestRecordConfigDrift(t *testing.T) {
+	fgAccess := featuregates.NewHardcodedFeatureGateAccess(
+		[]apicfgv1.FeatureGateName{features.FeatureGateMachineConfigNodes},
+		[]apicfgv1.FeatureGateName{features.FeatureGatePinnedImages},
+	)
+	dn := &Daemon{
+		node:                 &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-0"}},
+		mcfgClient:           fake.NewSimpleClientset(),
+		featureGatesAccessor: fgAccess,
+	}
+
+	report := &configDriftReport{
+		MachineConfig: "rendered-worker-1",
+		Entries: []configDriftReportEntry{
+			{Path: "/etc/motd", ExpectedSHA256: "abc", ActualSHA256: "def", Error: "content mismatch"},
+			{Path: "/etc/systemd/system/foo.service", Unit: "foo.service", Error: "unit missing"},
+		},
+		Truncated: 2,
+	}
+
+	// The full report is stored in the ConfigDrift condition of the MachineConfigNode.
+	dn.recordConfigDrift(report)
+	mcn, err := dn.mcfgClient.MachineconfigurationV1alpha1().MachineConfigNodes().Get(context.TODO(), "node-0", metav1.GetOptions{})
+	require.NoError(t, err)
+	cond := meta.FindStatusCondition(mcn.Status.Conditions, string(upgrademonitor.ConfigDrift))
+	require.NotNil(t, cond)
+	assert.Equal(t, metav1.ConditionTrue, cond.Status)
+
+	decoded := &configDriftReport{}
+	require.NoError(t, json.Unmarshal([]byte(cond.Message), decoded))
+	assert.Equal(t, "rendered-worker-1", decoded.MachineConfig)
+	assert.Equal(t, report.Entries, decoded.Entries)
+	assert.Equal(t, 2, decoded.Truncated)
+}
+
+func TestConfigDriftReportMessageSize(t *testing.T) {
+	report := &configDriftReport{MachineConfig: "rendered-worker-1"}
+	for i := 0; i < maxConfigDriftReportEntries; i++ {
+		report.Entries = append(report.Entries, configDriftReportEntry{
+			Path:  fmt.Sprintf("/etc/file-%d", i),
+			Error: strings.Repeat("x", 1024),
+		})
+	}
+
+	message := report.String()
+	assert.LessOrEqual(t, len(message), maxConfigDriftMessageSize)
+
+	decoded := &configDriftReport{}
+	require.NoError(t, json.Unmarshal([]byte(message), decoded))
+	assert.NotEmpty(t, decoded.Entries)
+	assert.Equal(t, report.Entries[:len(decoded.Entries)], decoded.Entries)
+	assert.Equal(t, maxConfigDriftReportEntries, len(decoded.Entries)+decoded.Truncated)
+	// The report itself is left untouched.
+	assert.Len(t, report.Entries, maxConfigDriftReportEntries)
+}
+
+func TestConfigDriftReportAndExclusions(t *testing.T) {
+	tmpDir := t.TempDir()
+	systemdPath := filepath.Join(tmpDir, "systemd")
+
+	intact := setDefaultUIDandGID(helpers.CreateEncodedIgn3File(filepath.Join(tmpDir, "intact"), "contents", int(defaultFilePermissions)))
+	drifted := setDefaultUIDandGID(helpers.CreateEncodedIgn3File(filepath.Join(tmpDir, "drifted"), "contents", int(defaultFilePermissions)))
+	agent := setDefaultUIDandGID(helpers.CreateEncodedIgn3File(filepath.Join(tmpDir, "agent.conf"), "contents", int(defaultFilePermissions)))
+	unit := ign3types.Unit{
+		Name:     "drifted.service",
+		Contents: helpers.StrToPtr("[Unit]"),
+		Dropins:  []ign3types.Dropin{{Name: "10-intact.conf", Contents: helpers.StrToPtr("[Service]")}},
+	}
+
+	writeFile := func(path, contents string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), defaultFilePermissions))
+		require.NoError(t, os.Chmod(path, defaultFilePermissions))
+	}
+
+	writeFile(intact.Path, "contents")
+	writeFile(drifted.Path, "contents")
+	require.NoError(t, os.Chmod(drifted.Path, 0o600))
+	writeFile(agent.Path, "changed by the agent")
+	writeFile(getIgn3SystemdUnitPath(systemdPath, unit), "[Unit]\nDescription=drifted")
+	writeFile(getIgn3SystemdDropinPath(systemdPath, unit, unit.Dropins[0]), "[Service]")
+
+	ignConfig := ign3types.Config{
+		Ignition: ign3types.Ignition{Version: ign3types.MaxVersion.String()},
+		Storage:  ign3types.Storage{Files: []ign3types.File{intact, drifted, agent}},
+		Systemd:  ign3types.Systemd{Units: []ign3types.Unit{unit}},
+	}
+	mc := helpers.CreateMachineConfigFromIgnition(ignConfig)
+	mc.Name = "rendered-worker-1"
+
+	exclusions := configDriftExclusions{filepath.Join(tmpDir, "*.conf")}
+
+	// The exclusions hide the agent's changes, but not the others.
+	assert.Error(t, validateOnDiskState(mc, systemdPath, nil))
+	assert.Error(t, validateOnDiskState(mc, systemdPath, exclusions))
+	files, units := getDriftedFilesAndUnits(ignConfig, systemdPath, exclusions)
+	assert.Equal(t, []string{drifted.Path}, files)
+	assert.Equal(t, []string{unit.Name}, units)
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	report, err := newConfigDriftReport(mc, systemdPath, exclusions, now)
+	require.NoError(t, err)
+	assert.Equal(t, "rendered-worker-1", report.MachineConfig)
+	assert.Equal(t, now, report.DetectedTime.Time)
+	require.Len(t, report.Entries, 2)
+
+	fileEntry := report.Entries[0]
+	assert.Equal(t, drifted.Path, fileEntry.Path)
+	assert.Equal(t, sha256Hex([]byte("contents")), fileEntry.ExpectedSHA256)
+	assert.Equal(t, fileEntry.ExpectedSHA256, fileEntry.ActualSHA256)
+	assert.Equal(t, "0644", fileEntry.ExpectedMode)
+	assert.Equal(t, "0600", fileEntry.ActualMode)
+	assert.NotEmpty(t, fileEntry.ActualOwner)
+	assert.Contains(t, fileEntry.Error, "mode mismatch")
+
+	unitEntry := report.Entries[1]
+	assert.Equal(t, getIgn3SystemdUnitPath(systemdPath, unit), unitEntry.Path)
+	assert.Equal(t, unit.Name, unitEntry.Unit)
+	assert.Equal(t, sha256Hex([]byte("[Unit]")), unitEntry.ExpectedSHA256)
+	assert.Equal(t, sha256Hex([]byte("[Unit]\nDescription=drifted")), unitEntry.ActualSHA256)
+
+	// The report round trips through the JSON persisted in the node annotations.
+	decoded := &configDriftReport{}
+	require.NoError(t, json.Unmarshal([]byte(report.String()), decoded))
+	assert.Equal(t, report.Entries, decoded.Entries)
+
+	// Once the remaining paths are excluded as well, there is no drift left.
+	exclusions = append(exclusions, drifted.Path, filepath.Join(systemdPath, "*.service"))
+	assert.NoError(t, validateOnDiskState(mc, systemdPath, exclusions))
+	report, err = newConfigDriftReport(mc, systemdPath, exclusions, now)
+	require.NoError(t, err)
+	assert.Empty(t, report.Entries)
+}
+
+func TestConfigDriftReportTruncation(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := []ign3types.File{}
+	for i := 0; i < maxConfigDriftReportEntries+5; i++ {
+		files = append(files, setDefaultUIDandGID(helpers.CreateEncodedIgn3File(filepath.Join(tmpDir, "missing", string(rune('a'+i))), "contents", int(defaultFilePermissions))))
+	}
+	mc := helpers.CreateMachineConfigFromIgnition(ign3types.Config{
+		Ignition: ign3types.Ignition{Version: ign3types.MaxVersion.String()},
+		Storage:  ign3types.Storage{Files: files},
+	})
+
+	report, err := newConfigDriftReport(mc, filepath.Join(tmpDir, "systemd"), nil, time.Now())
+	require.NoError(t, err)
+	assert.Len(t, report.Entries, maxConfigDriftReportEntries)
+	assert.Equal(t, 5, report.Truncated)
+	assert.Empty(t, report.Entries[0].ActualSHA256)
+}
@@ -54,6 +54,30 @@ const (
 	MachineConfigDaemonReasonAnnotationKey = "machineconfiguration.openshift.io/reason"
 	// MachineConfigDaemonPostConfigAction is set by the daemon when it needs to report a human readable post config action that takes place during update.
 	MachineConfigDaemonPostConfigAction = "machineconfiguration.openshift.io/post-config-action"
+	// DryRunAnnotationKey is set to "true" on a node to have the daemon record the plan for an update
+	// in the node's MachineConfigNode status and an event instead of applying it.
+	DryRunAnnotationKey = "machineconfiguration.openshift.io/dry-run"
+	// LastDryRunConfigAnnotationKey is set by the daemon to the name of the last MachineConfig it recorded a dry run plan for.
+	LastDryRunConfigAnnotationKey = "machineconfiguration.openshift.io/lastDryRunConfig"
+	// ConfigDriftPolicyAnnotationKey is set on a MachineConfigPool to choose how the daemon handles config drift
+	// on the pool's nodes. The node controller copies it onto the nodes of the pool, where the daemon reads it.
+	ConfigDriftPolicyAnnotationKey = "machineconfiguration.openshift.io/config-drift-policy"
+	// ConfigDriftPolicyDegrade degrades the node on config drift. This is the default.
+	ConfigDriftPolicyDegrade = "Degrade"
+	// ConfigDriftPolicyRemediate rewrites drifted files and units from the current MachineConfig, degrading
+	// the node only if that fails or keeps being necessary.
+	ConfigDriftPolicyRemediate = "Remediate"
+	// ConfigDriftExclusionsAnnotationKey is set on a MachineConfigPool to a comma separated list of glob patterns of
+	// paths the daemon does not check for config drift. The node controller copies it onto the nodes of the pool.
+	ConfigDriftExclusionsAnnotationKey = "machineconfiguration.openshift.io/config-drift-exclusions"
+	// BuiltinNodeDisruptionActionsAnnotationKey is set to "true" on the cluster MachineConfiguration to add the
+	// default node disruption policies applying changes to /etc/sysctl.d, /etc/modules-load.d and /etc/udev/rules.d
+	// with the built-in actions of the daemon instead of rebooting.
+	BuiltinNodeDisruptionActionsAnnotationKey = "machineconfiguration.openshift.io/builtin-node-disruption-actions"
+	// LiveKernelArgumentsAnnotationKey is set on a MachineConfigPool to a comma separated list of kernel argument
+	// keys which the daemon applies at runtime instead of rebooting, when their runtime equivalent is known.
+	// The node controller copies it onto the nodes of the pool.
+	LiveKernelArgumentsAnnotationKey = "machineconfiguration.openshift.io/live-kernel-arguments"
 	// MachineConfigDaemonFinalizeFailureAnnotationKey is set by the daemon when ostree fails to finalize
 	MachineConfigDaemonFinalizeFailureAnnotationKey = "machineconfiguration.openshift.io/ostree-finalize-staged-failure"
 	// InitialNodeAnnotationsFilePath defines the path at which it will find the node annotations it needs to set on the node once it comes up for the first time.
@@ -61,6 +85,9 @@ const (
 	InitialNodeAnnotationsFilePath = "/etc/machine-config-daemon/node-annotations.json"
 	// InitialNodeAnnotationsBakPath defines the path of InitialNodeAnnotationsFilePath when the initial bootstrap is done. We leave it around for debugging and reconciling.
 	InitialNodeAnnotationsBakPath = "/etc/machine-config-daemon/node-annotation.json.bak"
+	// LiveKernelArgumentsStateFilePath records the kernel arguments of the booted deployment while kernel
+	// arguments applied at runtime are staged in the pending deployment, until the next reboot.
+	LiveKernelArgumentsStateFilePath = "/etc/machine-config-daemon/live-kernel-arguments.json"
 
 	// IgnitionSystemdPresetFile is where Ignition writes initial enabled/disabled systemd unit configs
 	// This should be removed on boot after MCO takes over, so if any of these are deleted we can go back
@@ -87,10 +114,16 @@ const (
 	// to proceed and attempt to "reconcile" to the new "desiredConfig" state regardless.
 	MachineConfigDaemonForceFile = "/run/machine-config-daemon-force"
 
-	// coreUser is "core" and currently the only permissible user name
+	// coreUser is "core", the only user which exists on every node
 	CoreUserName  = "core"
 	CoreGroupName = "core"
 
+	// ManagedAccountMinID and ManagedAccountMaxID bound the uids and gids of the users
+	// and groups other than core which can be managed on running nodes. Accounts
+	// outside of this range are system accounts and are never modified.
+	ManagedAccountMinID = 1000
+	ManagedAccountMaxID = 60000
+
 	// changes to registries.conf will cause a crio reload and require extra logic about whether to drain
 	ContainerRegistryConfPath = "/etc/containers/registries.conf"
 
@@ -109,6 +142,15 @@ const (
 	// Changes to this directory should not trigger reboots because they are firstboot-only
 	OpenShiftNMStateConfigDir = "/etc/nmstate/openshift"
 
+	// changes to /etc/sysctl.d are applied by writing the changed settings to /proc/sys
+	SysctlConfigDir = "/etc/sysctl.d"
+
+	// changes to /etc/modules-load.d cause the listed kernel modules to be loaded
+	ModulesLoadConfigDir = "/etc/modules-load.d"
+
+	// changes to /etc/udev/rules.d cause a udev rules reload
+	UdevRulesDir = "/etc/udev/rules.d"
+
 	// SSH Keys for user "core" will only be written at /home/core/.ssh
 	CoreUserSSHPath = "/home/" + CoreUserName + "/.ssh"
 
@@ -137,6 +179,9 @@ const (
 	// KubeletAuthFile is the path to the kubelet auth file.
 	KubeletAuthFile = "/var/lib/kubelet/config.json"
 
+	// KubeletCABundleFilePath is the path to the CA bundle of the kubelet.
+	KubeletCABundleFilePath = "/etc/kubernetes/kubelet-ca.crt"
+
 	// MinFreeStorageAfterPrefetch is the minimum amount of storage
 	// available on the root filesystem after prefetching images.
 	MinFreeStorageAfterPrefetch = "16Gi"
@@ -49,6 +49,7 @@ import (
 	mcoResourceRead "github.com/openshift/machine-config-operator/lib/resourceread"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/daemon/disruption"
 	"github.com/openshift/machine-config-operator/pkg/upgrademonitor"
 
 	"github.com/openshift/machine-config-operator/pkg/daemon/osrelease"
@@ -107,6 +108,10 @@ type Daemon struct {
 	kubeletHealthzEnabled  bool
 	kubeletHealthzEndpoint string
 
+	// dryRun records the plan for each update instead of applying it. A node
+	// can also opt into this with the dry-run annotation.
+	dryRun bool
+
 	updateActive     bool
 	updateActiveLock sync.Mutex
 
@@ -148,6 +153,14 @@ type Daemon struct {
 	// Config Drift Monitor
 	configDriftMonitor ConfigDriftMonitor
 
+	configDriftRemediator *configDriftRemediator
+	// Queue of config drift remediations, worked off outside of the Config
+	// Drift Monitor's event loop.
+	configDriftQueue workqueue.TypedRateLimitingInterface[string]
+	// The config drift exclusions annotation last reported as invalid.
+	invalidConfigDriftExclusions string
+	configDriftExclusionsMu      sync.Mutex
+
 	// Used for Hypershift
 	hypershiftConfigMap string
 
@@ -155,6 +168,10 @@ type Daemon struct {
 
 	deferKubeletRestart bool
 
+	// extensionsCatalogue is read from the last extensions container the
+	// daemon extracted, see getExtensionsCatalogue
+	extensionsCatalogue ctrlcommon.ExtensionsCatalogue
+
 	// Ensures that only a single syncOSImagePullSecrets call can run at a time.
 	osImageMux *sync.Mutex
 }
@@ -213,7 +230,7 @@ const (
 	imageCAFilePath = "/etc/docker/certs.d"
 
 	// used for certificate syncing
-	caBundleFilePath      = "/etc/kubernetes/kubelet-ca.crt"
+	caBundleFilePath      = constants.KubeletCABundleFilePath
 	cloudCABundleFilePath = "/etc/kubernetes/static-pod-resources/configmaps/cloud-config/ca-bundle.pem"
 	userCABundleFilePath  = "/etc/pki/ca-trust/source/anchors/openshift-config-user-ca-bundle.crt"
 	kubeConfigPath        = "/etc/kubernetes/kubeconfig"
@@ -344,6 +361,7 @@ func New(
 		currentConfigPath:      currentConfigPath,
 		currentImagePath:       currentImagePath,
 		configDriftMonitor:     NewConfigDriftMonitor(),
+		configDriftRemediator:  newConfigDriftRemediator(),
 		osImageMux:             &sync.Mutex{},
 	}, nil
 }
@@ -360,6 +378,7 @@ func (dn *Daemon) ClusterConnect(
 	mcopClient mcopclientset.Interface,
 	kubeletHealthzEnabled bool,
 	kubeletHealthzEndpoint string,
+	dryRun bool,
 	featureGatesAccessor featuregates.FeatureGateAccess,
 ) error {
 	dn.name = name
@@ -383,6 +402,7 @@ func (dn *Daemon) ClusterConnect(
 	dn.mcLister = mcInformer.Lister()
 	dn.mcListerSynced = mcInformer.Informer().HasSynced
 	dn.ccQueue = workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]())
+	dn.configDriftQueue = newConfigDriftQueue()
 	ccInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    dn.handleControllerConfigEvent,
 		UpdateFunc: func(_, newObj interface{}) { dn.handleControllerConfigEvent(newObj) },
@@ -404,6 +424,7 @@ func (dn *Daemon) ClusterConnect(
 
 	dn.kubeletHealthzEnabled = kubeletHealthzEnabled
 	dn.kubeletHealthzEndpoint = kubeletHealthzEndpoint
+	dn.dryRun = dryRun
 
 	dn.featureGatesAccessor = featureGatesAccessor
 
@@ -1070,7 +1091,7 @@ func (dn *Daemon) syncNodeHypershift(key string) error {
 	if err != nil {
 		return fmt.Errorf("the update is not reconcilable: %w", err)
 	}
-	if mcDiff.isEmpty() {
+	if mcDiff.IsEmpty() {
 		// No diff was detected. Check if we are in the right state.
 		klog.Infof("No diff detected. Assuming a previous update was completed. Checking on-disk state.")
 		if err := dn.validateOnDiskState(&desiredConfig); err != nil {
@@ -1118,7 +1139,7 @@ func (dn *Daemon) syncNodeHypershift(key string) error {
 	}
 
 	// Check and perform node drain if required
-	drain, err := isDrainRequired(actions, diffFileSet, oldIgnConfig, newIgnConfig, false)
+	drain, err := disruption.IsDrainRequired(actions, diffFileSet, oldIgnConfig, newIgnConfig, false)
 	if err != nil {
 		return err
 	}
@@ -1155,16 +1176,16 @@ func (dn *Daemon) syncNodeHypershift(key string) error {
 
 	// Finally, once we are successful, we perform the necessary post config change action
 	// TODO should be de-duplicated with update()
-	if ctrlcommon.InSlice(postConfigChangeActionReboot, actions) {
+	if ctrlcommon.InSlice(disruption.PostConfigChangeActionReboot, actions) {
 		klog.Info("Rebooting node")
 		return dn.reboot(fmt.Sprintf("Node will reboot into config %s", desiredConfig.Name))
 	}
 
-	if ctrlcommon.InSlice(postConfigChangeActionNone, actions) {
+	if ctrlcommon.InSlice(disruption.PostConfigChangeActionNone, actions) {
 		klog.Infof("Node has Desired Config %s, skipping reboot", desiredConfig.Name)
 	}
 
-	if ctrlcommon.InSlice(postConfigChangeActionReloadCrio, actions) {
+	if ctrlcommon.InSlice(disruption.PostConfigChangeActionReloadCrio, actions) {
 		serviceName := constants.CRIOServiceName
 		if err := reloadService(serviceName); err != nil {
 			return fmt.Errorf("could not apply update: reloading %s configuration failed. Error: %w", serviceName, err)
@@ -1248,7 +1269,7 @@ func (dn *Daemon) RunFirstbootCompleteMachineconfig(machineConfigFile string) er
 
 	// Start with an empty config, then add our *booted* osImageURL to
 	// it, reflecting the current machine state.
-	oldConfig := canonicalizeEmptyMC(nil)
+	oldConfig := disruption.CanonicalizeEmptyMC(nil)
 	oldConfig.Spec.OSImageURL = dn.bootedOSImageURL
 
 	// Setting the Kernel Arguments is for comparison only with the desired MachineConfig.
@@ -1373,6 +1394,7 @@ func (dn *Daemon) Run(stopCh <-chan struct{}, exitCh <-chan error, errCh chan er
 	defer utilruntime.HandleCrash()
 	defer dn.queue.ShutDown()
 	defer dn.ccQueue.ShutDown()
+	defer dn.configDriftQueue.ShutDown()
 
 	if !cache.WaitForCacheSync(stopCh, dn.nodeListerSynced, dn.mcListerSynced, dn.ccListerSynced) {
 		return fmt.Errorf("failed to sync initial listers cache")
@@ -1383,6 +1405,7 @@ func (dn *Daemon) Run(stopCh <-chan struct{}, exitCh <-chan error, errCh chan er
 
 	go wait.Until(dn.worker, time.Second, stopCh)
 	go wait.Until(dn.controllerConfigWorker, time.Second, stopCh)
+	go wait.Until(dn.configDriftWorker, time.Second, stopCh)
 
 	for {
 		select {
@@ -1441,10 +1464,30 @@ func (dn *Daemon) kubeletRebootstrap(ctx context.Context) error {
 }
 
 // Called whenever the on-disk config has drifted from the current machineconfig.
-func (dn *Daemon) onConfigDrift(err error) {
+// Under the Remediate config drift policy, the drift is queued to be fixed up from
+// mc and the node is only degraded if that fails.
+func (dn *Daemon) onConfigDrift(mc *mcfgv1.MachineConfig, err error) {
+	if dn.configDriftRemediator.isRemediating() {
+		klog.V(4).Infof("Ignoring config drift caused by an ongoing remediation: %v", err)
+		return
+	}
+
 	mcdConfigDrift.SetToCurrentTime()
 	dn.nodeWriter.Eventf(corev1.EventTypeWarning, "ConfigDriftDetected", err.Error())
 	klog.Error(err)
+
+	report, reportErr := newConfigDriftReport(mc, pathSystemd, dn.getConfigDriftExclusions(), time.Now())
+	if reportErr != nil {
+		klog.Errorf("Could not generate config drift report: %v", reportErr)
+	} else {
+		dn.recordConfigDrift(report)
+	}
+
+	if dn.getConfigDriftPolicy() == constants.ConfigDriftPolicyRemediate {
+		dn.configDriftQueue.AddRateLimited(dn.configDriftRemediator.setPending(mc))
+		return
+	}
+
 	if err := dn.updateErrorState(err); err != nil {
 		klog.Errorf("Could not update annotation: %v", err)
 	}
@@ -1490,7 +1533,10 @@ func (dn *Daemon) startConfigDriftMonitor() {
 	}
 
 	opts := ConfigDriftMonitorOpts{
-		OnDrift:       dn.onConfigDrift,
+		OnDrift: func(err error) {
+			dn.onConfigDrift(odc.currentConfig, err)
+		},
+		Exclusions:    dn.getConfigDriftExclusions,
 		SystemdPath:   pathSystemd,
 		ErrChan:       dn.exitCh,
 		MachineConfig: odc.currentConfig,
@@ -1503,6 +1549,8 @@ func (dn *Daemon) startConfigDriftMonitor() {
 
 	dn.nodeWriter.Eventf(corev1.EventTypeNormal, "ConfigDriftMonitorStarted",
 		"Config Drift Monitor started, watching against %s", odc.currentConfig.Name)
+	// The on-disk state was validated before the monitor was started.
+	dn.recordConfigDrift(nil)
 
 	go func() {
 		// Common shutdown function
@@ -2459,6 +2507,12 @@ func (dn *Daemon) completeUpdate(desiredConfigName string) error {
 }
 
 func (dn *Daemon) triggerUpdate(currentConfig, desiredConfig *mcfgv1.MachineConfig, currentImage, desiredImage string) error {
+	// A dry run of a layered OS update only records the plan, so nothing may
+	// be written to the node before it.
+	if dn.isDryRun() && (desiredImage != "" || currentImage != "") {
+		return dn.dryRunImageUpdate(currentConfig, desiredConfig, currentImage, desiredImage)
+	}
+
 	// Before we do any updates, ensure that the image pull secrets that rpm-ostree uses are up-to-date.
 	if err := dn.syncOSImagePullSecrets(nil); err != nil {
 		return err
@@ -2502,6 +2556,10 @@ func (dn *Daemon) triggerUpdateWithMachineConfig(currentConfig, desiredConfig *m
 		}
 	}
 
+	if dn.isDryRun() {
+		return dn.dryRunUpdate(currentConfig, desiredConfig)
+	}
+
 	// Shut down the Config Drift Monitor since we'll be performing an update
 	// and the config will "drift" while the update is occurring.
 	dn.stopConfigDriftMonitor()
@@ -2511,8 +2569,13 @@ func (dn *Daemon) triggerUpdateWithMachineConfig(currentConfig, desiredConfig *m
 }
 
 // validateKernelArguments checks that the current boot has all arguments specified
-// in the target machineconfig.
+// in the target machineconfig, including the shouldExist arguments of its Ignition
+// config, and none of the shouldNotExist ones.
 func (dn *CoreOSDaemon) validateKernelArguments(currentConfig *mcfgv1.MachineConfig) error {
+	ignConfig, err := ctrlcommon.ParseAndConvertConfig(currentConfig.Spec.Config.Raw)
+	if err != nil {
+		return fmt.Errorf("parsing Ignition config failed: %w", err)
+	}
 	rpmostreeKargsBytes, err := runGetOut("rpm-ostree", "kargs")
 	if err != nil {
 		return err
@@ -2523,14 +2586,20 @@ func (dn *CoreOSDaemon) validateKernelArguments(currentConfig *mcfgv1.MachineCon
 	for _, arg := range foundArgsArray {
 		foundArgs[arg] = true
 	}
-	expected := parseKernelArguments(currentConfig.Spec.KernelArguments)
+	expected := parseKernelArguments(expectedKernelArguments(currentConfig, ignConfig))
 	missing := []string{}
 	for _, karg := range expected {
 		if _, ok := foundArgs[karg]; !ok {
 			missing = append(missing, karg)
 		}
 	}
-	if len(missing) > 0 {
+	unexpected := []string{}
+	for _, karg := range parseKernelArguments(ignKargsToStrings(ignConfig.KernelArguments.ShouldNotExist)) {
+		if _, ok := foundArgs[karg]; ok {
+			unexpected = append(unexpected, karg)
+		}
+	}
+	if len(missing) > 0 || len(unexpected) > 0 {
 		cmdlinebytes, err := os.ReadFile(CmdLineFile)
 		if err != nil {
 			klog.Warningf("Failed to read %s: %v", CmdLineFile, err)
@@ -2539,6 +2608,9 @@ func (dn *CoreOSDaemon) validateKernelArguments(currentConfig *mcfgv1.MachineCon
 		}
 		klog.Infof("Current ostree kargs: %s", rpmostreeKargs)
 		klog.Infof("Expected MachineConfig kargs: %v", expected)
+		if len(missing) == 0 {
+			return fmt.Errorf("unexpected kernel arguments: %v", unexpected)
+		}
 		return fmt.Errorf("missing expected kernel arguments: %v", missing)
 	}
 	return nil
@@ -2559,7 +2631,7 @@ func (dn *Daemon) validateOnDiskStateImpl(currentConfig *mcfgv1.MachineConfig, i
 		}
 	}
 
-	return validateOnDiskState(currentConfig, pathSystemd)
+	return validateOnDiskState(currentConfig, pathSystemd, dn.getConfigDriftExclusions())
 }
 
 // validateOnDiskState compares the on-disk state against what a configuration
@@ -2832,7 +2904,7 @@ func (dn *Daemon) getUnsupportedPackages() {
 		return
 	}
 
-	supportedExtensions := getSupportedExtensions()
+	supportedExtensions := dn.getExtensionsCatalogue()
 	supportedExtensionNames := make(map[string]bool)
 	for ext := range supportedExtensions {
 		supportedExtensionNames[ext] = true
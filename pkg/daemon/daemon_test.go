@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"strconv"
+	"syscall"
 	"testing"
 	"time"
 
@@ -61,12 +62,15 @@ func TestValidateFiles(t *testing.T) {
 		t.Errorf("Could not Lstat file: %v", err)
 	}
 	fileMode := int(fi.Mode().Perm())
+	stat := fi.Sys().(*syscall.Stat_t)
 
 	// validate single file in spec 3
 	filesV3 := []ign3types.File{
 		{
 			Node: ign3types.Node{
-				Path: "fixtures/test1.txt",
+				Path:  "fixtures/test1.txt",
+				User:  ign3types.NodeUser{ID: helpers.IntToPtr(int(stat.Uid))},
+				Group: ign3types.NodeGroup{ID: helpers.IntToPtr(int(stat.Gid))},
 			},
 			FileEmbedded1: ign3types.FileEmbedded1{
 				Contents: ign3types.Resource{
@@ -164,6 +168,7 @@ func (f *fixture) newController() *Daemon {
 		f.oclient,
 		false,
 		"",
+		false,
 		d.featureGatesAccessor,
 	)
 
@@ -339,39 +344,39 @@ func TestSetRunningKargs(t *testing.T) {
 	newConfig.ObjectMeta = metav1.ObjectMeta{Name: "newconfig"}
 	diff, err := newMachineConfigDiff(oldConfig, newConfig)
 	assert.Nil(t, err)
-	assert.True(t, diff.isEmpty())
+	assert.True(t, diff.IsEmpty())
 
 	cmdline := "BOOT_IMAGE=(hd0,gpt3)/ostree/rhcos-c3b004db4/vmlinuz-5.14.0-284.23.1.el9_2.x86_64 systemd.unified_cgroup_hierarchy=0 systemd.legacy_systemd_cgroup_controller=1"
 	newConfig.Spec.KernelArguments = []string{"systemd.unified_cgroup_hierarchy=0", "systemd.legacy_systemd_cgroup_controller=1"}
 	_ = setRunningKargsWithCmdline(oldConfig, newConfig.Spec.KernelArguments, []byte(cmdline))
 	diff, err = newMachineConfigDiff(oldConfig, newConfig)
 	assert.Nil(t, err)
-	assert.True(t, diff.isEmpty())
+	assert.True(t, diff.IsEmpty())
 
 	newConfig.Spec.KernelArguments = []string{"systemd.legacy_systemd_cgroup_controller=1", "systemd.unified_cgroup_hierarchy=0"}
 	diff, err = newMachineConfigDiff(oldConfig, newConfig)
 	assert.Nil(t, err)
-	assert.False(t, diff.isEmpty())
-	assert.True(t, diff.kargs)
+	assert.False(t, diff.IsEmpty())
+	assert.True(t, diff.Kargs)
 
 	newConfig.Spec.KernelArguments = []string{"systemd.unified_cgroup_hierarchy=0", "systemd.legacy_systemd_cgroup_controller=1", "systemd.unified_cgroup_hierarchy=0"}
 	diff, err = newMachineConfigDiff(oldConfig, newConfig)
 	assert.Nil(t, err)
-	assert.False(t, diff.isEmpty())
-	assert.True(t, diff.kargs)
+	assert.False(t, diff.IsEmpty())
+	assert.True(t, diff.Kargs)
 
 	cmdline = "BOOT_IMAGE=(hd0,gpt3)/ostree/rhcos-c3b004db4/vmlinuz-5.14.0-284.23.1.el9_2.x86_64 systemd.unified_cgroup_hierarchy=0 systemd.unified_cgroup_hierarchy=0 systemd.legacy_systemd_cgroup_controller=1"
 	_ = setRunningKargsWithCmdline(oldConfig, newConfig.Spec.KernelArguments, []byte(cmdline))
 	diff, err = newMachineConfigDiff(oldConfig, newConfig)
 	assert.Nil(t, err)
-	assert.False(t, diff.isEmpty())
-	assert.True(t, diff.kargs)
+	assert.False(t, diff.IsEmpty())
+	assert.True(t, diff.Kargs)
 
 	cmdline = "BOOT_IMAGE=(hd0,gpt3)/ostree/rhcos-c3b004db4/vmlinuz-5.14.0-284.23.1.el9_2.x86_64 systemd.unified_cgroup_hierarchy=0 systemd.legacy_systemd_cgroup_controller=1 systemd.unified_cgroup_hierarchy=0"
 	_ = setRunningKargsWithCmdline(oldConfig, newConfig.Spec.KernelArguments, []byte(cmdline))
 	diff, err = newMachineConfigDiff(oldConfig, newConfig)
 	assert.Nil(t, err)
-	assert.True(t, diff.isEmpty())
+	assert.True(t, diff.IsEmpty())
 }
 
 func TestPrepUpdateFromClusterOnDiskDrift(t *testing.T) {
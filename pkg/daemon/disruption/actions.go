@@ -0,0 +1,236 @@
+package disruption
+
+import (
+	"path/filepath"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+)
+
+const (
+	// These are the actions for a node to take after applying config changes. (e.g. a new machineconfig is applied)
+	// "None" means no special action needs to be taken
+	// This happens for example when ssh keys or the pull secret (/var/lib/kubelet/config.json) is changed
+	PostConfigChangeActionNone = "none"
+	// The "reload crio" action will run "systemctl reload crio"
+	PostConfigChangeActionReloadCrio = "reload crio"
+	// The "restart crio" action will run "systemctl restart crio"
+	PostConfigChangeActionRestartCrio = "restart crio"
+	// Rebooting is still the default scenario for any other change
+	PostConfigChangeActionReboot = "reboot"
+)
+
+// The built-in node disruption actions of the MCO's default policies for
+// kernel and device configuration. They are not part of the API: the operator
+// reports these policies with the Special action, which the daemon resolves
+// to the built-in action for the directory of the changed file.
+const (
+	ApplySysctlStatusAction opv1.NodeDisruptionPolicyStatusActionType = "ApplySysctl"
+	LoadModulesStatusAction opv1.NodeDisruptionPolicyStatusActionType = "LoadModules"
+	UdevReloadStatusAction  opv1.NodeDisruptionPolicyStatusActionType = "UdevReload"
+)
+
+// specialActionDirs maps the default policy directories with a Special action
+// to the built-in action applying changes to them.
+var specialActionDirs = map[string]opv1.NodeDisruptionPolicyStatusActionType{
+	constants.SysctlConfigDir:      ApplySysctlStatusAction,
+	constants.ModulesLoadConfigDir: LoadModulesStatusAction,
+	constants.UdevRulesDir:         UdevReloadStatusAction,
+}
+
+// CalculatePostConfigChangeActionFromMCDiff calculates the post config change
+// actions for a diff without looking at the state of the node.
+func CalculatePostConfigChangeActionFromMCDiff(diff *MachineConfigDiff, diffFileSet []string) []string {
+	if diff.OSUpdate || diff.Kargs || diff.FIPS || diff.Units || diff.KernelType || diff.Extensions {
+		// must reboot
+		return []string{PostConfigChangeActionReboot}
+	}
+
+	// Calculate actions based on file, unit and ssh diffs
+	return CalculatePostConfigChangeActionFromMCDiffs(diffFileSet)
+}
+
+// CalculatePostConfigChangeActionFromMCDiffs calculates the post config change
+// actions for the changed files.
+func CalculatePostConfigChangeActionFromMCDiffs(diffFileSet []string) (actions []string) {
+	filesPostConfigChangeActionNone := []string{
+		constants.KubeletCABundleFilePath,
+		constants.KubeletAuthFile,
+	}
+	directoriesPostConfigChangeActionNone := []string{
+		constants.OpenShiftNMStateConfigDir,
+	}
+	filesPostConfigChangeActionReloadCrio := []string{
+		constants.ContainerRegistryConfPath,
+		constants.GPGNoRebootPath,
+		constants.ContainerRegistryPolicyPath,
+	}
+	filesPostConfigChangeActionRestartCrio := []string{
+		constants.UserCABundlePath,
+	}
+	dirsPostConfigChangeActionReloadCrio := []string{
+		constants.SigstoreRegistriesConfigDir,
+	}
+
+	actions = []string{PostConfigChangeActionNone}
+	for _, path := range diffFileSet {
+		switch {
+		case ctrlcommon.InSlice(path, filesPostConfigChangeActionNone):
+			continue
+
+		case ctrlcommon.InSlice(path, filesPostConfigChangeActionReloadCrio),
+			ctrlcommon.InSlice(filepath.Dir(path), dirsPostConfigChangeActionReloadCrio):
+			// Don't override a restart CRIO action
+			if !ctrlcommon.InSlice(PostConfigChangeActionRestartCrio, actions) {
+				actions = []string{PostConfigChangeActionReloadCrio}
+			}
+
+		case ctrlcommon.InSlice(path, filesPostConfigChangeActionRestartCrio):
+			actions = []string{PostConfigChangeActionRestartCrio}
+
+		case ctrlcommon.InSlice(filepath.Dir(path), directoriesPostConfigChangeActionNone):
+			continue
+
+		default:
+			actions = []string{PostConfigChangeActionReboot}
+			return actions
+		}
+	}
+	return actions
+}
+
+// CalculatePostConfigChangeNodeDisruptionActionFromMCDiff calculates the node
+// disruption actions for a diff against the given cluster policies without
+// looking at the state of the node.
+func CalculatePostConfigChangeNodeDisruptionActionFromMCDiff(diff *MachineConfigDiff, diffFileSet, diffUnitSet []string, clusterPolicies opv1.NodeDisruptionPolicyClusterStatus) []opv1.NodeDisruptionPolicyStatusAction {
+	if diff.OSUpdate || diff.Kargs || diff.FIPS || diff.KernelType || diff.Extensions {
+		// must reboot
+		return []opv1.NodeDisruptionPolicyStatusAction{{
+			Type: opv1.RebootStatusAction,
+		}}
+	}
+	if !diff.Files && !diff.Units && !diff.Passwd {
+		// This is a diff which requires no actions
+		klog.Infof("No changes in files, units or SSH keys, no NodeDisruptionPolicies are in effect")
+		return []opv1.NodeDisruptionPolicyStatusAction{{
+			Type: opv1.NoneStatusAction,
+		}}
+	}
+
+	// Calculate actions based on file, unit and ssh diffs
+	return CalculatePostConfigChangeNodeDisruptionActionFromMCDiffs(diff.Passwd, diffFileSet, diffUnitSet, clusterPolicies)
+}
+
+// CalculatePostConfigChangeNodeDisruptionActionFromMCDiffs takes action based on the cluster's Node disruption policies.
+func CalculatePostConfigChangeNodeDisruptionActionFromMCDiffs(diffSSH bool, diffFileSet, diffUnitSet []string, clusterPolicies opv1.NodeDisruptionPolicyClusterStatus) []opv1.NodeDisruptionPolicyStatusAction {
+	actions := []opv1.NodeDisruptionPolicyStatusAction{}
+
+	// Step through all file based policies, and build out the actions object
+	for _, diffPath := range diffFileSet {
+		pathFound, actionsFound := ctrlcommon.FindClosestFilePolicyPathMatch(diffPath, clusterPolicies.Files)
+		if pathFound {
+			klog.Infof("NodeDisruptionPolicy %v found for diff file %s", actionsFound, diffPath)
+			for _, action := range ResolveSpecialActions(diffPath, actionsFound) {
+				// Built-in actions apply a whole directory, so they only need to run once
+				if IsBuiltinNodeDisruptionAction(action.Type) && apihelpers.CheckNodeDisruptionActionsForTargetActions(actions, action.Type) {
+					continue
+				}
+				actions = append(actions, action)
+			}
+		} else {
+			// If this file path has no policy defined, default to reboot
+			klog.V(4).Infof("no policy found for diff path %s", diffPath)
+			return []opv1.NodeDisruptionPolicyStatusAction{{
+				Type: opv1.RebootStatusAction,
+			}}
+		}
+	}
+
+	// Step through all unit based policies, and build out the actions object
+	for _, diffUnit := range diffUnitSet {
+		unitFound := false
+		for _, policyUnit := range clusterPolicies.Units {
+			klog.V(4).Infof("comparing policy unit name %s to diff unit name %s", string(policyUnit.Name), diffUnit)
+			if string(policyUnit.Name) == diffUnit {
+				klog.Infof("NodeDisruptionPolicy %v found for diff unit %s!", policyUnit.Actions, diffUnit)
+				actions = append(actions, policyUnit.Actions...)
+				unitFound = true
+				break
+			}
+		}
+		if !unitFound {
+			// If this unit has no policy defined, default to reboot
+			klog.V(4).Infof("no policy found for diff unit %s", diffUnit)
+			return []opv1.NodeDisruptionPolicyStatusAction{{
+				Type: opv1.RebootStatusAction,
+			}}
+		}
+	}
+
+	// SSH only has one possible policy(and there is a default), so blindly add that if there is an SSH diff
+	if diffSSH {
+		klog.Infof("SSH diff detected, applying SSH policy %v", clusterPolicies.SSHKey.Actions)
+		actions = append(actions, clusterPolicies.SSHKey.Actions...)
+	}
+
+	// If any of the actions need a reboot, then just return a single Reboot action
+	if apihelpers.CheckNodeDisruptionActionsForTargetActions(actions, opv1.RebootStatusAction) {
+		return []opv1.NodeDisruptionPolicyStatusAction{{
+			Type: opv1.RebootStatusAction,
+		}}
+	}
+
+	// If there is a "None" action in conjunction with other kinds of actions, strip out the "None" action elements as it is redundant
+	if apihelpers.CheckNodeDisruptionActionsForTargetActions(actions, opv1.NoneStatusAction) {
+		if apihelpers.CheckNodeDisruptionActionsForTargetActions(actions, opv1.DrainStatusAction, opv1.ReloadStatusAction, opv1.RestartStatusAction, opv1.DaemonReloadStatusAction, opv1.SpecialStatusAction, ApplySysctlStatusAction, LoadModulesStatusAction, UdevReloadStatusAction) {
+			finalActions := []opv1.NodeDisruptionPolicyStatusAction{}
+			for _, action := range actions {
+				if action.Type != opv1.NoneStatusAction {
+					finalActions = append(finalActions, action)
+				}
+			}
+			return finalActions
+		}
+		// If we're here, this means that the action list has only "None" actions; return a single "None" Action
+		return []opv1.NodeDisruptionPolicyStatusAction{{
+			Type: opv1.NoneStatusAction,
+		}}
+	}
+
+	// If we're here, return as is - this means action list had zero "None" actions in the list
+	return actions
+}
+
+// ResolveSpecialActions replaces the Special action of the file policy
+// matching diffPath with the built-in action for its directory. The Special
+// action of other paths, i.e. registries.conf, is kept and reloads crio.
+func ResolveSpecialActions(diffPath string, actions []opv1.NodeDisruptionPolicyStatusAction) []opv1.NodeDisruptionPolicyStatusAction {
+	resolved := make([]opv1.NodeDisruptionPolicyStatusAction, 0, len(actions))
+	for _, action := range actions {
+		if action.Type == opv1.SpecialStatusAction {
+			for dir, actionType := range specialActionDirs {
+				if ctrlcommon.IsSubdirectory(dir, diffPath) {
+					action = opv1.NodeDisruptionPolicyStatusAction{Type: actionType}
+					break
+				}
+			}
+		}
+		resolved = append(resolved, action)
+	}
+	return resolved
+}
+
+// IsBuiltinNodeDisruptionAction returns true for the actions which the daemon
+// resolves the Special action to.
+func IsBuiltinNodeDisruptionAction(actionType opv1.NodeDisruptionPolicyStatusActionType) bool {
+	for _, builtin := range specialActionDirs {
+		if actionType == builtin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package disruption
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveSpecialActions(t *testing.T) {
+	defaultPolicies := apihelpers.MergeClusterPolicies(opv1.NodeDisruptionPolicyConfig{}, true)
+
+	testCases := []struct {
+		name     string
+		files    []string
+		expected []opv1.NodeDisruptionPolicyStatusAction
+	}{
+		{
+			name:     "Sysctl changes",
+			files:    []string{"/etc/sysctl.d/99-foo.conf", "/etc/sysctl.d/99-bar.conf"},
+			expected: []opv1.NodeDisruptionPolicyStatusAction{{Type: ApplySysctlStatusAction}},
+		},
+		{
+			name:     "Kernel module changes",
+			files:    []string{"/etc/modules-load.d/foo.conf"},
+			expected: []opv1.NodeDisruptionPolicyStatusAction{{Type: LoadModulesStatusAction}},
+		},
+		{
+			name:     "Udev rule and sysctl changes",
+			files:    []string{"/etc/udev/rules.d/99-foo.rules", "/etc/sysctl.d/99-foo.conf"},
+			expected: []opv1.NodeDisruptionPolicyStatusAction{{Type: UdevReloadStatusAction}, {Type: ApplySysctlStatusAction}},
+		},
+		{
+			name:     "Registries change keeps the Special action",
+			files:    []string{"/etc/containers/registries.conf", "/etc/sysctl.d/99-foo.conf"},
+			expected: []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.SpecialStatusAction}, {Type: ApplySysctlStatusAction}},
+		},
+		{
+			name:     "Other changes still reboot",
+			files:    []string{"/etc/sysctl.d/99-foo.conf", "/etc/foo"},
+			expected: []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.RebootStatusAction}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actions := CalculatePostConfigChangeNodeDisruptionActionFromMCDiffs(false, testCase.files, nil, defaultPolicies)
+			assert.Equal(t, testCase.expected, actions)
+		})
+	}
+
+	// A user defined policy for the directory replaces the built-in action
+	userPolicies := apihelpers.MergeClusterPolicies(opv1.NodeDisruptionPolicyConfig{
+		Files: []opv1.NodeDisruptionPolicySpecFile{{
+			Path:    "/etc/sysctl.d",
+			Actions: []opv1.NodeDisruptionPolicySpecAction{{Type: opv1.RebootSpecAction}},
+		}},
+	}, true)
+	actions := CalculatePostConfigChangeNodeDisruptionActionFromMCDiffs(false, []string{"/etc/sysctl.d/99-foo.conf"}, nil, userPolicies)
+	assert.Equal(t, []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.RebootStatusAction}}, actions)
+
+	// Without opting in to the built-in actions, the changes reboot
+	actions = CalculatePostConfigChangeNodeDisruptionActionFromMCDiffs(false, []string{"/etc/sysctl.d/99-foo.conf"}, nil, apihelpers.MergeClusterPolicies(opv1.NodeDisruptionPolicyConfig{}, false))
+	assert.Equal(t, []opv1.NodeDisruptionPolicyStatusAction{{Type: opv1.RebootStatusAction}}, actions)
+}
@@ -0,0 +1,129 @@
+// Package disruption computes how a node is disrupted by an update from one
+// rendered MachineConfig to another: the post config change actions or node
+// disruption actions the MCD takes, and whether it drains the node. None of
+// this looks at the state of a particular node, so it is shared between the
+// MCD and the controllers.
+package disruption
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/clarketm/json"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+// MachineConfigDiff represents an ad-hoc difference between two MachineConfig objects.
+// At some point this may change into holding just the files/units that changed
+// and the MCO would just operate on that.  For now we're just doing this to get
+// improved logging.
+type MachineConfigDiff struct {
+	OSUpdate   bool
+	Kargs      bool
+	FIPS       bool
+	Passwd     bool
+	Files      bool // files, directories or links
+	Units      bool
+	KernelType bool
+	Extensions bool
+}
+
+// IsEmpty returns true if the MachineConfigDiff has no changes, or
+// in other words if the two MachineConfig objects are equivalent from
+// the MCD's point of view.  This is mainly relevant if e.g. two MC
+// objects happen to have different Ignition versions but are otherwise
+// the same.  (Probably a better way would be to canonicalize)
+func (mcDiff *MachineConfigDiff) IsEmpty() bool {
+	emptyDiff := MachineConfigDiff{}
+	return reflect.DeepEqual(mcDiff, &emptyDiff)
+}
+
+// OSChangesString generates a human-readable set of changes from the diff
+func (mcDiff *MachineConfigDiff) OSChangesString() string {
+	changes := []string{}
+	if mcDiff.OSUpdate {
+		changes = append(changes, "Upgrading OS")
+	}
+	if mcDiff.Extensions {
+		changes = append(changes, "Installing extensions")
+	}
+	if mcDiff.KernelType {
+		changes = append(changes, "Changing kernel type")
+	}
+	if mcDiff.Kargs {
+		changes = append(changes, "Changing kernel arguments")
+	}
+
+	return strings.Join(changes, "; ")
+}
+
+// CanonicalizeKernelType returns a valid kernelType. We consider empty("") and default kernelType as same
+func CanonicalizeKernelType(kernelType string) string {
+	if kernelType == ctrlcommon.KernelTypeRealtime {
+		return ctrlcommon.KernelTypeRealtime
+	} else if kernelType == ctrlcommon.KernelType64kPages {
+		return ctrlcommon.KernelType64kPages
+	}
+	return ctrlcommon.KernelTypeDefault
+}
+
+// DiffMachineConfigs compares two MachineConfig objects without looking at the
+// state of the node.
+func DiffMachineConfigs(oldConfig, newConfig *mcfgv1.MachineConfig) (*MachineConfigDiff, error) {
+	oldIgn, err := ctrlcommon.ParseAndConvertConfig(oldConfig.Spec.Config.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing old Ignition config failed with error: %w", err)
+	}
+	newIgn, err := ctrlcommon.ParseAndConvertConfig(newConfig.Spec.Config.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing new Ignition config failed with error: %w", err)
+	}
+
+	// Both nil and empty slices are of zero length,
+	// consider them as equal while comparing KernelArguments in both MachineConfigs
+	// and in the kernelArguments sections of both Ignition configs
+	kargsEmpty := len(oldConfig.Spec.KernelArguments) == 0 && len(newConfig.Spec.KernelArguments) == 0
+	ignKargsEmpty := len(oldIgn.KernelArguments.ShouldExist) == 0 && len(oldIgn.KernelArguments.ShouldNotExist) == 0 &&
+		len(newIgn.KernelArguments.ShouldExist) == 0 && len(newIgn.KernelArguments.ShouldNotExist) == 0
+	extensionsEmpty := len(oldConfig.Spec.Extensions) == 0 && len(newConfig.Spec.Extensions) == 0
+
+	kargsChanged := !(kargsEmpty || reflect.DeepEqual(oldConfig.Spec.KernelArguments, newConfig.Spec.KernelArguments))
+	ignKargsChanged := !(ignKargsEmpty || reflect.DeepEqual(oldIgn.KernelArguments, newIgn.KernelArguments))
+
+	return &MachineConfigDiff{
+		OSUpdate:   oldConfig.Spec.OSImageURL != newConfig.Spec.OSImageURL,
+		Kargs:      kargsChanged || ignKargsChanged,
+		FIPS:       oldConfig.Spec.FIPS != newConfig.Spec.FIPS,
+		Passwd:     !reflect.DeepEqual(oldIgn.Passwd, newIgn.Passwd),
+		Files:      len(ctrlcommon.CalculateConfigFileDiffs(&oldIgn, &newIgn)) != 0,
+		Units:      !reflect.DeepEqual(oldIgn.Systemd.Units, newIgn.Systemd.Units),
+		KernelType: CanonicalizeKernelType(oldConfig.Spec.KernelType) != CanonicalizeKernelType(newConfig.Spec.KernelType),
+		Extensions: !(extensionsEmpty || reflect.DeepEqual(oldConfig.Spec.Extensions, newConfig.Spec.Extensions)),
+	}, nil
+}
+
+// CanonicalizeEmptyMC returns config, or an empty MachineConfig if it is nil.
+func CanonicalizeEmptyMC(config *mcfgv1.MachineConfig) *mcfgv1.MachineConfig {
+	if config != nil {
+		return config
+	}
+	newIgnCfg := ctrlcommon.NewIgnConfig()
+	rawNewIgnCfg, err := json.Marshal(newIgnCfg)
+	if err != nil {
+		// This should never happen
+		panic(err)
+	}
+	return &mcfgv1.MachineConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "mco-empty-mc"},
+		Spec: mcfgv1.MachineConfigSpec{
+			Config: runtime.RawExtension{
+				Raw: rawNewIgnCfg,
+			},
+		},
+	}
+}
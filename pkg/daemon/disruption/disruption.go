@@ -0,0 +1,95 @@
+package disruption
+
+import (
+	"fmt"
+
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+// Disruption describes what the MCD does to a node after writing an update
+// from one rendered MachineConfig to another to disk.
+type Disruption struct {
+	Diff *MachineConfigDiff
+	// Files and Units are the paths and names of the files and units which
+	// are added, changed or removed.
+	Files []string
+	Units []string
+
+	// PostConfigChangeActions is set when node disruption policies are not in
+	// use, NodeDisruptionActions otherwise.
+	PostConfigChangeActions []string
+	NodeDisruptionActions   []opv1.NodeDisruptionPolicyStatusAction
+
+	// Drain is true if the node is drained before the update is applied.
+	Drain bool
+}
+
+// Calculate computes the Disruption of updating from oldConfig to newConfig.
+// If clusterPolicies is nil, the legacy post config change actions are
+// calculated, otherwise the node disruption actions for those policies are.
+// overrideImageRegistryDrain is true if the image registry drain override
+// ConfigMap exists. An error is returned if newConfig is not reconcilable with
+// oldConfig.
+func Calculate(oldConfig, newConfig *mcfgv1.MachineConfig, clusterPolicies *opv1.NodeDisruptionPolicyClusterStatus, overrideImageRegistryDrain bool) (*Disruption, error) {
+	oldConfig = CanonicalizeEmptyMC(oldConfig)
+
+	if err := ctrlcommon.IsRenderedConfigReconcilable(oldConfig, newConfig); err != nil {
+		return nil, fmt.Errorf("configs %s, %s are not reconcilable: %w", oldConfig.Name, newConfig.Name, err)
+	}
+
+	diff, err := DiffMachineConfigs(oldConfig, newConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating machineConfigDiff: %w", err)
+	}
+
+	oldIgnConfig, err := ctrlcommon.ParseAndConvertConfig(oldConfig.Spec.Config.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing old Ignition config failed: %w", err)
+	}
+	newIgnConfig, err := ctrlcommon.ParseAndConvertConfig(newConfig.Spec.Config.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing new Ignition config failed: %w", err)
+	}
+
+	d := &Disruption{
+		Diff:  diff,
+		Files: ctrlcommon.CalculateConfigFileDiffs(&oldIgnConfig, &newIgnConfig),
+		Units: ctrlcommon.CalculateConfigUnitDiffs(&oldIgnConfig, &newIgnConfig),
+	}
+
+	if clusterPolicies != nil {
+		d.NodeDisruptionActions = CalculatePostConfigChangeNodeDisruptionActionFromMCDiff(diff, d.Files, d.Units, *clusterPolicies)
+		d.Drain, err = IsDrainRequiredForNodeDisruptionActions(d.NodeDisruptionActions, oldIgnConfig, newIgnConfig, overrideImageRegistryDrain)
+	} else {
+		d.PostConfigChangeActions = CalculatePostConfigChangeActionFromMCDiff(diff, d.Files)
+		d.Drain, err = IsDrainRequired(d.PostConfigChangeActions, d.Files, oldIgnConfig, newIgnConfig, overrideImageRegistryDrain)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not determine whether drain is required: %w", err)
+	}
+
+	return d, nil
+}
+
+// RequiresReboot returns true if the MCD reboots the node to apply the
+// update.
+func (d *Disruption) RequiresReboot() bool {
+	return RequiresReboot(d.PostConfigChangeActions, d.NodeDisruptionActions)
+}
+
+// RequiresReboot returns true if the given post config change actions or,
+// if set, node disruption actions reboot the node.
+func RequiresReboot(postConfigChangeActions []string, nodeDisruptionActions []opv1.NodeDisruptionPolicyStatusAction) bool {
+	if nodeDisruptionActions == nil {
+		return ctrlcommon.InSlice(PostConfigChangeActionReboot, postConfigChangeActions)
+	}
+	for _, action := range nodeDisruptionActions {
+		if action.Type == opv1.RebootStatusAction {
+			return true
+		}
+	}
+	return false
+}
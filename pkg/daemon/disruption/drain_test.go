@@ -1,4 +1,4 @@
-package daemon
+package disruption
 
 import (
 	"fmt"
@@ -344,14 +344,14 @@ location = "mirror.com/repo/test-img-14"
 	}{
 		{
 			// skip drain: only None action is present
-			actions:        []string{postConfigChangeActionNone},
+			actions:        []string{PostConfigChangeActionNone},
 			oldConfig:      machineConfigs["mc1"],
 			newConfig:      machineConfigs["mc1"],
 			expectedAction: false,
 		},
 		{
 			// perform drain: reboot action is present
-			actions:        []string{postConfigChangeActionNone, postConfigChangeActionReboot},
+			actions:        []string{PostConfigChangeActionNone, PostConfigChangeActionReboot},
 			oldConfig:      machineConfigs["mc1"],
 			newConfig:      machineConfigs["mc1"],
 			expectedAction: true,
@@ -359,98 +359,98 @@ location = "mirror.com/repo/test-img-14"
 		// below tests are run when only crio reload action is present
 		{
 			// skip drain: no changes in registry config
-			actions:        []string{postConfigChangeActionReloadCrio},
+			actions:        []string{PostConfigChangeActionReloadCrio},
 			oldConfig:      machineConfigs["mc1"],
 			newConfig:      machineConfigs["mc1"],
 			expectedAction: false,
 		},
 		{
 			// skip drain: only new registry added with pull-from-mirror=digest-only
-			actions:        []string{postConfigChangeActionReloadCrio},
+			actions:        []string{PostConfigChangeActionReloadCrio},
 			oldConfig:      machineConfigs["mc3"],
 			newConfig:      machineConfigs["mc1"],
 			expectedAction: false,
 		},
 		{
 			// perform drain: only new registry added with mirror-by-digest-only set to false
-			actions:        []string{postConfigChangeActionReloadCrio},
+			actions:        []string{PostConfigChangeActionReloadCrio},
 			oldConfig:      machineConfigs["mc5"],
 			newConfig:      machineConfigs["mc6"],
 			expectedAction: true,
 		},
 		{
 			// perform drain: one or more registry has been removed
-			actions:        []string{postConfigChangeActionReloadCrio},
+			actions:        []string{PostConfigChangeActionReloadCrio},
 			oldConfig:      machineConfigs["mc1"],
 			newConfig:      machineConfigs["mc3"],
 			expectedAction: true,
 		},
 		{
 			// skip drain: only new mirrors got added to the registry with pull-from-mirror=digest-only
-			actions:        []string{postConfigChangeActionReloadCrio},
+			actions:        []string{PostConfigChangeActionReloadCrio},
 			oldConfig:      machineConfigs["mc1"],
 			newConfig:      machineConfigs["mc2"],
 			expectedAction: false,
 		},
 		{
 			// skip drain: only new mirrors are added to registry with pull-from-mirror=digest-only while existing registries with mirror-by-digest-only=false are unchanged
-			actions:        []string{postConfigChangeActionReloadCrio},
+			actions:        []string{PostConfigChangeActionReloadCrio},
 			oldConfig:      machineConfigs["mc9"],
 			newConfig:      machineConfigs["mc11"],
 			expectedAction: false,
 		},
 		{
 			// perform drain: only new mirrors got added to the registry with mirror-by-digest-only set to false for registry
-			actions:        []string{postConfigChangeActionReloadCrio},
+			actions:        []string{PostConfigChangeActionReloadCrio},
 			oldConfig:      machineConfigs["mc6"],
 			newConfig:      machineConfigs["mc7"],
 			expectedAction: true,
 		},
 		{
 			// perform drain: one or more mirror has been removed from a registry
-			actions:        []string{postConfigChangeActionReloadCrio},
+			actions:        []string{PostConfigChangeActionReloadCrio},
 			oldConfig:      machineConfigs["mc2"],
 			newConfig:      machineConfigs["mc1"],
 			expectedAction: true,
 		},
 		{
 			// perform drain: either item from unqualified-search-registries has been removed or ordering has been changed
-			actions:        []string{postConfigChangeActionReloadCrio},
+			actions:        []string{PostConfigChangeActionReloadCrio},
 			oldConfig:      machineConfigs["mc4"],
 			newConfig:      machineConfigs["mc3"],
 			expectedAction: true,
 		},
 		{
 			// skip drain: only additional unqualified-search-registries has been added
-			actions:        []string{postConfigChangeActionReloadCrio},
+			actions:        []string{PostConfigChangeActionReloadCrio},
 			oldConfig:      machineConfigs["mc3"],
 			newConfig:      machineConfigs["mc4"],
 			expectedAction: false,
 		},
 		{
 			// perform drain: prefix value of one or more registry has changed
-			actions:        []string{postConfigChangeActionReloadCrio},
+			actions:        []string{PostConfigChangeActionReloadCrio},
 			oldConfig:      machineConfigs["mc8"],
 			newConfig:      machineConfigs["mc10"],
 			expectedAction: true,
 		},
 		{
 			// perform drain: blocked value of one or more registry has changed
-			actions:        []string{postConfigChangeActionReloadCrio},
+			actions:        []string{PostConfigChangeActionReloadCrio},
 			oldConfig:      machineConfigs["mc8"],
 			newConfig:      machineConfigs["mc9"],
 			expectedAction: true,
 		},
 		{
 			// perform drain:  mirror-by-digest-only value of one or more registry has changed
-			actions:        []string{postConfigChangeActionReloadCrio},
+			actions:        []string{PostConfigChangeActionReloadCrio},
 			oldConfig:      machineConfigs["mc1"],
 			newConfig:      machineConfigs["mc6"],
 			expectedAction: true,
 		},
 		{
 			// skip drain: only new mirror added to registry with mirror-by-digest-only set to true
-			actions:        []string{postConfigChangeActionReloadCrio},
+			actions:        []string{PostConfigChangeActionReloadCrio},
 			oldConfig:      machineConfigs["mc12"],
 			newConfig:      machineConfigs["mc13"],
 			expectedAction: false,
@@ -468,7 +468,7 @@ location = "mirror.com/repo/test-img-14"
 				t.Errorf("parsing new Ignition config failed: %v", err)
 			}
 			diffFileSet := ctrlcommon.CalculateConfigFileDiffs(&oldIgnConfig, &newIgnConfig)
-			drain, err := isDrainRequired(test.actions, diffFileSet, oldIgnConfig, newIgnConfig, false)
+			drain, err := IsDrainRequired(test.actions, diffFileSet, oldIgnConfig, newIgnConfig, false)
 			if !reflect.DeepEqual(test.expectedAction, drain) {
 				t.Errorf("Failed determining drain behavior: expected: %v but result is: %v. Error: %v", test.expectedAction, drain, err)
 			}
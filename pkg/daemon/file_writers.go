@@ -264,6 +264,143 @@ func writeFiles(files []ign3types.File, skipCertificateWrite bool) error {
 	return nil
 }
 
+// createOrigDirectory is the createOrigFile counterpart for directories. Only
+// the mode and ownership of a directory are managed, so an empty directory
+// carrying them is kept as the orig backup instead of a copy of its contents.
+func createOrigDirectory(dpath string) error {
+	if _, err := os.Stat(noOrigFileStampName(dpath)); err == nil {
+		return nil
+	}
+	if _, err := os.Lstat(origFileName(dpath)); err == nil {
+		// the orig directory is already there and we avoid creating a new one to preserve the real default
+		return nil
+	}
+
+	fi, err := os.Stat(dpath)
+	if os.IsNotExist(err) {
+		// the directory is created by the MCD, so it can just be removed when deleting stale data.
+		if makeErr := os.MkdirAll(filepath.Dir(noOrigFileStampName(dpath)), 0o755); makeErr != nil {
+			return fmt.Errorf("creating no orig parent dir: %w", makeErr)
+		}
+		return writeFileAtomicallyWithDefaults(noOrigFileStampName(dpath), nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(origFileName(dpath)), 0o755); err != nil {
+		return fmt.Errorf("creating orig parent dir: %w", err)
+	}
+	if err := os.Mkdir(origFileName(dpath), fi.Mode().Perm()); err != nil {
+		return fmt.Errorf("creating orig directory for %q: %w", dpath, err)
+	}
+	if out, err := exec.Command("chmod", "--reference", dpath, origFileName(dpath)).CombinedOutput(); err != nil {
+		return fmt.Errorf("copying mode of %q: %s: %w", dpath, string(out), err)
+	}
+	if out, err := exec.Command("chown", "--reference", dpath, origFileName(dpath)).CombinedOutput(); err != nil {
+		return fmt.Errorf("copying ownership of %q: %s: %w", dpath, string(out), err)
+	}
+	return nil
+}
+
+// restoreDirectory gives a directory back the mode and ownership kept by
+// createOrigDirectory.
+func restoreDirectory(dpath string) error {
+	for _, cmd := range []string{"chmod", "chown"} {
+		if out, err := exec.Command(cmd, "--reference", origFileName(dpath), dpath).CombinedOutput(); err != nil {
+			return fmt.Errorf("restoring %q from orig directory %q: %s: %w", dpath, origFileName(dpath), string(out), err)
+		}
+	}
+	if err := os.Remove(origFileName(dpath)); err != nil {
+		return fmt.Errorf("deleting orig directory %q: %w", origFileName(dpath), err)
+	}
+	return nil
+}
+
+// writeDirectories creates the given directories, or updates the mode and
+// ownership of the existing ones.
+func writeDirectories(dirs []ign3types.Directory) error {
+	for _, dir := range dirs {
+		klog.Infof("Writing directory %q", dir.Path)
+
+		mode := defaultDirectoryPermissions
+		if dir.Mode != nil {
+			mode = os.FileMode(*dir.Mode)
+		}
+
+		uid, gid, err := getNodeOwnership(dir.Node)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve directory ownership for directory %q: %w", dir.Path, err)
+		}
+
+		if fi, err := os.Lstat(dir.Path); err == nil && !fi.IsDir() {
+			return fmt.Errorf("cannot write directory %q: path exists and is not a directory", dir.Path)
+		}
+		if err := createOrigDirectory(dir.Path); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir.Path, mode); err != nil {
+			return fmt.Errorf("failed to create directory %q: %w", dir.Path, err)
+		}
+		// Chmod explicitly since MkdirAll is subject to the umask and leaves existing directories alone
+		if err := os.Chmod(dir.Path, mode); err != nil {
+			return fmt.Errorf("failed to set mode of directory %q: %w", dir.Path, err)
+		}
+		if err := os.Chown(dir.Path, uid, gid); err != nil {
+			return fmt.Errorf("failed to set ownership of directory %q: %w", dir.Path, err)
+		}
+	}
+	return nil
+}
+
+// writeLinks creates the given symbolic and hard links, replacing whatever
+// non-directory is at their path.
+func writeLinks(links []ign3types.Link) error {
+	for _, link := range links {
+		klog.Infof("Writing link %q", link.Path)
+
+		if link.Target == nil || *link.Target == "" {
+			return fmt.Errorf("link %q has no target", link.Path)
+		}
+		if fi, err := os.Lstat(link.Path); err == nil && fi.IsDir() {
+			return fmt.Errorf("cannot write link %q: path exists and is a directory", link.Path)
+		}
+
+		uid, gid, err := getNodeOwnership(link.Node)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve link ownership for link %q: %w", link.Path, err)
+		}
+		if err := createOrigFile(link.Path, link.Path); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(link.Path), defaultDirectoryPermissions); err != nil {
+			return fmt.Errorf("failed to create parent directory of link %q: %w", link.Path, err)
+		}
+
+		if link.Hard != nil && *link.Hard {
+			// Link to a temporary name first so the link is replaced atomically, like renameio.Symlink does
+			tmp := link.Path + ".mcdtmp"
+			if err := os.Link(*link.Target, tmp); err != nil {
+				return fmt.Errorf("failed to hard link %q to %q: %w", link.Path, *link.Target, err)
+			}
+			if err := os.Rename(tmp, link.Path); err != nil {
+				os.Remove(tmp)
+				return fmt.Errorf("failed to hard link %q to %q: %w", link.Path, *link.Target, err)
+			}
+			// A hard link shares the ownership of its target, which is not ours to change
+			continue
+		}
+
+		if err := renameio.Symlink(*link.Target, link.Path); err != nil {
+			return fmt.Errorf("failed to symlink %q to %q: %w", link.Path, *link.Target, err)
+		}
+		if err := os.Lchown(link.Path, uid, gid); err != nil {
+			return fmt.Errorf("failed to set ownership of link %q: %w", link.Path, err)
+		}
+	}
+	return nil
+}
+
 // writeUnit writes a systemd unit and its dropins to disk
 func writeUnit(u ign3types.Unit, systemdRoot string, isCoreOSVariant bool) error {
 	if err := writeDropins(u, systemdRoot, isCoreOSVariant); err != nil {
@@ -363,21 +500,27 @@ func lookupGID(group string) (int, error) {
 
 // This is essentially ResolveNodeUidAndGid() from Ignition; XXX should dedupe
 func getFileOwnership(file ign3types.File) (int, int, error) {
+	return getNodeOwnership(file.Node)
+}
+
+// getNodeOwnership returns the uid and gid an Ignition file, directory or link
+// should be owned by.
+func getNodeOwnership(node ign3types.Node) (int, int, error) {
 	uid, gid := 0, 0 // default to root
 	var err error    // create default error var
-	if file.User.ID != nil {
-		uid = *file.User.ID
-	} else if file.User.Name != nil && *file.User.Name != "" {
-		uid, err = lookupUID(*file.User.Name)
+	if node.User.ID != nil {
+		uid = *node.User.ID
+	} else if node.User.Name != nil && *node.User.Name != "" {
+		uid, err = lookupUID(*node.User.Name)
 		if err != nil {
 			return uid, gid, err
 		}
 	}
 
-	if file.Group.ID != nil {
-		gid = *file.Group.ID
-	} else if file.Group.Name != nil && *file.Group.Name != "" {
-		gid, err = lookupGID(*file.Group.Name)
+	if node.Group.ID != nil {
+		gid = *node.Group.ID
+	} else if node.Group.Name != nil && *node.Group.Name != "" {
+		gid, err = lookupGID(*node.Group.Name)
 		if err != nil {
 			return uid, gid, err
 		}
@@ -2,16 +2,20 @@ package daemon
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
 	// Enable sha256 in container image references
 	_ "crypto/sha256"
 
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
 	"github.com/openshift/machine-config-operator/pkg/daemon/osrelease"
 	"github.com/openshift/machine-config-operator/pkg/daemon/pivot/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog/v2"
 )
 
@@ -177,3 +181,164 @@ func UpdateTuningArgs(tuningFilePath, cmdLinePath string) error {
 	}
 	return nil
 }
+
+// liveKernelArgSysctls maps the kernel arguments which have a sysctl
+// equivalent to its path under /proc/sys.
+var liveKernelArgSysctls = map[string]string{
+	"panic":            "kernel/panic",
+	"panic_on_warn":    "kernel/panic_on_warn",
+	"nmi_watchdog":     "kernel/nmi_watchdog",
+	"hung_task_panic":  "kernel/hung_task_panic",
+	"softlockup_panic": "kernel/softlockup_panic",
+}
+
+var (
+	// procSysPath and sysModulePath are variables so tests can override them
+	procSysPath   = "/proc/sys"
+	sysModulePath = "/sys/module"
+)
+
+// liveKernelArg is a kernel argument value together with the file which
+// applies it at runtime.
+type liveKernelArg struct {
+	arg   string
+	path  string
+	value string
+}
+
+// liveKernelArgPath returns the file which applies the kernel argument key at
+// runtime. Besides the known sysctls, module parameters can be applied through
+// /sys/module if the module allows changing them.
+func liveKernelArgPath(key string) (string, bool) {
+	if sysctl, ok := liveKernelArgSysctls[key]; ok {
+		return filepath.Join(procSysPath, sysctl), true
+	}
+
+	module, param, ok := strings.Cut(key, ".")
+	if !ok || module == "" || param == "" || strings.ContainsAny(key, "/") {
+		return "", false
+	}
+	// the kernel treats dashes and underscores in module names the same
+	path := filepath.Join(sysModulePath, strings.ReplaceAll(module, "-", "_"), "parameters", param)
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm()&0o200 == 0 {
+		return "", false
+	}
+	return path, true
+}
+
+// parseLiveKernelArgKeys parses the comma separated kernel argument keys of the
+// live kernel arguments annotation.
+func parseLiveKernelArgKeys(annotation string) sets.Set[string] {
+	keys := sets.New[string]()
+	for _, key := range strings.Split(annotation, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys.Insert(key)
+		}
+	}
+	return keys
+}
+
+// classifyLiveKernelArgs returns the runtime changes for a kernel argument
+// change, and whether every changed argument is in allowed and can be applied
+// at runtime. Removing an argument is only live when it is replaced by another
+// value of the same key, since the runtime value it should revert to isn't known.
+func classifyLiveKernelArgs(added, removed []string, allowed sets.Set[string]) ([]liveKernelArg, bool) {
+	if len(added) == 0 {
+		return nil, false
+	}
+
+	live := []liveKernelArg{}
+	addedKeys := sets.New[string]()
+	for _, arg := range added {
+		key, value, hasValue := strings.Cut(arg, "=")
+		// bare arguments have no runtime value, and a repeated key is ambiguous
+		if !hasValue || !allowed.Has(key) || addedKeys.Has(key) {
+			return nil, false
+		}
+		path, ok := liveKernelArgPath(key)
+		if !ok {
+			return nil, false
+		}
+		addedKeys.Insert(key)
+		live = append(live, liveKernelArg{arg: arg, path: path, value: value})
+	}
+
+	for _, arg := range removed {
+		key, _, _ := strings.Cut(arg, "=")
+		if !addedKeys.Has(key) {
+			return nil, false
+		}
+	}
+
+	return live, true
+}
+
+// applyLiveKernelArgs writes the values of the kernel arguments to their
+// runtime files. It returns the previous values, which can be passed back to
+// it to undo the change.
+func applyLiveKernelArgs(args []liveKernelArg) ([]liveKernelArg, error) {
+	previous := []liveKernelArg{}
+	for _, arg := range args {
+		content, err := os.ReadFile(arg.path)
+		if err != nil {
+			return previous, fmt.Errorf("failed to read current value of kernel argument %s: %w", arg.arg, err)
+		}
+		klog.Infof("Applying kernel argument %s at runtime", arg.arg)
+		if err := os.WriteFile(arg.path, []byte(arg.value), 0o644); err != nil {
+			return previous, fmt.Errorf("failed to apply kernel argument %s at runtime: %w", arg.arg, err)
+		}
+		previous = append(previous, liveKernelArg{arg: arg.arg, path: arg.path, value: strings.TrimSpace(string(content))})
+	}
+	return previous, nil
+}
+
+// liveKernelArgsState records the kernel arguments of the booted deployment
+// while the pending deployment carries kernel arguments which were applied at
+// runtime. It only applies to the boot it was written in.
+type liveKernelArgsState struct {
+	BootID                  string                    `json:"bootID"`
+	KernelArguments         []string                  `json:"kernelArguments"`
+	IgnitionKernelArguments ign3types.KernelArguments `json:"ignitionKernelArguments"`
+}
+
+// readLiveKernelArgsState returns the state written during the current boot,
+// or nil if there is none. A state left over from a previous boot is removed.
+func readLiveKernelArgsState(statePath, bootID string) (*liveKernelArgsState, error) {
+	content, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", statePath, err)
+	}
+
+	state := &liveKernelArgsState{}
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", statePath, err)
+	}
+	if state.BootID != bootID {
+		klog.Infof("Removing live kernel arguments state of a previous boot")
+		if err := os.Remove(statePath); err != nil {
+			return nil, fmt.Errorf("failed to remove %s: %w", statePath, err)
+		}
+		return nil, nil
+	}
+	return state, nil
+}
+
+// writeLiveKernelArgsState writes the state unless one was already written
+// during the current boot, since that one has the kernel arguments of the
+// booted deployment.
+func writeLiveKernelArgsState(statePath string, state *liveKernelArgsState) error {
+	existing, err := readLiveKernelArgsState(statePath, state.BootID)
+	if err != nil || existing != nil {
+		return err
+	}
+
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomicallyWithDefaults(statePath, content)
+}
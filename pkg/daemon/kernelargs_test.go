@@ -0,0 +1,216 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/daemon/disruption"
+	"github.com/openshift/machine-config-operator/pkg/daemon/osrelease"
+	"github.com/openshift/machine-config-operator/test/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// setupLiveKernelArgsDirs points the runtime kernel argument paths at a temp
+// dir with the panic sysctl and a writable and a read-only module parameter.
+func setupLiveKernelArgsDirs(t *testing.T) {
+	t.Helper()
+
+	testDir := t.TempDir()
+	oldProcSysPath, oldSysModulePath := procSysPath, sysModulePath
+	procSysPath = filepath.Join(testDir, "proc", "sys")
+	sysModulePath = filepath.Join(testDir, "sys", "module")
+	t.Cleanup(func() {
+		procSysPath, sysModulePath = oldProcSysPath, oldSysModulePath
+	})
+
+	for path, mode := range map[string]os.FileMode{
+		filepath.Join(procSysPath, "kernel", "panic"):                          0o644,
+		filepath.Join(sysModulePath, "nf_conntrack", "parameters", "hashsize"): 0o600,
+		filepath.Join(sysModulePath, "kvm", "parameters", "nx_huge_pages"):     0o444,
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte("0\n"), mode))
+	}
+}
+
+func TestLiveKernelArgPath(t *testing.T) {
+	setupLiveKernelArgsDirs(t)
+
+	path, ok := liveKernelArgPath("panic")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(procSysPath, "kernel", "panic"), path)
+
+	// dashes in module names are the same as underscores
+	path, ok = liveKernelArgPath("nf-conntrack.hashsize")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(sysModulePath, "nf_conntrack", "parameters", "hashsize"), path)
+
+	for _, key := range []string{"kvm.nx_huge_pages", "kvm.missing", "nosmt", ".hashsize", "nf_conntrack.", "../kvm.hashsize"} {
+		_, ok := liveKernelArgPath(key)
+		assert.False(t, ok, key)
+	}
+}
+
+func TestClassifyLiveKernelArgs(t *testing.T) {
+	setupLiveKernelArgsDirs(t)
+
+	allowed := sets.New[string]("panic", "nf_conntrack.hashsize", "kvm.nx_huge_pages", "nosmt")
+
+	testCases := []struct {
+		name     string
+		added    []string
+		removed  []string
+		expected []liveKernelArg
+		live     bool
+	}{
+		{
+			name:  "New sysctl argument",
+			added: []string{"panic=10"},
+			expected: []liveKernelArg{
+				{arg: "panic=10", path: filepath.Join(procSysPath, "kernel", "panic"), value: "10"},
+			},
+			live: true,
+		},
+		{
+			name:    "Changed module parameter",
+			added:   []string{"nf_conntrack.hashsize=131072"},
+			removed: []string{"nf_conntrack.hashsize=65536"},
+			expected: []liveKernelArg{
+				{arg: "nf_conntrack.hashsize=131072", path: filepath.Join(sysModulePath, "nf_conntrack", "parameters", "hashsize"), value: "131072"},
+			},
+			live: true,
+		},
+		{
+			name:  "Argument not allowed",
+			added: []string{"panic=10", "nmi_watchdog=0"},
+		},
+		{
+			name:  "Read-only module parameter",
+			added: []string{"kvm.nx_huge_pages=off"},
+		},
+		{
+			name:  "Bare argument",
+			added: []string{"nosmt"},
+		},
+		{
+			name:  "Repeated argument",
+			added: []string{"panic=10", "panic=20"},
+		},
+		{
+			name:    "Removed argument",
+			added:   []string{"panic=10"},
+			removed: []string{"nf_conntrack.hashsize=65536"},
+		},
+		{
+			name:    "Only removed arguments",
+			removed: []string{"panic=10"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			live, ok := classifyLiveKernelArgs(testCase.added, testCase.removed, allowed)
+			assert.Equal(t, testCase.live, ok)
+			assert.Equal(t, testCase.expected, live)
+		})
+	}
+
+	assert.Equal(t, sets.New[string]("panic", "nf_conntrack.hashsize"), parseLiveKernelArgKeys(" panic, nf_conntrack.hashsize,,"))
+	assert.Equal(t, 0, parseLiveKernelArgKeys("").Len())
+}
+
+func TestApplyLiveKernelArgs(t *testing.T) {
+	setupLiveKernelArgsDirs(t)
+
+	live, ok := classifyLiveKernelArgs([]string{"panic=10", "nf_conntrack.hashsize=131072"}, nil, sets.New[string]("panic", "nf_conntrack.hashsize"))
+	require.True(t, ok)
+
+	previous, err := applyLiveKernelArgs(live)
+	require.NoError(t, err)
+	for _, arg := range live {
+		content, err := os.ReadFile(arg.path)
+		require.NoError(t, err)
+		assert.Equal(t, arg.value, string(content))
+	}
+
+	// The previous values undo the change
+	_, err = applyLiveKernelArgs(previous)
+	require.NoError(t, err)
+	for _, arg := range live {
+		content, err := os.ReadFile(arg.path)
+		require.NoError(t, err)
+		assert.Equal(t, "0", string(content))
+	}
+}
+
+func TestLiveKernelArgsState(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "live-kernel-arguments.json")
+
+	state, err := readLiveKernelArgsState(statePath, "boot1")
+	require.NoError(t, err)
+	assert.Nil(t, state)
+
+	booted := &liveKernelArgsState{
+		BootID:                  "boot1",
+		KernelArguments:         []string{"panic=10"},
+		IgnitionKernelArguments: ign3types.KernelArguments{ShouldExist: []ign3types.KernelArgument{"nf_conntrack.hashsize=65536"}},
+	}
+	require.NoError(t, writeLiveKernelArgsState(statePath, booted))
+
+	// The first state of a boot has the kernel arguments of the booted deployment, so it is kept
+	require.NoError(t, writeLiveKernelArgsState(statePath, &liveKernelArgsState{BootID: "boot1", KernelArguments: []string{"panic=20"}}))
+	state, err = readLiveKernelArgsState(statePath, "boot1")
+	require.NoError(t, err)
+	assert.Equal(t, booted, state)
+
+	// A state from a previous boot is removed
+	state, err = readLiveKernelArgsState(statePath, "boot2")
+	require.NoError(t, err)
+	assert.Nil(t, state)
+	assert.NoFileExists(t, statePath)
+}
+
+func TestGetLiveKernelArgs(t *testing.T) {
+	setupLiveKernelArgsDirs(t)
+
+	d := newMockDaemon()
+	rhcos, err := osrelease.LoadOSRelease("ID=\"rhcos\"\nVERSION_ID=\"4.13\"\nRHEL_VERSION=\"9.0\"\n", "")
+	require.NoError(t, err)
+	d.os = rhcos
+	d.node = &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}}}
+
+	oldConfig := helpers.NewMachineConfigExtended("rendered-old", nil, nil, nil, nil, nil, nil, false, []string{"panic=10"}, "", "dummy://")
+	newConfig := helpers.NewMachineConfigExtended("rendered-new", nil, nil, nil, nil, nil, nil, false, []string{"panic=20"}, "", "dummy://")
+	oldIgnConfig, err := ctrlcommon.ParseAndConvertConfig(oldConfig.Spec.Config.Raw)
+	require.NoError(t, err)
+	newIgnConfig, err := ctrlcommon.ParseAndConvertConfig(newConfig.Spec.Config.Raw)
+	require.NoError(t, err)
+
+	diff, err := newMachineConfigDiff(oldConfig, newConfig)
+	require.NoError(t, err)
+	require.True(t, diff.Kargs)
+
+	// Nothing is applied at runtime unless the pool opts in
+	assert.Nil(t, d.getLiveKernelArgs(diff, oldConfig, newConfig, oldIgnConfig, newIgnConfig))
+
+	d.node.Annotations[constants.LiveKernelArgumentsAnnotationKey] = "panic"
+	live := d.getLiveKernelArgs(diff, oldConfig, newConfig, oldIgnConfig, newIgnConfig)
+	assert.Equal(t, []liveKernelArg{{arg: "panic=20", path: filepath.Join(procSysPath, "kernel", "panic"), value: "20"}}, live)
+
+	// Without the kernel argument change the diff needs no reboot
+	actionsDiff := *diff
+	actionsDiff.Kargs = false
+	assert.Equal(t, []string{disruption.PostConfigChangeActionNone}, disruption.CalculatePostConfigChangeActionFromMCDiff(&actionsDiff, nil))
+
+	// Other changes which need a reboot reboot anyway
+	diff.Extensions = true
+	assert.Nil(t, d.getLiveKernelArgs(diff, oldConfig, newConfig, oldIgnConfig, newIgnConfig))
+}
@@ -57,6 +57,12 @@ var (
 			Name: "mcd_config_drift",
 			Help: "timestamp for config drift",
 		})
+	// mcdConfigDriftRemediations counts config drift remediations by result
+	mcdConfigDriftRemediations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcd_config_drift_remediations_total",
+			Help: "Total number of config drift remediations attempted, by result.",
+		}, []string{"result"})
 	// mcdMissingMC tracks the missing machine config error
 	mcdMissingMC = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -92,6 +98,7 @@ func RegisterMCDMetrics() error {
 		mcdRebootErr,
 		mcdUpdateState,
 		mcdConfigDrift,
+		mcdConfigDriftRemediations,
 		unsupportedPackages,
 	})
 
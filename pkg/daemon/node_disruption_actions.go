@@ -0,0 +1,402 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	mcfgalphav1 "github.com/openshift/api/machineconfiguration/v1alpha1"
+	opv1 "github.com/openshift/api/operator/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/daemon/disruption"
+	"github.com/openshift/machine-config-operator/pkg/upgrademonitor"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// builtinNodeDisruptionActions applies and verifies each built-in action for
+// the files changed by an update.
+var builtinNodeDisruptionActions = map[opv1.NodeDisruptionPolicyStatusActionType]func(changedFiles []string) error{
+	disruption.ApplySysctlStatusAction: applySysctls,
+	disruption.LoadModulesStatusAction: func([]string) error { return loadModules() },
+	disruption.UdevReloadStatusAction:  func([]string) error { return reloadUdevRules() },
+}
+
+var (
+	// sysctlConfigDirs are the directories read by sysctl --system, in order
+	// of precedence. The first one is the directory managed by MachineConfigs.
+	// These and the paths below are variables so tests can override them.
+	sysctlConfigDirs = []string{constants.SysctlConfigDir, "/run/sysctl.d", "/usr/local/lib/sysctl.d", "/usr/lib/sysctl.d", "/lib/sysctl.d"}
+	// sysctlConfigFile is read by sysctl --system after all the directories.
+	sysctlConfigFile = "/etc/sysctl.conf"
+	modulesLoadDir   = constants.ModulesLoadConfigDir
+	libModulesPath   = "/lib/modules"
+)
+
+// executeBuiltinNodeDisruptionAction runs a built-in action, which fails
+// unless the node's runtime state matches the new configuration afterwards.
+func (dn *Daemon) executeBuiltinNodeDisruptionAction(actionType opv1.NodeDisruptionPolicyStatusActionType, changedFiles []string) error {
+	if err := builtinNodeDisruptionActions[actionType](changedFiles); err != nil {
+		if dn.nodeWriter != nil {
+			dn.nodeWriter.Eventf(corev1.EventTypeWarning, "FailedNodeDisruptionAction", fmt.Sprintf("Node disruption action %s failed. Error: %v", actionType, err))
+		}
+		return fmt.Errorf("could not apply update: %s failed. Error: %w", actionType, err)
+	}
+
+	err := upgrademonitor.GenerateAndApplyMachineConfigNodes(
+		&upgrademonitor.Condition{State: mcfgalphav1.MachineConfigNodeUpdatePostActionComplete, Reason: string(mcfgalphav1.MachineConfigNodeUpdateReloaded), Message: fmt.Sprintf("Node has completed action %s", actionType)},
+		&upgrademonitor.Condition{State: mcfgalphav1.MachineConfigNodeUpdateReloaded, Reason: fmt.Sprintf("%s%s", string(mcfgalphav1.MachineConfigNodeUpdatePostActionComplete), string(mcfgalphav1.MachineConfigNodeUpdateReloaded)), Message: fmt.Sprintf("Upgrade required action %s. Completed this as a post update action.", actionType)},
+		metav1.ConditionTrue,
+		metav1.ConditionTrue,
+		dn.node,
+		dn.mcfgClient,
+		dn.featureGatesAccessor,
+	)
+	if err != nil {
+		klog.Errorf("Error making MCN for %s success: %v", actionType, err)
+	}
+
+	if dn.nodeWriter != nil {
+		dn.nodeWriter.Eventf(corev1.EventTypeNormal, "NodeDisruptionAction", "Config changes do not require reboot. Action %s was completed.", actionType)
+	}
+	logSystem("%s completed successfully!", actionType)
+	return nil
+}
+
+// applySysctls applies the settings of the changed files of the MachineConfig
+// managed sysctl directory and verifies that they are in effect. Unlike
+// sysctl --system, it leaves all other settings alone, so values set at runtime,
+// e.g. by tuned, are not reset. The settings of removed files keep their value
+// until the next reboot.
+func applySysctls(changedFiles []string) error {
+	settings, err := readSysctlConfig()
+	if err != nil {
+		return fmt.Errorf("could not read sysctl configuration: %w", err)
+	}
+
+	keys, err := changedSysctlKeys(changedFiles, settings)
+	if err != nil {
+		return fmt.Errorf("could not read sysctl configuration: %w", err)
+	}
+
+	for _, key := range keys {
+		setting := settings[key]
+		paths, err := sysctlKeyPaths(key)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			if err := writeSysctl(path, setting.value); err != nil {
+				if os.IsNotExist(err) && setting.optional {
+					continue
+				}
+				return fmt.Errorf("could not set sysctl %s from %s: %w", key, setting.file, err)
+			}
+		}
+		klog.Infof("Set sysctl %s = %s from %s", key, setting.value, setting.file)
+	}
+
+	return verifySysctls(settings, keys)
+}
+
+// changedSysctlKeys returns the keys set by the changed files of the
+// MachineConfig managed sysctl directory which are not overridden by a later
+// file.
+func changedSysctlKeys(changedFiles []string, settings map[string]sysctlSetting) ([]string, error) {
+	keys := []string{}
+	for _, file := range changedFiles {
+		if filepath.Dir(file) != sysctlConfigDirs[0] || !strings.HasSuffix(file, ".conf") {
+			continue
+		}
+		fileSettings, err := readSysctlFile(file)
+		if os.IsNotExist(err) {
+			klog.Infof("Sysctl configuration %s was removed, its settings keep their value until the next reboot", file)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for key := range fileSettings {
+			if settings[key].file == file {
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// writeSysctl sets the value of a /proc/sys file, which must exist.
+func writeSysctl(path, value string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(value); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// sysctlSetting is the value of a sysctl key as configured on disk.
+type sysctlSetting struct {
+	value string
+	file  string
+	// optional settings start with "-" and are skipped if the key doesn't exist
+	optional bool
+}
+
+// readSysctlConfig returns the settings sysctl --system applies, following
+// the ordering and overriding rules of sysctl.d(5).
+func readSysctlConfig() (map[string]sysctlSetting, error) {
+	files := map[string]string{}
+	for _, dir := range sysctlConfigDirs {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			// A file in a directory earlier in the list masks files with the same name
+			if _, ok := files[entry.Name()]; ok || entry.IsDir() || !strings.HasSuffix(entry.Name(), ".conf") {
+				continue
+			}
+			files[entry.Name()] = filepath.Join(dir, entry.Name())
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	paths := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		paths = append(paths, files[name])
+	}
+	paths = append(paths, sysctlConfigFile)
+
+	settings := map[string]sysctlSetting{}
+	for _, path := range paths {
+		fileSettings, err := readSysctlFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for key, setting := range fileSettings {
+			settings[key] = setting
+		}
+	}
+	return settings, nil
+}
+
+// readSysctlFile returns the settings of a sysctl.d(5) file.
+func readSysctlFile(path string) (map[string]sysctlSetting, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := map[string]sysctlSetting{}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		optional := strings.HasPrefix(key, "-")
+		key = strings.TrimPrefix(key, "-")
+		settings[key] = sysctlSetting{value: strings.TrimSpace(value), file: path, optional: optional}
+	}
+	return settings, nil
+}
+
+// sysctlKeyPath returns the /proc/sys file of a sysctl key. As in sysctl.d(5),
+// a key whose first separator is a dot uses dots and slashes swapped.
+func sysctlKeyPath(key string) string {
+	if i := strings.IndexAny(key, "./"); i >= 0 && key[i] == '.' {
+		key = strings.Map(func(r rune) rune {
+			switch r {
+			case '.':
+				return '/'
+			case '/':
+				return '.'
+			}
+			return r
+		}, key)
+	}
+	return filepath.Join(procSysPath, key)
+}
+
+// sysctlKeyPaths returns the /proc/sys files a sysctl key applies to. Glob
+// keys apply to all the files they match.
+func sysctlKeyPaths(key string) ([]string, error) {
+	if !strings.ContainsAny(key, "*?[") {
+		return []string{sysctlKeyPath(key)}, nil
+	}
+	paths, err := filepath.Glob(sysctlKeyPath(key))
+	if err != nil {
+		return nil, fmt.Errorf("invalid sysctl key %s: %w", key, err)
+	}
+	return paths, nil
+}
+
+// verifySysctls checks that the running kernel has the configured values of
+// the given sysctl keys.
+func verifySysctls(settings map[string]sysctlSetting, keys []string) error {
+	mismatched := []string{}
+	for _, key := range keys {
+		setting := settings[key]
+		paths, err := sysctlKeyPaths(key)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			content, err := os.ReadFile(path)
+			if os.IsNotExist(err) && setting.optional {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("could not read sysctl %s from %s: %w", key, setting.file, err)
+			}
+			// Multi-value settings are read back separated by tabs
+			if strings.Join(strings.Fields(string(content)), " ") != strings.Join(strings.Fields(setting.value), " ") {
+				// Glob keys are reported with the file they matched
+				name := key
+				if strings.ContainsAny(key, "*?[") {
+					name = strings.TrimPrefix(path, procSysPath+"/")
+				}
+				mismatched = append(mismatched, fmt.Sprintf("%s=%q (expected %q)", name, strings.TrimSpace(string(content)), setting.value))
+			}
+		}
+	}
+	if len(mismatched) != 0 {
+		sort.Strings(mismatched)
+		return fmt.Errorf("sysctls not applied: %v", mismatched)
+	}
+	return nil
+}
+
+// readModulesLoadConfig returns the kernel modules listed in the MachineConfig
+// managed modules-load.d directory.
+func readModulesLoadConfig() ([]string, error) {
+	entries, err := os.ReadDir(modulesLoadDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	modules := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".conf") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(modulesLoadDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+				continue
+			}
+			module := strings.ReplaceAll(line, "-", "_")
+			if !ctrlcommon.InSlice(module, modules) {
+				modules = append(modules, module)
+			}
+		}
+	}
+	return modules, nil
+}
+
+// loadModules loads the kernel modules of the modules-load.d configuration and
+// verifies that they are loaded. Modules removed from the configuration stay
+// loaded until the next reboot, as with systemd-modules-load.
+func loadModules() error {
+	modules, err := readModulesLoadConfig()
+	if err != nil {
+		return fmt.Errorf("could not read modules-load.d configuration: %w", err)
+	}
+	if len(modules) == 0 {
+		return nil
+	}
+
+	if err := runCmdSync("modprobe", append([]string{"-a"}, modules...)...); err != nil {
+		return err
+	}
+	return verifyModules(modules)
+}
+
+// verifyModules checks that the kernel modules are loaded or built into the
+// running kernel.
+func verifyModules(modules []string) error {
+	var builtin []string
+	notLoaded := []string{}
+	for _, module := range modules {
+		if _, err := os.Stat(filepath.Join(sysModulePath, module)); err == nil {
+			continue
+		}
+
+		// Built-in modules without parameters have no entry in /sys/module
+		if builtin == nil {
+			var err error
+			if builtin, err = readBuiltinModules(); err != nil {
+				return err
+			}
+		}
+		if !ctrlcommon.InSlice(module, builtin) {
+			notLoaded = append(notLoaded, module)
+		}
+	}
+	if len(notLoaded) != 0 {
+		return fmt.Errorf("kernel modules not loaded: %v", notLoaded)
+	}
+	return nil
+}
+
+// readBuiltinModules returns the modules built into the running kernel.
+func readBuiltinModules() ([]string, error) {
+	release, err := os.ReadFile(filepath.Join(procSysPath, "kernel", "osrelease"))
+	if err != nil {
+		return nil, fmt.Errorf("could not get kernel release: %w", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(libModulesPath, strings.TrimSpace(string(release)), "modules.builtin"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read built-in kernel modules: %w", err)
+	}
+
+	builtin := []string{}
+	for _, line := range strings.Fields(string(content)) {
+		module := strings.TrimSuffix(filepath.Base(line), ".ko")
+		builtin = append(builtin, strings.ReplaceAll(module, "-", "_"))
+	}
+	return builtin, nil
+}
+
+// reloadUdevRules reloads the udev rules, replays the device events so the
+// new rules apply to existing devices and waits for udev to process them.
+func reloadUdevRules() error {
+	if err := runCmdSync("udevadm", "control", "--reload"); err != nil {
+		return err
+	}
+	if err := runCmdSync("udevadm", "trigger", "--action=change"); err != nil {
+		return err
+	}
+	return runCmdSync("udevadm", "settle", "--timeout=120")
+}
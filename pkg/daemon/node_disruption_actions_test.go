@@ -0,0 +1,130 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupSysctlDirs points the sysctl configuration and /proc/sys at a temp dir
+// and writes the given files, relative to it.
+func setupSysctlDirs(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	testDir := t.TempDir()
+	oldSysctlConfigDirs, oldSysctlConfigFile, oldProcSysPath := sysctlConfigDirs, sysctlConfigFile, procSysPath
+	sysctlConfigDirs = []string{filepath.Join(testDir, "etc", "sysctl.d"), filepath.Join(testDir, "usr", "lib", "sysctl.d")}
+	sysctlConfigFile = filepath.Join(testDir, "etc", "sysctl.conf")
+	procSysPath = filepath.Join(testDir, "proc", "sys")
+	t.Cleanup(func() {
+		sysctlConfigDirs, sysctlConfigFile, procSysPath = oldSysctlConfigDirs, oldSysctlConfigFile, oldProcSysPath
+	})
+
+	for path, content := range files {
+		path = filepath.Join(testDir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	return testDir
+}
+
+func TestApplySysctls(t *testing.T) {
+	testDir := setupSysctlDirs(t, map[string]string{
+		"etc/sysctl.d/99-foo.conf":              "# comment\nnet.ipv4.ip_forward = 1\nnet.ipv4.ip_local_port_range = 32768 60999\n-kernel.missing = 1\nnet.ipv4.conf.*.rp_filter = 2\n",
+		"etc/sysctl.d/10-masked.conf":           "kernel.panic = 10\n",
+		"usr/lib/sysctl.d/10-masked.conf":       "kernel.panic = 20\n",
+		"usr/lib/sysctl.d/50-os.conf":           "kernel.sysrq = 16\n",
+		"proc/sys/net/ipv4/ip_forward":          "0\n",
+		"proc/sys/net/ipv4/ip_local_port_range": "1024\t65535\n",
+		"proc/sys/net/ipv4/conf/all/rp_filter":  "0\n",
+		"proc/sys/net/ipv4/conf/eth0/rp_filter": "0\n",
+		"proc/sys/kernel/panic":                 "5\n",
+		"proc/sys/kernel/sysrq":                 "0\n",
+	})
+
+	settings, err := readSysctlConfig()
+	require.NoError(t, err)
+	assert.Equal(t, sysctlSetting{value: "10", file: filepath.Join(sysctlConfigDirs[0], "10-masked.conf")}, settings["kernel.panic"])
+	assert.True(t, settings["kernel.missing"].optional)
+
+	readProcSys := func(path string) string {
+		content, err := os.ReadFile(filepath.Join(testDir, "proc/sys", path))
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	changedFiles := []string{
+		filepath.Join(sysctlConfigDirs[0], "99-foo.conf"),
+		filepath.Join(sysctlConfigDirs[0], "50-removed.conf"),
+		"/etc/chrony.conf",
+	}
+	require.NoError(t, applySysctls(changedFiles))
+	assert.Equal(t, "1", readProcSys("net/ipv4/ip_forward"))
+	assert.Equal(t, "32768 60999", readProcSys("net/ipv4/ip_local_port_range"))
+	assert.Equal(t, "2", readProcSys("net/ipv4/conf/all/rp_filter"))
+	assert.Equal(t, "2", readProcSys("net/ipv4/conf/eth0/rp_filter"))
+	// Settings of unchanged files and of the OS keep their runtime value
+	assert.Equal(t, "5\n", readProcSys("kernel/panic"))
+	assert.Equal(t, "0\n", readProcSys("kernel/sysrq"))
+
+	keys, err := changedSysctlKeys(changedFiles, settings)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kernel.missing", "net.ipv4.conf.*.rp_filter", "net.ipv4.ip_forward", "net.ipv4.ip_local_port_range"}, keys)
+
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "proc/sys/net/ipv4/ip_forward"), []byte("0\n"), 0o644))
+	assert.ErrorContains(t, verifySysctls(settings, keys), "net.ipv4.ip_forward")
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "proc/sys/net/ipv4/conf/eth0/rp_filter"), []byte("1\n"), 0o644))
+	assert.ErrorContains(t, verifySysctls(settings, keys), "net/ipv4/conf/eth0/rp_filter")
+
+	// A later file overrides the setting, so it is left alone
+	require.NoError(t, os.WriteFile(sysctlConfigFile, []byte("net.ipv4.ip_forward = 0\n"), 0o644))
+	settings, err = readSysctlConfig()
+	require.NoError(t, err)
+	keys, err = changedSysctlKeys(changedFiles, settings)
+	require.NoError(t, err)
+	assert.NotContains(t, keys, "net.ipv4.ip_forward")
+}
+
+func TestSysctlKeyPath(t *testing.T) {
+	setupSysctlDirs(t, nil)
+
+	assert.Equal(t, filepath.Join(procSysPath, "net/ipv4/ip_forward"), sysctlKeyPath("net.ipv4.ip_forward"))
+	assert.Equal(t, filepath.Join(procSysPath, "net/ipv4/conf/eth0.100/rp_filter"), sysctlKeyPath("net.ipv4.conf.eth0/100.rp_filter"))
+	assert.Equal(t, filepath.Join(procSysPath, "net/ipv4/conf/eth0.100/rp_filter"), sysctlKeyPath("net/ipv4/conf/eth0.100/rp_filter"))
+}
+
+func TestVerifyModules(t *testing.T) {
+	testDir := t.TempDir()
+	oldModulesLoadDir, oldProcSysPath, oldSysModulePath, oldLibModulesPath := modulesLoadDir, procSysPath, sysModulePath, libModulesPath
+	modulesLoadDir = filepath.Join(testDir, "etc", "modules-load.d")
+	procSysPath = filepath.Join(testDir, "proc", "sys")
+	sysModulePath = filepath.Join(testDir, "sys", "module")
+	libModulesPath = filepath.Join(testDir, "lib", "modules")
+	t.Cleanup(func() {
+		modulesLoadDir, procSysPath, sysModulePath, libModulesPath = oldModulesLoadDir, oldProcSysPath, oldSysModulePath, oldLibModulesPath
+	})
+
+	for path, content := range map[string]string{
+		"etc/modules-load.d/foo.conf":                       "# comment\nbr-netfilter\n; comment\nnf_conntrack\n",
+		"etc/modules-load.d/bar.conf":                       "nf_conntrack\nvfio\n",
+		"proc/sys/kernel/osrelease":                         "5.14.0-427.el9.x86_64\n",
+		"lib/modules/5.14.0-427.el9.x86_64/modules.builtin": "kernel/drivers/vfio/vfio.ko\n",
+		"sys/module/br_netfilter/refcnt":                    "0\n",
+	} {
+		path = filepath.Join(testDir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	modules, err := readModulesLoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"nf_conntrack", "vfio", "br_netfilter"}, modules)
+
+	assert.EqualError(t, verifyModules(modules), "kernel modules not loaded: [nf_conntrack]")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(sysModulePath, "nf_conntrack"), 0o755))
+	require.NoError(t, verifyModules(modules))
+}
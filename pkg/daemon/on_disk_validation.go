@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 
 	ign2types "github.com/coreos/ignition/config/v2_2/types"
 	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
@@ -15,8 +17,9 @@ import (
 	"k8s.io/klog/v2"
 )
 
-// Validates that the on-disk state matches a given MachineConfig.
-func validateOnDiskState(currentConfig *mcfgv1.MachineConfig, systemdPath string) error {
+// Validates that the on-disk state matches a given MachineConfig, skipping the
+// paths matched by exclusions.
+func validateOnDiskState(currentConfig *mcfgv1.MachineConfig, systemdPath string, exclusions configDriftExclusions) error {
 	// And the rest of the disk state
 	// We want to verify the disk state in the spec version that it was created with,
 	// to remove possibilities of behaviour changes due to translation
@@ -27,18 +30,26 @@ func validateOnDiskState(currentConfig *mcfgv1.MachineConfig, systemdPath string
 
 	switch typedConfig := ignconfigi.(type) {
 	case ign3types.Config:
-		if err := checkV3Files(ignconfigi.(ign3types.Config).Storage.Files); err != nil {
+		ignConfig := exclusions.filterIgn3Config(typedConfig, getSystemdPath(systemdPath))
+		if err := checkV3Files(ignConfig.Storage.Files); err != nil {
 			return &fileConfigDriftErr{err}
 		}
-		if err := checkV3Units(ignconfigi.(ign3types.Config).Systemd.Units, systemdPath); err != nil {
+		if err := checkV3Directories(ignConfig.Storage.Directories); err != nil {
+			return &directoryConfigDriftErr{err}
+		}
+		if err := checkV3Links(ignConfig.Storage.Links); err != nil {
+			return &linkConfigDriftErr{err}
+		}
+		if err := checkV3Units(ignConfig.Systemd.Units, systemdPath); err != nil {
 			return &unitConfigDriftErr{err}
 		}
 		return nil
 	case ign2types.Config:
-		if err := checkV2Files(ignconfigi.(ign2types.Config).Storage.Files); err != nil {
+		ignConfig := exclusions.filterIgn2Config(typedConfig, getSystemdPath(systemdPath))
+		if err := checkV2Files(ignConfig.Storage.Files); err != nil {
 			return &fileConfigDriftErr{err}
 		}
-		if err := checkV2Units(ignconfigi.(ign2types.Config).Systemd.Units, systemdPath); err != nil {
+		if err := checkV2Units(ignConfig.Systemd.Units, systemdPath); err != nil {
 			return &unitConfigDriftErr{err}
 		}
 		return nil
@@ -47,6 +58,29 @@ func validateOnDiskState(currentConfig *mcfgv1.MachineConfig, systemdPath string
 	}
 }
 
+// getDriftedFilesAndUnits returns the paths of the files and the names of the
+// units in ignConfig whose on-disk state does not match it, skipping the paths
+// matched by exclusions.
+func getDriftedFilesAndUnits(ignConfig ign3types.Config, systemdPath string, exclusions configDriftExclusions) (files, units []string) {
+	ignConfig = exclusions.filterIgn3Config(ignConfig, getSystemdPath(systemdPath))
+
+	for _, f := range ignConfig.Storage.Files {
+		if err := checkV3Files([]ign3types.File{f}); err != nil {
+			klog.V(4).Infof("File %s has drifted: %v", f.Path, err)
+			files = append(files, f.Path)
+		}
+	}
+
+	for _, unit := range ignConfig.Systemd.Units {
+		if err := checkV3Unit(unit, systemdPath); err != nil {
+			klog.V(4).Infof("Unit %s has drifted: %v", unit.Name, err)
+			units = append(units, unit.Name)
+		}
+	}
+
+	return files, units
+}
+
 // Checks that the ondisk state for a systemd dropin matches the expected state.
 func checkV3Dropin(systemdPath string, unit ign3types.Unit, dropin ign3types.Dropin) error {
 	path := getIgn3SystemdDropinPath(systemdPath, unit, dropin)
@@ -80,6 +114,10 @@ func checkV3Unit(unit ign3types.Unit, systemdPath string) error {
 		}
 	}
 
+	if err := checkV3UnitEnablement(unit, systemdPath); err != nil {
+		return &unitEnablementConfigDriftErr{err}
+	}
+
 	if unit.Contents == nil || *unit.Contents == "" {
 		// Return early if the contents are empty.
 		return nil
@@ -199,10 +237,175 @@ func checkV3Files(files []ign3types.File) error {
 		if err := checkFileContentsAndMode(f.Path, contents, mode); err != nil {
 			return err
 		}
+		if err := checkNodeOwnership(f.Node); err != nil {
+			return &ownershipConfigDriftErr{err}
+		}
+	}
+	return nil
+}
+
+// checkV3Directories validates that all the directories in the target config
+// exist with the expected mode and ownership.
+func checkV3Directories(dirs []ign3types.Directory) error {
+	for _, d := range dirs {
+		fi, err := os.Lstat(d.Path)
+		if err != nil {
+			return fmt.Errorf("could not stat directory %q: %w", d.Path, err)
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("expected %q to be a directory, found %v", d.Path, fi.Mode().Type())
+		}
+		// Ignition only applies the mode when it creates the directory, so
+		// only an explicitly requested mode is checked.
+		if d.Mode != nil && fi.Mode().Perm() != os.FileMode(*d.Mode).Perm() {
+			return fmt.Errorf("mode mismatch for directory: %q; expected: %#o; received: %#o", d.Path, os.FileMode(*d.Mode).Perm(), fi.Mode().Perm())
+		}
+		if err := checkNodeOwnership(d.Node); err != nil {
+			return &ownershipConfigDriftErr{err}
+		}
+	}
+	return nil
+}
+
+// checkV3Links validates that all the links in the target config exist and
+// point to the expected targets.
+func checkV3Links(links []ign3types.Link) error {
+	for _, l := range links {
+		if l.Target == nil {
+			continue
+		}
+		target := *l.Target
+
+		if l.Hard != nil && *l.Hard {
+			linkInfo, err := os.Lstat(l.Path)
+			if err != nil {
+				return fmt.Errorf("could not stat hard link %q: %w", l.Path, err)
+			}
+			targetInfo, err := os.Lstat(target)
+			if err != nil {
+				return fmt.Errorf("could not stat target %q of hard link %q: %w", target, l.Path, err)
+			}
+			if !os.SameFile(linkInfo, targetInfo) {
+				return fmt.Errorf("hard link %q does not point to %q", l.Path, target)
+			}
+			continue
+		}
+
+		fi, err := os.Lstat(l.Path)
+		if err != nil {
+			return fmt.Errorf("could not stat symlink %q: %w", l.Path, err)
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("expected %q to be a symlink, found %v", l.Path, fi.Mode().Type())
+		}
+		actual, err := os.Readlink(l.Path)
+		if err != nil {
+			return fmt.Errorf("could not read symlink %q: %w", l.Path, err)
+		}
+		if actual != target {
+			return fmt.Errorf("symlink target mismatch for %q; expected: %q; received: %q", l.Path, target, actual)
+		}
 	}
 	return nil
 }
 
+// checkNodeOwnership validates that the file or directory of an Ignition node
+// is owned by the expected user and group. An ID of -1 is not checked.
+func checkNodeOwnership(node ign3types.Node) error {
+	uid, gid, err := getNodeOwnership(node)
+	if err != nil {
+		return fmt.Errorf("could not get the expected ownership of %q: %w", node.Path, err)
+	}
+	fi, err := os.Lstat(node.Path)
+	if err != nil {
+		return fmt.Errorf("could not stat %q: %w", node.Path, err)
+	}
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if (uid != -1 && int(stat.Uid) != uid) || (gid != -1 && int(stat.Gid) != gid) {
+		return fmt.Errorf("ownership mismatch for %q; expected: %d:%d; received: %d:%d", node.Path, uid, gid, stat.Uid, stat.Gid)
+	}
+	return nil
+}
+
+// checkV3UnitEnablement validates that a unit whose enablement is set in the
+// target config has the enablement symlinks systemctl creates from the
+// WantedBy= and RequiredBy= directives of its [Install] section. Units
+// without such directives, template units and masked units are not checked.
+func checkV3UnitEnablement(unit ign3types.Unit, systemdPath string) error {
+	if unit.Enabled == nil || (unit.Mask != nil && *unit.Mask) {
+		return nil
+	}
+
+	for _, link := range getUnitEnablementLinks(unit, systemdPath) {
+		_, err := os.Lstat(link)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not stat %q: %w", link, err)
+		}
+		exists := err == nil
+		if *unit.Enabled && !exists {
+			return fmt.Errorf("unit %q is expected to be enabled but %q is missing", unit.Name, link)
+		}
+		if !*unit.Enabled && exists {
+			return fmt.Errorf("unit %q is expected to be disabled but %q exists", unit.Name, link)
+		}
+	}
+	return nil
+}
+
+// getUnitEnablementLinks returns the paths of the symlinks systemctl creates
+// when enabling unit. The [Install] section is read from the unit contents in
+// the target config, or else from the unit file on disk.
+func getUnitEnablementLinks(unit ign3types.Unit, systemdPath string) []string {
+	if strings.Contains(unit.Name, "@.") {
+		return nil
+	}
+
+	var contents string
+	if unit.Contents != nil && *unit.Contents != "" {
+		contents = *unit.Contents
+	} else {
+		for _, path := range []string{getIgn3SystemdUnitPath(systemdPath, unit), filepath.Join(usrPath, "lib/systemd/system", unit.Name)} {
+			if b, err := os.ReadFile(path); err == nil {
+				contents = string(b)
+				break
+			}
+		}
+	}
+
+	links := []string{}
+	inInstall := false
+	for _, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inInstall = line == "[Install]"
+			continue
+		}
+		if !inInstall {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		var suffix string
+		switch strings.TrimSpace(key) {
+		case "WantedBy":
+			suffix = ".wants"
+		case "RequiredBy":
+			suffix = ".requires"
+		default:
+			continue
+		}
+		for _, target := range strings.Fields(value) {
+			links = append(links, filepath.Join(getSystemdPath(systemdPath), target+suffix, unit.Name))
+		}
+	}
+	return links
+}
+
 // checkV2Files validates the contents of all the files in the target config.
 func checkV2Files(files []ign2types.File) error {
 	checkedFiles := make(map[string]bool)
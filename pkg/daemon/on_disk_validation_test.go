@@ -0,0 +1,174 @@
+package daemon
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestCheckNodeOwnership(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(path, []byte("contents"), 0o644))
+
+	uid, gid := os.Getuid(), os.Getgid()
+	node := func(uid, gid int) ign3types.Node {
+		return ign3types.Node{
+			Path:  path,
+			User:  ign3types.NodeUser{ID: helpers.IntToPtr(uid)},
+			Group: ign3types.NodeGroup{ID: helpers.IntToPtr(gid)},
+		}
+	}
+
+	assert.NoError(t, checkNodeOwnership(node(uid, gid)))
+	assert.NoError(t, checkNodeOwnership(node(-1, -1)))
+	assert.Error(t, checkNodeOwnership(node(uid+1, gid)))
+	assert.Error(t, checkNodeOwnership(node(-1, gid+1)))
+
+	file := helpers.CreateEncodedIgn3File(path, "contents", 0o644)
+	file.Node = node(uid+1, gid)
+	err := checkV3Files([]ign3types.File{file})
+	var ownershipErr *ownershipConfigDriftErr
+	assert.True(t, errors.As(err, &ownershipErr), "expected an ownership error, got %v", err)
+}
+
+func TestCheckV3Directories(t *testing.T) {
+	tmpDir := t.TempDir()
+	dirPath := filepath.Join(tmpDir, "dir")
+	filePath := filepath.Join(tmpDir, "file")
+	require.NoError(t, os.Mkdir(dirPath, 0o700))
+	require.NoError(t, os.Chmod(dirPath, 0o700))
+	require.NoError(t, os.WriteFile(filePath, nil, 0o644))
+
+	dir := func(path string, mode *int) ign3types.Directory {
+		return ign3types.Directory{
+			Node: ign3types.Node{
+				Path:  path,
+				User:  ign3types.NodeUser{ID: helpers.IntToPtr(-1)},
+				Group: ign3types.NodeGroup{ID: helpers.IntToPtr(-1)},
+			},
+			DirectoryEmbedded1: ign3types.DirectoryEmbedded1{Mode: mode},
+		}
+	}
+
+	assert.NoError(t, checkV3Directories([]ign3types.Directory{dir(dirPath, nil)}))
+	assert.NoError(t, checkV3Directories([]ign3types.Directory{dir(dirPath, helpers.IntToPtr(0o700))}))
+	assert.Error(t, checkV3Directories([]ign3types.Directory{dir(dirPath, helpers.IntToPtr(0o755))}))
+	assert.Error(t, checkV3Directories([]ign3types.Directory{dir(filePath, nil)}))
+	assert.Error(t, checkV3Directories([]ign3types.Directory{dir(filepath.Join(tmpDir, "missing"), nil)}))
+}
+
+func TestCheckV3Links(t *testing.T) {
+	tmpDir := t.TempDir()
+	target := filepath.Join(tmpDir, "target")
+	other := filepath.Join(tmpDir, "other")
+	symlink := filepath.Join(tmpDir, "symlink")
+	hardlink := filepath.Join(tmpDir, "hardlink")
+	require.NoError(t, os.WriteFile(target, nil, 0o644))
+	require.NoError(t, os.WriteFile(other, nil, 0o644))
+	require.NoError(t, os.Symlink(target, symlink))
+	require.NoError(t, os.Link(target, hardlink))
+
+	link := func(path, target string, hard bool) ign3types.Link {
+		return ign3types.Link{
+			Node:          ign3types.Node{Path: path},
+			LinkEmbedded1: ign3types.LinkEmbedded1{Target: helpers.StrToPtr(target), Hard: helpers.BoolToPtr(hard)},
+		}
+	}
+
+	assert.NoError(t, checkV3Links([]ign3types.Link{link(symlink, target, false), link(hardlink, target, true)}))
+	assert.Error(t, checkV3Links([]ign3types.Link{link(symlink, other, false)}))
+	assert.Error(t, checkV3Links([]ign3types.Link{link(hardlink, other, true)}))
+	assert.Error(t, checkV3Links([]ign3types.Link{link(target, other, false)}))
+	assert.Error(t, checkV3Links([]ign3types.Link{link(filepath.Join(tmpDir, "missing"), target, false)}))
+}
+
+func TestCheckV3UnitEnablement(t *testing.T) {
+	systemdPath := t.TempDir()
+	unit := ign3types.Unit{
+		Name:     "test.service",
+		Contents: helpers.StrToPtr("[Unit]\nDescription=test\n\n[Install]\nWantedBy=multi-user.target\nRequiredBy=a.target b.target\n"),
+	}
+
+	assert.Equal(t, []string{
+		filepath.Join(systemdPath, "multi-user.target.wants", "test.service"),
+		filepath.Join(systemdPath, "a.target.requires", "test.service"),
+		filepath.Join(systemdPath, "b.target.requires", "test.service"),
+	}, getUnitEnablementLinks(unit, systemdPath))
+
+	enabled, disabled := unit, unit
+	enabled.Enabled = helpers.BoolToPtr(true)
+	disabled.Enabled = helpers.BoolToPtr(false)
+
+	// Nothing is enabled yet.
+	assert.NoError(t, checkV3UnitEnablement(unit, systemdPath))
+	assert.NoError(t, checkV3UnitEnablement(disabled, systemdPath))
+	assert.Error(t, checkV3UnitEnablement(enabled, systemdPath))
+
+	for _, link := range getUnitEnablementLinks(unit, systemdPath) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(link), 0o755))
+		require.NoError(t, os.Symlink(getIgn3SystemdUnitPath(systemdPath, unit), link))
+	}
+
+	assert.NoError(t, checkV3UnitEnablement(enabled, systemdPath))
+	assert.Error(t, checkV3UnitEnablement(disabled, systemdPath))
+
+	// The unit file on disk is used for units without contents.
+	require.NoError(t, os.WriteFile(getIgn3SystemdUnitPath(systemdPath, unit), []byte(*unit.Contents), 0o644))
+	enabled.Contents = nil
+	assert.NoError(t, checkV3UnitEnablement(enabled, systemdPath))
+
+	// Units without an [Install] section and masked units are not checked.
+	noInstall := ign3types.Unit{Name: "other.service", Contents: helpers.StrToPtr("[Unit]\n"), Enabled: helpers.BoolToPtr(true)}
+	assert.NoError(t, checkV3UnitEnablement(noInstall, systemdPath))
+	disabled.Mask = helpers.BoolToPtr(true)
+	assert.NoError(t, checkV3UnitEnablement(disabled, systemdPath))
+
+	assert.NoError(t, checkV3Units([]ign3types.Unit{enabled}, systemdPath))
+	disabled.Mask = nil
+	err := checkV3Units([]ign3types.Unit{disabled}, systemdPath)
+	var enablementErr *unitEnablementConfigDriftErr
+	assert.True(t, errors.As(err, &enablementErr), "expected a unit enablement error, got %v", err)
+}
+
+func TestConfigDriftErrorsUnwrap(t *testing.T) {
+	tmpDir := t.TempDir()
+	systemdPath := filepath.Join(tmpDir, "systemd")
+
+	file := helpers.CreateEncodedIgn3File(filepath.Join(tmpDir, "file"), "contents", 0o644)
+	file.Node.User = ign3types.NodeUser{ID: helpers.IntToPtr(os.Getuid() + 1)}
+	file.Node.Group = ign3types.NodeGroup{ID: helpers.IntToPtr(os.Getgid())}
+	require.NoError(t, os.WriteFile(file.Path, []byte("contents"), 0o644))
+	require.NoError(t, os.Chmod(file.Path, 0o644))
+
+	unit := ign3types.Unit{
+		Name:     "test.service",
+		Contents: helpers.StrToPtr("[Unit]\n\n[Install]\nWantedBy=multi-user.target\n"),
+		Enabled:  helpers.BoolToPtr(true),
+	}
+
+	validate := func(ignConfig ign3types.Config) error {
+		ignConfig.Ignition.Version = ign3types.MaxVersion.String()
+		return &configDriftErr{validateOnDiskState(helpers.CreateMachineConfigFromIgnition(ignConfig), systemdPath, nil)}
+	}
+
+	// The specific drift is reachable through the generic config drift errors.
+	err := validate(ign3types.Config{Storage: ign3types.Storage{Files: []ign3types.File{file}}})
+	var fileErr *fileConfigDriftErr
+	var ownershipErr *ownershipConfigDriftErr
+	assert.ErrorAs(t, err, &fileErr)
+	assert.ErrorAs(t, err, &ownershipErr)
+
+	err = validate(ign3types.Config{Systemd: ign3types.Systemd{Units: []ign3types.Unit{unit}}})
+	var unitErr *unitConfigDriftErr
+	var enablementErr *unitEnablementConfigDriftErr
+	assert.ErrorAs(t, err, &unitErr)
+	assert.ErrorAs(t, err, &enablementErr)
+	assert.False(t, errors.As(err, &ownershipErr))
+}
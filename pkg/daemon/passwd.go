@@ -0,0 +1,273 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+)
+
+// isManagedAccountName reports whether the user or group name may be managed by
+// updateUsersAndGroups. The core user and group are handled by updateSSHKeys
+// and SetPasswordHash instead.
+func isManagedAccountName(name string) bool {
+	return name != "root" && name != constants.CoreUserName && name != constants.CoreGroupName
+}
+
+// checkManagedAccountID returns an error if the id of an existing user or
+// group belongs to a system account.
+func checkManagedAccountID(kind, name, id string) error {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return fmt.Errorf("could not parse id %q of %s %s: %w", id, kind, name, err)
+	}
+	if n < constants.ManagedAccountMinID || n > constants.ManagedAccountMaxID {
+		return fmt.Errorf("refusing to modify system %s %s with id %d", kind, name, n)
+	}
+	return nil
+}
+
+// updateUsersAndGroups creates, updates and removes the users and groups other
+// than core so that they match newPasswd. Entries which are the same in both
+// configs are left alone. Existing accounts whose uid or gid is outside of the
+// managed range are system accounts and are never modified.
+func (dn *Daemon) updateUsersAndGroups(newPasswd, oldPasswd ign3types.Passwd) error {
+	klog.Info("Updating users and groups")
+
+	oldGroups := map[string]ign3types.PasswdGroup{}
+	for _, g := range oldPasswd.Groups {
+		oldGroups[g.Name] = g
+	}
+	newGroups := map[string]struct{}{}
+	for _, g := range newPasswd.Groups {
+		newGroups[g.Name] = struct{}{}
+		if old, ok := oldGroups[g.Name]; !isManagedAccountName(g.Name) || (ok && reflect.DeepEqual(old, g)) {
+			continue
+		}
+		if err := ensureGroup(g); err != nil {
+			return err
+		}
+	}
+
+	oldUsers := map[string]ign3types.PasswdUser{}
+	for _, u := range oldPasswd.Users {
+		oldUsers[u.Name] = u
+	}
+	newUsers := map[string]struct{}{}
+	for _, u := range newPasswd.Users {
+		newUsers[u.Name] = struct{}{}
+		if old, ok := oldUsers[u.Name]; !isManagedAccountName(u.Name) || (ok && reflect.DeepEqual(old, u)) {
+			continue
+		}
+		if err := dn.ensureUser(u); err != nil {
+			return err
+		}
+	}
+
+	// users go first, since a group can't be removed while it is the primary group of a user
+	for _, u := range oldPasswd.Users {
+		if _, ok := newUsers[u.Name]; ok || !isManagedAccountName(u.Name) {
+			continue
+		}
+		if err := removeUser(u.Name); err != nil {
+			return err
+		}
+	}
+	for _, g := range oldPasswd.Groups {
+		if _, ok := newGroups[g.Name]; ok || !isManagedAccountName(g.Name) {
+			continue
+		}
+		if err := removeGroup(g.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// groupArgs returns the groupadd or groupmod arguments for g.
+func groupArgs(g ign3types.PasswdGroup) []string {
+	args := []string{}
+	if g.Gid != nil {
+		args = append(args, "-g", strconv.Itoa(*g.Gid))
+	}
+	if g.PasswordHash != nil {
+		args = append(args, "-p", *g.PasswordHash)
+	}
+	return append(args, g.Name)
+}
+
+// ensureGroup creates g, or updates it if it already exists.
+func ensureGroup(g ign3types.PasswdGroup) error {
+	cmd := "groupadd"
+	var uErr user.UnknownGroupError
+	switch existing, err := user.LookupGroup(g.Name); {
+	case err == nil:
+		if err := checkManagedAccountID("group", g.Name, existing.Gid); err != nil {
+			return err
+		}
+		cmd = "groupmod"
+	case errors.As(err, &uErr):
+	default:
+		return fmt.Errorf("failed to check if group %s exists: %w", g.Name, err)
+	}
+
+	klog.Infof("Configuring group %s with %s", g.Name, cmd)
+	return runPasswdCommand(cmd, groupArgs(g)...)
+}
+
+// userArgs returns the useradd or usermod arguments for u. The password hash
+// is set separately by SetPasswordHash.
+func userArgs(u ign3types.PasswdUser, exists bool) []string {
+	args := []string{}
+	if !exists {
+		args = append(args, "-m")
+	}
+	if u.UID != nil {
+		args = append(args, "-u", strconv.Itoa(*u.UID))
+	}
+	if u.PrimaryGroup != nil && *u.PrimaryGroup != "" {
+		args = append(args, "-g", *u.PrimaryGroup)
+	}
+	groups := []string{}
+	for _, g := range u.Groups {
+		groups = append(groups, string(g))
+	}
+	// usermod -G replaces the supplementary groups, so pass it even when empty
+	// to drop the groups which were removed from the config
+	if exists || len(groups) > 0 {
+		args = append(args, "-G", strings.Join(groups, ","))
+	}
+	if u.Shell != nil && *u.Shell != "" {
+		args = append(args, "-s", *u.Shell)
+	}
+	if u.Gecos != nil {
+		args = append(args, "-c", *u.Gecos)
+	}
+	if u.HomeDir != nil && *u.HomeDir != "" {
+		args = append(args, "-d", *u.HomeDir)
+	}
+	return append(args, u.Name)
+}
+
+// ensureUser creates u, or updates it if it already exists, and writes its SSH keys.
+func (dn *Daemon) ensureUser(u ign3types.PasswdUser) error {
+	cmd := "useradd"
+	exists := false
+	var uErr user.UnknownUserError
+	switch existing, err := user.Lookup(u.Name); {
+	case err == nil:
+		if err := checkManagedAccountID("user", u.Name, existing.Uid); err != nil {
+			return err
+		}
+		cmd = "usermod"
+		exists = true
+	case errors.As(err, &uErr):
+	default:
+		return fmt.Errorf("failed to check if user %s exists: %w", u.Name, err)
+	}
+
+	klog.Infof("Configuring user %s with %s", u.Name, cmd)
+	if err := runPasswdCommand(cmd, userArgs(u, exists)...); err != nil {
+		return err
+	}
+
+	return dn.writeUserSSHKeys(u)
+}
+
+// writeUserSSHKeys writes the SSH keys of a user other than core to its home
+// directory, at the same location updateSSHKeys uses for core.
+func (dn *Daemon) writeUserSSHKeys(u ign3types.PasswdUser) error {
+	osUser, err := user.Lookup(u.Name)
+	if err != nil {
+		return fmt.Errorf("failed to look up user %s: %w", u.Name, err)
+	}
+	uid, _ := strconv.Atoi(osUser.Uid)
+	gid, _ := strconv.Atoi(osUser.Gid)
+
+	authKeyPath := filepath.Join(osUser.HomeDir, ".ssh", "authorized_keys")
+	if dn.useNewSSHKeyPath() {
+		authKeyPath = filepath.Join(osUser.HomeDir, ".ssh", "authorized_keys.d", "ignition")
+	}
+
+	if len(u.SSHAuthorizedKeys) == 0 {
+		if err := os.Remove(authKeyPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove SSH keys of user %s: %w", u.Name, err)
+		}
+		return nil
+	}
+
+	// create the key directories owned by the user, since sshd refuses keys in
+	// directories other users can write to
+	for _, dir := range []string{filepath.Join(osUser.HomeDir, ".ssh"), filepath.Dir(authKeyPath)} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+		if err := os.Chown(dir, uid, gid); err != nil {
+			return fmt.Errorf("failed to set ownership of %s: %w", dir, err)
+		}
+	}
+
+	var keys strings.Builder
+	for _, k := range u.SSHAuthorizedKeys {
+		keys.WriteString(string(k) + "\n")
+	}
+	klog.Infof("Writing SSH keys of user %s to %q", u.Name, authKeyPath)
+	return writeFileAtomically(authKeyPath, []byte(keys.String()), os.FileMode(0o700), os.FileMode(0o600), uid, gid)
+}
+
+// removeUser deletes a user which was dropped from the config. Its home
+// directory is kept.
+func removeUser(name string) error {
+	var uErr user.UnknownUserError
+	existing, err := user.Lookup(name)
+	if errors.As(err, &uErr) {
+		klog.Infof("User %s was removed from the config but does not exist", name)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to check if user %s exists: %w", name, err)
+	}
+	if err := checkManagedAccountID("user", name, existing.Uid); err != nil {
+		return err
+	}
+
+	klog.Infof("Removing user %s", name)
+	return runPasswdCommand("userdel", name)
+}
+
+// removeGroup deletes a group which was dropped from the config.
+func removeGroup(name string) error {
+	var uErr user.UnknownGroupError
+	existing, err := user.LookupGroup(name)
+	if errors.As(err, &uErr) {
+		klog.Infof("Group %s was removed from the config but does not exist", name)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to check if group %s exists: %w", name, err)
+	}
+	if err := checkManagedAccountID("group", name, existing.Gid); err != nil {
+		return err
+	}
+
+	klog.Infof("Removing group %s", name)
+	return runPasswdCommand("groupdel", name)
+}
+
+// runPasswdCommand runs one of the shadow-utils commands. Unlike runCmdSync it
+// doesn't log the arguments, which may contain a password hash.
+func runPasswdCommand(cmd string, args ...string) error {
+	if out, err := exec.Command(cmd, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to run %s for %s: %s: %w", cmd, args[len(args)-1], out, err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package daemon
+
+import (
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestUserAndGroupArgs(t *testing.T) {
+	u := ign3types.PasswdUser{
+		Name:         "breakglass",
+		UID:          helpers.IntToPtr(5000),
+		PrimaryGroup: helpers.StrToPtr("breakglass"),
+		Groups:       []ign3types.Group{"auditors", "operators"},
+		Shell:        helpers.StrToPtr("/bin/bash"),
+		PasswordHash: helpers.StrToPtr("hash"),
+	}
+
+	assert.Equal(t, []string{"-m", "-u", "5000", "-g", "breakglass", "-G", "auditors,operators", "-s", "/bin/bash", "breakglass"}, userArgs(u, false))
+	assert.Equal(t, []string{"-u", "5000", "-g", "breakglass", "-G", "auditors,operators", "-s", "/bin/bash", "breakglass"}, userArgs(u, true))
+
+	// Supplementary groups are cleared when updating a user without any.
+	minimal := ign3types.PasswdUser{Name: "auditor"}
+	assert.Equal(t, []string{"-m", "auditor"}, userArgs(minimal, false))
+	assert.Equal(t, []string{"-G", "", "auditor"}, userArgs(minimal, true))
+
+	assert.Equal(t, []string{"-g", "5001", "auditors"}, groupArgs(ign3types.PasswdGroup{Name: "auditors", Gid: helpers.IntToPtr(5001)}))
+	assert.Equal(t, []string{"auditors"}, groupArgs(ign3types.PasswdGroup{Name: "auditors"}))
+}
+
+func TestManagedAccounts(t *testing.T) {
+	assert.True(t, isManagedAccountName("breakglass"))
+	assert.False(t, isManagedAccountName("core"))
+	assert.False(t, isManagedAccountName("root"))
+
+	assert.NoError(t, checkManagedAccountID("user", "breakglass", "1000"))
+	assert.NoError(t, checkManagedAccountID("user", "breakglass", "60000"))
+	assert.Error(t, checkManagedAccountID("user", "sshd", "74"))
+	assert.Error(t, checkManagedAccountID("user", "nobody", "65534"))
+	assert.Error(t, checkManagedAccountID("group", "auditors", "invalid"))
+}
+
+func TestUpdateUsersAndGroupsSkipsUnchangedAndCoreEntries(t *testing.T) {
+	d := newMockDaemon()
+
+	// Nothing is run for core, or for entries present unchanged in both configs.
+	passwd := ign3types.Passwd{
+		Users: []ign3types.PasswdUser{
+			{Name: "core", SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"1234"}},
+			{Name: "breakglass", UID: helpers.IntToPtr(5000)},
+		},
+		Groups: []ign3types.PasswdGroup{{Name: "auditors"}},
+	}
+	assert.NoError(t, d.updateUsersAndGroups(passwd, passwd))
+
+	core := ign3types.Passwd{Users: passwd.Users[:1]}
+	assert.NoError(t, d.updateUsersAndGroups(core, ign3types.Passwd{}))
+	assert.NoError(t, d.updateUsersAndGroups(ign3types.Passwd{}, core))
+}
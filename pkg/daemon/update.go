@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"reflect"
 	goruntime "runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -22,8 +23,8 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	kubeErrs "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
@@ -35,9 +36,9 @@ import (
 
 	opv1 "github.com/openshift/api/operator/v1"
 
-	"github.com/openshift/machine-config-operator/pkg/apihelpers"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/daemon/disruption"
 	pivottypes "github.com/openshift/machine-config-operator/pkg/daemon/pivot/types"
 	pivotutils "github.com/openshift/machine-config-operator/pkg/daemon/pivot/utils"
 	"github.com/openshift/machine-config-operator/pkg/daemon/runtimeassets"
@@ -53,17 +54,6 @@ const (
 	fipsFile                   = "/proc/sys/crypto/fips_enabled"
 	extensionsRepo             = "/etc/yum.repos.d/coreos-extensions.repo"
 	osExtensionsContentBaseDir = "/run/mco-extensions/"
-
-	// These are the actions for a node to take after applying config changes. (e.g. a new machineconfig is applied)
-	// "None" means no special action needs to be taken
-	// This happens for example when ssh keys or the pull secret (/var/lib/kubelet/config.json) is changed
-	postConfigChangeActionNone = "none"
-	// The "reload crio" action will run "systemctl reload crio"
-	postConfigChangeActionReloadCrio = "reload crio"
-	// The "restart crio" action will run "systemctl restart crio"
-	postConfigChangeActionRestartCrio = "restart crio"
-	// Rebooting is still the default scenario for any other change
-	postConfigChangeActionReboot = "reboot"
 )
 
 func getNodeRef(node *corev1.Node) *corev1.ObjectReference {
@@ -145,7 +135,7 @@ func (dn *Daemon) executeReloadServiceNodeDisruptionAction(serviceName string, r
 // For non-reboot action, it applies configuration, updates node's config and state.
 // In the end uncordon node to schedule workload.
 // If at any point an error occurs, we reboot the node so that node has correct configuration.
-func (dn *Daemon) performPostConfigChangeNodeDisruptionAction(postConfigChangeActions []opv1.NodeDisruptionPolicyStatusAction, configName string) error {
+func (dn *Daemon) performPostConfigChangeNodeDisruptionAction(postConfigChangeActions []opv1.NodeDisruptionPolicyStatusAction, changedFiles []string, configName string) error {
 	for _, action := range postConfigChangeActions {
 
 		// Drain is already completed at this stage and essentially a no-op for this loop, so no need to log that.
@@ -239,6 +229,12 @@ func (dn *Daemon) performPostConfigChangeNodeDisruptionAction(postConfigChangeAc
 			if err := dn.executeReloadServiceNodeDisruptionAction(constants.DaemonReloadCommand, reloadDaemon()); err != nil {
 				return err
 			}
+
+		case disruption.ApplySysctlStatusAction, disruption.LoadModulesStatusAction, disruption.UdevReloadStatusAction:
+			// Built-in actions for changes to sysctl, modules-load.d and udev rules files
+			if err := dn.executeBuiltinNodeDisruptionAction(action.Type, changedFiles); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -251,7 +247,7 @@ func (dn *Daemon) performPostConfigChangeNodeDisruptionAction(postConfigChangeAc
 // In the end uncordon node to schedule workload.
 // If at any point an error occurs, we reboot the node so that node has correct configuration.
 func (dn *Daemon) performPostConfigChangeAction(postConfigChangeActions []string, configName string) error {
-	if ctrlcommon.InSlice(postConfigChangeActionReboot, postConfigChangeActions) {
+	if ctrlcommon.InSlice(disruption.PostConfigChangeActionReboot, postConfigChangeActions) {
 		err := upgrademonitor.GenerateAndApplyMachineConfigNodes(
 			&upgrademonitor.Condition{State: mcfgalphav1.MachineConfigNodeUpdatePostActionComplete, Reason: string(mcfgalphav1.MachineConfigNodeUpdateRebooted), Message: fmt.Sprintf("Node will reboot into config %s", configName)},
 			&upgrademonitor.Condition{State: mcfgalphav1.MachineConfigNodeUpdateRebooted, Reason: fmt.Sprintf("%s%s", string(mcfgalphav1.MachineConfigNodeUpdatePostActionComplete), string(mcfgalphav1.MachineConfigNodeUpdateRebooted)), Message: "Upgrade requires a reboot. Currently doing this as the post update action."},
@@ -268,7 +264,7 @@ func (dn *Daemon) performPostConfigChangeAction(postConfigChangeActions []string
 		return dn.reboot(fmt.Sprintf("Node will reboot into config %s", configName))
 	}
 
-	if ctrlcommon.InSlice(postConfigChangeActionNone, postConfigChangeActions) {
+	if ctrlcommon.InSlice(disruption.PostConfigChangeActionNone, postConfigChangeActions) {
 		if dn.nodeWriter != nil {
 			dn.nodeWriter.Eventf(corev1.EventTypeNormal, "SkipReboot", "Config changes do not require reboot.")
 		}
@@ -287,7 +283,7 @@ func (dn *Daemon) performPostConfigChangeAction(postConfigChangeActions []string
 		logSystem("Node has Desired Config %s, skipping reboot", configName)
 	}
 
-	if ctrlcommon.InSlice(postConfigChangeActionReloadCrio, postConfigChangeActions) {
+	if ctrlcommon.InSlice(disruption.PostConfigChangeActionReloadCrio, postConfigChangeActions) {
 		serviceName := constants.CRIOServiceName
 
 		if err := reloadService(serviceName); err != nil {
@@ -316,7 +312,7 @@ func (dn *Daemon) performPostConfigChangeAction(postConfigChangeActions []string
 		logSystem("%s config reloaded successfully! Desired config %s has been applied, skipping reboot", serviceName, configName)
 	}
 
-	if ctrlcommon.InSlice(postConfigChangeActionRestartCrio, postConfigChangeActions) {
+	if ctrlcommon.InSlice(disruption.PostConfigChangeActionRestartCrio, postConfigChangeActions) {
 		cmd := exec.Command(constants.UpdateCATrustCommand)
 		var stderr bytes.Buffer
 		cmd.Stdout = os.Stdout
@@ -364,36 +360,16 @@ func setRunningKargs(config *mcfgv1.MachineConfig, requestedKargs []string) erro
 	return setRunningKargsWithCmdline(config, requestedKargs, rpmostreeKargsBytes)
 }
 
-func canonicalizeEmptyMC(config *mcfgv1.MachineConfig) *mcfgv1.MachineConfig {
-	if config != nil {
-		return config
-	}
-	newIgnCfg := ctrlcommon.NewIgnConfig()
-	rawNewIgnCfg, err := json.Marshal(newIgnCfg)
-	if err != nil {
-		// This should never happen
-		panic(err)
-	}
-	return &mcfgv1.MachineConfig{
-		ObjectMeta: metav1.ObjectMeta{Name: "mco-empty-mc"},
-		Spec: mcfgv1.MachineConfigSpec{
-			Config: runtime.RawExtension{
-				Raw: rawNewIgnCfg,
-			},
-		},
-	}
-}
-
-// return true if the machineConfigDiff is not empty
+// return true if the MachineConfigDiff is not empty
 func (dn *Daemon) compareMachineConfig(oldConfig, newConfig *mcfgv1.MachineConfig) (bool, error) {
-	oldConfig = canonicalizeEmptyMC(oldConfig)
+	oldConfig = disruption.CanonicalizeEmptyMC(oldConfig)
 	oldConfigName := oldConfig.GetName()
 	newConfigName := newConfig.GetName()
 	mcDiff, err := newMachineConfigDiff(oldConfig, newConfig)
 	if err != nil {
 		return true, fmt.Errorf("error creating machineConfigDiff for comparison: %w", err)
 	}
-	if mcDiff.isEmpty() {
+	if mcDiff.IsEmpty() {
 		logSystem("No changes from %s to %s", oldConfigName, newConfigName)
 		return false, nil
 	}
@@ -508,9 +484,9 @@ func removePendingDeployment() error {
 }
 
 // applyOSChanges extracts the OS image and adds coreos-extensions repo if we have either OS update or package layering to perform
-func (dn *CoreOSDaemon) applyOSChanges(mcDiff machineConfigDiff, oldConfig, newConfig *mcfgv1.MachineConfig) (retErr error) {
+func (dn *CoreOSDaemon) applyOSChanges(mcDiff disruption.MachineConfigDiff, oldConfig, newConfig *mcfgv1.MachineConfig) (retErr error) {
 	// We previously did not emit this event when kargs changed, so we still don't
-	if mcDiff.osUpdate || mcDiff.extensions || mcDiff.kernelType {
+	if mcDiff.OSUpdate || mcDiff.Extensions || mcDiff.KernelType {
 		// We emitted this event before, so keep it
 		if dn.nodeWriter != nil {
 			dn.nodeWriter.Eventf(corev1.EventTypeNormal, "InClusterUpgrade", fmt.Sprintf("Updating from oscontainer %s", newConfig.Spec.OSImageURL))
@@ -525,14 +501,14 @@ func (dn *CoreOSDaemon) applyOSChanges(mcDiff machineConfigDiff, oldConfig, newC
 	// to make sure we don't break that use case, but realtime kernel update and extensions update always ran
 	// if they were in use, so we also need to preserve that behavior.
 	// https://issues.redhat.com/browse/OCPBUGS-4049
-	if mcDiff.osUpdate || mcDiff.extensions || mcDiff.kernelType || mcDiff.kargs ||
-		canonicalizeKernelType(newConfig.Spec.KernelType) == ctrlcommon.KernelTypeRealtime ||
-		canonicalizeKernelType(newConfig.Spec.KernelType) == ctrlcommon.KernelType64kPages ||
+	if mcDiff.OSUpdate || mcDiff.Extensions || mcDiff.KernelType || mcDiff.Kargs ||
+		disruption.CanonicalizeKernelType(newConfig.Spec.KernelType) == ctrlcommon.KernelTypeRealtime ||
+		disruption.CanonicalizeKernelType(newConfig.Spec.KernelType) == ctrlcommon.KernelType64kPages ||
 		len(newConfig.Spec.Extensions) > 0 {
 
 		// Throw started/staged events only if there is any update required for the OS
 		if dn.nodeWriter != nil {
-			dn.nodeWriter.Eventf(corev1.EventTypeNormal, "OSUpdateStarted", mcDiff.osChangesString())
+			dn.nodeWriter.Eventf(corev1.EventTypeNormal, "OSUpdateStarted", mcDiff.OSChangesString())
 		}
 
 		if err := dn.applyLayeredOSChanges(mcDiff, oldConfig, newConfig); err != nil {
@@ -578,128 +554,7 @@ func (dn *CoreOSDaemon) applyOSChanges(mcDiff machineConfigDiff, oldConfig, newC
 	return nil
 }
 
-func calculatePostConfigChangeActionFromMCDiffs(diffFileSet []string) (actions []string) {
-	filesPostConfigChangeActionNone := []string{
-		caBundleFilePath,
-		constants.KubeletAuthFile,
-	}
-	directoriesPostConfigChangeActionNone := []string{
-		constants.OpenShiftNMStateConfigDir,
-	}
-	filesPostConfigChangeActionReloadCrio := []string{
-		constants.ContainerRegistryConfPath,
-		constants.GPGNoRebootPath,
-		constants.ContainerRegistryPolicyPath,
-	}
-	filesPostConfigChangeActionRestartCrio := []string{
-		constants.UserCABundlePath,
-	}
-	dirsPostConfigChangeActionReloadCrio := []string{
-		constants.SigstoreRegistriesConfigDir,
-	}
-
-	actions = []string{postConfigChangeActionNone}
-	for _, path := range diffFileSet {
-		switch {
-		case ctrlcommon.InSlice(path, filesPostConfigChangeActionNone):
-			continue
-
-		case ctrlcommon.InSlice(path, filesPostConfigChangeActionReloadCrio),
-			ctrlcommon.InSlice(filepath.Dir(path), dirsPostConfigChangeActionReloadCrio):
-			// Don't override a restart CRIO action
-			if !ctrlcommon.InSlice(postConfigChangeActionRestartCrio, actions) {
-				actions = []string{postConfigChangeActionReloadCrio}
-			}
-
-		case ctrlcommon.InSlice(path, filesPostConfigChangeActionRestartCrio):
-			actions = []string{postConfigChangeActionRestartCrio}
-
-		case ctrlcommon.InSlice(filepath.Dir(path), directoriesPostConfigChangeActionNone):
-			continue
-
-		default:
-			actions = []string{postConfigChangeActionReboot}
-			return actions
-		}
-	}
-	return actions
-}
-
-// calculatePostConfigChangeNodeDisruptionActionFromMCDiffs takes action based on the cluster's Node disruption policies.
-func calculatePostConfigChangeNodeDisruptionActionFromMCDiffs(diffSSH bool, diffFileSet, diffUnitSet []string, clusterPolicies opv1.NodeDisruptionPolicyClusterStatus) []opv1.NodeDisruptionPolicyStatusAction {
-	actions := []opv1.NodeDisruptionPolicyStatusAction{}
-
-	// Step through all file based policies, and build out the actions object
-	for _, diffPath := range diffFileSet {
-		pathFound, actionsFound := ctrlcommon.FindClosestFilePolicyPathMatch(diffPath, clusterPolicies.Files)
-		if pathFound {
-			klog.Infof("NodeDisruptionPolicy %v found for diff file %s", actionsFound, diffPath)
-			actions = append(actions, actionsFound...)
-		} else {
-			// If this file path has no policy defined, default to reboot
-			klog.V(4).Infof("no policy found for diff path %s", diffPath)
-			return []opv1.NodeDisruptionPolicyStatusAction{{
-				Type: opv1.RebootStatusAction,
-			}}
-		}
-	}
-
-	// Step through all unit based policies, and build out the actions object
-	for _, diffUnit := range diffUnitSet {
-		unitFound := false
-		for _, policyUnit := range clusterPolicies.Units {
-			klog.V(4).Infof("comparing policy unit name %s to diff unit name %s", string(policyUnit.Name), diffUnit)
-			if string(policyUnit.Name) == diffUnit {
-				klog.Infof("NodeDisruptionPolicy %v found for diff unit %s!", policyUnit.Actions, diffUnit)
-				actions = append(actions, policyUnit.Actions...)
-				unitFound = true
-				break
-			}
-		}
-		if !unitFound {
-			// If this unit has no policy defined, default to reboot
-			klog.V(4).Infof("no policy found for diff unit %s", diffUnit)
-			return []opv1.NodeDisruptionPolicyStatusAction{{
-				Type: opv1.RebootStatusAction,
-			}}
-		}
-	}
-
-	// SSH only has one possible policy(and there is a default), so blindly add that if there is an SSH diff
-	if diffSSH {
-		klog.Infof("SSH diff detected, applying SSH policy %v", clusterPolicies.SSHKey.Actions)
-		actions = append(actions, clusterPolicies.SSHKey.Actions...)
-	}
-
-	// If any of the actions need a reboot, then just return a single Reboot action
-	if apihelpers.CheckNodeDisruptionActionsForTargetActions(actions, opv1.RebootStatusAction) {
-		return []opv1.NodeDisruptionPolicyStatusAction{{
-			Type: opv1.RebootStatusAction,
-		}}
-	}
-
-	// If there is a "None" action in conjunction with other kinds of actions, strip out the "None" action elements as it is redundant
-	if apihelpers.CheckNodeDisruptionActionsForTargetActions(actions, opv1.NoneStatusAction) {
-		if apihelpers.CheckNodeDisruptionActionsForTargetActions(actions, opv1.DrainStatusAction, opv1.ReloadStatusAction, opv1.RestartStatusAction, opv1.DaemonReloadStatusAction, opv1.SpecialStatusAction) {
-			finalActions := []opv1.NodeDisruptionPolicyStatusAction{}
-			for _, action := range actions {
-				if action.Type != opv1.NoneStatusAction {
-					finalActions = append(finalActions, action)
-				}
-			}
-			return finalActions
-		}
-		// If we're here, this means that the action list has only "None" actions; return a single "None" Action
-		return []opv1.NodeDisruptionPolicyStatusAction{{
-			Type: opv1.NoneStatusAction,
-		}}
-	}
-
-	// If we're here, return as is - this means action list had zero "None" actions in the list
-	return actions
-}
-
-func calculatePostConfigChangeAction(diff *machineConfigDiff, diffFileSet []string) ([]string, error) {
+func calculatePostConfigChangeAction(diff *disruption.MachineConfigDiff, diffFileSet []string) ([]string, error) {
 	// If a machine-config-daemon-force file is present, it means the user wants to
 	// move to desired state without additional validation. We will reboot the node in
 	// this case regardless of what MachineConfig diff is.
@@ -708,43 +563,18 @@ func calculatePostConfigChangeAction(diff *machineConfigDiff, diffFileSet []stri
 			return []string{}, fmt.Errorf("failed to remove force validation file: %w", err)
 		}
 		klog.Infof("Setting post config change action to postConfigChangeActionReboot; %s present", constants.MachineConfigDaemonForceFile)
-		return []string{postConfigChangeActionReboot}, nil
+		return []string{disruption.PostConfigChangeActionReboot}, nil
 	}
 
-	if diff.osUpdate || diff.kargs || diff.fips || diff.units || diff.kernelType || diff.extensions {
-		// must reboot
-		return []string{postConfigChangeActionReboot}, nil
-	}
-
-	// Calculate actions based on file, unit and ssh diffs
-	return calculatePostConfigChangeActionFromMCDiffs(diffFileSet), nil
+	return disruption.CalculatePostConfigChangeActionFromMCDiff(diff, diffFileSet), nil
 }
 
 // calculatePostConfigChangeNodeDisruptionAction takes action based on the cluster's Node disruption policies.
-func (dn *Daemon) calculatePostConfigChangeNodeDisruptionAction(diff *machineConfigDiff, diffFileSet, diffUnitSet []string) ([]opv1.NodeDisruptionPolicyStatusAction, error) {
+func (dn *Daemon) calculatePostConfigChangeNodeDisruptionAction(diff *disruption.MachineConfigDiff, diffFileSet, diffUnitSet []string) ([]opv1.NodeDisruptionPolicyStatusAction, error) {
 
-	var mcop *opv1.MachineConfiguration
-	var pollErr error
-	// Wait for mcop.Status.NodeDisruptionPolicyStatus to populate, otherwise error out. This shouldn't take very long
-	// as this is done by the operator sync loop, but may be extended if transitioning to TechPreview as the operator restarts,
-	if err := wait.PollUntilContextTimeout(context.TODO(), 5*time.Second, 2*time.Minute, true, func(_ context.Context) (bool, error) {
-		mcop, pollErr = dn.mcopClient.OperatorV1().MachineConfigurations().Get(context.TODO(), ctrlcommon.MCOOperatorKnobsObjectName, metav1.GetOptions{})
-		if pollErr != nil {
-			klog.Errorf("calculating NodeDisruptionPolicies: MachineConfiguration/cluster has not been created yet")
-			pollErr = fmt.Errorf("MachineConfiguration/cluster has not been created yet")
-			return false, nil
-		}
-
-		// Ensure status.ObservedGeneration matches the last generation of MachineConfiguration
-		if mcop.Generation != mcop.Status.ObservedGeneration {
-			klog.Errorf("calculating NodeDisruptionPolicies: NodeDisruptionPolicyStatus is not up to date.")
-			pollErr = fmt.Errorf("NodeDisruptionPolicyStatus is not up to date")
-			return false, nil
-		}
-		return true, nil
-	}); err != nil {
-		klog.Errorf("NodeDisruptionPolicyStatus was not ready: %v", pollErr)
-		return nil, fmt.Errorf("NodeDisruptionPolicyStatus was not ready: %v", pollErr)
+	clusterPolicies, err := dn.getNodeDisruptionPolicyClusterStatus()
+	if err != nil {
+		return nil, err
 	}
 
 	// Continue policy calculation if no errors were encountered in fetching the policy.
@@ -762,22 +592,7 @@ func (dn *Daemon) calculatePostConfigChangeNodeDisruptionAction(diff *machineCon
 		}}, nil
 	}
 
-	if diff.osUpdate || diff.kargs || diff.fips || diff.kernelType || diff.extensions {
-		// must reboot
-		return []opv1.NodeDisruptionPolicyStatusAction{{
-			Type: opv1.RebootStatusAction,
-		}}, nil
-	}
-	if !diff.files && !diff.units && !diff.passwd {
-		// This is a diff which requires no actions
-		klog.Infof("No changes in files, units or SSH keys, no NodeDisruptionPolicies are in effect")
-		return []opv1.NodeDisruptionPolicyStatusAction{{
-			Type: opv1.NoneStatusAction,
-		}}, nil
-	}
-
-	// Calculate actions based on file, unit and ssh diffs
-	nodeDisruptionActions := calculatePostConfigChangeNodeDisruptionActionFromMCDiffs(diff.passwd, diffFileSet, diffUnitSet, mcop.Status.NodeDisruptionPolicyStatus.ClusterPolicies)
+	nodeDisruptionActions := disruption.CalculatePostConfigChangeNodeDisruptionActionFromMCDiff(diff, diffFileSet, diffUnitSet, *clusterPolicies)
 
 	// Print out node disruption actions for debug purposes
 	klog.Infof("Calculated node disruption actions:")
@@ -796,6 +611,36 @@ func (dn *Daemon) calculatePostConfigChangeNodeDisruptionAction(diff *machineCon
 
 }
 
+// getNodeDisruptionPolicyClusterStatus waits for the operator to populate the
+// cluster's node disruption policies and returns them.
+func (dn *Daemon) getNodeDisruptionPolicyClusterStatus() (*opv1.NodeDisruptionPolicyClusterStatus, error) {
+	var mcop *opv1.MachineConfiguration
+	var pollErr error
+	// Wait for mcop.Status.NodeDisruptionPolicyStatus to populate, otherwise error out. This shouldn't take very long
+	// as this is done by the operator sync loop, but may be extended if transitioning to TechPreview as the operator restarts,
+	if err := wait.PollUntilContextTimeout(context.TODO(), 5*time.Second, 2*time.Minute, true, func(_ context.Context) (bool, error) {
+		mcop, pollErr = dn.mcopClient.OperatorV1().MachineConfigurations().Get(context.TODO(), ctrlcommon.MCOOperatorKnobsObjectName, metav1.GetOptions{})
+		if pollErr != nil {
+			klog.Errorf("calculating NodeDisruptionPolicies: MachineConfiguration/cluster has not been created yet")
+			pollErr = fmt.Errorf("MachineConfiguration/cluster has not been created yet")
+			return false, nil
+		}
+
+		// Ensure status.ObservedGeneration matches the last generation of MachineConfiguration
+		if mcop.Generation != mcop.Status.ObservedGeneration {
+			klog.Errorf("calculating NodeDisruptionPolicies: NodeDisruptionPolicyStatus is not up to date.")
+			pollErr = fmt.Errorf("NodeDisruptionPolicyStatus is not up to date")
+			return false, nil
+		}
+		return true, nil
+	}); err != nil {
+		klog.Errorf("NodeDisruptionPolicyStatus was not ready: %v", pollErr)
+		return nil, fmt.Errorf("NodeDisruptionPolicyStatus was not ready: %v", pollErr)
+	}
+
+	return &mcop.Status.NodeDisruptionPolicyStatus.ClusterPolicies, nil
+}
+
 // This is another update function implementation for the special case of
 // on-cluster built images. It is necessary to perform certain steps
 // post-reboot since rpm-ostree will not write contents to the /home/core
@@ -805,7 +650,7 @@ func (dn *Daemon) calculatePostConfigChangeNodeDisruptionAction(diff *machineCon
 //
 //nolint:gocyclo
 func (dn *Daemon) updateOnClusterBuild(oldConfig, newConfig *mcfgv1.MachineConfig, oldImage, newImage string, skipCertificateWrite bool) (retErr error) {
-	oldConfig = canonicalizeEmptyMC(oldConfig)
+	oldConfig = disruption.CanonicalizeEmptyMC(oldConfig)
 
 	if dn.nodeWriter != nil {
 		state, err := getNodeAnnotationExt(dn.node, constants.MachineConfigDaemonStateAnnotationKey, true)
@@ -910,12 +755,30 @@ func (dn *Daemon) updateOnClusterBuild(oldConfig, newConfig *mcfgv1.MachineConfi
 		return err
 	}
 
+	// create, update and remove the users and groups other than core before
+	// their SSH keys and password hashes are set
+	if diff.Passwd {
+		if err := dn.updateUsersAndGroups(newIgnConfig.Passwd, oldIgnConfig.Passwd); err != nil {
+			return err
+		}
+
+		defer func() {
+			if retErr != nil {
+				if err := dn.updateUsersAndGroups(oldIgnConfig.Passwd, newIgnConfig.Passwd); err != nil {
+					errs := kubeErrs.NewAggregate([]error{err, retErr})
+					retErr = fmt.Errorf("error rolling back users and groups updates: %w", errs)
+					return
+				}
+			}
+		}()
+	}
+
 	// only update passwd if it has changed (do not nullify)
 	// we do not need to include SetPasswordHash in this, since only updateSSHKeys has issues on firstboot.
 	// For on-cluster builds, this needs to be performed here instead of during
 	// the image build process. This is bceause rpm-ostree will not touch files
 	// in /home/core. See: https://issues.redhat.com/browse/OCPBUGS-18458
-	if diff.passwd {
+	if diff.Passwd {
 		if err := dn.updateSSHKeys(newIgnConfig.Passwd.Users, oldIgnConfig.Passwd.Users); err != nil {
 			return err
 		}
@@ -948,9 +811,9 @@ func (dn *Daemon) updateOnClusterBuild(oldConfig, newConfig *mcfgv1.MachineConfi
 	}()
 
 	// Update the kernal args if there is a difference
-	if diff.kargs && dn.os.IsCoreOSVariant() {
+	if diff.Kargs && dn.os.IsCoreOSVariant() {
 		coreOSDaemon := CoreOSDaemon{dn}
-		if err := coreOSDaemon.updateKernelArguments(oldConfig.Spec.KernelArguments, newConfig.Spec.KernelArguments); err != nil {
+		if err := coreOSDaemon.updateKernelArguments(oldConfig, newConfig); err != nil {
 			return err
 		}
 	}
@@ -1046,7 +909,7 @@ func (dn *Daemon) finalizeRevertToNonLayering(newConfig *mcfgv1.MachineConfig) e
 //
 //nolint:gocyclo
 func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig, skipCertificateWrite bool) (retErr error) {
-	oldConfig = canonicalizeEmptyMC(oldConfig)
+	oldConfig = disruption.CanonicalizeEmptyMC(oldConfig)
 
 	if dn.nodeWriter != nil {
 		state, err := getNodeAnnotationExt(dn.node, constants.MachineConfigDaemonStateAnnotationKey, true)
@@ -1122,13 +985,23 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig, skipCertifi
 		}
 	}
 
+	// Kernel arguments which are applied at runtime don't need a reboot; their
+	// persistent change is staged for the next one.
+	actionsDiff := diff
+	liveKargs := dn.getLiveKernelArgs(diff, oldConfig, newConfig, oldIgnConfig, newIgnConfig)
+	if liveKargs != nil {
+		actionsDiff = &disruption.MachineConfigDiff{}
+		*actionsDiff = *diff
+		actionsDiff.Kargs = false
+	}
+
 	var nodeDisruptionActions []opv1.NodeDisruptionPolicyStatusAction
 	var actions []string
 	// If FeatureGateNodeDisruptionPolicy is set, calculate NodeDisruptionPolicy based actions for this MC diff
 	if fg != nil && fg.Enabled(features.FeatureGateNodeDisruptionPolicy) {
-		nodeDisruptionActions, err = dn.calculatePostConfigChangeNodeDisruptionAction(diff, diffFileSet, diffUnitSet)
+		nodeDisruptionActions, err = dn.calculatePostConfigChangeNodeDisruptionAction(actionsDiff, diffFileSet, diffUnitSet)
 	} else {
-		actions, err = calculatePostConfigChangeAction(diff, diffFileSet)
+		actions, err = calculatePostConfigChangeAction(actionsDiff, diffFileSet)
 	}
 
 	if err != nil {
@@ -1154,14 +1027,14 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig, skipCertifi
 	}
 	if fg != nil && fg.Enabled(features.FeatureGateNodeDisruptionPolicy) {
 		// Check actions list and perform node drain if required
-		drain, err = isDrainRequiredForNodeDisruptionActions(nodeDisruptionActions, oldIgnConfig, newIgnConfig, crioOverrideConfigmapExists)
+		drain, err = disruption.IsDrainRequiredForNodeDisruptionActions(nodeDisruptionActions, oldIgnConfig, newIgnConfig, crioOverrideConfigmapExists)
 		if err != nil {
 			return err
 		}
 		klog.Infof("Drain calculated for node disruption: %v for config %s", drain, newConfigName)
 	} else {
 		// Check and perform node drain if required
-		drain, err = isDrainRequired(actions, diffFileSet, oldIgnConfig, newIgnConfig, crioOverrideConfigmapExists)
+		drain, err = disruption.IsDrainRequired(actions, diffFileSet, oldIgnConfig, newIgnConfig, crioOverrideConfigmapExists)
 		if err != nil {
 			return err
 		}
@@ -1218,10 +1091,10 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig, skipCertifi
 	}
 
 	updatesNeeded := []string{"not", "not"}
-	if diff.passwd {
+	if diff.Passwd {
 		updatesNeeded[1] = ""
 	}
-	if diff.osUpdate || diff.extensions || diff.kernelType {
+	if diff.OSUpdate || diff.Extensions || diff.KernelType {
 		updatesNeeded[0] = ""
 	}
 
@@ -1258,9 +1131,27 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig, skipCertifi
 		return err
 	}
 
+	// create, update and remove the users and groups other than core before
+	// their SSH keys and password hashes are set
+	if diff.Passwd {
+		if err := dn.updateUsersAndGroups(newIgnConfig.Passwd, oldIgnConfig.Passwd); err != nil {
+			return err
+		}
+
+		defer func() {
+			if retErr != nil {
+				if err := dn.updateUsersAndGroups(oldIgnConfig.Passwd, newIgnConfig.Passwd); err != nil {
+					errs := kubeErrs.NewAggregate([]error{err, retErr})
+					retErr = fmt.Errorf("error rolling back users and groups updates: %w", errs)
+					return
+				}
+			}
+		}()
+	}
+
 	// only update passwd if it has changed (do not nullify)
 	// we do not need to include SetPasswordHash in this, since only updateSSHKeys has issues on firstboot.
-	if diff.passwd {
+	if diff.Passwd {
 		if err := dn.updateSSHKeys(newIgnConfig.Passwd.Users, oldIgnConfig.Passwd.Users); err != nil {
 			return err
 		}
@@ -1310,6 +1201,32 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig, skipCertifi
 		klog.Info("updating the OS on non-CoreOS nodes is not supported")
 	}
 
+	if liveKargs != nil {
+		previous, err := applyLiveKernelArgs(liveKargs)
+		// restore the values written before a failure as well
+		defer func() {
+			if retErr != nil {
+				if _, err := applyLiveKernelArgs(previous); err != nil {
+					errs := kubeErrs.NewAggregate([]error{err, retErr})
+					retErr = fmt.Errorf("error rolling back kernel arguments applied at runtime: %w", errs)
+					return
+				}
+			}
+		}()
+		if err != nil {
+			return err
+		}
+
+		state := &liveKernelArgsState{
+			BootID:                  dn.bootID,
+			KernelArguments:         oldConfig.Spec.KernelArguments,
+			IgnitionKernelArguments: oldIgnConfig.KernelArguments,
+		}
+		if err := writeLiveKernelArgsState(constants.LiveKernelArgumentsStateFilePath, state); err != nil {
+			return err
+		}
+	}
+
 	// Ideally we would want to update kernelArguments only via MachineConfigs.
 	// We are keeping this to maintain compatibility and OKD requirement.
 	if err := UpdateTuningArgs(KernelTuningFile, CmdLineFile); err != nil {
@@ -1351,7 +1268,7 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig, skipCertifi
 	}
 
 	if fg != nil && fg.Enabled(features.FeatureGateNodeDisruptionPolicy) {
-		return dn.performPostConfigChangeNodeDisruptionAction(nodeDisruptionActions, newConfig.GetName())
+		return dn.performPostConfigChangeNodeDisruptionAction(nodeDisruptionActions, diffFileSet, newConfig.GetName())
 	}
 	// If we're here, FeatureGateNodeDisruptionPolicy is off/errored, so perform legacy action
 	return dn.performPostConfigChangeAction(actions, newConfig.GetName())
@@ -1360,7 +1277,7 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig, skipCertifi
 // This is currently a subsection copied over from update() since we need to be more nuanced. Should eventually
 // de-dupe the functions.
 // See: https://issues.redhat.com/browse/MCO-810
-func (dn *Daemon) updateHypershift(oldConfig, newConfig *mcfgv1.MachineConfig, diff *machineConfigDiff) (retErr error) {
+func (dn *Daemon) updateHypershift(oldConfig, newConfig *mcfgv1.MachineConfig, diff *disruption.MachineConfigDiff) (retErr error) {
 	oldIgnConfig, err := ctrlcommon.ParseAndConvertConfig(oldConfig.Spec.Config.Raw)
 	if err != nil {
 		return fmt.Errorf("parsing old Ignition config failed: %w", err)
@@ -1386,6 +1303,24 @@ func (dn *Daemon) updateHypershift(oldConfig, newConfig *mcfgv1.MachineConfig, d
 		}
 	}()
 
+	// create, update and remove the users and groups other than core before
+	// their SSH keys and password hashes are set
+	if diff.Passwd {
+		if err := dn.updateUsersAndGroups(newIgnConfig.Passwd, oldIgnConfig.Passwd); err != nil {
+			return err
+		}
+
+		defer func() {
+			if retErr != nil {
+				if err := dn.updateUsersAndGroups(oldIgnConfig.Passwd, newIgnConfig.Passwd); err != nil {
+					errs := kubeErrs.NewAggregate([]error{err, retErr})
+					retErr = fmt.Errorf("error rolling back users and groups updates: %w", errs)
+					return
+				}
+			}
+		}()
+	}
+
 	if err := dn.updateSSHKeys(newIgnConfig.Passwd.Users, oldIgnConfig.Passwd.Users); err != nil {
 		return err
 	}
@@ -1441,87 +1376,15 @@ func (dn *Daemon) removeRollback() error {
 	return runRpmOstree("cleanup", "-r")
 }
 
-// machineConfigDiff represents an ad-hoc difference between two MachineConfig objects.
-// At some point this may change into holding just the files/units that changed
-// and the MCO would just operate on that.  For now we're just doing this to get
-// improved logging.
-type machineConfigDiff struct {
-	osUpdate   bool
-	kargs      bool
-	fips       bool
-	passwd     bool
-	files      bool
-	units      bool
-	kernelType bool
-	extensions bool
-}
-
-// isEmpty returns true if the machineConfigDiff has no changes, or
-// in other words if the two MachineConfig objects are equivalent from
-// the MCD's point of view.  This is mainly relevant if e.g. two MC
-// objects happen to have different Ignition versions but are otherwise
-// the same.  (Probably a better way would be to canonicalize)
-func (mcDiff *machineConfigDiff) isEmpty() bool {
-	emptyDiff := machineConfigDiff{}
-	return reflect.DeepEqual(mcDiff, &emptyDiff)
-}
-
-// osChangesString generates a human-readable set of changes from the diff
-func (mcDiff *machineConfigDiff) osChangesString() string {
-	changes := []string{}
-	if mcDiff.osUpdate {
-		changes = append(changes, "Upgrading OS")
-	}
-	if mcDiff.extensions {
-		changes = append(changes, "Installing extensions")
-	}
-	if mcDiff.kernelType {
-		changes = append(changes, "Changing kernel type")
-	}
-	if mcDiff.kargs {
-		changes = append(changes, "Changing kernel arguments")
-	}
-
-	return strings.Join(changes, "; ")
-}
-
-// canonicalizeKernelType returns a valid kernelType. We consider empty("") and default kernelType as same
-func canonicalizeKernelType(kernelType string) string {
-	if kernelType == ctrlcommon.KernelTypeRealtime {
-		return ctrlcommon.KernelTypeRealtime
-	} else if kernelType == ctrlcommon.KernelType64kPages {
-		return ctrlcommon.KernelType64kPages
-	}
-	return ctrlcommon.KernelTypeDefault
-}
-
-// newMachineConfigDiff compares two MachineConfig objects.
-func newMachineConfigDiff(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineConfigDiff, error) {
-	oldIgn, err := ctrlcommon.ParseAndConvertConfig(oldConfig.Spec.Config.Raw)
+// newMachineConfigDiff compares two MachineConfig objects. The presence of the
+// force file on the node is treated as an OS update.
+func newMachineConfigDiff(oldConfig, newConfig *mcfgv1.MachineConfig) (*disruption.MachineConfigDiff, error) {
+	mcDiff, err := disruption.DiffMachineConfigs(oldConfig, newConfig)
 	if err != nil {
-		return nil, fmt.Errorf("parsing old Ignition config failed with error: %w", err)
-	}
-	newIgn, err := ctrlcommon.ParseAndConvertConfig(newConfig.Spec.Config.Raw)
-	if err != nil {
-		return nil, fmt.Errorf("parsing new Ignition config failed with error: %w", err)
+		return nil, err
 	}
-
-	// Both nil and empty slices are of zero length,
-	// consider them as equal while comparing KernelArguments in both MachineConfigs
-	kargsEmpty := len(oldConfig.Spec.KernelArguments) == 0 && len(newConfig.Spec.KernelArguments) == 0
-	extensionsEmpty := len(oldConfig.Spec.Extensions) == 0 && len(newConfig.Spec.Extensions) == 0
-
-	force := forceFileExists()
-	return &machineConfigDiff{
-		osUpdate:   oldConfig.Spec.OSImageURL != newConfig.Spec.OSImageURL || force,
-		kargs:      !(kargsEmpty || reflect.DeepEqual(oldConfig.Spec.KernelArguments, newConfig.Spec.KernelArguments)),
-		fips:       oldConfig.Spec.FIPS != newConfig.Spec.FIPS,
-		passwd:     !reflect.DeepEqual(oldIgn.Passwd, newIgn.Passwd),
-		files:      !reflect.DeepEqual(oldIgn.Storage.Files, newIgn.Storage.Files),
-		units:      !reflect.DeepEqual(oldIgn.Systemd.Units, newIgn.Systemd.Units),
-		kernelType: canonicalizeKernelType(oldConfig.Spec.KernelType) != canonicalizeKernelType(newConfig.Spec.KernelType),
-		extensions: !(extensionsEmpty || reflect.DeepEqual(oldConfig.Spec.Extensions, newConfig.Spec.Extensions)),
-	}, nil
+	mcDiff.OSUpdate = mcDiff.OSUpdate || forceFileExists()
+	return mcDiff, nil
 }
 
 // reconcilable checks the configs to make sure that the only changes requested
@@ -1540,7 +1403,7 @@ func newMachineConfigDiff(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineC
 // underlying node filesystem and can inspect the FIPS file
 // (/proc/sys/crypto/fips_enabled) and can determine if there is a mismatch
 // between the MachineConfig and the actual on-disk state.
-func reconcilable(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineConfigDiff, error) {
+func reconcilable(oldConfig, newConfig *mcfgv1.MachineConfig) (*disruption.MachineConfigDiff, error) {
 	if err := ctrlcommon.IsRenderedConfigReconcilable(oldConfig, newConfig); err != nil {
 		return nil, fmt.Errorf("configs %s, %s are not reconcilable: %w", oldConfig.Name, newConfig.Name, err)
 	}
@@ -1689,9 +1552,71 @@ func generateKargs(oldKernelArguments, newKernelArguments []string) []string {
 	return cmdArgs
 }
 
-// updateKernelArguments adjusts the kernel args
-func (dn *CoreOSDaemon) updateKernelArguments(oldKernelArguments, newKernelArguments []string) error {
-	kargs := generateKargs(oldKernelArguments, newKernelArguments)
+// generateIgnitionKargs performs a diff between the kernelArguments sections of
+// the old/new Ignition configs, and generates the command line arguments suitable
+// for `rpm-ostree kargs`. Unlike the MC kernelArguments, they keep the semantics
+// Ignition gives them on firstboot: shouldExist arguments are appended if missing
+// and shouldNotExist arguments are deleted if present. shouldExist arguments which
+// were dropped from the config are deleted if present. Arguments which are also in
+// the new MC kernelArguments are left to generateKargs.
+func generateIgnitionKargs(oldIgnKargs, newIgnKargs ign3types.KernelArguments, newKernelArguments []string) []string {
+	specKargs := sets.New[string](parseKernelArguments(newKernelArguments)...)
+	newShouldExist := parseKernelArguments(ignKargsToStrings(newIgnKargs.ShouldExist))
+	newShouldExistSet := sets.New[string](newShouldExist...)
+	cmdArgs := []string{}
+
+	for _, arg := range parseKernelArguments(ignKargsToStrings(oldIgnKargs.ShouldExist)) {
+		if !newShouldExistSet.Has(arg) && !specKargs.Has(arg) {
+			cmdArgs = append(cmdArgs, "--delete-if-present="+arg)
+		}
+	}
+	for _, arg := range parseKernelArguments(ignKargsToStrings(newIgnKargs.ShouldNotExist)) {
+		cmdArgs = append(cmdArgs, "--delete-if-present="+arg)
+	}
+	for _, arg := range newShouldExist {
+		if !specKargs.Has(arg) {
+			cmdArgs = append(cmdArgs, "--append-if-missing="+arg)
+		}
+	}
+	return cmdArgs
+}
+
+func ignKargsToStrings(kargs []ign3types.KernelArgument) []string {
+	out := []string{}
+	for _, karg := range kargs {
+		out = append(out, string(karg))
+	}
+	return out
+}
+
+// expectedKernelArguments returns the kernel arguments a node running mc must
+// have: its MC kernelArguments followed by the shouldExist arguments of its
+// Ignition config.
+func expectedKernelArguments(mc *mcfgv1.MachineConfig, ignConfig ign3types.Config) []string {
+	kargs := append([]string{}, mc.Spec.KernelArguments...)
+	return append(kargs, ignKargsToStrings(ignConfig.KernelArguments.ShouldExist)...)
+}
+
+// updateKernelArguments adjusts the kernel args for both the MC kernelArguments
+// and the kernelArguments section of the Ignition config.
+func (dn *CoreOSDaemon) updateKernelArguments(oldConfig, newConfig *mcfgv1.MachineConfig) error {
+	oldIgnConfig, err := ctrlcommon.ParseAndConvertConfig(oldConfig.Spec.Config.Raw)
+	if err != nil {
+		return fmt.Errorf("parsing old Ignition config failed: %w", err)
+	}
+	newIgnConfig, err := ctrlcommon.ParseAndConvertConfig(newConfig.Spec.Config.Raw)
+	if err != nil {
+		return fmt.Errorf("parsing new Ignition config failed: %w", err)
+	}
+
+	return runKernelArgumentsUpdate(oldConfig.Spec.KernelArguments, oldIgnConfig.KernelArguments, newConfig.Spec.KernelArguments, newIgnConfig.KernelArguments)
+}
+
+// runKernelArgumentsUpdate stages the change from the old MachineConfig and
+// Ignition kernel arguments to the new ones with rpm-ostree.
+func runKernelArgumentsUpdate(oldKargs []string, oldIgnKargs ign3types.KernelArguments, newKargs []string, newIgnKargs ign3types.KernelArguments) error {
+	kargs := generateKargs(oldKargs, newKargs)
+	kargs = append(kargs, generateIgnitionKargs(oldIgnKargs, newIgnKargs, newKargs)...)
 	if len(kargs) == 0 {
 		return nil
 	}
@@ -1701,37 +1626,99 @@ func (dn *CoreOSDaemon) updateKernelArguments(oldKernelArguments, newKernelArgum
 	return runRpmOstree(args...)
 }
 
-func (dn *Daemon) generateExtensionsArgs(oldConfig, newConfig *mcfgv1.MachineConfig) []string {
-	removed := []string{}
-	added := []string{}
+// restageLiveKernelArgs stages the change from the kernel arguments of the
+// booted deployment recorded in state to those of config, after the pending
+// deployment carrying kernel arguments applied at runtime was removed.
+func (dn *CoreOSDaemon) restageLiveKernelArgs(state *liveKernelArgsState, config *mcfgv1.MachineConfig) error {
+	ignConfig, err := ctrlcommon.ParseAndConvertConfig(config.Spec.Config.Raw)
+	if err != nil {
+		return fmt.Errorf("parsing Ignition config failed: %w", err)
+	}
+	return runKernelArgumentsUpdate(state.KernelArguments, state.IgnitionKernelArguments, config.Spec.KernelArguments, ignConfig.KernelArguments)
+}
 
-	oldExt := make(map[string]bool)
-	for _, ext := range oldConfig.Spec.Extensions {
-		oldExt[ext] = true
+// getLiveKernelArgs returns the runtime changes for the kernel argument changes
+// between oldConfig and newConfig if they are the only change which needs a
+// reboot and all of them are allowed by the live kernel arguments annotation
+// of the node and can be applied at runtime. Otherwise it returns nil.
+func (dn *Daemon) getLiveKernelArgs(diff *disruption.MachineConfigDiff, oldConfig, newConfig *mcfgv1.MachineConfig, oldIgnConfig, newIgnConfig ign3types.Config) []liveKernelArg {
+	if !diff.Kargs || diff.OSUpdate || diff.FIPS || diff.KernelType || diff.Extensions || dn.node == nil || !dn.os.IsCoreOSVariant() {
+		return nil
 	}
-	newExt := make(map[string]bool)
-	for _, ext := range newConfig.Spec.Extensions {
-		newExt[ext] = true
+	allowed := parseLiveKernelArgKeys(dn.node.Annotations[constants.LiveKernelArgumentsAnnotationKey])
+	if allowed.Len() == 0 {
+		return nil
 	}
 
-	for ext := range oldExt {
-		if !newExt[ext] {
-			removed = append(removed, ext)
+	added, removed := diffKernelArguments(expectedKernelArguments(oldConfig, oldIgnConfig), expectedKernelArguments(newConfig, newIgnConfig))
+	shouldNotExistAdded, _ := diffKernelArguments(ignKargsToStrings(oldIgnConfig.KernelArguments.ShouldNotExist), ignKargsToStrings(newIgnConfig.KernelArguments.ShouldNotExist))
+	live, ok := classifyLiveKernelArgs(added, append(removed, shouldNotExistAdded...), allowed)
+	if !ok {
+		klog.Infof("Kernel argument changes +%v -%v can not all be applied at runtime", added, removed)
+		return nil
+	}
+	return live
+}
+
+// diffKernelArguments returns the kernel arguments which are present in
+// newKernelArguments but not in oldKernelArguments, and vice versa.
+func diffKernelArguments(oldKernelArguments, newKernelArguments []string) (added, removed []string) {
+	oldKargs := parseKernelArguments(oldKernelArguments)
+	newKargs := parseKernelArguments(newKernelArguments)
+	return diffStringSlices(oldKargs, newKargs)
+}
+
+// diffExtensions returns the extensions which are enabled in newConfig but not
+// in oldConfig, and vice versa.
+func diffExtensions(oldConfig, newConfig *mcfgv1.MachineConfig) (added, removed []string) {
+	return diffStringSlices(oldConfig.Spec.Extensions, newConfig.Spec.Extensions)
+}
+
+// diffStringSlices returns the unique elements of newItems which are not in
+// oldItems and the unique elements of oldItems which are not in newItems,
+// preserving their order.
+func diffStringSlices(oldItems, newItems []string) (added, removed []string) {
+	added = []string{}
+	removed = []string{}
+
+	oldSet := sets.New[string](oldItems...)
+	newSet := sets.New[string](newItems...)
+
+	seen := sets.New[string]()
+	for _, item := range newItems {
+		if !oldSet.Has(item) && !seen.Has(item) {
+			added = append(added, item)
 		}
+		seen.Insert(item)
 	}
-	for ext := range newExt {
-		if !oldExt[ext] {
-			added = append(added, ext)
+	seen = sets.New[string]()
+	for _, item := range oldItems {
+		if !newSet.Has(item) && !seen.Has(item) {
+			removed = append(removed, item)
 		}
+		seen.Insert(item)
 	}
+	return added, removed
+}
 
-	// Supported extensions has package list info that is required
+// getExtensionsCatalogue returns the catalogue of the last extensions container
+// extracted by the daemon, or the default catalogue if there is none yet.
+func (dn *Daemon) getExtensionsCatalogue() ctrlcommon.ExtensionsCatalogue {
+	if dn.extensionsCatalogue == nil {
+		return ctrlcommon.DefaultExtensionsCatalogue()
+	}
+	return dn.extensionsCatalogue
+}
+
+func (dn *Daemon) generateExtensionsArgs(oldConfig, newConfig *mcfgv1.MachineConfig, extensions ctrlcommon.ExtensionsCatalogue) []string {
+	added, removed := diffExtensions(oldConfig, newConfig)
+
+	// The extensions catalogue has package list info that is required
 	// to enable an extension
 
 	extArgs := []string{"update"}
 
 	if dn.os.IsEL() {
-		extensions := getSupportedExtensions()
 		for _, ext := range added {
 			for _, pkg := range extensions[ext] {
 				extArgs = append(extArgs, "--install", pkg)
@@ -1761,40 +1748,7 @@ func (dn *Daemon) generateExtensionsArgs(oldConfig, newConfig *mcfgv1.MachineCon
 	return extArgs
 }
 
-// Returns list of extensions possible to install on a CoreOS based system.
-func getSupportedExtensions() map[string][]string {
-	// In future when list of extensions grow, it will make
-	// more sense to populate it in a dynamic way.
-
-	// These are RHCOS supported extensions.
-	// Each extension keeps a list of packages required to get enabled on host.
-	return map[string][]string{
-		"wasm":                 {"crun-wasm"},
-		"ipsec":                {"NetworkManager-libreswan", "libreswan"},
-		"usbguard":             {"usbguard"},
-		"kerberos":             {"krb5-workstation", "libkadm5"},
-		"kernel-devel":         {"kernel-devel", "kernel-headers"},
-		"sandboxed-containers": {"kata-containers"},
-		"sysstat":              {"sysstat"},
-	}
-}
-
-func validateExtensions(exts []string) error {
-	supportedExtensions := getSupportedExtensions()
-	invalidExts := []string{}
-	for _, ext := range exts {
-		if _, ok := supportedExtensions[ext]; !ok {
-			invalidExts = append(invalidExts, ext)
-		}
-	}
-	if len(invalidExts) != 0 {
-		return fmt.Errorf("invalid extensions found: %v", invalidExts)
-	}
-	return nil
-
-}
-
-func (dn *CoreOSDaemon) applyExtensions(oldConfig, newConfig *mcfgv1.MachineConfig) error {
+func (dn *CoreOSDaemon) applyExtensions(oldConfig, newConfig *mcfgv1.MachineConfig, extensions ctrlcommon.ExtensionsCatalogue) error {
 	extensionsEmpty := len(oldConfig.Spec.Extensions) == 0 && len(newConfig.Spec.Extensions) == 0
 	if (extensionsEmpty) ||
 		(reflect.DeepEqual(oldConfig.Spec.Extensions, newConfig.Spec.Extensions) && oldConfig.Spec.OSImageURL == newConfig.Spec.OSImageURL) {
@@ -1802,11 +1756,11 @@ func (dn *CoreOSDaemon) applyExtensions(oldConfig, newConfig *mcfgv1.MachineConf
 	}
 
 	// Validate extensions allowlist on RHCOS nodes
-	if err := validateExtensions(newConfig.Spec.Extensions); err != nil && dn.os.IsEL() {
+	if err := extensions.Validate(newConfig.Spec.Extensions); err != nil && dn.os.IsEL() {
 		return err
 	}
 
-	args := dn.generateExtensionsArgs(oldConfig, newConfig)
+	args := dn.generateExtensionsArgs(oldConfig, newConfig, extensions)
 	klog.Infof("Applying extensions : %+q", args)
 	return runRpmOstree(args...)
 }
@@ -1820,8 +1774,8 @@ func (dn *CoreOSDaemon) switchKernel(oldConfig, newConfig *mcfgv1.MachineConfig)
 		return nil
 	}
 
-	oldKtype := canonicalizeKernelType(oldConfig.Spec.KernelType)
-	newKtype := canonicalizeKernelType(newConfig.Spec.KernelType)
+	oldKtype := disruption.CanonicalizeKernelType(oldConfig.Spec.KernelType)
+	newKtype := disruption.CanonicalizeKernelType(newConfig.Spec.KernelType)
 
 	// In the OS update path, we removed overrides for kernel-rt.  So if the target (new) config
 	// is also default (i.e. throughput) then we have nothing to do.
@@ -1870,7 +1824,8 @@ func (dn *CoreOSDaemon) switchKernel(oldConfig, newConfig *mcfgv1.MachineConfig)
 }
 
 // updateFiles writes files specified by the nodeconfig to disk. it also writes
-// systemd units. there is no support for multiple filesystems at this point.
+// directories, links and systemd units. there is no support for multiple
+// filesystems at this point.
 //
 // in addition to files, we also write systemd units to disk. we mask, enable,
 // and disable unit files when appropriate. this function relies on the system
@@ -1888,9 +1843,15 @@ func (dn *CoreOSDaemon) switchKernel(oldConfig, newConfig *mcfgv1.MachineConfig)
 // touched.
 func (dn *Daemon) updateFiles(oldIgnConfig, newIgnConfig ign3types.Config, skipCertificateWrite bool) error {
 	klog.Info("Updating files")
+	if err := dn.writeDirectories(newIgnConfig.Storage.Directories); err != nil {
+		return err
+	}
 	if err := dn.writeFiles(newIgnConfig.Storage.Files, skipCertificateWrite); err != nil {
 		return err
 	}
+	if err := dn.writeLinks(newIgnConfig.Storage.Links); err != nil {
+		return err
+	}
 	if err := dn.writeUnits(newIgnConfig.Systemd.Units); err != nil {
 		return err
 	}
@@ -2117,6 +2078,14 @@ func (dn *Daemon) deleteStaleData(oldIgnConfig, newIgnConfig ign3types.Config) e
 		}
 	}
 
+	if err := deleteStaleLinks(oldIgnConfig, newIgnConfig); err != nil {
+		return err
+	}
+	// directories go last, once the stale files and links in them are gone
+	if err := deleteStaleDirectories(oldIgnConfig, newIgnConfig); err != nil {
+		return err
+	}
+
 	// nolint:revive // because i disagree that returning this directly would be cleaner
 	if err := dn.workaroundOcpBugs33694(); err != nil {
 		return err
@@ -2125,6 +2094,99 @@ func (dn *Daemon) deleteStaleData(oldIgnConfig, newIgnConfig ign3types.Config) e
 	return nil
 }
 
+// deleteStaleLinks removes the links that are present in the old config but not
+// in the new one, restoring whatever was at their path before the MCD took over.
+func deleteStaleLinks(oldIgnConfig, newIgnConfig ign3types.Config) error {
+	newLinkSet := make(map[string]struct{})
+	for _, l := range newIgnConfig.Storage.Links {
+		newLinkSet[l.Path] = struct{}{}
+	}
+
+	for _, l := range oldIgnConfig.Storage.Links {
+		if _, ok := newLinkSet[l.Path]; ok {
+			continue
+		}
+		klog.V(2).Infof("Deleting stale link: %s", l.Path)
+		// remove the link first so that restoring the orig file doesn't write through it
+		if err := os.Remove(l.Path); err != nil {
+			newErr := fmt.Errorf("unable to delete %s: %w", l.Path, err)
+			if !os.IsNotExist(err) {
+				return newErr
+			}
+			// otherwise, just warn
+			klog.Warningf("%v", newErr)
+		}
+		if _, err := os.Stat(noOrigFileStampName(l.Path)); err == nil {
+			if delErr := os.Remove(noOrigFileStampName(l.Path)); delErr != nil {
+				return fmt.Errorf("deleting noorig file stamp %q: %w", noOrigFileStampName(l.Path), delErr)
+			}
+		} else if _, err := os.Lstat(origFileName(l.Path)); err == nil {
+			if err := restorePath(l.Path); err != nil {
+				return err
+			}
+			klog.V(2).Infof("Restored file %q", l.Path)
+			continue
+		}
+		klog.Infof("Removed stale link %q", l.Path)
+	}
+	return nil
+}
+
+// deleteStaleDirectories handles the directories that are present in the old
+// config but not in the new one. Directories the MCD created are removed if
+// they are empty, the others get back their original mode and ownership.
+func deleteStaleDirectories(oldIgnConfig, newIgnConfig ign3types.Config) error {
+	newDirSet := make(map[string]struct{})
+	for _, d := range newIgnConfig.Storage.Directories {
+		newDirSet[d.Path] = struct{}{}
+	}
+
+	staleDirs := []string{}
+	for _, d := range oldIgnConfig.Storage.Directories {
+		if _, ok := newDirSet[d.Path]; !ok {
+			staleDirs = append(staleDirs, d.Path)
+		}
+	}
+	// handle nested directories before their parents
+	sort.Sort(sort.Reverse(sort.StringSlice(staleDirs)))
+
+	for _, path := range staleDirs {
+		if _, err := os.Stat(origFileName(path)); err == nil {
+			if err := restoreDirectory(path); err != nil {
+				return err
+			}
+			klog.V(2).Infof("Restored directory %q", path)
+			continue
+		}
+		if _, err := os.Stat(noOrigFileStampName(path)); err == nil {
+			if delErr := os.Remove(noOrigFileStampName(path)); delErr != nil {
+				return fmt.Errorf("deleting noorig file stamp %q: %w", noOrigFileStampName(path), delErr)
+			}
+		}
+		// Like files in deleteStaleData, directories owned by an rpm are part of
+		// the OS and are never removed.
+		rpmNotFound, isOwned, err := isFileOwnedByRPMPkg(path)
+		switch {
+		case isOwned:
+			klog.Infof("Not removing stale directory %s as it is owned by an rpm package", path)
+			continue
+		case rpmNotFound:
+			klog.V(4).Infof("Running on non-Fedora/RHEL machine, not checking whether %s is owned by an rpm package", path)
+		case err != nil:
+			return err
+		}
+		klog.V(2).Infof("Deleting stale directory: %s", path)
+		// os.Remove refuses to delete a directory that still has contents, which
+		// were not written by this config and are kept.
+		if err := os.Remove(path); err != nil {
+			klog.Warningf("Not removing stale directory %s: %v", path, err)
+			continue
+		}
+		klog.Infof("Removed stale directory %q", path)
+	}
+	return nil
+}
+
 // Previous versions of the MCD leaked some enablement symlinks. We clean a
 // known problematic subset of those here. See also:
 // https://issues.redhat.com/browse/OCPBUGS-33694?focusedId=24917003#comment-24917003
@@ -2266,6 +2328,16 @@ func (dn *Daemon) writeFiles(files []ign3types.File, skipCertificateWrite bool)
 	return writeFiles(files, skipCertificateWrite)
 }
 
+// writeDirectories writes the given directories to disk.
+func (dn *Daemon) writeDirectories(dirs []ign3types.Directory) error {
+	return writeDirectories(dirs)
+}
+
+// writeLinks writes the given links to disk.
+func (dn *Daemon) writeLinks(links []ign3types.Link) error {
+	return writeLinks(links)
+}
+
 // Ensures that both the SSH root directory (/home/core/.ssh) as well as any
 // subdirectories are created with the correct (0700) permissions.
 func createSSHKeyDir(authKeyDir string) error {
@@ -2418,11 +2490,13 @@ func (dn *Daemon) updateSSHKeys(newUsers, oldUsers []ign3types.PasswdUser) error
 		return fmt.Errorf("failed to check if user core exists: %w", err)
 	}
 
-	// we're also appending all keys for any user to core, so for now
-	// we pass this to atomicallyWriteSSHKeys to write.
-	// we know these users are "core" ones also cause this slice went through Reconcilable
+	// we pass the keys of the core user to atomicallyWriteSSHKeys to write.
+	// the keys of other users are written by updateUsersAndGroups.
 	var concatSSHKeys string
 	for _, u := range newUsers {
+		if u.Name != constants.CoreUserName {
+			continue
+		}
 		for _, k := range u.SSHAuthorizedKeys {
 			concatSSHKeys = concatSSHKeys + string(k) + "\n"
 		}
@@ -2457,6 +2531,10 @@ func (dn *Daemon) updateSSHKeys(newUsers, oldUsers []ign3types.PasswdUser) error
 
 func deconfigureAbsentUsers(newUsers, oldUsers []ign3types.PasswdUser) {
 	for _, oldUser := range oldUsers {
+		// users other than core are removed altogether by updateUsersAndGroups
+		if isManagedAccountName(oldUser.Name) {
+			continue
+		}
 		if !isUserPresent(oldUser, newUsers) {
 			klog.Infof("Absent user detected, deconfiguring the password for user %s\n", oldUser.Name)
 			deconfigureUser(oldUser)
@@ -2804,17 +2882,18 @@ func (dn *Daemon) reboot(rationale string) error {
 	return nil
 }
 
-func (dn *CoreOSDaemon) applyLayeredOSChanges(mcDiff machineConfigDiff, oldConfig, newConfig *mcfgv1.MachineConfig) (retErr error) {
+func (dn *CoreOSDaemon) applyLayeredOSChanges(mcDiff disruption.MachineConfigDiff, oldConfig, newConfig *mcfgv1.MachineConfig) (retErr error) {
 	// Override the computed diff if the booted state differs from the oldConfig
 	// https://issues.redhat.com/browse/OCPBUGS-2757
-	if mcDiff.osUpdate && dn.bootedOSImageURL == newConfig.Spec.OSImageURL {
+	if mcDiff.OSUpdate && dn.bootedOSImageURL == newConfig.Spec.OSImageURL {
 		klog.Infof("Already in desired image %s", newConfig.Spec.OSImageURL)
-		mcDiff.osUpdate = false
+		mcDiff.OSUpdate = false
 	}
 
 	var osExtensionsContentDir string
 	var err error
-	if newConfig.Spec.BaseOSExtensionsContainerImage != "" && (mcDiff.osUpdate || mcDiff.extensions || mcDiff.kernelType) {
+	extensions := dn.getExtensionsCatalogue()
+	if newConfig.Spec.BaseOSExtensionsContainerImage != "" && (mcDiff.OSUpdate || mcDiff.Extensions || mcDiff.KernelType) {
 
 		// TODO(jkyros): the original intent was that we use the extensions container as a service, but that currently results
 		// in a lot of complexity due to boostrap and firstboot where the service isn't easily available, so for now we are going
@@ -2825,12 +2904,24 @@ func (dn *CoreOSDaemon) applyLayeredOSChanges(mcDiff machineConfigDiff, oldConfi
 		// Delete extracted OS image once we are done.
 		defer os.RemoveAll(osExtensionsContentDir)
 
+		if extensions, err = ctrlcommon.ReadExtensionsCatalogue(osExtensionsContentDir); err != nil {
+			return err
+		}
+		dn.extensionsCatalogue = extensions
+
 		if err := addExtensionsRepo(osExtensionsContentDir); err != nil {
 			return err
 		}
 		defer os.Remove(extensionsRepo)
 	}
 
+	// If kernel arguments were applied at runtime during this boot, the pending
+	// deployment removed below carries their persistent change.
+	liveKargsState, err := readLiveKernelArgsState(constants.LiveKernelArgumentsStateFilePath, dn.bootID)
+	if err != nil {
+		return err
+	}
+
 	// Always clean up pending, because the RT kernel switch logic below operates on booted,
 	// not pending.
 	if err := removePendingDeployment(); err != nil {
@@ -2850,12 +2941,21 @@ func (dn *CoreOSDaemon) applyLayeredOSChanges(mcDiff machineConfigDiff, oldConfi
 				retErr = fmt.Errorf("error removing staged deployment: %w", errs)
 				return
 			}
+			// The kernel arguments applied at runtime for oldConfig are still in
+			// effect, so stage their persistent change again.
+			if liveKargsState != nil {
+				if err := dn.restageLiveKernelArgs(liveKargsState, oldConfig); err != nil {
+					errs := kubeErrs.NewAggregate([]error{err, retErr})
+					retErr = fmt.Errorf("error staging kernel arguments applied at runtime: %w", errs)
+					return
+				}
+			}
 		}
 	}()
 
 	// If we have an OS update *or* a kernel type change, then we must undo the kernel swap
 	// enablement.
-	if mcDiff.osUpdate || mcDiff.kernelType {
+	if mcDiff.OSUpdate || mcDiff.KernelType {
 		if err := dn.queueRevertKernelSwap(); err != nil {
 			mcdPivotErr.Inc()
 			return err
@@ -2863,7 +2963,7 @@ func (dn *CoreOSDaemon) applyLayeredOSChanges(mcDiff machineConfigDiff, oldConfi
 	}
 
 	// Update OS
-	if mcDiff.osUpdate {
+	if mcDiff.OSUpdate {
 		if err := dn.updateLayeredOS(newConfig); err != nil {
 			mcdPivotErr.Inc()
 			return err
@@ -2881,21 +2981,25 @@ func (dn *CoreOSDaemon) applyLayeredOSChanges(mcDiff machineConfigDiff, oldConfi
 	// if we're here, we've successfully pivoted, or pivoting wasn't necessary, so we reset the error gauge
 	mcdPivotErr.Set(0)
 
-	if mcDiff.kargs {
-		if err := dn.updateKernelArguments(oldConfig.Spec.KernelArguments, newConfig.Spec.KernelArguments); err != nil {
+	if liveKargsState != nil {
+		if err := dn.restageLiveKernelArgs(liveKargsState, newConfig); err != nil {
+			return err
+		}
+	} else if mcDiff.Kargs {
+		if err := dn.updateKernelArguments(oldConfig, newConfig); err != nil {
 			return err
 		}
 	}
 
 	// Switch to real time kernel
-	if mcDiff.osUpdate || mcDiff.kernelType {
+	if mcDiff.OSUpdate || mcDiff.KernelType {
 		if err := dn.switchKernel(oldConfig, newConfig); err != nil {
 			return err
 		}
 	}
 
 	// Apply extensions
-	return dn.applyExtensions(oldConfig, newConfig)
+	return dn.applyExtensions(oldConfig, newConfig, extensions)
 }
 
 func (dn *Daemon) hasImageRegistryDrainOverrideConfigMap() (bool, error) {
@@ -0,0 +1,274 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+
+	features "github.com/openshift/api/features"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/daemon/disruption"
+	"github.com/openshift/machine-config-operator/pkg/upgrademonitor"
+)
+
+// UpdatePlan describes what the MCD would do to a node to move it from one
+// rendered MachineConfig to another. It is computed solely from the two
+// MachineConfigs, so it does not account for the force file, FIPS state or
+// any other on-disk state of a particular node.
+type UpdatePlan struct {
+	OldConfig string `json:"oldConfig"`
+	NewConfig string `json:"newConfig"`
+
+	// OSImageURL is the new OS image, set only if it changes.
+	OSImageURL string `json:"osImageURL,omitempty"`
+	// KernelType is the new kernel type, set only if it changes.
+	KernelType string `json:"kernelType,omitempty"`
+	// FIPS is true if the FIPS setting changes.
+	FIPS bool `json:"fips,omitempty"`
+	// Passwd is true if users, SSH keys or password hashes change.
+	Passwd bool `json:"passwd,omitempty"`
+
+	// Files and Units are the paths and names of the files and units which
+	// are added, changed or removed.
+	Files []string `json:"files,omitempty"`
+	Units []string `json:"units,omitempty"`
+
+	KernelArgumentsAdded   []string `json:"kernelArgumentsAdded,omitempty"`
+	KernelArgumentsRemoved []string `json:"kernelArgumentsRemoved,omitempty"`
+	ExtensionsAdded        []string `json:"extensionsAdded,omitempty"`
+	ExtensionsRemoved      []string `json:"extensionsRemoved,omitempty"`
+
+	// PostConfigChangeActions is set when node disruption policies are not in
+	// use, NodeDisruptionActions otherwise.
+	PostConfigChangeActions []string                                `json:"postConfigChangeActions,omitempty"`
+	NodeDisruptionActions   []opv1.NodeDisruptionPolicyStatusAction `json:"nodeDisruptionActions,omitempty"`
+
+	// Drain is true if the node would be drained before applying the update.
+	Drain bool `json:"drain"`
+}
+
+// NewUpdatePlan computes the UpdatePlan for moving from oldConfig to
+// newConfig. If clusterPolicies is nil, the legacy post config change actions
+// are calculated, otherwise the node disruption actions for those policies
+// are. An error is returned if newConfig is not reconcilable with oldConfig.
+func NewUpdatePlan(oldConfig, newConfig *mcfgv1.MachineConfig, clusterPolicies *opv1.NodeDisruptionPolicyClusterStatus) (*UpdatePlan, error) {
+	// The image registry drain override ConfigMap only exists in a live
+	// cluster, so it is assumed to be absent here.
+	return newUpdatePlan(oldConfig, newConfig, clusterPolicies, false)
+}
+
+func newUpdatePlan(oldConfig, newConfig *mcfgv1.MachineConfig, clusterPolicies *opv1.NodeDisruptionPolicyClusterStatus, crioOverrideConfigmapExists bool) (*UpdatePlan, error) {
+	oldConfig = disruption.CanonicalizeEmptyMC(oldConfig)
+
+	d, err := disruption.Calculate(oldConfig, newConfig, clusterPolicies, crioOverrideConfigmapExists)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &UpdatePlan{
+		OldConfig:               oldConfig.Name,
+		NewConfig:               newConfig.Name,
+		FIPS:                    d.Diff.FIPS,
+		Passwd:                  d.Diff.Passwd,
+		Files:                   d.Files,
+		Units:                   d.Units,
+		PostConfigChangeActions: d.PostConfigChangeActions,
+		NodeDisruptionActions:   d.NodeDisruptionActions,
+		Drain:                   d.Drain,
+	}
+	if d.Diff.OSUpdate {
+		plan.OSImageURL = newConfig.Spec.OSImageURL
+	}
+	if d.Diff.KernelType {
+		plan.KernelType = disruption.CanonicalizeKernelType(newConfig.Spec.KernelType)
+	}
+	if d.Diff.Kargs {
+		oldIgnConfig, err := ctrlcommon.ParseAndConvertConfig(oldConfig.Spec.Config.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("parsing old Ignition config failed: %w", err)
+		}
+		newIgnConfig, err := ctrlcommon.ParseAndConvertConfig(newConfig.Spec.Config.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("parsing new Ignition config failed: %w", err)
+		}
+		plan.KernelArgumentsAdded, plan.KernelArgumentsRemoved = diffKernelArguments(expectedKernelArguments(oldConfig, oldIgnConfig), expectedKernelArguments(newConfig, newIgnConfig))
+		shouldNotExistAdded, _ := diffKernelArguments(ignKargsToStrings(oldIgnConfig.KernelArguments.ShouldNotExist), ignKargsToStrings(newIgnConfig.KernelArguments.ShouldNotExist))
+		plan.KernelArgumentsRemoved = append(plan.KernelArgumentsRemoved, shouldNotExistAdded...)
+	}
+	if d.Diff.Extensions {
+		plan.ExtensionsAdded, plan.ExtensionsRemoved = diffExtensions(oldConfig, newConfig)
+	}
+
+	return plan, nil
+}
+
+// Actions returns a human-readable list of the actions the MCD would take
+// after writing the new configuration to disk.
+func (p *UpdatePlan) Actions() []string {
+	if p.NodeDisruptionActions == nil {
+		return p.PostConfigChangeActions
+	}
+
+	actions := []string{}
+	for _, action := range p.NodeDisruptionActions {
+		switch action.Type {
+		case opv1.ReloadStatusAction:
+			actions = append(actions, fmt.Sprintf("%s %s", action.Type, action.Reload.ServiceName))
+		case opv1.RestartStatusAction:
+			actions = append(actions, fmt.Sprintf("%s %s", action.Type, action.Restart.ServiceName))
+		default:
+			actions = append(actions, string(action.Type))
+		}
+	}
+	return actions
+}
+
+// RequiresReboot returns true if the MCD would reboot the node to apply the
+// update.
+func (p *UpdatePlan) RequiresReboot() bool {
+	return disruption.RequiresReboot(p.PostConfigChangeActions, p.NodeDisruptionActions)
+}
+
+// String returns a one-line summary of the plan.
+func (p *UpdatePlan) String() string {
+	changes := []string{}
+	if p.OSImageURL != "" {
+		changes = append(changes, fmt.Sprintf("OS image %s", p.OSImageURL))
+	}
+	if p.KernelType != "" {
+		changes = append(changes, fmt.Sprintf("kernel type %s", p.KernelType))
+	}
+	if p.FIPS {
+		changes = append(changes, "FIPS")
+	}
+	if p.Passwd {
+		changes = append(changes, "passwd")
+	}
+	if len(p.Files) > 0 {
+		changes = append(changes, fmt.Sprintf("files %v", p.Files))
+	}
+	if len(p.Units) > 0 {
+		changes = append(changes, fmt.Sprintf("units %v", p.Units))
+	}
+	if len(p.KernelArgumentsAdded) > 0 || len(p.KernelArgumentsRemoved) > 0 {
+		changes = append(changes, fmt.Sprintf("kargs +%v -%v", p.KernelArgumentsAdded, p.KernelArgumentsRemoved))
+	}
+	if len(p.ExtensionsAdded) > 0 || len(p.ExtensionsRemoved) > 0 {
+		changes = append(changes, fmt.Sprintf("extensions +%v -%v", p.ExtensionsAdded, p.ExtensionsRemoved))
+	}
+	if len(changes) == 0 {
+		changes = append(changes, "none")
+	}
+
+	return fmt.Sprintf("%s -> %s: changes: %s; actions: %v; drain required: %t", p.OldConfig, p.NewConfig, strings.Join(changes, ", "), p.Actions(), p.Drain)
+}
+
+// isDryRun returns true if the daemon was started in dry run mode or the node
+// has opted into it via the dry-run annotation.
+func (dn *Daemon) isDryRun() bool {
+	if dn.dryRun {
+		return true
+	}
+	return dn.node != nil && dn.node.Annotations[constants.DryRunAnnotationKey] == "true"
+}
+
+// dryRunUpdate computes the UpdatePlan for moving from oldConfig to newConfig
+// and records it in the MachineConfigNode status and a node event instead of
+// applying it. The plan is only recorded once per desired config.
+func (dn *Daemon) dryRunUpdate(oldConfig, newConfig *mcfgv1.MachineConfig) error {
+	newConfigName := newConfig.GetName()
+	if dn.node != nil && dn.node.Annotations[constants.LastDryRunConfigAnnotationKey] == newConfigName {
+		klog.V(2).Infof("Dry run plan for config %s already recorded, skipping update", newConfigName)
+		return nil
+	}
+
+	plan, err := dn.calculateUpdatePlan(oldConfig, newConfig)
+	if err != nil {
+		// An update which can't be planned is reported, but must not degrade
+		// the node since nothing was attempted.
+		wrappedErr := fmt.Errorf("dry run of update from %s to %s failed: %w", oldConfig.GetName(), newConfigName, err)
+		klog.Error(wrappedErr)
+		if dn.nodeWriter != nil {
+			dn.nodeWriter.Eventf(corev1.EventTypeWarning, "DryRunFailed", wrappedErr.Error())
+		}
+		return dn.recordDryRun(newConfigName, metav1.ConditionFalse, wrappedErr.Error())
+	}
+
+	logSystem("Dry run of update from %s to %s: %s", plan.OldConfig, plan.NewConfig, plan)
+	if dn.nodeWriter != nil {
+		dn.nodeWriter.Eventf(corev1.EventTypeNormal, "DryRunUpdate", plan.String())
+	}
+	return dn.recordDryRun(newConfigName, metav1.ConditionTrue, plan.String())
+}
+
+// dryRunImageUpdate is the dry run counterpart of updateOnClusterBuild. The
+// images take the place of the OS images of the configs, so the recorded plan
+// shows the rebase onto the new image.
+func (dn *Daemon) dryRunImageUpdate(oldConfig, newConfig *mcfgv1.MachineConfig, oldImage, newImage string) error {
+	oldConfig = disruption.CanonicalizeEmptyMC(oldConfig).DeepCopy()
+	newConfig = newConfig.DeepCopy()
+	if oldImage != "" {
+		oldConfig.Spec.OSImageURL = oldImage
+	}
+	if newImage != "" {
+		newConfig.Spec.OSImageURL = newImage
+	}
+	return dn.dryRunUpdate(oldConfig, newConfig)
+}
+
+// calculateUpdatePlan computes the UpdatePlan against the state of the cluster
+// the daemon is running in.
+func (dn *Daemon) calculateUpdatePlan(oldConfig, newConfig *mcfgv1.MachineConfig) (*UpdatePlan, error) {
+	var clusterPolicies *opv1.NodeDisruptionPolicyClusterStatus
+	// featureGatesAccessor is not present during firstboot, where NodeDisruptionPolicies are not active.
+	if dn.featureGatesAccessor != nil {
+		fg, err := dn.featureGatesAccessor.CurrentFeatureGates()
+		if err != nil {
+			return nil, err
+		}
+		if fg.Enabled(features.FeatureGateNodeDisruptionPolicy) {
+			clusterPolicies, err = dn.getNodeDisruptionPolicyClusterStatus()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	crioOverrideConfigmapExists, err := dn.hasImageRegistryDrainOverrideConfigMap()
+	if err != nil {
+		return nil, err
+	}
+
+	return newUpdatePlan(oldConfig, newConfig, clusterPolicies, crioOverrideConfigmapExists)
+}
+
+// recordDryRun sets the UpdateDryRun condition on the node's MachineConfigNode
+// and marks the dry run for newConfigName as done on the node.
+func (dn *Daemon) recordDryRun(newConfigName string, status metav1.ConditionStatus, message string) error {
+	err := upgrademonitor.GenerateAndApplyMachineConfigNodes(
+		&upgrademonitor.Condition{State: upgrademonitor.UpdateDryRun, Reason: string(upgrademonitor.UpdateDryRun), Message: message},
+		nil,
+		status,
+		metav1.ConditionFalse,
+		dn.node,
+		dn.mcfgClient,
+		dn.featureGatesAccessor,
+	)
+	if err != nil {
+		klog.Errorf("Error making MCN for Update Dry Run: %v", err)
+	}
+
+	if dn.nodeWriter == nil {
+		return nil
+	}
+	if _, err := dn.nodeWriter.SetAnnotations(map[string]string{constants.LastDryRunConfigAnnotationKey: newConfigName}); err != nil {
+		return fmt.Errorf("error setting %s annotation: %w", constants.LastDryRunConfigAnnotationKey, err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package daemon
+
+import (
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/machine-config-operator/pkg/apihelpers"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/pkg/daemon/disruption"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestNewUpdatePlan(t *testing.T) {
+	registries1 := ctrlcommon.NewIgnFile("/etc/containers/registries.conf", "unqualified-search-registries = ['registry.access.redhat.com', 'docker.io']")
+	registries2 := ctrlcommon.NewIgnFile("/etc/containers/registries.conf", "unqualified-search-registries = ['registry.access.redhat.com', 'docker.io', 'quay.io']")
+	randomFile := ctrlcommon.NewIgnFile("/etc/random-file", "hello")
+	defaultPolicies := apihelpers.MergeClusterPolicies(opv1.NodeDisruptionPolicyConfig{}, false)
+
+	oldConfig := helpers.NewMachineConfigExtended("rendered-old", nil, nil, []ign3types.File{registries1}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{"key1"}, []string{"usbguard"}, false, []string{"karg1 karg2"}, "default", "dummy://")
+
+	testCases := []struct {
+		name                    string
+		newFiles                []ign3types.File
+		newKargs                []string
+		newExtensions           []string
+		clusterPolicies         *opv1.NodeDisruptionPolicyClusterStatus
+		expectedFiles           []string
+		expectedKargsAdded      []string
+		expectedKargsRemoved    []string
+		expectedExtAdded        []string
+		expectedExtRemoved      []string
+		expectedPostActions     []string
+		expectedDisruptionTypes []opv1.NodeDisruptionPolicyStatusActionType
+		expectedDrain           bool
+	}{
+		{
+			name:                    "No changes",
+			newFiles:                []ign3types.File{registries1},
+			newKargs:                []string{"karg1 karg2"},
+			newExtensions:           []string{"usbguard"},
+			clusterPolicies:         &defaultPolicies,
+			expectedDisruptionTypes: []opv1.NodeDisruptionPolicyStatusActionType{opv1.NoneStatusAction},
+		},
+		{
+			name:                "Registries change with legacy actions",
+			newFiles:            []ign3types.File{registries2},
+			newKargs:            []string{"karg1 karg2"},
+			newExtensions:       []string{"usbguard"},
+			expectedFiles:       []string{"/etc/containers/registries.conf"},
+			expectedPostActions: []string{disruption.PostConfigChangeActionReloadCrio},
+		},
+		{
+			name:                    "Registries change with node disruption policies",
+			newFiles:                []ign3types.File{registries2},
+			newKargs:                []string{"karg1 karg2"},
+			newExtensions:           []string{"usbguard"},
+			clusterPolicies:         &defaultPolicies,
+			expectedFiles:           []string{"/etc/containers/registries.conf"},
+			expectedDisruptionTypes: []opv1.NodeDisruptionPolicyStatusActionType{opv1.SpecialStatusAction},
+		},
+		{
+			name:                    "Unknown file added",
+			newFiles:                []ign3types.File{registries1, randomFile},
+			newKargs:                []string{"karg1 karg2"},
+			newExtensions:           []string{"usbguard"},
+			clusterPolicies:         &defaultPolicies,
+			expectedFiles:           []string{"/etc/random-file"},
+			expectedDisruptionTypes: []opv1.NodeDisruptionPolicyStatusActionType{opv1.RebootStatusAction},
+			expectedDrain:           true,
+		},
+		{
+			name:                    "Kernel arguments and extensions change",
+			newFiles:                []ign3types.File{registries1},
+			newKargs:                []string{"karg2", "karg3"},
+			newExtensions:           []string{"kerberos"},
+			clusterPolicies:         &defaultPolicies,
+			expectedKargsAdded:      []string{"karg3"},
+			expectedKargsRemoved:    []string{"karg1"},
+			expectedExtAdded:        []string{"kerberos"},
+			expectedExtRemoved:      []string{"usbguard"},
+			expectedDisruptionTypes: []opv1.NodeDisruptionPolicyStatusActionType{opv1.RebootStatusAction},
+			expectedDrain:           true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			newConfig := helpers.NewMachineConfigExtended("rendered-new", nil, nil, testCase.newFiles, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{"key1"}, testCase.newExtensions, false, testCase.newKargs, "default", "dummy://")
+
+			plan, err := NewUpdatePlan(oldConfig, newConfig, testCase.clusterPolicies)
+			require.NoError(t, err)
+
+			assert.Equal(t, "rendered-old", plan.OldConfig)
+			assert.Equal(t, "rendered-new", plan.NewConfig)
+			assert.ElementsMatch(t, testCase.expectedFiles, plan.Files)
+			assert.ElementsMatch(t, testCase.expectedKargsAdded, plan.KernelArgumentsAdded)
+			assert.ElementsMatch(t, testCase.expectedKargsRemoved, plan.KernelArgumentsRemoved)
+			assert.ElementsMatch(t, testCase.expectedExtAdded, plan.ExtensionsAdded)
+			assert.ElementsMatch(t, testCase.expectedExtRemoved, plan.ExtensionsRemoved)
+			assert.Equal(t, testCase.expectedPostActions, plan.PostConfigChangeActions)
+			assert.Equal(t, testCase.expectedDrain, plan.Drain)
+
+			disruptionTypes := []opv1.NodeDisruptionPolicyStatusActionType{}
+			for _, action := range plan.NodeDisruptionActions {
+				disruptionTypes = append(disruptionTypes, action.Type)
+			}
+			assert.ElementsMatch(t, testCase.expectedDisruptionTypes, disruptionTypes)
+			assert.Equal(t, testCase.expectedDrain, plan.RequiresReboot())
+			assert.NotEmpty(t, plan.String())
+		})
+	}
+}
+
+func TestNewUpdatePlanUnreconcilable(t *testing.T) {
+	oldConfig := helpers.NewMachineConfigExtended("rendered-old", nil, nil, []ign3types.File{}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{}, []string{}, false, []string{}, "default", "dummy://")
+	newConfig := helpers.NewMachineConfigExtended("rendered-new", nil, nil, []ign3types.File{}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{}, []string{}, true, []string{}, "default", "dummy://")
+
+	_, err := NewUpdatePlan(oldConfig, newConfig, nil)
+	assert.Error(t, err)
+}
+
+func TestDryRunUpdate(t *testing.T) {
+	oldConfig := helpers.NewMachineConfigExtended("rendered-old", nil, nil, []ign3types.File{}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{}, []string{}, false, []string{}, "default", "dummy://")
+	newConfig := helpers.NewMachineConfigExtended("rendered-new", nil, nil, []ign3types.File{ctrlcommon.NewIgnFile("/etc/random-file", "hello")}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{}, []string{}, false, []string{}, "default", "dummy://")
+
+	dn := &Daemon{node: &corev1.Node{}}
+	assert.False(t, dn.isDryRun())
+
+	dn.dryRun = true
+	assert.True(t, dn.isDryRun())
+
+	dn = &Daemon{node: &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{constants.DryRunAnnotationKey: "true"}}}}
+	assert.True(t, dn.isDryRun())
+
+	plan, err := dn.calculateUpdatePlan(oldConfig, newConfig)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/etc/random-file"}, plan.Files)
+	assert.Equal(t, []string{disruption.PostConfigChangeActionReboot}, plan.PostConfigChangeActions)
+	assert.True(t, plan.Drain)
+
+	// Neither a plan nor an unreconcilable update touch the node.
+	assert.NoError(t, dn.dryRunUpdate(oldConfig, newConfig))
+	fipsConfig := helpers.NewMachineConfigExtended("rendered-fips", nil, nil, []ign3types.File{}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{}, []string{}, true, []string{}, "default", "dummy://")
+	assert.NoError(t, dn.dryRunUpdate(oldConfig, fipsConfig))
+}
+
+func TestDryRunImageUpdate(t *testing.T) {
+	oldConfig := helpers.NewMachineConfigExtended("rendered-old", nil, nil, []ign3types.File{}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{}, []string{}, false, []string{}, "default", "dummy://")
+	newConfig := helpers.NewMachineConfigExtended("rendered-new", nil, nil, []ign3types.File{}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{}, []string{}, false, []string{}, "default", "dummy://")
+
+	// The daemon has no listers or clients, so triggerUpdate fails if it gets
+	// past the dry run check.
+	dn := &Daemon{node: &corev1.Node{}, dryRun: true}
+	assert.NoError(t, dn.triggerUpdate(oldConfig, newConfig, "", "registry.hostname.com/org/repo@sha256:1234"))
+
+	// The configs are left untouched.
+	assert.Equal(t, "dummy://", newConfig.Spec.OSImageURL)
+}
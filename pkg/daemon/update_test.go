@@ -18,6 +18,7 @@ import (
 	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
 	opv1 "github.com/openshift/api/operator/v1"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/disruption"
 	"github.com/openshift/machine-config-operator/pkg/daemon/osrelease"
 	"github.com/openshift/machine-config-operator/test/helpers"
 	"github.com/stretchr/testify/assert"
@@ -90,21 +91,21 @@ func TestMachineConfigDiff(t *testing.T) {
 	newConfig.ObjectMeta = metav1.ObjectMeta{Name: "newconfig"}
 	diff, err := newMachineConfigDiff(oldConfig, newConfig)
 	assert.Nil(t, err)
-	assert.True(t, diff.isEmpty())
+	assert.True(t, diff.IsEmpty())
 
 	newConfig.Spec.OSImageURL = "quay.io/example/foo@sha256:b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c"
 	diff, err = newMachineConfigDiff(oldConfig, newConfig)
 	assert.Nil(t, err)
-	assert.False(t, diff.isEmpty())
-	assert.True(t, diff.osUpdate)
+	assert.False(t, diff.IsEmpty())
+	assert.True(t, diff.OSUpdate)
 
-	emptyMc := canonicalizeEmptyMC(nil)
-	otherEmptyMc := canonicalizeEmptyMC(nil)
+	emptyMc := disruption.CanonicalizeEmptyMC(nil)
+	otherEmptyMc := disruption.CanonicalizeEmptyMC(nil)
 	emptyMc.Spec.KernelArguments = nil
 	otherEmptyMc.Spec.KernelArguments = []string{}
 	diff, err = newMachineConfigDiff(emptyMc, otherEmptyMc)
 	assert.Nil(t, err)
-	assert.True(t, diff.isEmpty())
+	assert.True(t, diff.IsEmpty())
 
 	passwdTestCases := []struct {
 		name        string
@@ -116,7 +117,7 @@ func TestMachineConfigDiff(t *testing.T) {
 			passwdUsers: []ign3types.PasswdUser{
 				{Name: "core", SSHAuthorizedKeys: []ign3types.SSHAuthorizedKey{"1234"}},
 			},
-			baseMC: canonicalizeEmptyMC(nil),
+			baseMC: disruption.CanonicalizeEmptyMC(nil),
 		},
 		{
 			name: "SSH key changes recognized - New Key",
@@ -139,7 +140,7 @@ func TestMachineConfigDiff(t *testing.T) {
 			passwdUsers: []ign3types.PasswdUser{
 				{Name: "core", PasswordHash: helpers.StrToPtr("testpass")},
 			},
-			baseMC: canonicalizeEmptyMC(nil),
+			baseMC: disruption.CanonicalizeEmptyMC(nil),
 		},
 		{
 			name: "PasswordHash changes recognized - Password Change",
@@ -167,8 +168,8 @@ func TestMachineConfigDiff(t *testing.T) {
 
 			diff, err = newMachineConfigDiff(testCase.baseMC, newMC)
 			assert.Nil(t, err)
-			assert.False(t, diff.isEmpty())
-			assert.True(t, diff.passwd)
+			assert.False(t, diff.IsEmpty())
+			assert.True(t, diff.Passwd)
 		})
 	}
 }
@@ -197,27 +198,27 @@ func TestReconcilableDiff(t *testing.T) {
 
 	diff, err := reconcilable(oldConfig, newConfig)
 	checkReconcilableResults(t, "add file", err)
-	assert.Equal(t, diff.osUpdate, false)
-	assert.Equal(t, diff.passwd, false)
-	assert.Equal(t, diff.units, false)
-	assert.Equal(t, diff.files, true)
+	assert.Equal(t, diff.OSUpdate, false)
+	assert.Equal(t, diff.Passwd, false)
+	assert.Equal(t, diff.Units, false)
+	assert.Equal(t, diff.Files, true)
 
 	newConfig = newMachineConfigFromFiles(nil)
 	diff, err = reconcilable(oldConfig, newConfig)
 	checkReconcilableResults(t, "remove all files", err)
-	assert.Equal(t, diff.osUpdate, false)
-	assert.Equal(t, diff.passwd, false)
-	assert.Equal(t, diff.units, false)
-	assert.Equal(t, diff.files, true)
+	assert.Equal(t, diff.OSUpdate, false)
+	assert.Equal(t, diff.Passwd, false)
+	assert.Equal(t, diff.Units, false)
+	assert.Equal(t, diff.Files, true)
 
 	newConfig = newMachineConfigFromFiles(oldFiles)
 	newConfig.Spec.OSImageURL = "example.com/rhel-coreos:new"
 	diff, err = reconcilable(oldConfig, newConfig)
 	checkReconcilableResults(t, "os update", err)
-	assert.Equal(t, diff.osUpdate, true)
-	assert.Equal(t, diff.passwd, false)
-	assert.Equal(t, diff.units, false)
-	assert.Equal(t, diff.files, false)
+	assert.Equal(t, diff.OSUpdate, true)
+	assert.Equal(t, diff.Passwd, false)
+	assert.Equal(t, diff.Units, false)
+	assert.Equal(t, diff.Files, false)
 }
 
 func TestKernelAguments(t *testing.T) {
@@ -273,6 +274,92 @@ func TestKernelAguments(t *testing.T) {
 	}
 }
 
+func TestIgnitionKernelArguments(t *testing.T) {
+	tests := []struct {
+		oldIgnKargs ign3types.KernelArguments
+		newIgnKargs ign3types.KernelArguments
+		newKargs    []string
+		out         []string
+	}{
+		{
+			newIgnKargs: ign3types.KernelArguments{
+				ShouldExist:    []ign3types.KernelArgument{"foo=bar", "baz"},
+				ShouldNotExist: []ign3types.KernelArgument{"quiet"},
+			},
+			out: []string{"--delete-if-present=quiet", "--append-if-missing=foo=bar", "--append-if-missing=baz"},
+		},
+		{
+			oldIgnKargs: ign3types.KernelArguments{ShouldExist: []ign3types.KernelArgument{"foo=bar baz"}},
+			newIgnKargs: ign3types.KernelArguments{ShouldExist: []ign3types.KernelArgument{"baz"}},
+			out:         []string{"--delete-if-present=foo=bar", "--append-if-missing=baz"},
+		},
+		{
+			// Arguments also in the MC kernelArguments are left to generateKargs
+			oldIgnKargs: ign3types.KernelArguments{ShouldExist: []ign3types.KernelArgument{"foo=bar"}},
+			newIgnKargs: ign3types.KernelArguments{ShouldExist: []ign3types.KernelArgument{"baz"}},
+			newKargs:    []string{"foo=bar baz"},
+			out:         []string{},
+		},
+		{
+			// Dropping shouldNotExist arguments does nothing
+			oldIgnKargs: ign3types.KernelArguments{ShouldNotExist: []ign3types.KernelArgument{"quiet"}},
+			out:         []string{},
+		},
+	}
+
+	for idx, test := range tests {
+		t.Run(fmt.Sprintf("case#%d", idx), func(t *testing.T) {
+			assert.Equal(t, test.out, generateIgnitionKargs(test.oldIgnKargs, test.newIgnKargs, test.newKargs))
+		})
+	}
+
+	ignCfg := ctrlcommon.NewIgnConfig()
+	ignCfg.KernelArguments.ShouldExist = []ign3types.KernelArgument{"foo=bar"}
+	mc := helpers.CreateMachineConfigFromIgnition(ignCfg)
+	mc.Spec.KernelArguments = []string{"baz"}
+	assert.Equal(t, []string{"baz", "foo=bar"}, expectedKernelArguments(mc, ignCfg))
+
+	// Changes to the Ignition kernelArguments are kargs changes
+	diff, err := newMachineConfigDiff(disruption.CanonicalizeEmptyMC(nil), mc)
+	assert.Nil(t, err)
+	assert.True(t, diff.Kargs)
+	mc.Spec.KernelArguments = nil
+	diff, err = newMachineConfigDiff(disruption.CanonicalizeEmptyMC(nil), mc)
+	assert.Nil(t, err)
+	assert.True(t, diff.Kargs)
+	ignCfg.KernelArguments.ShouldExist = []ign3types.KernelArgument{}
+	diff, err = newMachineConfigDiff(disruption.CanonicalizeEmptyMC(nil), helpers.CreateMachineConfigFromIgnition(ignCfg))
+	assert.Nil(t, err)
+	assert.False(t, diff.Kargs)
+}
+
+func TestGenerateExtensionsArgs(t *testing.T) {
+	d := newMockDaemon()
+	rhcos, err := osrelease.LoadOSRelease("ID=\"rhcos\"\nVERSION_ID=\"4.13\"\nRHEL_VERSION=\"9.0\"\n", "")
+	require.NoError(t, err)
+	d.os = rhcos
+
+	oldConfig := disruption.CanonicalizeEmptyMC(nil)
+	oldConfig.Spec.Extensions = []string{"usbguard"}
+	newConfig := disruption.CanonicalizeEmptyMC(nil)
+	newConfig.Spec.Extensions = []string{"nvidia-drivers"}
+
+	// Packages come from the catalogue of the extensions container
+	catalogue := ctrlcommon.ExtensionsCatalogue{
+		"usbguard":       {"usbguard"},
+		"nvidia-drivers": {"nvidia-driver", "nvidia-driver-cuda"},
+	}
+	assert.Equal(t, []string{"update", "--install", "nvidia-driver", "--install", "nvidia-driver-cuda", "--uninstall", "usbguard"},
+		d.generateExtensionsArgs(oldConfig, newConfig, catalogue))
+	assert.NoError(t, catalogue.Validate(newConfig.Spec.Extensions))
+
+	// The default catalogue is used until an extensions container is extracted
+	assert.Equal(t, ctrlcommon.DefaultExtensionsCatalogue(), d.getExtensionsCatalogue())
+	assert.Error(t, d.getExtensionsCatalogue().Validate(newConfig.Spec.Extensions))
+	d.extensionsCatalogue = catalogue
+	assert.Equal(t, catalogue, d.getExtensionsCatalogue())
+}
+
 func TestWriteFiles(t *testing.T) {
 	testDir, cleanup := setupTempDirWithEtc(t)
 	defer cleanup()
@@ -403,7 +490,7 @@ func TestInvalidIgnConfig(t *testing.T) {
 	newMcfg = helpers.CreateMachineConfigFromIgnition(newIgnConfig)
 	diff, err := reconcilable(oldMcfg, newMcfg)
 	assert.Nil(t, err, "Expected no error. Absolute paths should not fail general ignition validation")
-	assert.Equal(t, diff.files, true)
+	assert.Equal(t, diff.Files, true)
 }
 
 func TestDropinCheck(t *testing.T) {
@@ -501,84 +588,84 @@ func TestCalculatePostConfigChangeAction(t *testing.T) {
 			// test that a normal file change is reboot
 			oldConfig:      helpers.NewMachineConfig("00-test", nil, "dummy://", []ign3types.File{files["randomfile1"]}),
 			newConfig:      helpers.NewMachineConfig("01-test", nil, "dummy://", []ign3types.File{files["randomfile2"]}),
-			expectedAction: []string{postConfigChangeActionReboot},
+			expectedAction: []string{disruption.PostConfigChangeActionReboot},
 		},
 		{
 			// test that a pull secret change is none
 			oldConfig:      helpers.NewMachineConfig("00-test", nil, "dummy://", []ign3types.File{files["pullsecret1"]}),
 			newConfig:      helpers.NewMachineConfig("01-test", nil, "dummy://", []ign3types.File{files["pullsecret2"]}),
-			expectedAction: []string{postConfigChangeActionNone},
+			expectedAction: []string{disruption.PostConfigChangeActionNone},
 		},
 		{
 			// test that a SSH key change is none
 			oldConfig:      helpers.NewMachineConfigExtended("00-test", nil, nil, []ign3types.File{}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{"key1"}, []string{}, false, []string{}, "default", "dummy://"),
 			newConfig:      helpers.NewMachineConfigExtended("01-test", nil, nil, []ign3types.File{}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{"key2"}, []string{}, false, []string{}, "default", "dummy://"),
-			expectedAction: []string{postConfigChangeActionNone},
+			expectedAction: []string{disruption.PostConfigChangeActionNone},
 		},
 		{
 			// test that a registries change is reload
 			oldConfig:      helpers.NewMachineConfig("00-test", nil, "dummy://", []ign3types.File{files["registries1"]}),
 			newConfig:      helpers.NewMachineConfig("01-test", nil, "dummy://", []ign3types.File{files["registries2"]}),
-			expectedAction: []string{postConfigChangeActionReloadCrio},
+			expectedAction: []string{disruption.PostConfigChangeActionReloadCrio},
 		},
 		{
 			// test that a kubelet CA change is none
 			oldConfig:      helpers.NewMachineConfig("00-test", nil, "dummy://", []ign3types.File{files["kubeletCA1"]}),
 			newConfig:      helpers.NewMachineConfig("01-test", nil, "dummy://", []ign3types.File{files["kubeletCA2"]}),
-			expectedAction: []string{postConfigChangeActionNone},
+			expectedAction: []string{disruption.PostConfigChangeActionNone},
 		},
 		{
 			// test that a registries change (reload) overwrites pull secret (none)
 			oldConfig:      helpers.NewMachineConfig("00-test", nil, "dummy://", []ign3types.File{files["registries1"], files["pullsecret1"]}),
 			newConfig:      helpers.NewMachineConfig("01-test", nil, "dummy://", []ign3types.File{files["registries2"], files["pullsecret2"]}),
-			expectedAction: []string{postConfigChangeActionReloadCrio},
+			expectedAction: []string{disruption.PostConfigChangeActionReloadCrio},
 		},
 		{
 			// test that a osImage change (reboot) overwrites registries (reload) and SSH keys (none)
 			oldConfig:      helpers.NewMachineConfigExtended("00-test", nil, nil, []ign3types.File{files["registries1"]}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{"key1"}, []string{}, false, []string{}, "default", "dummy://"),
 			newConfig:      helpers.NewMachineConfigExtended("01-test", nil, nil, []ign3types.File{files["registries2"]}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{"key2"}, []string{}, false, []string{}, "default", "dummy1://"),
-			expectedAction: []string{postConfigChangeActionReboot},
+			expectedAction: []string{disruption.PostConfigChangeActionReboot},
 		},
 		{
 			// test that adding a pull secret is none
 			oldConfig:      helpers.NewMachineConfigExtended("00-test", nil, nil, []ign3types.File{files["registries1"]}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{"key1"}, []string{}, false, []string{}, "default", "dummy://"),
 			newConfig:      helpers.NewMachineConfigExtended("01-test", nil, nil, []ign3types.File{files["registries1"], files["pullsecret2"]}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{"key1"}, []string{}, false, []string{}, "default", "dummy://"),
-			expectedAction: []string{postConfigChangeActionNone},
+			expectedAction: []string{disruption.PostConfigChangeActionNone},
 		},
 		{
 			// test that removing a registries is crio reload
 			oldConfig:      helpers.NewMachineConfigExtended("00-test", nil, nil, []ign3types.File{files["randomfile1"], files["registries1"]}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{"key1"}, []string{}, false, []string{}, "default", "dummy://"),
 			newConfig:      helpers.NewMachineConfigExtended("01-test", nil, nil, []ign3types.File{files["randomfile1"]}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{"key1"}, []string{}, false, []string{}, "default", "dummy://"),
-			expectedAction: []string{postConfigChangeActionReloadCrio},
+			expectedAction: []string{disruption.PostConfigChangeActionReloadCrio},
 		},
 		{
 			// mixed test - final should be reboot due to kargs changes
 			oldConfig:      helpers.NewMachineConfigExtended("00-test", nil, nil, []ign3types.File{files["registries1"]}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{"key1"}, []string{}, false, []string{}, "default", "dummy://"),
 			newConfig:      helpers.NewMachineConfigExtended("01-test", nil, nil, []ign3types.File{files["pullsecret2"], files["kubeletCA1"]}, []ign3types.Unit{}, []ign3types.SSHAuthorizedKey{"key2"}, []string{}, false, []string{"karg1"}, "default", "dummy://"),
-			expectedAction: []string{postConfigChangeActionReboot},
+			expectedAction: []string{disruption.PostConfigChangeActionReboot},
 		},
 		{
 			// test that updating policy.json is crio reload
 			oldConfig:      helpers.NewMachineConfig("00-test", nil, "dummy://", []ign3types.File{files["policy1"]}),
 			newConfig:      helpers.NewMachineConfig("01-test", nil, "dummy://", []ign3types.File{files["policy2"]}),
-			expectedAction: []string{postConfigChangeActionReloadCrio},
+			expectedAction: []string{disruption.PostConfigChangeActionReloadCrio},
 		},
 		{
 			// test that updating containers-gpg.pub is crio reload
 			oldConfig:      helpers.NewMachineConfig("00-test", nil, "dummy://", []ign3types.File{files["containers-gpg1"]}),
 			newConfig:      helpers.NewMachineConfig("01-test", nil, "dummy://", []ign3types.File{files["containers-gpg2"]}),
-			expectedAction: []string{postConfigChangeActionReloadCrio},
+			expectedAction: []string{disruption.PostConfigChangeActionReloadCrio},
 		},
 		{
 			// test that updating openshift-config-user-ca-bundle.crt is crio restart
 			oldConfig:      helpers.NewMachineConfig("00-test", nil, "dummy://", []ign3types.File{files["restart-crio1"]}),
 			newConfig:      helpers.NewMachineConfig("01-test", nil, "dummy://", []ign3types.File{files["restart-crio2"]}),
-			expectedAction: []string{postConfigChangeActionRestartCrio}},
+			expectedAction: []string{disruption.PostConfigChangeActionRestartCrio}},
 		{
 			// test that updating openshift-config-user-ca-bundle.crt is crio restart and that it overrides a following crio reload
 			oldConfig:      helpers.NewMachineConfig("00-test", nil, "dummy://", []ign3types.File{files["restart-crio1"]}),
 			newConfig:      helpers.NewMachineConfig("01-test", nil, "dummy://", []ign3types.File{files["restart-crio2"], files["containers-gpg1"]}),
-			expectedAction: []string{postConfigChangeActionRestartCrio},
+			expectedAction: []string{disruption.PostConfigChangeActionRestartCrio},
 		},
 	}
 
@@ -670,6 +757,121 @@ func TestOriginalFileBackupRestore(t *testing.T) {
 	assert.True(t, os.IsNotExist(err))
 }
 
+// TestWriteAndDeleteDirectoriesAndLinks tests that directories and links are written, and that
+// stale ones are removed or get their original mode back.
+func TestWriteAndDeleteDirectoriesAndLinks(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("Non-Linux OS %q detected, skipping test", runtime.GOOS)
+	}
+
+	testDir, cleanup := setupTempDirWithEtc(t)
+	defer cleanup()
+
+	// use the current user so the test doesn't try to chown to root
+	node := func(path string) ign3types.Node {
+		return ign3types.Node{
+			Path:  path,
+			User:  ign3types.NodeUser{ID: helpers.IntToPtr(os.Getuid())},
+			Group: ign3types.NodeGroup{ID: helpers.IntToPtr(os.Getgid())},
+		}
+	}
+	mode := func(path string) os.FileMode {
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+		return fi.Mode().Perm()
+	}
+
+	existingDir := filepath.Join(testDir, "existing")
+	createdDir := filepath.Join(testDir, "created", "nested")
+	target := filepath.Join(testDir, "target")
+	symlink := filepath.Join(createdDir, "symlink")
+	hardlink := filepath.Join(testDir, "hardlink")
+	require.NoError(t, os.Mkdir(existingDir, 0o755))
+	require.NoError(t, os.Chmod(existingDir, 0o755))
+	require.NoError(t, os.WriteFile(target, []byte("target"), 0o644))
+
+	newIgnConfig := ign3types.Config{
+		Storage: ign3types.Storage{
+			Directories: []ign3types.Directory{
+				{Node: node(existingDir), DirectoryEmbedded1: ign3types.DirectoryEmbedded1{Mode: helpers.IntToPtr(0o700)}},
+				{Node: node(createdDir), DirectoryEmbedded1: ign3types.DirectoryEmbedded1{Mode: helpers.IntToPtr(0o750)}},
+			},
+			Links: []ign3types.Link{
+				{Node: node(symlink), LinkEmbedded1: ign3types.LinkEmbedded1{Target: helpers.StrToPtr(target)}},
+				{Node: node(hardlink), LinkEmbedded1: ign3types.LinkEmbedded1{Target: helpers.StrToPtr(target), Hard: helpers.BoolToPtr(true)}},
+			},
+		},
+	}
+
+	require.NoError(t, writeDirectories(newIgnConfig.Storage.Directories))
+	require.NoError(t, writeLinks(newIgnConfig.Storage.Links))
+	assert.NoError(t, checkV3Directories(newIgnConfig.Storage.Directories))
+	assert.NoError(t, checkV3Links(newIgnConfig.Storage.Links))
+	assert.Equal(t, os.FileMode(0o700), mode(existingDir))
+	assert.Equal(t, os.FileMode(0o750), mode(createdDir))
+
+	// Writing them again is a no-op.
+	require.NoError(t, writeDirectories(newIgnConfig.Storage.Directories))
+	require.NoError(t, writeLinks(newIgnConfig.Storage.Links))
+	assert.NoError(t, checkV3Links(newIgnConfig.Storage.Links))
+
+	// Links and directories can't replace each other.
+	assert.Error(t, writeLinks([]ign3types.Link{{Node: node(existingDir), LinkEmbedded1: ign3types.LinkEmbedded1{Target: helpers.StrToPtr(target)}}}))
+	assert.Error(t, writeDirectories([]ign3types.Directory{{Node: node(hardlink)}}))
+
+	// Dropping everything removes the links and the directory created by the MCD,
+	// and restores the mode of the pre-existing directory.
+	require.NoError(t, deleteStaleLinks(newIgnConfig, ign3types.Config{}))
+	require.NoError(t, deleteStaleDirectories(newIgnConfig, ign3types.Config{}))
+	for _, path := range []string{symlink, hardlink, createdDir} {
+		_, err := os.Lstat(path)
+		assert.True(t, os.IsNotExist(err), "expected %s to be removed, got %v", path, err)
+	}
+	assert.Equal(t, os.FileMode(0o755), mode(existingDir))
+	_, err := os.Stat(origFileName(existingDir))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(target)
+	assert.NoError(t, err)
+}
+
+func TestDeleteStaleDirectoriesKeepsRPMOwnedDirectories(t *testing.T) {
+	tmpDir := t.TempDir()
+	ownedDir := filepath.Join(tmpDir, "owned")
+	createdDir := filepath.Join(tmpDir, "created")
+	for _, dir := range []string{ownedDir, createdDir} {
+		require.NoError(t, os.Mkdir(dir, 0o755))
+	}
+
+	// Fake an rpm database owning ownedDir.
+	binDir := filepath.Join(tmpDir, "bin")
+	require.NoError(t, os.Mkdir(binDir, 0o755))
+	rpm := fmt.Sprintf(`#!/bin/sh
+if [ "$2" = %q ]; then
+	echo filesystem-3.18-6.el9.x86_64
+	exit 0
+fi
+echo "file $2 is not owned by any package"
+exit 1
+`, ownedDir)
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "rpm"), []byte(rpm), 0o755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	oldIgnConfig := ign3types.Config{
+		Storage: ign3types.Storage{
+			Directories: []ign3types.Directory{
+				{Node: ign3types.Node{Path: ownedDir}},
+				{Node: ign3types.Node{Path: createdDir}},
+			},
+		},
+	}
+
+	require.NoError(t, deleteStaleDirectories(oldIgnConfig, ign3types.Config{}))
+	_, err := os.Stat(ownedDir)
+	assert.NoError(t, err)
+	_, err = os.Stat(createdDir)
+	assert.True(t, os.IsNotExist(err), "expected %s to be removed, got %v", createdDir, err)
+}
+
 func TestIsImagePresent(t *testing.T) {
 	// Create a temporary directory for the mock "podman" command.
 	tmpDir, err := os.MkdirTemp("", "*.test")
@@ -131,6 +131,7 @@ func (tc upgradeMonitorTestCase) run(t *testing.T) {
 		f.oclient,
 		false,
 		"",
+		false,
 		fgAccess,
 	)
 
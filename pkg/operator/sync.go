@@ -107,6 +107,7 @@ const (
 	mobClusterRoleBindingServiceAccountManifestPath = "manifests/machineosbuilder/clusterrolebinding-service-account.yaml"
 	mobClusterRolebindingAnyUIDManifestPath         = "manifests/machineosbuilder/clusterrolebinding-anyuid.yaml"
 	mobServiceAccountManifestPath                   = "manifests/machineosbuilder/sa.yaml"
+	mobVerifierServiceAccountManifestPath           = "manifests/machineosbuilder/verifier-sa.yaml"
 
 	// Machine Config Daemon manifest paths
 	mcdClusterRoleManifestPath                      = "manifests/machineconfigdaemon/clusterrole.yaml"
@@ -127,6 +128,8 @@ const (
 	// Machine Config Server manifest paths
 	mcsClusterRoleManifestPath                    = "manifests/machineconfigserver/clusterrole.yaml"
 	mcsClusterRoleBindingManifestPath             = "manifests/machineconfigserver/clusterrolebinding.yaml"
+	mcsBootstrapTokenRoleManifestPath             = "manifests/machineconfigserver/bootstrap-token-role.yaml"
+	mcsBootstrapTokenRoleBindingManifestPath      = "manifests/machineconfigserver/bootstrap-token-rolebinding.yaml"
 	mcsCSRBootstrapRoleBindingManifestPath        = "manifests/machineconfigserver/csr-bootstrap-role-binding.yaml"
 	mcsCSRRenewalRoleBindingManifestPath          = "manifests/machineconfigserver/csr-renewal-role-binding.yaml"
 	mcsServiceAccountManifestPath                 = "manifests/machineconfigserver/sa.yaml"
@@ -1200,6 +1203,7 @@ func (optr *Operator) syncMachineOSBuilder(config *renderConfig, _ *configv1.Clu
 		},
 		serviceAccounts: []string{
 			mobServiceAccountManifestPath,
+			mobVerifierServiceAccountManifestPath,
 		},
 	}
 
@@ -1481,6 +1485,12 @@ func (optr *Operator) syncMachineConfigServer(config *renderConfig, _ *configv1.
 		clusterRoles: []string{
 			mcsClusterRoleManifestPath,
 		},
+		roles: []string{
+			mcsBootstrapTokenRoleManifestPath,
+		},
+		roleBindings: []string{
+			mcsBootstrapTokenRoleBindingManifestPath,
+		},
 		clusterRoleBindings: []string{
 			mcsClusterRoleBindingManifestPath,
 			mcsCSRBootstrapRoleBindingManifestPath,
@@ -2135,9 +2145,10 @@ func (optr *Operator) syncMachineConfiguration(_ *renderConfig, _ *configv1.Clus
 	// If FeatureGateNodeDisruptionPolicy feature gate is not enabled, no updates will need to be done for the MachineConfiguration object.
 	if fg.Enabled(features.FeatureGateNodeDisruptionPolicy) {
 		// Merges the cluster's default node disruption policies with the user defined policies, if any.
+		// The built-in actions for kernel and device configuration are opt-in.
 		newMachineConfigurationStatus := mcop.Status.DeepCopy()
 		newMachineConfigurationStatus.NodeDisruptionPolicyStatus = opv1.NodeDisruptionPolicyStatus{
-			ClusterPolicies: apihelpers.MergeClusterPolicies(mcop.Spec.NodeDisruptionPolicy),
+			ClusterPolicies: apihelpers.MergeClusterPolicies(mcop.Spec.NodeDisruptionPolicy, mcop.Annotations[daemonconsts.BuiltinNodeDisruptionActionsAnnotationKey] == "true"),
 		}
 		newMachineConfigurationStatus.ObservedGeneration = mcop.GetGeneration()
 
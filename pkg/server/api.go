@@ -2,16 +2,18 @@ package server
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
 	"path"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/clarketm/json"
 	"github.com/coreos/go-semver/semver"
-	"k8s.io/apimachinery/pkg/runtime"
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
 
@@ -28,6 +30,9 @@ const (
 type poolRequest struct {
 	machineConfigPool string
 	version           *semver.Version
+	// nodeIdentity identifies the requesting node if it sent one, see
+	// parseNodeIdentity.
+	nodeIdentity string
 }
 
 // APIServer provides the HTTP(s) endpoint
@@ -39,14 +44,25 @@ type APIServer struct {
 	cert      string
 	key       string
 	tlsConfig *tls.Config
+	auth      *AuthOptions
 }
 
 // NewAPIServer initializes a new API server
 // that runs the Machine Config Server as a
-// handler.
-func NewAPIServer(a *APIHandler, p int, is bool, c, k string, t *tls.Config) *APIServer {
+// handler. If auth enables any authentication
+// method, config requests must be authenticated.
+func NewAPIServer(a *APIHandler, p int, is bool, c, k string, t *tls.Config, auth *AuthOptions) *APIServer {
 	mux := http.NewServeMux()
-	mux.Handle("/config/", a)
+	var config http.Handler = a
+	if auth.enabled() {
+		config = &authHandler{next: config, auth: auth}
+	}
+	// Rate limiting comes first, so that floods of requests are turned away
+	// before any authentication work is done for them.
+	if a.rateLimiter != nil {
+		config = &rateLimitHandler{next: config, limiter: a.rateLimiter}
+	}
+	mux.Handle("/config/", config)
 	mux.Handle("/healthz", &healthHandler{})
 	mux.Handle("/", &defaultHandler{})
 
@@ -57,6 +73,7 @@ func NewAPIServer(a *APIHandler, p int, is bool, c, k string, t *tls.Config) *AP
 		cert:      c,
 		key:       k,
 		tlsConfig: t,
+		auth:      auth,
 	}
 }
 
@@ -75,6 +92,11 @@ func (a *APIServer) Serve() {
 		if err != nil {
 			klog.Exitf("failed to load serving cert: %v", err)
 		}
+		tlsConfig, err := a.auth.configureTLS(mcs.TLSConfig)
+		if err != nil {
+			klog.Exitf("failed to configure client authentication: %v", err)
+		}
+		mcs.TLSConfig = tlsConfig
 		mcs.TLSConfig.GetCertificate = certWatcher.GetCertificate
 
 		if err := mcs.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
@@ -86,7 +108,8 @@ func (a *APIServer) Serve() {
 // APIHandler is the HTTP Handler for the
 // Machine Config Server.
 type APIHandler struct {
-	server Server
+	server      Server
+	rateLimiter *ipRateLimiter
 }
 
 // NewServerAPIHandler initializes a new API handler
@@ -97,9 +120,29 @@ func NewServerAPIHandler(s Server) *APIHandler {
 	}
 }
 
+// NewServerAPIHandlerWithRateLimit initializes a new API handler for the
+// Machine Config Server which serves at most requestsPerSecond requests,
+// with bursts of up to burst requests, to any single source address. The
+// limit is enforced by the APIServer serving the handler.
+func NewServerAPIHandlerWithRateLimit(s Server, requestsPerSecond float64, burst int) *APIHandler {
+	return &APIHandler{
+		server:      s,
+		rateLimiter: newIPRateLimiter(requestsPerSecond, burst),
+	}
+}
+
 // ServeHTTP handles the requests for the machine config server
-// API handler.
+// API handler. Every request is audited.
 func (sh *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	started := time.Now()
+	aw := newAuditResponseWriter(w)
+
+	sh.serveConfig(aw, r)
+	auditRequest(r, aw.status(), aw.payloadHash(), started, nil)
+}
+
+// serveConfig writes the config requested by r.
+func (sh *APIHandler) serveConfig(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		w.Header().Set("Content-Length", "0")
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -115,7 +158,7 @@ func (sh *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	poolName := path.Base(r.URL.Path)
 	useragent := r.Header.Get("User-Agent")
 	acceptHeader := r.Header.Get("Accept")
-	klog.Infof("Pool %q requested by address:%q User-Agent:%q Accept-Header: %q", poolName, r.RemoteAddr, useragent, acceptHeader)
+	klog.V(4).Infof("Pool %q requested by address:%q User-Agent:%q Accept-Header: %q Query: %q", poolName, r.RemoteAddr, useragent, acceptHeader, r.URL.RawQuery)
 
 	reqConfigVer, err := detectSpecVersionFromAcceptHeader(acceptHeader)
 	if err != nil {
@@ -125,12 +168,27 @@ func (sh *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	nodeIdentity, err := parseNodeIdentity(r.URL.Query())
+	if err != nil {
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusBadRequest)
+		klog.Error(err.Error())
+		return
+	}
+
 	cr := poolRequest{
 		machineConfigPool: poolName,
 		version:           reqConfigVer,
+		nodeIdentity:      nodeIdentity,
 	}
 
 	conf, err := sh.server.GetConfig(cr)
+	if errors.Is(err, errNodeNotAllowed) {
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusForbidden)
+		klog.Errorf("refusing config for req: %+v, error: %v", cr, err)
+		return
+	}
 	if err != nil {
 		w.Header().Set("Content-Length", "0")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -142,54 +200,18 @@ func (sh *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
-	// we know we're at 3.4 in code.. serve directly, parsing is expensive...
+	// the internal version can be served directly, parsing is expensive...
 	// we're doing it during an HTTP request, and most notably before we write the HTTP headers
-	var serveConf *runtime.RawExtension
-
-	switch {
-	case reqConfigVer.Equal(*semver.New("3.4.0")):
-		serveConf = conf
-
-	case reqConfigVer.Equal(*semver.New("3.3.0")):
-		converted33, err := ctrlcommon.ConvertRawExtIgnitionToV3_3(conf)
-		if err != nil {
-			w.Header().Set("Content-Length", "0")
-			w.WriteHeader(http.StatusInternalServerError)
-			klog.Errorf("couldn't convert config for req: %v, error: %v", cr, err)
-			return
-		}
-		serveConf = &converted33
-
-	case reqConfigVer.Equal(*semver.New("3.2.0")):
-		converted32, err := ctrlcommon.ConvertRawExtIgnitionToV3_2(conf)
-		if err != nil {
-			w.Header().Set("Content-Length", "0")
-			w.WriteHeader(http.StatusInternalServerError)
-			klog.Errorf("couldn't convert config for req: %v, error: %v", cr, err)
-			return
-		}
-		serveConf = &converted32
-
-	case reqConfigVer.Equal(*semver.New("3.1.0")):
-		converted31, err := ctrlcommon.ConvertRawExtIgnitionToV3_1(conf)
-		if err != nil {
-			w.Header().Set("Content-Length", "0")
-			w.WriteHeader(http.StatusInternalServerError)
-			klog.Errorf("couldn't convert config for req: %v, error: %v", cr, err)
-			return
-		}
-		serveConf = &converted31
-
-	default:
-		// Can only be 2.2 here
-		converted2, err := ctrlcommon.ConvertRawExtIgnitionToV2_2(conf)
+	serveConf := conf
+	if !reqConfigVer.Equal(ign3types.MaxVersion) {
+		converted, err := ctrlcommon.ConvertRawExtIgnitionToVersion(conf, *reqConfigVer)
 		if err != nil {
 			w.Header().Set("Content-Length", "0")
 			w.WriteHeader(http.StatusInternalServerError)
 			klog.Errorf("couldn't convert config for req: %v, error: %v", cr, err)
 			return
 		}
-		serveConf = &converted2
+		serveConf = &converted
 	}
 
 	data, err := json.Marshal(serveConf)
@@ -213,6 +235,18 @@ func (sh *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// negotiateSpecVersion returns the newest supported Ignition spec version that
+// a client requesting requested can read, or nil if there is none.
+func negotiateSpecVersion(requested semver.Version) *semver.Version {
+	for _, version := range ctrlcommon.SupportedIgnitionSpecVersions() {
+		if version.Major == requested.Major && !requested.LessThan(version) {
+			version := version
+			return &version
+		}
+	}
+	return nil
+}
+
 type healthHandler struct{}
 
 type acceptHeaderValue struct {
@@ -305,10 +339,6 @@ func detectSpecVersionFromAcceptHeader(acceptHeader string) (*semver.Version, er
 	// For v2.x, it looks like:
 	// "application/vnd.coreos.ignition+json;version=3.2.0, */*;q=0.1".
 	v2_2 := semver.New("2.2.0")
-	v3_1 := semver.New("3.1.0")
-	v3_2 := semver.New("3.2.0")
-	v3_3 := semver.New("3.3.0")
-	v3_4 := semver.New("3.4.0")
 
 	var ignVersionError error
 	headers, err := parseAcceptHeader(acceptHeader)
@@ -319,20 +349,13 @@ func detectSpecVersionFromAcceptHeader(acceptHeader string) (*semver.Version, er
 
 	for _, header := range headers {
 		if header.MIMESubtype == "vnd.coreos.ignition+json" && header.SemVer != nil {
-			switch {
-			case !header.SemVer.LessThan(*v3_4) && header.SemVer.LessThan(*semver.New("4.0.0")):
-				return v3_4, nil
-			case !header.SemVer.LessThan(*v3_3) && header.SemVer.LessThan(*v3_4):
-				return v3_3, nil
-			case !header.SemVer.LessThan(*v3_2) && header.SemVer.LessThan(*v3_3):
-				return v3_2, nil
-			case !header.SemVer.LessThan(*v3_1) && header.SemVer.LessThan(*v3_2):
-				return v3_1, nil
-			case !header.SemVer.LessThan(*v2_2) && header.SemVer.LessThan(*semver.New("3.0.0")):
-				return v2_2, nil
-			default:
-				ignVersionError = fmt.Errorf("unsupported Ignition version in Accept header: %s", acceptHeader)
+			// Ignition accepts any config of its own major version that is
+			// not newer than its own, so serve the newest such version.
+			version := negotiateSpecVersion(*header.SemVer)
+			if version != nil {
+				return version, nil
 			}
+			ignVersionError = fmt.Errorf("unsupported Ignition version in Accept header: %s", acceptHeader)
 		}
 	}
 
@@ -2,6 +2,7 @@ package server
 
 import (
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -57,6 +58,8 @@ func TestAcceptHeaders(t *testing.T) {
 	v3_2 := semver.New("3.2.0")
 	v3_3 := semver.New("3.3.0")
 	v3_4 := semver.New("3.4.0")
+	v3_5 := semver.New("3.5.0")
+	v3_7 := semver.New("3.7.0")
 	headers := []acceptHeaderScenario{
 		{
 			name:  "IgnV0",
@@ -83,6 +86,44 @@ func TestAcceptHeaders(t *testing.T) {
 			},
 			versionOut: v2_2,
 		},
+		{
+			name:  "IgnNewerThanSupported",
+			input: "application/vnd.coreos.ignition+json;version=3.7.0, */*;q=0.1",
+			headerVals: []acceptHeaderValue{
+				{
+					MIMEType:    "application",
+					MIMESubtype: "vnd.coreos.ignition+json",
+					SemVer:      v3_7,
+					QValue:      float32ToPtr(1.0),
+				},
+				{
+					MIMEType:    "*",
+					MIMESubtype: "*",
+					SemVer:      nil,
+					QValue:      float32ToPtr(0.1),
+				},
+			},
+			versionOut: v3_5,
+		},
+		{
+			name:  "IgnV2_35",
+			input: "application/vnd.coreos.ignition+json;version=3.5.0, */*;q=0.1",
+			headerVals: []acceptHeaderValue{
+				{
+					MIMEType:    "application",
+					MIMESubtype: "vnd.coreos.ignition+json",
+					SemVer:      v3_5,
+					QValue:      float32ToPtr(1.0),
+				},
+				{
+					MIMEType:    "*",
+					MIMESubtype: "*",
+					SemVer:      nil,
+					QValue:      float32ToPtr(0.1),
+				},
+			},
+			versionOut: v3_5,
+		},
 		{
 			name:  "IgnV2_34",
 			input: "application/vnd.coreos.ignition+json;version=3.4.0, */*;q=0.1",
@@ -229,6 +270,11 @@ func setV3_4AcceptHeaderOnReq(req *http.Request) *http.Request {
 	return req
 }
 
+func setV3_5AcceptHeaderOnReq(req *http.Request) *http.Request {
+	req.Header.Set("Accept", "application/vnd.coreos.ignition+json;version=3.5.0, */*;q=0.1")
+	return req
+}
+
 func TestAPIHandler(t *testing.T) {
 	scenarios := []scenario{
 		{
@@ -334,6 +380,50 @@ func TestAPIHandler(t *testing.T) {
 				checkBodyLength(t, response, expectedContentLength)
 			},
 		},
+		{
+			name:    "get config with node identity",
+			request: setAcceptHeaderOnReq(httptest.NewRequest(http.MethodGet, "http://testrequest/config/worker?mac=AA:BB:CC:DD:EE:FF", nil)),
+			serverFunc: func(pr poolRequest) (*runtime.RawExtension, error) {
+				if pr.nodeIdentity != "aa-bb-cc-dd-ee-ff" {
+					return nil, fmt.Errorf("unexpected node identity %q", pr.nodeIdentity)
+				}
+				return &runtime.RawExtension{
+					Raw: helpers.MarshalOrDie(ctrlcommon.NewIgnConfig()),
+				}, nil
+			},
+			checkResponse: func(t *testing.T, response *http.Response) {
+				checkStatus(t, response, http.StatusOK)
+				checkContentType(t, response, "application/json")
+				checkContentLength(t, response, expectedContentLength)
+				checkBodyLength(t, response, expectedContentLength)
+			},
+		},
+		{
+			name:    "get config with invalid node identity",
+			request: setAcceptHeaderOnReq(httptest.NewRequest(http.MethodGet, "http://testrequest/config/worker?mac=not-a-mac", nil)),
+			serverFunc: func(poolRequest) (*runtime.RawExtension, error) {
+				return &runtime.RawExtension{
+					Raw: helpers.MarshalOrDie(ctrlcommon.NewIgnConfig()),
+				}, nil
+			},
+			checkResponse: func(t *testing.T, response *http.Response) {
+				checkStatus(t, response, http.StatusBadRequest)
+				checkContentLength(t, response, 0)
+				checkBodyLength(t, response, 0)
+			},
+		},
+		{
+			name:    "get config for node not in the allowlist",
+			request: setAcceptHeaderOnReq(httptest.NewRequest(http.MethodGet, "http://testrequest/config/worker?hostname=worker-0", nil)),
+			serverFunc: func(pr poolRequest) (*runtime.RawExtension, error) {
+				return nil, fmt.Errorf("%w: %s", errNodeNotAllowed, pr.nodeIdentity)
+			},
+			checkResponse: func(t *testing.T, response *http.Response) {
+				checkStatus(t, response, http.StatusForbidden)
+				checkContentLength(t, response, 0)
+				checkBodyLength(t, response, 0)
+			},
+		},
 		{
 			name:    "get spec v3_4 config path that exists",
 			request: setV3_4AcceptHeaderOnReq(httptest.NewRequest(http.MethodGet, "http://testrequest/config/master", nil)),
@@ -349,6 +439,46 @@ func TestAPIHandler(t *testing.T) {
 				checkBodyLength(t, response, expectedContentLength)
 			},
 		},
+		{
+			name:    "get spec v3_5 config path that exists",
+			request: setV3_5AcceptHeaderOnReq(httptest.NewRequest(http.MethodGet, "http://testrequest/config/master", nil)),
+			serverFunc: func(poolRequest) (*runtime.RawExtension, error) {
+				return &runtime.RawExtension{
+					Raw: helpers.MarshalOrDie(ctrlcommon.NewIgnConfig()),
+				}, nil
+			},
+			checkResponse: func(t *testing.T, response *http.Response) {
+				checkStatus(t, response, http.StatusOK)
+				checkContentType(t, response, "application/json")
+				checkIgnitionVersion(t, response, "3.5.0")
+			},
+		},
+		{
+			name:    "get spec v3_3 config is downgraded",
+			request: setV3_3AcceptHeaderOnReq(httptest.NewRequest(http.MethodGet, "http://testrequest/config/master", nil)),
+			serverFunc: func(poolRequest) (*runtime.RawExtension, error) {
+				return &runtime.RawExtension{
+					Raw: helpers.MarshalOrDie(ctrlcommon.NewIgnConfig()),
+				}, nil
+			},
+			checkResponse: func(t *testing.T, response *http.Response) {
+				checkStatus(t, response, http.StatusOK)
+				checkIgnitionVersion(t, response, "3.3.0")
+			},
+		},
+		{
+			name:    "get spec v2_2 config is downgraded",
+			request: setAcceptHeaderOnReq(httptest.NewRequest(http.MethodGet, "http://testrequest/config/master", nil)),
+			serverFunc: func(poolRequest) (*runtime.RawExtension, error) {
+				return &runtime.RawExtension{
+					Raw: helpers.MarshalOrDie(ctrlcommon.NewIgnConfig()),
+				}, nil
+			},
+			checkResponse: func(t *testing.T, response *http.Response) {
+				checkStatus(t, response, http.StatusOK)
+				checkIgnitionVersion(t, response, "2.2.0")
+			},
+		},
 	}
 
 	for _, scenario := range scenarios {
@@ -634,7 +764,7 @@ func TestAPIServer(t *testing.T) {
 			ms := &mockServer{
 				GetConfigFn: scenario.serverFunc,
 			}
-			server := NewAPIServer(NewServerAPIHandler(ms), 0, false, "", "", nil)
+			server := NewAPIServer(NewServerAPIHandler(ms), 0, false, "", "", nil, nil)
 			server.handler.ServeHTTP(w, scenario.request)
 
 			resp := w.Result()
@@ -839,3 +969,15 @@ func checkBodyLength(t *testing.T, response *http.Response, l int) {
 		t.Errorf("expected response body length %d, received %d", l, len(body))
 	}
 }
+
+func checkIgnitionVersion(t *testing.T, response *http.Response, expected string) {
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	config := struct {
+		Ignition struct {
+			Version string `json:"version"`
+		} `json:"ignition"`
+	}{}
+	require.NoError(t, json.Unmarshal(body, &config))
+	assert.Equal(t, expected, config.Ignition.Version)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"path"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// auditResponseWriter records the status code and a hash of the body written
+// in response to a config request.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	code    int
+	hash    hash.Hash
+	written int
+}
+
+func newAuditResponseWriter(w http.ResponseWriter) *auditResponseWriter {
+	return &auditResponseWriter{ResponseWriter: w, hash: sha256.New()}
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *auditResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	w.hash.Write(b)
+	w.written += len(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditResponseWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
+
+// payloadHash returns the hex encoded SHA256 of the response body, or an
+// empty string if no body was written.
+func (w *auditResponseWriter) payloadHash() string {
+	if w.written == 0 {
+		return ""
+	}
+	return hex.EncodeToString(w.hash.Sum(nil))
+}
+
+// auditRequest logs who requested which config and how the request was
+// answered, and counts it in the request metrics. reason explains why a
+// request was refused, if it was.
+func auditRequest(r *http.Request, code int, payloadHash string, started time.Time, reason error) {
+	pool := path.Base(r.URL.Path)
+
+	ignitionVersion := ""
+	if version, err := detectSpecVersionFromAcceptHeader(r.Header.Get("Accept")); err == nil {
+		ignitionVersion = version.String()
+	}
+
+	keysAndValues := []interface{}{
+		"pool", pool,
+		"remoteAddr", r.RemoteAddr,
+		"userAgent", r.UserAgent(),
+		"ignitionVersion", ignitionVersion,
+		"query", r.URL.RawQuery,
+		"code", code,
+		"payloadSHA256", payloadHash,
+		"duration", time.Since(started),
+	}
+	if reason != nil {
+		keysAndValues = append(keysAndValues, "reason", reason.Error())
+	}
+	klog.InfoS("Config request audit", keysAndValues...)
+
+	recordConfigRequest(pool, code)
+}
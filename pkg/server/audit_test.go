@@ -0,0 +1,110 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestAuditResponseWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	aw := newAuditResponseWriter(w)
+	assert.Equal(t, http.StatusOK, aw.status())
+	assert.Equal(t, "", aw.payloadHash())
+
+	_, err := aw.Write([]byte("hello"))
+	require.NoError(t, err)
+	sum := sha256.Sum256([]byte("hello"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), aw.payloadHash())
+	assert.Equal(t, http.StatusOK, aw.status())
+
+	w = httptest.NewRecorder()
+	aw = newAuditResponseWriter(w)
+	aw.WriteHeader(http.StatusNotFound)
+	assert.Equal(t, http.StatusNotFound, aw.status())
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPoolLabel(t *testing.T) {
+	assert.Equal(t, unknownPoolLabel, poolLabel("audit-test-pool", http.StatusInternalServerError))
+	assert.Equal(t, "audit-test-pool", poolLabel("audit-test-pool", http.StatusOK))
+	assert.Equal(t, "audit-test-pool", poolLabel("audit-test-pool", http.StatusInternalServerError))
+	assert.Equal(t, unknownPoolLabel, poolLabel("../../etc/passwd", http.StatusNotFound))
+}
+
+func TestIPRateLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := newIPRateLimiter(1, 2)
+
+	// The burst is served, further requests have to wait for the limit.
+	assert.True(t, limiter.allow("10.0.0.1", now))
+	assert.True(t, limiter.allow("10.0.0.1", now))
+	assert.False(t, limiter.allow("10.0.0.1", now))
+
+	// Other sources are limited independently.
+	assert.True(t, limiter.allow("10.0.0.2", now))
+
+	assert.True(t, limiter.allow("10.0.0.1", now.Add(time.Second)))
+
+	// Idle sources are forgotten.
+	later := now.Add(rateLimiterIdleTimeout + time.Second)
+	assert.True(t, limiter.allow("10.0.0.3", later))
+	assert.Len(t, limiter.limiters, 1)
+}
+
+func TestRateLimitedAPIHandler(t *testing.T) {
+	ms := &mockServer{
+		GetConfigFn: func(poolRequest) (*runtime.RawExtension, error) {
+			return &runtime.RawExtension{Raw: helpers.MarshalOrDie(ctrlcommon.NewIgnConfig())}, nil
+		},
+	}
+	handler := NewAPIServer(NewServerAPIHandlerWithRateLimit(ms, 0.001, 1), 0, true, "", "", nil, &AuthOptions{}).handler
+
+	serve := func(remoteAddr string) *http.Response {
+		r := setAcceptHeaderOnReq(httptest.NewRequest(http.MethodGet, "http://testrequest/config/worker", nil))
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	resp := serve("10.0.0.1:1234")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The port is ignored when identifying the source.
+	resp = serve("10.0.0.1:5678")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Empty(t, body)
+
+	resp = serve("10.0.0.2:1234")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Requests are rate limited before they are authenticated.
+	auth := &AuthOptions{TokenAuthenticator: &fakeTokenAuthenticator{secret: newBootstrapTokenSecret(nil)}}
+	handler = NewAPIServer(NewServerAPIHandlerWithRateLimit(ms, 0.001, 1), 0, true, "", "", nil, auth).handler
+
+	resp = serve("10.0.0.3:1234")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = serve("10.0.0.3:1234")
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	yaml "github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/machine-config-operator/internal/clients"
+)
+
+const (
+	// bootstrapTokenSecretNamespace is the namespace holding bootstrap token Secrets.
+	bootstrapTokenSecretNamespace = metav1.NamespaceSystem
+	// bootstrapTokenSecretPrefix is the name prefix of bootstrap token Secrets.
+	bootstrapTokenSecretPrefix = "bootstrap-token-"
+
+	bootstrapTokenIDKey     = "token-id"
+	bootstrapTokenSecretKey = "token-secret"
+	bootstrapTokenExpiryKey = "expiration"
+	bootstrapTokenUsageKey  = "usage-bootstrap-authentication"
+
+	// secretCacheSyncTimeout bounds how long the server waits for the
+	// bootstrap token Secrets to be listed on startup.
+	secretCacheSyncTimeout = 2 * time.Minute
+)
+
+var (
+	// bootstrapTokenRegexp matches the <token-id>.<token-secret> bootstrap token format.
+	bootstrapTokenRegexp = regexp.MustCompile(`^([a-z0-9]{6})\.([a-z0-9]{16})$`)
+
+	errNoCredentials     = errors.New("no client certificate or bootstrap token presented")
+	errInsecureTransport = errors.New("authentication is only possible over TLS")
+)
+
+// AuthOptions configures how the machine-config-server authenticates requests
+// for Ignition configs. A request is accepted if it satisfies any of the
+// configured methods; a nil or empty AuthOptions disables authentication.
+type AuthOptions struct {
+	// ClientCAFile is a PEM bundle of CAs used to verify client certificates.
+	ClientCAFile string
+	// TokenAuthenticator validates bootstrap tokens sent as
+	// "Authorization: Bearer <token-id>.<token-secret>".
+	TokenAuthenticator TokenAuthenticator
+}
+
+func (o *AuthOptions) enabled() bool {
+	return o != nil && (o.ClientCAFile != "" || o.TokenAuthenticator != nil)
+}
+
+// configureTLS returns a copy of tlsConfig that requests and verifies client
+// certificates against the configured client CA bundle. Clients without a
+// certificate are still allowed to complete the handshake so that they can
+// authenticate with a token or reach the health endpoint.
+func (o *AuthOptions) configureTLS(tlsConfig *tls.Config) (*tls.Config, error) {
+	if o == nil || o.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	caData, err := os.ReadFile(o.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read client CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return nil, fmt.Errorf("no certificates found in client CA bundle %s", o.ClientCAFile)
+	}
+
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	} else {
+		tlsConfig = tlsConfig.Clone()
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return tlsConfig, nil
+}
+
+// TokenAuthenticator validates bootstrap tokens.
+type TokenAuthenticator interface {
+	// AuthenticateToken returns the token ID if token is a valid bootstrap
+	// token that may be used for authentication.
+	AuthenticateToken(token string) (string, error)
+}
+
+// authHandler rejects requests that cannot be authenticated with one of the
+// configured methods before handing them to next.
+type authHandler struct {
+	next http.Handler
+	auth *AuthOptions
+}
+
+// ServeHTTP authenticates and forwards the request, auditing every rejection.
+func (h *authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	started := time.Now()
+	identity, err := h.authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusUnauthorized)
+		auditRequest(r, http.StatusUnauthorized, "", started, err)
+		return
+	}
+
+	klog.V(2).Infof("Authenticated request for %s from %s as %s", r.URL.Path, r.RemoteAddr, identity)
+	h.next.ServeHTTP(w, r)
+}
+
+// authenticate returns a description of the authenticated client.
+func (h *authHandler) authenticate(r *http.Request) (string, error) {
+	// Neither a client certificate nor a bearer token can be trusted on a
+	// plain HTTP connection.
+	if r.TLS == nil {
+		return "", errInsecureTransport
+	}
+
+	if h.auth.ClientCAFile != "" && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return fmt.Sprintf("client certificate %q", r.TLS.VerifiedChains[0][0].Subject.CommonName), nil
+	}
+
+	if h.auth.TokenAuthenticator != nil {
+		if header := r.Header.Get("Authorization"); header != "" {
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok {
+				return "", errors.New("unsupported authorization scheme")
+			}
+			tokenID, err := h.auth.TokenAuthenticator.AuthenticateToken(strings.TrimSpace(token))
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("bootstrap token %q", tokenID), nil
+		}
+	}
+
+	return "", errNoCredentials
+}
+
+// validateBootstrapToken checks token against its bootstrap token Secret,
+// looked up by name with getSecret, and returns the token ID.
+func validateBootstrapToken(token string, getSecret func(name string) (*corev1.Secret, error), now time.Time) (string, error) {
+	parts := bootstrapTokenRegexp.FindStringSubmatch(token)
+	if parts == nil {
+		return "", errors.New("malformed bootstrap token")
+	}
+	tokenID, tokenSecret := parts[1], parts[2]
+
+	secret, err := getSecret(bootstrapTokenSecretPrefix + tokenID)
+	if err != nil {
+		if apierrors.IsNotFound(err) || errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("unknown bootstrap token %q", tokenID)
+		}
+		return "", fmt.Errorf("could not look up bootstrap token %q: %w", tokenID, err)
+	}
+
+	if secret.Type != corev1.SecretTypeBootstrapToken {
+		return "", fmt.Errorf("secret for bootstrap token %q has unexpected type %q", tokenID, secret.Type)
+	}
+	if string(secret.Data[bootstrapTokenIDKey]) != tokenID {
+		return "", fmt.Errorf("secret for bootstrap token %q has mismatched token ID", tokenID)
+	}
+	if subtle.ConstantTimeCompare(secret.Data[bootstrapTokenSecretKey], []byte(tokenSecret)) != 1 {
+		return "", fmt.Errorf("invalid secret for bootstrap token %q", tokenID)
+	}
+	if string(secret.Data[bootstrapTokenUsageKey]) != "true" {
+		return "", fmt.Errorf("bootstrap token %q may not be used for authentication", tokenID)
+	}
+	if expiry, ok := secret.Data[bootstrapTokenExpiryKey]; ok {
+		expiration, err := time.Parse(time.RFC3339, string(expiry))
+		if err != nil {
+			return "", fmt.Errorf("could not parse expiration of bootstrap token %q: %w", tokenID, err)
+		}
+		if now.After(expiration) {
+			return "", fmt.Errorf("bootstrap token %q expired at %s", tokenID, expiration.Format(time.RFC3339))
+		}
+	}
+
+	return tokenID, nil
+}
+
+// ensure both authenticators implement the TokenAuthenticator interface.
+var (
+	_ = TokenAuthenticator(&secretTokenAuthenticator{})
+	_ = TokenAuthenticator(&fileTokenAuthenticator{})
+)
+
+// secretTokenAuthenticator validates bootstrap tokens against the bootstrap
+// token Secrets in the kube-system namespace.
+type secretTokenAuthenticator struct {
+	secretLister corelisterv1.SecretLister
+}
+
+// NewSecretTokenAuthenticator returns a TokenAuthenticator backed by the
+// bootstrap token Secrets of the cluster. It accepts a kubeConfig, which is
+// not required when it's run from within a cluster. The Secrets are watched
+// until stopCh is closed. An error is returned if they could not be listed
+// within secretCacheSyncTimeout.
+func NewSecretTokenAuthenticator(kubeConfig string, stopCh <-chan struct{}) (TokenAuthenticator, error) {
+	clientsBuilder, err := clients.NewBuilder(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes rest client: %w", err)
+	}
+
+	kubeClient := clientsBuilder.KubeClientOrDie("bootstrap-token-authenticator")
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(kubeClient, resyncPeriod()(),
+		informers.WithNamespace(bootstrapTokenSecretNamespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("type", string(corev1.SecretTypeBootstrapToken)).String()
+		}))
+	secretInformer := informerFactory.Core().V1().Secrets()
+	secretLister := secretInformer.Lister()
+
+	informerFactory.Start(stopCh)
+
+	ctx, cancel := context.WithTimeout(wait.ContextForChannel(stopCh), secretCacheSyncTimeout)
+	defer cancel()
+
+	if !cache.WaitForCacheSync(ctx.Done(), secretInformer.Informer().HasSynced) {
+		return nil, fmt.Errorf("could not list bootstrap token secrets in namespace %s within %s", bootstrapTokenSecretNamespace, secretCacheSyncTimeout)
+	}
+
+	return &secretTokenAuthenticator{secretLister: secretLister}, nil
+}
+
+// AuthenticateToken implements TokenAuthenticator.
+func (a *secretTokenAuthenticator) AuthenticateToken(token string) (string, error) {
+	return validateBootstrapToken(token, a.secretLister.Secrets(bootstrapTokenSecretNamespace).Get, time.Now())
+}
+
+// fileTokenAuthenticator validates bootstrap tokens against bootstrap token
+// Secret manifests in a directory, for use by the bootstrap server before the
+// cluster API is available.
+type fileTokenAuthenticator struct {
+	dir string
+}
+
+// NewFileTokenAuthenticator returns a TokenAuthenticator backed by the
+// bootstrap token Secret manifests in dir. The manifests are read on every
+// request, so tokens may be added or removed while the server runs.
+func NewFileTokenAuthenticator(dir string) (TokenAuthenticator, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read bootstrap token directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("bootstrap token path %s is not a directory", dir)
+	}
+	return &fileTokenAuthenticator{dir: dir}, nil
+}
+
+// AuthenticateToken implements TokenAuthenticator.
+func (a *fileTokenAuthenticator) AuthenticateToken(token string) (string, error) {
+	return validateBootstrapToken(token, a.getSecret, time.Now())
+}
+
+// getSecret finds the Secret manifest with the given name in the directory.
+func (a *fileTokenAuthenticator) getSecret(name string) (*corev1.Secret, error) {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(a.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		secret := &corev1.Secret{}
+		if err := yaml.Unmarshal(data, secret); err != nil {
+			klog.V(4).Infof("Skipping %s while looking for bootstrap tokens: %v", entry.Name(), err)
+			continue
+		}
+		if secret.Kind != "Secret" || secret.Name != name {
+			continue
+		}
+
+		// Manifests written by hand typically use stringData, which the API
+		// server would otherwise merge into data for us.
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		for k, v := range secret.StringData {
+			secret.Data[k] = []byte(v)
+		}
+		return secret, nil
+	}
+
+	return nil, fmt.Errorf("secret %s: %w", name, os.ErrNotExist)
+}
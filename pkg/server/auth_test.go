@@ -0,0 +1,326 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+const (
+	testTokenID     = "abcdef"
+	testTokenSecret = "0123456789abcdef"
+	testToken       = testTokenID + "." + testTokenSecret
+)
+
+func newBootstrapTokenSecret(data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      bootstrapTokenSecretPrefix + testTokenID,
+			Namespace: bootstrapTokenSecretNamespace,
+		},
+		Type: corev1.SecretTypeBootstrapToken,
+		Data: map[string][]byte{
+			bootstrapTokenIDKey:     []byte(testTokenID),
+			bootstrapTokenSecretKey: []byte(testTokenSecret),
+			bootstrapTokenUsageKey:  []byte("true"),
+		},
+	}
+	for k, v := range data {
+		if v == "" {
+			delete(secret.Data, k)
+		} else {
+			secret.Data[k] = []byte(v)
+		}
+	}
+	return secret
+}
+
+type fakeTokenAuthenticator struct {
+	secret *corev1.Secret
+}
+
+func (f *fakeTokenAuthenticator) AuthenticateToken(token string) (string, error) {
+	return validateBootstrapToken(token, func(name string) (*corev1.Secret, error) {
+		if f.secret == nil || f.secret.Name != name {
+			return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+		}
+		return f.secret, nil
+	}, time.Now())
+}
+
+func TestValidateBootstrapToken(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name      string
+		token     string
+		secret    *corev1.Secret
+		expectErr bool
+	}{
+		{
+			name:   "valid token",
+			token:  testToken,
+			secret: newBootstrapTokenSecret(nil),
+		},
+		{
+			name:   "valid token before expiration",
+			token:  testToken,
+			secret: newBootstrapTokenSecret(map[string]string{bootstrapTokenExpiryKey: "2024-06-02T00:00:00Z"}),
+		},
+		{
+			name:      "malformed token",
+			token:     "not-a-token",
+			secret:    newBootstrapTokenSecret(nil),
+			expectErr: true,
+		},
+		{
+			name:      "unknown token",
+			token:     testToken,
+			expectErr: true,
+		},
+		{
+			name:      "wrong secret",
+			token:     testTokenID + ".fedcba9876543210",
+			secret:    newBootstrapTokenSecret(nil),
+			expectErr: true,
+		},
+		{
+			name:      "expired token",
+			token:     testToken,
+			secret:    newBootstrapTokenSecret(map[string]string{bootstrapTokenExpiryKey: "2024-05-01T00:00:00Z"}),
+			expectErr: true,
+		},
+		{
+			name:      "token not usable for authentication",
+			token:     testToken,
+			secret:    newBootstrapTokenSecret(map[string]string{bootstrapTokenUsageKey: ""}),
+			expectErr: true,
+		},
+		{
+			name:  "secret of the wrong type",
+			token: testToken,
+			secret: func() *corev1.Secret {
+				secret := newBootstrapTokenSecret(nil)
+				secret.Type = corev1.SecretTypeOpaque
+				return secret
+			}(),
+			expectErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			getSecret := func(name string) (*corev1.Secret, error) {
+				if testCase.secret == nil || testCase.secret.Name != name {
+					return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+				}
+				return testCase.secret, nil
+			}
+
+			tokenID, err := validateBootstrapToken(testCase.token, getSecret, now)
+			if testCase.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testTokenID, tokenID)
+		})
+	}
+}
+
+func TestFileTokenAuthenticator(t *testing.T) {
+	dir := t.TempDir()
+
+	manifest := `apiVersion: v1
+kind: Secret
+metadata:
+  name: bootstrap-token-abcdef
+  namespace: kube-system
+type: bootstrap.kubernetes.io/token
+stringData:
+  token-id: abcdef
+  token-secret: 0123456789abcdef
+  usage-bootstrap-authentication: "true"
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bootstrap-token.yaml"), []byte(manifest), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("not a manifest"), 0o644))
+
+	auth, err := NewFileTokenAuthenticator(dir)
+	require.NoError(t, err)
+
+	tokenID, err := auth.AuthenticateToken(testToken)
+	require.NoError(t, err)
+	assert.Equal(t, testTokenID, tokenID)
+
+	_, err = auth.AuthenticateToken("zzzzzz." + testTokenSecret)
+	assert.Error(t, err)
+
+	_, err = NewFileTokenAuthenticator(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestAuthHandler(t *testing.T) {
+	caCert := &x509.Certificate{Subject: pkix.Name{CommonName: "test-ca"}}
+	clientCert := &x509.Certificate{Subject: pkix.Name{CommonName: "test-client"}}
+
+	testCases := []struct {
+		name           string
+		auth           *AuthOptions
+		request        func() *http.Request
+		expectedStatus int
+	}{
+		{
+			name: "authentication disabled",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "http://testrequest/config/worker", nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "valid bootstrap token",
+			auth: &AuthOptions{TokenAuthenticator: &fakeTokenAuthenticator{secret: newBootstrapTokenSecret(nil)}},
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "https://testrequest/config/worker", nil)
+				r.Header.Set("Authorization", "Bearer "+testToken)
+				return r
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "invalid bootstrap token",
+			auth: &AuthOptions{TokenAuthenticator: &fakeTokenAuthenticator{secret: newBootstrapTokenSecret(nil)}},
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "https://testrequest/config/worker", nil)
+				r.Header.Set("Authorization", "Bearer "+testTokenID+".fedcba9876543210")
+				return r
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "unsupported authorization scheme",
+			auth: &AuthOptions{TokenAuthenticator: &fakeTokenAuthenticator{secret: newBootstrapTokenSecret(nil)}},
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "https://testrequest/config/worker", nil)
+				r.Header.Set("Authorization", "Basic "+testToken)
+				return r
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "bootstrap token over plain HTTP",
+			auth: &AuthOptions{TokenAuthenticator: &fakeTokenAuthenticator{secret: newBootstrapTokenSecret(nil)}},
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "http://testrequest/config/worker", nil)
+				r.Header.Set("Authorization", "Bearer "+testToken)
+				return r
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "verified client certificate",
+			auth: &AuthOptions{ClientCAFile: "/etc/mcs/client-ca.crt"},
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "https://testrequest/config/worker", nil)
+				r.TLS.VerifiedChains = [][]*x509.Certificate{{clientCert, caCert}}
+				return r
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "no credentials",
+			auth: &AuthOptions{ClientCAFile: "/etc/mcs/client-ca.crt", TokenAuthenticator: &fakeTokenAuthenticator{}},
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "https://testrequest/config/worker", nil)
+			},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "health endpoint does not require authentication",
+			auth: &AuthOptions{ClientCAFile: "/etc/mcs/client-ca.crt"},
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "https://testrequest/healthz", nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			ms := &mockServer{
+				GetConfigFn: func(poolRequest) (*runtime.RawExtension, error) {
+					return &runtime.RawExtension{Raw: helpers.MarshalOrDie(ctrlcommon.NewIgnConfig())}, nil
+				},
+			}
+			server := NewAPIServer(NewServerAPIHandler(ms), 0, false, "", "", nil, testCase.auth)
+
+			w := httptest.NewRecorder()
+			server.handler.ServeHTTP(w, setAcceptHeaderOnReq(testCase.request()))
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			assert.Equal(t, testCase.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestConfigureTLS(t *testing.T) {
+	// An AuthOptions without a client CA leaves the TLS config untouched.
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	got, err := (&AuthOptions{}).configureTLS(tlsConfig)
+	require.NoError(t, err)
+	assert.Same(t, tlsConfig, got)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644))
+	got, err = (&AuthOptions{ClientCAFile: caFile}).configureTLS(tlsConfig)
+	require.NoError(t, err)
+	assert.NotSame(t, tlsConfig, got)
+	assert.Equal(t, tls.VerifyClientCertIfGiven, got.ClientAuth)
+	assert.NotNil(t, got.ClientCAs)
+	assert.Equal(t, tls.NoClientCert, tlsConfig.ClientAuth)
+
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0o644))
+	_, err = (&AuthOptions{ClientCAFile: caFile}).configureTLS(tlsConfig)
+	assert.Error(t, err)
+
+	_, err = (&AuthOptions{ClientCAFile: filepath.Join(dir, "missing.crt")}).configureTLS(tlsConfig)
+	assert.Error(t, err)
+}
@@ -134,7 +134,10 @@ func (bsc *bootstrapServer) GetConfig(cr poolRequest) (*runtime.RawExtension, er
 
 	addDataAndMaybeAppendToIgnition(caBundleFilePath, cc.Spec.KubeAPIServerServingCAData, &ignConf)
 	addDataAndMaybeAppendToIgnition(cloudProviderCAPath, cc.Spec.CloudProviderCAData, &ignConf)
-	appenders := getAppenders(currConf, nil, bsc.kubeconfigFunc, bsc.certs, bsc.serverBaseDir)
+	if cr.nodeIdentity != "" {
+		klog.Infof("Ignoring node identity %q: node overlays are not served during bootstrap", cr.nodeIdentity)
+	}
+	appenders := getAppenders(currConf, nil, bsc.kubeconfigFunc, bsc.certs, bsc.serverBaseDir, nil)
 	for _, a := range appenders {
 		if err := a(&ignConf, mc); err != nil {
 			return nil, err
@@ -161,9 +161,14 @@ func (cs *clusterServer) GetConfig(cr poolRequest) (*runtime.RawExtension, error
 		return nil, fmt.Errorf("failed to migrate kernel args %w", err)
 	}
 
+	overlays, err := cs.getNodeOverlays(cr.nodeIdentity)
+	if err != nil {
+		return nil, err
+	}
+
 	addDataAndMaybeAppendToIgnition(caBundleFilePath, cc.Spec.KubeAPIServerServingCAData, &ignConf)
 	addDataAndMaybeAppendToIgnition(cloudProviderCAPath, cc.Spec.CloudProviderCAData, &ignConf)
-	appenders := getAppenders(currConf, cr.version, cs.kubeconfigFunc, []string{}, "")
+	appenders := getAppenders(currConf, cr.version, cs.kubeconfigFunc, []string{}, "", overlays)
 	for _, a := range appenders {
 		if err := a(&ignConf, mc); err != nil {
 			return nil, err
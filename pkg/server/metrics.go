@@ -0,0 +1,58 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+// unknownPoolLabel is used in place of pool names that have never been served,
+// so that arbitrary request paths cannot blow up the metric cardinality.
+const unknownPoolLabel = "unknown"
+
+// MCS Metrics
+var (
+	// mcsConfigRequests counts config requests by pool and response code
+	mcsConfigRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcs_config_requests_total",
+			Help: "total number of config requests served by the machine-config-server",
+		}, []string{"pool", "code"})
+
+	// servedPools records the pools which have successfully been served.
+	servedPools sync.Map
+)
+
+// RegisterMCSMetrics registers the machine-config-server metrics.
+func RegisterMCSMetrics() error {
+	err := ctrlcommon.RegisterMetrics([]prometheus.Collector{
+		mcsConfigRequests,
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not register machine-config-server metrics: %w", err)
+	}
+
+	return nil
+}
+
+// recordConfigRequest counts a config request for pool answered with code.
+func recordConfigRequest(pool string, code int) {
+	mcsConfigRequests.WithLabelValues(poolLabel(pool, code), strconv.Itoa(code)).Inc()
+}
+
+// poolLabel returns the pool label for a request for pool answered with code.
+func poolLabel(pool string, code int) string {
+	if code >= 200 && code < 300 {
+		servedPools.Store(pool, struct{}{})
+		return pool
+	}
+	if _, ok := servedPools.Load(pool); ok {
+		return pool
+	}
+	return unknownPoolLabel
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	ign3 "github.com/coreos/ignition/v2/config/v3_4"
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+// errNodeNotAllowed is returned when a node identifies itself but is not in
+// the node allowlist.
+var errNodeNotAllowed = errors.New("node is not in the allowlist")
+
+// parseNodeIdentity returns the identity of the node requesting its config
+// from the "mac" or "hostname" query parameter, or "" if it sent neither.
+// The identity is normalized into a valid ConfigMap key: MAC addresses are
+// lowercased and use "-" as their separator, hostnames are lowercased.
+func parseNodeIdentity(query url.Values) (string, error) {
+	mac, hostname := query.Get("mac"), query.Get("hostname")
+	switch {
+	case mac != "" && hostname != "":
+		return "", fmt.Errorf("only one of the mac and hostname query parameters can be set")
+	case mac != "":
+		hw, err := net.ParseMAC(mac)
+		if err != nil {
+			return "", fmt.Errorf("invalid mac query parameter %q: %w", mac, err)
+		}
+		return strings.ReplaceAll(hw.String(), ":", "-"), nil
+	case hostname != "":
+		hostname = strings.ToLower(hostname)
+		if errs := validation.IsDNS1123Subdomain(hostname); len(errs) > 0 {
+			return "", fmt.Errorf("invalid hostname query parameter %q: %s", hostname, strings.Join(errs, ", "))
+		}
+		return hostname, nil
+	}
+	return "", nil
+}
+
+// getNodeOverlays returns the node overlay MachineConfigs for the node with
+// the given identity, sorted by name. The identity must be a key of the node
+// allowlist ConfigMap, whose value is the name of the node the overlays are
+// labeled for.
+func (cs *clusterServer) getNodeOverlays(nodeIdentity string) ([]*mcfgv1.MachineConfig, error) {
+	if nodeIdentity == "" {
+		return nil, nil
+	}
+	if cs.configMapLister == nil {
+		return nil, fmt.Errorf("%w: cannot look up %s", errNodeNotAllowed, nodeIdentity)
+	}
+
+	cm, err := cs.configMapLister.ConfigMaps(ctrlcommon.MCONamespace).Get(ctrlcommon.MachineConfigServerNodeAllowlistConfigMapName)
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: %s: no allowlist", errNodeNotAllowed, nodeIdentity)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get node allowlist: %w", err)
+	}
+	nodeName, ok := cm.Data[nodeIdentity]
+	if !ok || nodeName == "" {
+		return nil, fmt.Errorf("%w: %s", errNodeNotAllowed, nodeIdentity)
+	}
+
+	overlays, err := cs.machineConfigLister.List(labels.SelectorFromSet(labels.Set{ctrlcommon.NodeOverlayLabelKey: nodeName}))
+	if err != nil {
+		return nil, fmt.Errorf("could not list node overlays for %s: %w", nodeName, err)
+	}
+	sort.SliceStable(overlays, func(i, j int) bool { return overlays[i].Name < overlays[j].Name })
+	return overlays, nil
+}
+
+// appendNodeOverlays merges the Ignition configs of the node overlays into
+// conf. Overlays may only add to the rendered config: the MCD manages the
+// files and units of the rendered config after the node has booted, so an
+// overlay changing them would be undone on the first update.
+func appendNodeOverlays(conf *ign3types.Config, overlays []*mcfgv1.MachineConfig) error {
+	if len(overlays) == 0 {
+		return nil
+	}
+
+	managedFiles := map[string]bool{}
+	for _, f := range conf.Storage.Files {
+		managedFiles[f.Path] = true
+	}
+	managedUnits := map[string]bool{}
+	for _, u := range conf.Systemd.Units {
+		managedUnits[u.Name] = true
+	}
+
+	for _, overlay := range overlays {
+		overlayConf, err := ctrlcommon.ParseAndConvertConfig(overlay.Spec.Config.Raw)
+		if err != nil {
+			return fmt.Errorf("parsing Ignition config of node overlay %s failed with error: %w", overlay.Name, err)
+		}
+		for _, f := range overlayConf.Storage.Files {
+			if managedFiles[f.Path] {
+				return fmt.Errorf("node overlay %s cannot change file %s of the rendered config", overlay.Name, f.Path)
+			}
+		}
+		for _, u := range overlayConf.Systemd.Units {
+			if managedUnits[u.Name] {
+				return fmt.Errorf("node overlay %s cannot change unit %s of the rendered config", overlay.Name, u.Name)
+			}
+		}
+		*conf = ign3.Merge(*conf, overlayConf)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	mcfgv1 "github.com/openshift/api/machineconfiguration/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestParseNodeIdentity(t *testing.T) {
+	tests := []struct {
+		query     string
+		expected  string
+		expectErr bool
+	}{
+		{query: "", expected: ""},
+		{query: "mac=AA:BB:CC:DD:EE:FF", expected: "aa-bb-cc-dd-ee-ff"},
+		{query: "mac=aa-bb-cc-dd-ee-ff", expected: "aa-bb-cc-dd-ee-ff"},
+		{query: "hostname=Worker-0.example.com", expected: "worker-0.example.com"},
+		{query: "mac=zz", expectErr: true},
+		{query: "hostname=worker_0", expectErr: true},
+		{query: "mac=aa:bb:cc:dd:ee:ff&hostname=worker-0", expectErr: true},
+	}
+
+	for _, test := range tests {
+		query, err := url.ParseQuery(test.query)
+		require.NoError(t, err)
+		identity, err := parseNodeIdentity(query)
+		if test.expectErr {
+			assert.Error(t, err, test.query)
+			continue
+		}
+		assert.NoError(t, err, test.query)
+		assert.Equal(t, test.expected, identity, test.query)
+	}
+}
+
+func TestClusterServerNodeOverlays(t *testing.T) {
+	mp, err := getTestMachineConfigPool()
+	require.NoError(t, err)
+
+	newOverlay := func(name, nodeName string, files []ign3types.File) *mcfgv1.MachineConfig {
+		return helpers.NewMachineConfig(name, map[string]string{ctrlcommon.NodeOverlayLabelKey: nodeName}, "", files)
+	}
+
+	rendered := helpers.NewMachineConfig(mp.Status.Configuration.Name, nil, "", []ign3types.File{ctrlcommon.NewIgnFile("/etc/rendered", "rendered")})
+	hostname := newOverlay("50-worker-0-hostname", "worker-0", []ign3types.File{ctrlcommon.NewIgnFile("/etc/hostname", "worker-0")})
+	network := newOverlay("60-worker-0-network", "worker-0", []ign3types.File{ctrlcommon.NewIgnFile("/etc/NetworkManager/system-connections/eno1.nmconnection", "[connection]")})
+	conflicting := newOverlay("50-worker-1-rendered", "worker-1", []ign3types.File{ctrlcommon.NewIgnFile("/etc/rendered", "overlay")})
+	other := newOverlay("50-worker-2-hostname", "worker-2", []ign3types.File{ctrlcommon.NewIgnFile("/etc/hostname", "worker-2")})
+
+	cmIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	require.NoError(t, cmIndexer.Add(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ctrlcommon.MachineConfigServerNodeAllowlistConfigMapName, Namespace: ctrlcommon.MCONamespace},
+		Data: map[string]string{
+			"aa-bb-cc-dd-ee-ff": "worker-0",
+			"worker-1":          "worker-1",
+		},
+	}))
+
+	csc := &clusterServer{
+		machineConfigPoolLister: &mockMCPLister{pools: []*mcfgv1.MachineConfigPool{mp}},
+		machineConfigLister:     &mockMCLister{configs: []*mcfgv1.MachineConfig{rendered, network, hostname, conflicting, other}},
+		controllerConfigLister:  &mockCCLister{configs: []*mcfgv1.ControllerConfig{getTestControllerConfig()}},
+		configMapLister:         corelisterv1.NewConfigMapLister(cmIndexer),
+		kubeconfigFunc: func() ([]byte, []byte, error) {
+			return getKubeConfigContent(t)
+		},
+	}
+
+	getFiles := func(nodeIdentity string) (map[string]ign3types.File, error) {
+		res, err := csc.GetConfig(poolRequest{machineConfigPool: testPool, nodeIdentity: nodeIdentity})
+		if err != nil {
+			return nil, err
+		}
+		conf, err := ctrlcommon.ParseAndConvertConfig(res.Raw)
+		require.NoError(t, err)
+		return createFileMap(conf.Storage.Files), nil
+	}
+
+	// Without a node identity, no overlays are served.
+	files, err := getFiles("")
+	require.NoError(t, err)
+	assert.Contains(t, files, "/etc/rendered")
+	assert.NotContains(t, files, "/etc/hostname")
+
+	// The overlays of the node in the allowlist are merged.
+	files, err = getFiles("aa-bb-cc-dd-ee-ff")
+	require.NoError(t, err)
+	assert.Contains(t, files, "/etc/rendered")
+	assert.Contains(t, files, "/etc/hostname")
+	assert.Contains(t, files, "/etc/NetworkManager/system-connections/eno1.nmconnection")
+	contents, err := ctrlcommon.DecodeIgnitionFileContents(files["/etc/hostname"].Contents.Source, files["/etc/hostname"].Contents.Compression)
+	require.NoError(t, err)
+	assert.Equal(t, "worker-0", string(contents))
+
+	// Overlays may not change the rendered config.
+	_, err = getFiles("worker-1")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errNodeNotAllowed)
+
+	// Nodes which are not in the allowlist are refused.
+	_, err = getFiles("worker-2")
+	assert.ErrorIs(t, err, errNodeNotAllowed)
+}
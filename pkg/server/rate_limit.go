@@ -0,0 +1,105 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// rateLimiterIdleTimeout is how long a source address keeps its limiter
+	// after its last request.
+	rateLimiterIdleTimeout = 10 * time.Minute
+	// rateLimiterSweepInterval is how often idle limiters are dropped.
+	rateLimiterSweepInterval = time.Minute
+)
+
+var errRateLimited = errors.New("rate limit exceeded for source address")
+
+// ipRateLimiter limits the rate of requests per source address.
+type ipRateLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	limiters  map[string]*sourceLimiter
+	lastSweep time.Time
+}
+
+type sourceLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newIPRateLimiter(requestsPerSecond float64, burst int) *ipRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &ipRateLimiter{
+		limit:    rate.Limit(requestsPerSecond),
+		burst:    burst,
+		limiters: map[string]*sourceLimiter{},
+	}
+}
+
+// allow reports whether a request from source may be served at now.
+func (l *ipRateLimiter) allow(source string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= rateLimiterSweepInterval {
+		for s, sl := range l.limiters {
+			if now.Sub(sl.lastSeen) >= rateLimiterIdleTimeout {
+				delete(l.limiters, s)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	sl, ok := l.limiters[source]
+	if !ok {
+		sl = &sourceLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[source] = sl
+	}
+	sl.lastSeen = now
+	return sl.limiter.AllowN(now, 1)
+}
+
+// rateLimitHandler turns away requests from source addresses which exceed
+// their rate limit before passing them on to next.
+type rateLimitHandler struct {
+	next    http.Handler
+	limiter *ipRateLimiter
+}
+
+func (h *rateLimitHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.limiter.allow(requestSource(r), time.Now()) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Retry-After", "1")
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	// A flood of requests must not turn into a flood of log lines, so
+	// rejected requests are only counted in the request metrics.
+	klog.V(2).InfoS("Config request rejected", "remoteAddr", r.RemoteAddr, "path", r.URL.Path, "reason", errRateLimited)
+	recordConfigRequest(path.Base(r.URL.Path), http.StatusTooManyRequests)
+}
+
+// requestSource returns the address a request came from, without its port.
+// Forwarding headers are deliberately ignored as they can be forged.
+func requestSource(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -46,8 +46,10 @@ type Server interface {
 	GetConfig(poolRequest) (*runtime.RawExtension, error)
 }
 
-func getAppenders(currMachineConfig string, version *semver.Version, f kubeconfigFunc, certs []string, serverDir string) []appenderFunc {
+func getAppenders(currMachineConfig string, version *semver.Version, f kubeconfigFunc, certs []string, serverDir string, overlays []*mcfgv1.MachineConfig) []appenderFunc {
 	appenders := []appenderFunc{
+		// merge the node overlays, before anything else is added to the config.
+		func(cfg *ign3types.Config, _ *mcfgv1.MachineConfig) error { return appendNodeOverlays(cfg, overlays) },
 		// append machine annotations file.
 		func(cfg *ign3types.Config, _ *mcfgv1.MachineConfig) error {
 			return appendNodeAnnotations(cfg, currMachineConfig)
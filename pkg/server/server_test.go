@@ -238,7 +238,12 @@ type mockMCLister struct {
 }
 
 func (mcpl *mockMCLister) List(selector labels.Selector) (ret []*mcfgv1.MachineConfig, err error) {
-	return mcpl.configs, nil
+	for _, config := range mcpl.configs {
+		if selector.Matches(labels.Set(config.Labels)) {
+			ret = append(ret, config)
+		}
+	}
+	return ret, nil
 }
 func (mcpl *mockMCLister) Get(name string) (ret *mcfgv1.MachineConfig, err error) {
 	if mcpl.configs == nil {
@@ -20,6 +20,14 @@ import (
 
 const NotYetSet = "NotYetSet"
 
+// UpdateDryRun is the condition the MCD uses to record the plan for an update
+// which it computed but did not apply because it is running in dry run mode.
+const UpdateDryRun mcfgalphav1.StateProgress = "UpdateDryRun"
+
+// ConfigDrift is the condition the MCD uses to report how the on-disk state of
+// the node has drifted from its current MachineConfig.
+const ConfigDrift mcfgalphav1.StateProgress = "ConfigDrift"
+
 type Condition struct {
 	State   mcfgalphav1.StateProgress
 	Reason  string